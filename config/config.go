@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 )
 
@@ -21,19 +21,150 @@ type DBConfig struct {
 
 type ApiConfig struct {
 	ApiPort string
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For header is believed for the client IP. None are
+	// trusted by default, so the client IP is always the peer address.
+	TrustedProxies []string
 }
 
 type TokenConfig struct {
-	IssuerName       string `json:"IssuerName"`
-	JwtSignatureKy   []byte `json:"JwtSignatureKy"`
-	JwtSigningMethod *jwt.SigningMethodHMAC
-	JwtExpiresTime   time.Duration
+	IssuerName     string `json:"IssuerName"`
+	JwtSignatureKy []byte `json:"JwtSignatureKy"`
+	// JwtAlgorithm is HS256, signing with JwtSignatureKy, or RS256 or EdDSA, signing with the newest private key
+	// in JwtKeysDir.
+	JwtAlgorithm   string
+	JwtKeysDir     string
+	JwtExpiresTime time.Duration
+	// RefreshExpiresTime is how long a refresh token stays usable; every refresh issues a new one.
+	RefreshExpiresTime time.Duration
+}
+
+type FulfillmentConfig struct {
+	Interval     time.Duration
+	BatchSize    int
+	ClaimTimeout time.Duration
+	// Supplier names the supplier gateway orders are sent to. Without one the fulfillment worker doesn't run and
+	// transaction details stay pending.
+	Supplier string
+}
+
+type ScheduleConfig struct {
+	Interval      time.Duration
+	BatchSize     int
+	RetryInterval time.Duration
+	MaxRetries    int
+}
+
+type TopupConfig struct {
+	ReconcileInterval  time.Duration
+	ReconcileBatchSize int
+	ReconcileMinAge    time.Duration
+	Lifetime           time.Duration
+	// IdempotencyLease is how long an idempotency key stays in progress before a retry may take it over.
+	IdempotencyLease time.Duration
+}
+
+type PaymentConfig struct {
+	Gateway   string
+	FakeToken string
+}
+
+type MidtransConfig struct {
+	ServerKey string
+	SnapURL   string
+	ApiURL    string
+}
+
+type XenditConfig struct {
+	SecretKey     string
+	CallbackToken string
+	BaseURL       string
+}
+
+type AuthConfig struct {
+	// AllowRegistration keeps the public /auth/register endpoint open.
+	AllowRegistration bool
+	// The first admin is created with these credentials when the database has no user yet.
+	BootstrapAdminUsername string
+	BootstrapAdminPassword string
+	// Failed logins wait LoginDelay, doubled on every failure, and lock out for LockoutDuration once a username
+	// or a client ip reaches its maximum.
+	MaxLoginAttempts   int
+	MaxIPLoginAttempts int
+	LoginDelay         time.Duration
+	LockoutDuration    time.Duration
+	// MFAIssuer names the service in authenticator apps, MFAChallengeLifetime is how long a login waits for the
+	// second factor.
+	MFAIssuer            string
+	MFAChallengeLifetime time.Duration
+}
+
+// APIKeyConfig signs the merchant API keys. APIKeySecret derives the signing secret of every key, so keys stop
+// working when it changes; without it API keys are disabled. A signed request is accepted within MaxClockSkew of
+// its timestamp.
+type APIKeyConfig struct {
+	APIKeySecret []byte
+	MaxClockSkew time.Duration
+}
+
+// PasswordConfig is the policy every new password must meet.
+type PasswordConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
 }
 
 type Config struct {
 	DBConfig
 	ApiConfig
 	TokenConfig
+	AuthConfig
+	APIKeyConfig
+	PasswordConfig
+	FulfillmentConfig
+	ScheduleConfig
+	TopupConfig
+	PaymentConfig
+	MidtransConfig
+	XenditConfig
+}
+
+// envInt reads a positive integer from the environment, falling back when it is missing or invalid.
+func envInt(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// envBool reads a boolean from the environment, falling back when it is missing or invalid.
+func envBool(name string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// envList reads a comma separated list from the environment, leaving out empty entries.
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envString reads a string from the environment, falling back when it is empty.
+func envString(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
 }
 
 func (c *Config) readConfig() error {
@@ -50,21 +181,92 @@ func (c *Config) readConfig() error {
 		Driver:   os.Getenv("DB_DRIVER"),
 	}
 
-	c.ApiConfig = ApiConfig{ApiPort: os.Getenv("API_PORT")}
+	c.ApiConfig = ApiConfig{ApiPort: os.Getenv("API_PORT"), TrustedProxies: envList("TRUSTED_PROXIES")}
 
-	tokenExpire, _ := strconv.Atoi(os.Getenv("TOKEN_EXPIRE"))
 	c.TokenConfig = TokenConfig{
-		IssuerName:       os.Getenv("TOKEN_ISSUE"),
-		JwtSignatureKy:   []byte(os.Getenv("TOKEN_SECRET")),
-		JwtSigningMethod: jwt.SigningMethodHS256,
-		JwtExpiresTime:   time.Duration(tokenExpire) * time.Minute,
+		IssuerName:         os.Getenv("TOKEN_ISSUE"),
+		JwtSignatureKy:     []byte(os.Getenv("TOKEN_SECRET")),
+		JwtAlgorithm:       envString("TOKEN_ALGORITHM", "HS256"),
+		JwtKeysDir:         envString("TOKEN_KEYS_DIR", "keys/jwt"),
+		JwtExpiresTime:     time.Duration(envInt("TOKEN_EXPIRE", 15)) * time.Minute,
+		RefreshExpiresTime: time.Duration(envInt("REFRESH_TOKEN_EXPIRE", 7*24)) * time.Hour,
+	}
+
+	c.AuthConfig = AuthConfig{
+		AllowRegistration:      envBool("ALLOW_REGISTRATION", true),
+		BootstrapAdminUsername: os.Getenv("BOOTSTRAP_ADMIN_USERNAME"),
+		BootstrapAdminPassword: os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"),
+		MaxLoginAttempts:       envInt("LOGIN_MAX_ATTEMPTS", 5),
+		MaxIPLoginAttempts:     envInt("LOGIN_MAX_IP_ATTEMPTS", 20),
+		LoginDelay:             time.Duration(envInt("LOGIN_DELAY", 1)) * time.Second,
+		LockoutDuration:        time.Duration(envInt("LOGIN_LOCKOUT", 15)) * time.Minute,
+		MFAIssuer:              envString("MFA_ISSUER", "Server Pulsa"),
+		MFAChallengeLifetime:   time.Duration(envInt("MFA_CHALLENGE_EXPIRE", 5)) * time.Minute,
+	}
+
+	c.APIKeyConfig = APIKeyConfig{
+		APIKeySecret: []byte(os.Getenv("API_KEY_SECRET")),
+		MaxClockSkew: time.Duration(envInt("API_SIGNATURE_MAX_SKEW", 300)) * time.Second,
+	}
+
+	c.PasswordConfig = PasswordConfig{
+		MinLength:     envInt("PASSWORD_MIN_LENGTH", 8),
+		RequireUpper:  envBool("PASSWORD_REQUIRE_UPPER", false),
+		RequireLower:  envBool("PASSWORD_REQUIRE_LOWER", false),
+		RequireDigit:  envBool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol: envBool("PASSWORD_REQUIRE_SYMBOL", false),
+	}
+
+	c.FulfillmentConfig = FulfillmentConfig{
+		Interval:     time.Duration(envInt("FULFILLMENT_INTERVAL", 5)) * time.Second,
+		BatchSize:    envInt("FULFILLMENT_BATCH_SIZE", 20),
+		ClaimTimeout: time.Duration(envInt("FULFILLMENT_CLAIM_TIMEOUT", 10)) * time.Minute,
+		Supplier:     os.Getenv("SUPPLIER_GATEWAY"),
+	}
+
+	c.ScheduleConfig = ScheduleConfig{
+		Interval:      time.Duration(envInt("SCHEDULE_INTERVAL", 60)) * time.Second,
+		BatchSize:     envInt("SCHEDULE_BATCH_SIZE", 20),
+		RetryInterval: time.Duration(envInt("SCHEDULE_RETRY_INTERVAL", 30)) * time.Minute,
+		MaxRetries:    envInt("SCHEDULE_MAX_RETRIES", 3),
+	}
+
+	c.TopupConfig = TopupConfig{
+		ReconcileInterval:  time.Duration(envInt("TOPUP_RECONCILE_INTERVAL", 60)) * time.Second,
+		ReconcileBatchSize: envInt("TOPUP_RECONCILE_BATCH_SIZE", 20),
+		ReconcileMinAge:    time.Duration(envInt("TOPUP_RECONCILE_MIN_AGE", 5)) * time.Minute,
+		Lifetime:           time.Duration(envInt("TOPUP_LIFETIME", 1440)) * time.Minute,
+		IdempotencyLease:   time.Duration(envInt("TOPUP_IDEMPOTENCY_LEASE", 5)) * time.Minute,
+	}
+
+	c.PaymentConfig = PaymentConfig{
+		Gateway:   envString("PAYMENT_GATEWAY", "midtrans"),
+		FakeToken: os.Getenv("FAKE_PAYMENT_TOKEN"),
+	}
+
+	c.MidtransConfig = MidtransConfig{
+		ServerKey: os.Getenv("MIDTRANS_SERVER_KEY"),
+		SnapURL:   envString("BASE_URL_MIDTRANS", "https://app.sandbox.midtrans.com/snap/v1/transactions"),
+		ApiURL:    envString("MIDTRANS_API_URL", "https://api.sandbox.midtrans.com"),
+	}
+
+	c.XenditConfig = XenditConfig{
+		SecretKey:     os.Getenv("XENDIT_SECRET_KEY"),
+		CallbackToken: os.Getenv("XENDIT_CALLBACK_TOKEN"),
+		BaseURL:       envString("XENDIT_BASE_URL", "https://api.xendit.co"),
 	}
 
 	if c.Host == "" || c.Port == "" || c.User == "" || c.Name == "" || c.Driver == "" || c.ApiPort == "" ||
-		c.IssuerName == "" || c.JwtExpiresTime < 0 || len(c.JwtSignatureKy) == 0 {
+		c.IssuerName == "" || c.JwtExpiresTime < 0 || (c.JwtAlgorithm == "HS256" && len(c.JwtSignatureKy) == 0) {
 		return fmt.Errorf("missing required environment")
 	}
 
+	switch c.JwtAlgorithm {
+	case "HS256", "RS256", "EdDSA":
+	default:
+		return fmt.Errorf("unsupported TOKEN_ALGORITHM %q", c.JwtAlgorithm)
+	}
+
 	return nil
 
 }
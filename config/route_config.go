@@ -9,6 +9,15 @@ const (
 	PutMerchant     = "/merchant/:id"
 	DeleteMerchant  = "/merchant/:id"
 
+	// merchant balance ledger route
+	GetMerchantMutations   = "/merchant/:id/mutations"
+	PostMerchantAdjustment = "/merchant/:id/adjustment"
+
+	// merchant api keys, used by partners calling the transaction api from their own systems
+	PostMerchantAPIKey   = "/merchant/:id/api-key"
+	ListMerchantAPIKeys  = "/merchant/:id/api-keys"
+	RevokeMerchantAPIKey = "/merchant/:id/api-key/:key"
+
 	// product route
 	PostProduct    = "/product"
 	GetProductList = "/products"
@@ -19,26 +28,95 @@ const (
 	//transaction route
 	PostTransaction   = "/transaction"
 	ListTransactions  = "/transactions"
+	BulkTransactions  = "/transactions/bulk"
 	DetailTransaction = "/transaction/:id"
+	RefundTransaction = "/transaction/:id/refund"
+
+	// transaction schedule route
+	PostSchedule   = "/schedule"
+	ListSchedules  = "/schedules"
+	ScheduleRuns   = "/schedule/:id/runs"
+	DeleteSchedule = "/schedule/:id"
+
+	// operator prefix route
+	GetOperatorPrefixList = "/operator-prefixes"
+	PutOperatorPrefix     = "/operator-prefix/:prefix"
+	DeleteOperatorPrefix  = "/operator-prefix/:prefix"
 
 	// user route
-	GetUserList = "/users"
-	GetUser     = "/user/:id"
-	PutUser     = "/user/:id"
-	DeleteUser  = "/user/:id"
+	GetUserList  = "/users"
+	PostUser     = "/user"
+	GetUser      = "/user/:id"
+	PutUser      = "/user/:id"
+	DeleteUser   = "/user/:id"
+	UnlockUser   = "/user/:id/unlock"
+	ResetUserMFA = "/user/:id/mfa"
+
+	// account of the logged in user
+	GetMe            = "/me"
+	PatchMe          = "/me"
+	ChangeMyPassword = "/me/password"
+
+	// two factor authentication of the logged in user
+	GetMyMFA     = "/me/mfa"
+	EnrollMyMFA  = "/me/mfa/enroll"
+	ConfirmMyMFA = "/me/mfa/confirm"
+	DisableMyMFA = "/me/mfa/disable"
+
+	// role route
+	GetPermissionList = "/permissions"
+	GetRoleList       = "/roles"
+	PostRole          = "/role"
+	PutRole           = "/role/:name"
+	DeleteRole        = "/role/:name"
+	PutRoleMFA        = "/role/:name/mfa"
 
 	// auth route
 	Login    = "/auth/login"
 	Register = "/auth/register"
+	Refresh  = "/auth/refresh"
+	Logout   = "/auth/logout"
+
+	// public keys of the access tokens, served at the root instead of under ApiGroup
+	Jwks = "/.well-known/jwks.json"
+
+	// second step of a login with two factor authentication
+	VerifyMFA = "/auth/mfa/verify"
+	EnrollMFA = "/auth/mfa/enroll"
 
 	// topup route
 	PostTopup            = "/topup"
 	GetTopupByMerchantId = "/topup/:id"
 	// GetTopupList = "/topups"
 
+	// manual bank transfer topup
+	PostManualTopup     = "/topup/manual"
+	ListManualTopups    = "/topups/manual"
+	GetManualTopupProof = "/topup/manual/:id/proof"
+	ReviewManualTopup   = "/topup/manual/:id/review"
+
+	// admin managed topup limits and payment method fees
+	GetTopupSettings = "/topup/settings"
+	PutTopupSettings = "/topup/settings"
+	GetTopupFees     = "/topup/fees"
+	PutTopupFee      = "/topup/fee/:method"
+	DeleteTopupFee   = "/topup/fee/:method"
+
+	// employee topup route, scoped to the merchants of the logged in user
+	PostOwnTopup       = "/me/topup"
+	PostOwnManualTopup = "/me/topup/manual"
+	ListOwnTopups      = "/me/topups"
+
+	// employee balance ledger route, scoped to the merchants of the logged in user
+	GetOwnMerchantMutations = "/me/merchant/:id/mutations"
+
 	// callback topup
 	PostCallback = "/topup/callback"
 
 	//report route
-	GetReport = "/report"
+	GetReport       = "/report"
+	GetProfitReport = "/report/profit"
+
+	// audit trail of administrative and money moving changes
+	ListAuditLogs = "/audit-logs"
 )
@@ -835,7 +835,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update an existing user",
+                "description": "Update an existing user. A new password or role logs out every session of the user",
                 "consumes": [
                     "application/json"
                 ],
@@ -2,7 +2,31 @@ CREATE DATABASE server_pulsa_db;
 
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 
-CREATE TYPE roles AS ENUM ('admin', 'employee');
+CREATE TABLE mst_role(
+    name VARCHAR(50) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    built_in BOOLEAN NOT NULL DEFAULT FALSE,
+    require_mfa BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE role_permission(
+    role VARCHAR(50) NOT NULL REFERENCES mst_role(name) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO mst_role (name, description, built_in) VALUES
+    ('admin', 'Manages the platform', TRUE),
+    ('employee', 'Operates a merchant', TRUE);
+
+INSERT INTO role_permission (role, permission) VALUES
+    ('admin', 'transaction:refund'), ('admin', 'report:profit'), ('admin', 'merchant:manage'),
+    ('admin', 'product:manage'), ('admin', 'operator:manage'), ('admin', 'user:manage'), ('admin', 'role:manage'),
+    ('admin', 'topup:create'), ('admin', 'topup:read'), ('admin', 'topup:review'), ('admin', 'topup:setting'),
+    ('admin', 'audit:read'),
+    ('employee', 'transaction:create'), ('employee', 'transaction:read'), ('employee', 'schedule:manage'),
+    ('employee', 'report:read'), ('employee', 'topup:own'), ('employee', 'merchant:own');
 
 CREATE TABLE mst_supliyer(
     id_supliyer uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
@@ -22,7 +46,7 @@ CREATE TABLE mst_user(
     id_user uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
     username VARCHAR(255) NOT NULL UNIQUE,
     password VARCHAR(255) NOT NULL,
-    role roles NOT NULL
+    role VARCHAR(50) NOT NULL REFERENCES mst_role(name)
 );
 
 CREATE TABLE mst_merchant(
@@ -40,14 +64,34 @@ CREATE TABLE transactions(
     id_user UUID REFERENCES mst_user(id_user),
     customer_name VARCHAR(255) NOT NULL,
     destination_number VARCHAR(15) NOT NULL,
-    transaction_date DATE
+    transaction_date DATE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
 );
 
 CREATE TABLE transaction_detail(
     transaction_detail_id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
     transaction_id UUID REFERENCES transactions(transaction_id),
     id_product UUID REFERENCES mst_product(id_product),
-    price DECIMAL(10, 2) NOT NULL
+    cost DOUBLE PRECISION NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    margin DECIMAL(10, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    serial_number VARCHAR(255),
+    supplier_message VARCHAR(255),
+    claimed_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_transaction_detail_status ON transaction_detail(status);
+
+CREATE TABLE transaction_refund(
+    id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    transaction_id UUID REFERENCES transactions(transaction_id),
+    transaction_detail_id UUID UNIQUE REFERENCES transaction_detail(transaction_detail_id),
+    amount DOUBLE PRECISION NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    refunded_by UUID REFERENCES mst_user(id_user),
+    created_at TIMESTAMP DEFAULT NOW()
 );
 
 CREATE TABLE tx_topup (
@@ -57,6 +101,227 @@ CREATE TABLE tx_topup (
     item_name VARCHAR(255) NOT NULL,
     amount DOUBLE PRECISION NOT NULL,
     payment_method VARCHAR(255),
-    status VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid', 'expired', 'cancelled', 'denied', 'rejected', 'refunded')),
+    method VARCHAR(20) NOT NULL DEFAULT 'gateway' CHECK (method IN ('gateway', 'manual')),
+    rejection_reason VARCHAR(255),
+    reviewed_by UUID REFERENCES mst_user(id_user),
+    reviewed_at TIMESTAMP,
+    fee DOUBLE PRECISION NOT NULL DEFAULT 0,
+    fee_charged_to VARCHAR(20) NOT NULL DEFAULT 'platform' CHECK (fee_charged_to IN ('merchant', 'platform')),
+    created_at TIMESTAMP DEFAULT NOW(),
+    checked_at TIMESTAMP
+);
+
+CREATE INDEX idx_tx_topup_pending ON tx_topup(method, created_at) WHERE status = 'pending';
+
+CREATE TABLE topup_setting (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    min_amount DOUBLE PRECISION NOT NULL DEFAULT 10000,
+    max_amount DOUBLE PRECISION NOT NULL DEFAULT 0,
+    daily_limit DOUBLE PRECISION NOT NULL DEFAULT 0,
+    monthly_limit DOUBLE PRECISION NOT NULL DEFAULT 0,
+    updated_by UUID REFERENCES mst_user(id_user),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO topup_setting (id) VALUES (1);
+
+CREATE TABLE topup_fee (
+    payment_method VARCHAR(50) PRIMARY KEY,
+    fee_type VARCHAR(10) NOT NULL CHECK (fee_type IN ('flat', 'percent')),
+    fee_value DOUBLE PRECISION NOT NULL CHECK (fee_value >= 0),
+    charged_to VARCHAR(20) NOT NULL CHECK (charged_to IN ('merchant', 'platform')),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE topup_proof (
+    id_topup UUID PRIMARY KEY REFERENCES tx_topup(id),
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    content BYTEA NOT NULL,
+    uploaded_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE idempotency_key (
+    scope VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    response JSONB,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (scope, idempotency_key)
+);
+
+CREATE TABLE merchant_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    id_merchant UUID NOT NULL REFERENCES mst_merchant(id_merchant),
+    entry_type VARCHAR(20) NOT NULL,
+    amount DOUBLE PRECISION NOT NULL,
+    balance_after DOUBLE PRECISION NOT NULL,
+    reference_type VARCHAR(30) NOT NULL,
+    reference_id VARCHAR(64),
+    description VARCHAR(255),
+    created_by UUID REFERENCES mst_user(id_user),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_merchant_ledger_merchant ON merchant_ledger(id_merchant, id DESC);
+
+CREATE OR REPLACE FUNCTION reject_merchant_ledger_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'merchant_ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER merchant_ledger_append_only
+BEFORE UPDATE OR DELETE ON merchant_ledger
+FOR EACH ROW EXECUTE FUNCTION reject_merchant_ledger_change();
+
+-- Open the ledger of merchants that already had a balance, so their mutations add up to it.
+INSERT INTO merchant_ledger (id_merchant, entry_type, amount, balance_after, reference_type, reference_id, description)
+SELECT m.id_merchant, 'opening', m.balance, m.balance, 'mst_merchant', m.id_merchant::text, 'opening balance'
+FROM mst_merchant m
+WHERE COALESCE(m.balance, 0) <> 0
+    AND NOT EXISTS (SELECT 1 FROM merchant_ledger l WHERE l.id_merchant = m.id_merchant);
+
+CREATE TABLE mst_operator_prefix(
+    prefix VARCHAR(6) PRIMARY KEY,
+    operator VARCHAR(50) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO mst_operator_prefix (prefix, operator) VALUES
+    ('0811', 'Telkomsel'), ('0812', 'Telkomsel'), ('0813', 'Telkomsel'), ('0821', 'Telkomsel'), ('0822', 'Telkomsel'),
+    ('0823', 'Telkomsel'), ('0851', 'Telkomsel'), ('0852', 'Telkomsel'), ('0853', 'Telkomsel'),
+    ('0814', 'Indosat'), ('0815', 'Indosat'), ('0816', 'Indosat'), ('0855', 'Indosat'), ('0856', 'Indosat'),
+    ('0857', 'Indosat'), ('0858', 'Indosat'),
+    ('0817', 'XL'), ('0818', 'XL'), ('0819', 'XL'), ('0859', 'XL'), ('0877', 'XL'), ('0878', 'XL'),
+    ('0831', 'Axis'), ('0832', 'Axis'), ('0833', 'Axis'), ('0838', 'Axis'),
+    ('0895', 'Tri'), ('0896', 'Tri'), ('0897', 'Tri'), ('0898', 'Tri'), ('0899', 'Tri'),
+    ('0881', 'Smartfren'), ('0882', 'Smartfren'), ('0883', 'Smartfren'), ('0884', 'Smartfren'), ('0885', 'Smartfren'),
+    ('0886', 'Smartfren'), ('0887', 'Smartfren'), ('0888', 'Smartfren'), ('0889', 'Smartfren');
+
+CREATE TABLE transaction_schedule(
+    id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    id_merchant UUID NOT NULL REFERENCES mst_merchant(id_merchant),
+    id_user UUID NOT NULL REFERENCES mst_user(id_user),
+    customer_name VARCHAR(255) NOT NULL,
+    destination_number VARCHAR(15) NOT NULL,
+    id_product UUID NOT NULL REFERENCES mst_product(id_product),
+    recurrence VARCHAR(100) NOT NULL,
+    occurrence_at TIMESTAMP NOT NULL,
+    next_run_at TIMESTAMP NOT NULL,
+    retry_count INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_transaction_schedule_due ON transaction_schedule(next_run_at) WHERE active;
+
+CREATE TABLE transaction_schedule_run(
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id UUID NOT NULL REFERENCES transaction_schedule(id),
+    scheduled_for TIMESTAMP NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    transaction_id UUID REFERENCES transactions(transaction_id),
+    message VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_transaction_schedule_run_schedule ON transaction_schedule_run(schedule_id, created_at DESC);
+
+CREATE TABLE auth_session (
+    id UUID DEFAULT uuid_generate_v4() PRIMARY KEY,
+    id_user UUID NOT NULL REFERENCES mst_user(id_user) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    revoked_at TIMESTAMP,
+    revoke_reason VARCHAR(50)
+);
+
+CREATE TABLE refresh_token (
+    token_hash CHAR(64) PRIMARY KEY,
+    id_session UUID NOT NULL REFERENCES auth_session(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_refresh_token_session ON refresh_token(id_session);
+
+-- failed logins per username and per client ip, counted since the last failure within the lockout window
+CREATE TABLE login_attempt (
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('username', 'ip')),
+    key VARCHAR(255) NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    blocked_until TIMESTAMP,
+    PRIMARY KEY (scope, key)
+);
+
+-- totp second factor, pending until the first code is confirmed
+CREATE TABLE user_mfa (
+    id_user UUID PRIMARY KEY REFERENCES mst_user(id_user) ON DELETE CASCADE,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT NOW()
 );
+
+CREATE TABLE mfa_recovery_code (
+    id_user UUID NOT NULL REFERENCES user_mfa(id_user) ON DELETE CASCADE,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    PRIMARY KEY (id_user, code_hash)
+);
+
+-- issued by a login with the right password, exchanged for the tokens with a second factor code
+CREATE TABLE mfa_challenge (
+    token_hash CHAR(64) PRIMARY KEY,
+    id_user UUID NOT NULL REFERENCES mst_user(id_user) ON DELETE CASCADE,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- merchant api keys. The signing secret is derived from the server secret and the key id, only its hash is stored
+CREATE TABLE merchant_api_key (
+    id VARCHAR(40) PRIMARY KEY,
+    id_merchant UUID NOT NULL REFERENCES mst_merchant(id_merchant) ON DELETE CASCADE,
+    id_user UUID NOT NULL REFERENCES mst_user(id_user) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    secret_hash CHAR(64) NOT NULL,
+    allowed_ips TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_merchant_api_key_merchant ON merchant_api_key(id_merchant);
+
+-- nonces of signed requests, kept until their timestamp leaves the accepted window
+CREATE TABLE api_key_nonce (
+    id_key VARCHAR(40) NOT NULL REFERENCES merchant_api_key(id) ON DELETE CASCADE,
+    nonce VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (id_key, nonce)
+);
+
+-- who changed what. No foreign keys, the trail outlives the users and entities it mentions
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    id_user UUID,
+    actor_role VARCHAR(50),
+    api_key VARCHAR(40),
+    system VARCHAR(50),
+    action VARCHAR(30) NOT NULL,
+    entity_type VARCHAR(30) NOT NULL,
+    entity_id VARCHAR(64) NOT NULL,
+    before_value JSONB,
+    after_value JSONB,
+    client_ip VARCHAR(45),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_log_created ON audit_log(created_at DESC);
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at DESC);
+CREATE INDEX idx_audit_log_user ON audit_log(id_user, created_at DESC);
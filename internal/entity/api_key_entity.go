@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+type (
+	// APIKey lets a merchant call the transaction api from its own systems. Requests made with it act as IdUser,
+	// the user who created the key, with the permissions of OwnerRole, that user's current role.
+	APIKey struct {
+		Id         string     `json:"id"`
+		IdMerchant string     `json:"id_merchant"`
+		IdUser     string     `json:"id_user"`
+		OwnerRole  string     `json:"-"`
+		Name       string     `json:"name"`
+		SecretHash string     `json:"-"`
+		AllowedIPs []string   `json:"allowed_ips"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+		RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	}
+
+	// APIKeyRequest creates a key. Without allowed ips the key is accepted from any address, otherwise only from
+	// the listed addresses and CIDR ranges.
+	APIKeyRequest struct {
+		Name       string   `json:"name" binding:"required"`
+		AllowedIPs []string `json:"allowed_ips"`
+	}
+
+	// APIKeyCreated carries the signing secret of a new key. It is only shown once.
+	APIKeyCreated struct {
+		APIKey
+		Secret string `json:"secret"`
+	}
+
+	// SignedRequest is a request authenticated with an api key instead of a bearer token.
+	SignedRequest struct {
+		KeyId     string
+		Timestamp string
+		Nonce     string
+		Signature string
+		Method    string
+		URI       string
+		Body      []byte
+		ClientIP  string
+	}
+)
+
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
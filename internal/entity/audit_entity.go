@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited actions.
+const (
+	AuditCreate         = "create"
+	AuditUpdate         = "update"
+	AuditDelete         = "delete"
+	AuditAdjustBalance  = "adjust_balance"
+	AuditChangePassword = "change_password"
+	AuditReview         = "review"
+	AuditPayment        = "payment"
+	AuditRefund         = "refund"
+	AuditRevoke         = "revoke"
+	AuditResetMFA       = "reset_mfa"
+	AuditUnlock         = "unlock"
+)
+
+// Audited entity types.
+const (
+	AuditMerchant     = "merchant"
+	AuditProduct      = "product"
+	AuditUser         = "user"
+	AuditTopup        = "topup"
+	AuditTransaction  = "transaction"
+	AuditRole         = "role"
+	AuditAPIKey       = "api_key"
+	AuditTopupSetting = "topup_setting"
+	AuditTopupFee     = "topup_fee"
+)
+
+type (
+	// AuditActor is who made a change: a logged in user, a merchant api key, or the system itself, such as a
+	// background job or a payment callback.
+	AuditActor struct {
+		IdUser   string
+		Role     string
+		ApiKey   string
+		System   string
+		ClientIP string
+	}
+
+	// AuditLog is one recorded change with the entity as it was before and after, as JSON.
+	AuditLog struct {
+		Id         int64           `json:"id"`
+		IdUser     string          `json:"id_user,omitempty"`
+		Role       string          `json:"role,omitempty"`
+		ApiKey     string          `json:"api_key,omitempty"`
+		System     string          `json:"system,omitempty"`
+		Action     string          `json:"action"`
+		EntityType string          `json:"entity_type"`
+		EntityId   string          `json:"entity_id"`
+		Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+		After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+		ClientIP   string          `json:"client_ip,omitempty"`
+		CreatedAt  time.Time       `json:"created_at"`
+	}
+
+	AuditFilter struct {
+		IdUser     string
+		EntityType string
+		EntityId   string
+		From       time.Time
+		To         time.Time
+		Page       int
+		Limit      int
+	}
+)
+
+// SystemActor is the actor of changes nobody asked for through the api.
+func SystemActor(name string) AuditActor {
+	return AuditActor{System: name}
+}
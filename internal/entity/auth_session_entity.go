@@ -0,0 +1,8 @@
+package entity
+
+// AuthSession is a login session. Every refresh token rotated from one login belongs to the same session, so
+// revoking it revokes the whole token family together with the access tokens issued for it.
+type AuthSession struct {
+	Id     string `json:"id"`
+	IdUser string `json:"id_user"`
+}
@@ -0,0 +1,30 @@
+package entity
+
+const (
+	BulkRowSuccess = "success"
+	BulkRowFailed  = "failed"
+)
+
+type (
+	// BulkTransactionRow is one line of an uploaded transaction sheet together with its outcome.
+	BulkTransactionRow struct {
+		Line              int    `json:"line"`
+		CustomerName      string `json:"customerName"`
+		DestinationNumber string `json:"destinationNumber"`
+		ProductId         string `json:"productId"`
+		Status            string `json:"status"`
+		TransactionId     string `json:"transactionId,omitempty"`
+		Error             string `json:"error,omitempty"`
+	}
+
+	// BulkTransactionResult holds the outcome of every row and the result file sent back to the
+	// merchant, in the same format as the upload.
+	BulkTransactionResult struct {
+		Rows        []BulkTransactionRow
+		Succeeded   int
+		Failed      int
+		FileName    string
+		ContentType string
+		File        []byte
+	}
+)
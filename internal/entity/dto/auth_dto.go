@@ -5,8 +5,27 @@ type AuthRequestDto struct {
 	Password string `json:"password"`
 }
 
+// AuthResponseDto carries the tokens, or a challenge when the login still needs a second factor.
 type AuthResponseDto struct {
-	Token string `json:"token"`
+	Token                 string   `json:"token,omitempty"`
+	RefreshToken          string   `json:"refresh_token,omitempty"`
+	MFARequired           bool     `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"`
+	ChallengeToken        string   `json:"challenge_token,omitempty"`
+	RecoveryCodes         []string `json:"recovery_codes,omitempty"`
+}
+
+type RefreshTokenRequestDto struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type MFAVerifyRequestDto struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+type MFAChallengeRequestDto struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
 }
 
 type (
@@ -16,11 +35,31 @@ type (
 	}
 
 	AuthResponse struct {
-		Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIs..."`
+		Token                 string   `json:"token" example:"eyJhbGciOiJIUzI1NiIs..."`
+		RefreshToken          string   `json:"refresh_token" example:"q3Hk0n4cX9..."`
+		MFARequired           bool     `json:"mfa_required" example:"false"`
+		MFAEnrollmentRequired bool     `json:"mfa_enrollment_required" example:"false"`
+		ChallengeToken        string   `json:"challenge_token" example:"Zk1c8rV0aQ..."`
+		RecoveryCodes         []string `json:"recovery_codes" example:"k3f9-x2qa"`
+	}
+
+	MFAVerifyRequest struct {
+		ChallengeToken string `json:"challenge_token" binding:"required" example:"Zk1c8rV0aQ..."`
+		Code           string `json:"code" binding:"required" example:"492039"`
+	}
+
+	MFAChallengeRequest struct {
+		ChallengeToken string `json:"challenge_token" binding:"required" example:"Zk1c8rV0aQ..."`
+	}
+
+	RefreshTokenRequest struct {
+		RefreshToken string `json:"refresh_token" binding:"required" example:"q3Hk0n4cX9..."`
 	}
 
 	AuthRegisterRes struct {
-		Password string `json:"password" example:"Hashed Password"`
+		IdUser   string `json:"id_user" example:"6f1c9a52-1d2b-4c3e-9f8a-2b7d5e4c1a90"`
+		Username string `json:"name" example:"john_doe"`
+		Role     string `json:"role" example:"employee"`
 	}
 
 	ErrorResponse struct {
@@ -0,0 +1,32 @@
+package entity
+
+const (
+	FulfillmentPending    = "pending"
+	FulfillmentProcessing = "processing"
+	FulfillmentSuccess    = "success"
+	FulfillmentFailed     = "failed"
+	FulfillmentRefunded   = "refunded"
+)
+
+type (
+	// SupplierOrder is a single transaction detail dispatched to the supplier
+	// that owns the product. TransactionDetailId doubles as the reference the
+	// supplier must use to deduplicate retried orders.
+	SupplierOrder struct {
+		TransactionDetailId string  `json:"transactionDetailId"`
+		TransactionsId      string  `json:"transactionId"`
+		MerchantId          string  `json:"merchantId"`
+		SupplierId          string  `json:"supplierId"`
+		ProductId           string  `json:"productId"`
+		NameProvider        string  `json:"nameProvider"`
+		Nominal             float64 `json:"nominal"`
+		Cost                float64 `json:"cost"`
+		DestinationNumber   string  `json:"destinationNumber"`
+	}
+
+	SupplierResult struct {
+		Status       string `json:"status"`
+		SerialNumber string `json:"serialNumber"`
+		Message      string `json:"message"`
+	}
+)
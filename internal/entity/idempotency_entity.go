@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// IdempotencyKey is the client supplied Idempotency-Key header together with the hash of the
+// request it was first used with and the response that request produced.
+type IdempotencyKey struct {
+	Key         string    `json:"key"`
+	Scope       string    `json:"scope"`
+	RequestHash string    `json:"requestHash"`
+	Response    []byte    `json:"response,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
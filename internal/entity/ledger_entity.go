@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+const (
+	LedgerSale        = "sale"
+	LedgerTopup       = "topup"
+	LedgerTopupRefund = "topup_refund"
+	LedgerRefund      = "refund"
+	LedgerAdjustment  = "adjustment"
+	// LedgerOpening carries over a balance that existed before the ledger did.
+	LedgerOpening = "opening"
+)
+
+type (
+	// LedgerEntry is one immutable movement of a merchant balance (mutasi saldo).
+	// Amount is negative for debits and positive for credits.
+	LedgerEntry struct {
+		Id            int64     `json:"id"`
+		IdMerchant    string    `json:"idMerchant"`
+		EntryType     string    `json:"entryType"`
+		Amount        float64   `json:"amount"`
+		BalanceAfter  float64   `json:"balanceAfter"`
+		ReferenceType string    `json:"referenceType"`
+		ReferenceId   string    `json:"referenceId"`
+		Description   string    `json:"description"`
+		CreatedBy     string    `json:"createdBy,omitempty"`
+		CreatedAt     time.Time `json:"createdAt"`
+	}
+
+	BalanceAdjustmentRequest struct {
+		Amount      float64 `json:"amount" binding:"required" example:"-5000"`
+		Description string  `json:"description" binding:"required" example:"correction of duplicated topup"`
+	}
+)
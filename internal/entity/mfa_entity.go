@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+type (
+	// UserMFA is the second factor of a user. Required comes from the role of the user.
+	UserMFA struct {
+		IdUser       string     `json:"id_user"`
+		Secret       string     `json:"-"`
+		EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+		LastUsedStep int64      `json:"-"`
+		Required     bool       `json:"required"`
+	}
+
+	// MFAEnrollment is a new totp secret, to be confirmed with a first code.
+	MFAEnrollment struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}
+
+	MFACodeRequest struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	MFARecoveryCodes struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+)
+
+func (m UserMFA) Enabled() bool {
+	return m.EnabledAt != nil
+}
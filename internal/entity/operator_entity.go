@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+type (
+	// OperatorPrefix maps the leading digits of a destination number in 08 form to its operator.
+	OperatorPrefix struct {
+		Prefix    string    `json:"prefix" example:"0812"`
+		Operator  string    `json:"operator" example:"Telkomsel"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+
+	OperatorPrefixRequest struct {
+		Operator string `json:"operator" binding:"required" example:"Telkomsel"`
+	}
+
+	OperatorPrefixErrorResponse struct {
+		Error string `json:"error" example:"Invalid operator prefix"`
+	}
+)
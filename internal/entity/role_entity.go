@@ -0,0 +1,59 @@
+package entity
+
+import "time"
+
+// Built in roles. Other roles are created by admins at runtime.
+const (
+	RoleAdmin    = "admin"
+	RoleEmployee = "employee"
+)
+
+// Permissions guard the API routes. A role grants a set of them.
+const (
+	PermTransactionCreate = "transaction:create"
+	PermTransactionRead   = "transaction:read"
+	PermTransactionRefund = "transaction:refund"
+	PermScheduleManage    = "schedule:manage"
+	PermReportRead        = "report:read"
+	PermReportProfit      = "report:profit"
+	PermMerchantManage    = "merchant:manage"
+	PermMerchantOwn       = "merchant:own"
+	PermProductManage     = "product:manage"
+	PermOperatorManage    = "operator:manage"
+	PermUserManage        = "user:manage"
+	PermRoleManage        = "role:manage"
+	PermTopupCreate       = "topup:create"
+	PermTopupRead         = "topup:read"
+	PermTopupReview       = "topup:review"
+	PermTopupSetting      = "topup:setting"
+	PermTopupOwn          = "topup:own"
+	PermAuditRead         = "audit:read"
+)
+
+// Permissions lists every permission a role may be granted.
+var Permissions = []string{
+	PermTransactionCreate, PermTransactionRead, PermTransactionRefund, PermScheduleManage,
+	PermReportRead, PermReportProfit, PermMerchantManage, PermMerchantOwn, PermProductManage, PermOperatorManage,
+	PermUserManage, PermRoleManage, PermTopupCreate, PermTopupRead, PermTopupReview, PermTopupSetting, PermTopupOwn,
+	PermAuditRead,
+}
+
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	BuiltIn     bool      `json:"built_in"`
+	RequireMFA  bool      `json:"require_mfa"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// RoleMFARequest makes two factor authentication mandatory, or optional again, for the users of a role.
+type RoleMFARequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
@@ -0,0 +1,48 @@
+package entity
+
+import "time"
+
+const (
+	ScheduleRunSuccess = "success"
+	ScheduleRunRetry   = "retry"
+	ScheduleRunSkipped = "skipped"
+	ScheduleRunFailed  = "failed"
+)
+
+type (
+	// TransactionSchedule sends the same product to a customer on every occurrence of Recurrence, a
+	// five field cron expression. OccurrenceAt is the occurrence being worked on and NextRunAt the
+	// next attempt, which is later than OccurrenceAt while an insufficient balance is being retried.
+	TransactionSchedule struct {
+		Id                string    `json:"id"`
+		MerchantId        string    `json:"merchantId"`
+		UserId            string    `json:"userId"`
+		CustomerName      string    `json:"customerName"`
+		DestinationNumber string    `json:"destinationNumber"`
+		ProductId         string    `json:"productId"`
+		Recurrence        string    `json:"recurrence"`
+		OccurrenceAt      time.Time `json:"occurrenceAt"`
+		NextRunAt         time.Time `json:"nextRunAt"`
+		RetryCount        int       `json:"retryCount"`
+		Active            bool      `json:"active"`
+		CreatedAt         time.Time `json:"createdAt"`
+	}
+
+	TransactionScheduleReq struct {
+		MerchantId        string `json:"merchantId" binding:"required" example:"eyJhbGciOiJIUzI1NiIs..."`
+		CustomerName      string `json:"customerName" binding:"required" example:"customer a"`
+		DestinationNumber string `json:"destinationNumber" binding:"required" example:"081234567890"`
+		ProductId         string `json:"productId" binding:"required" example:"eyJhbGciOiJIUzI1NiIs..."`
+		Recurrence        string `json:"recurrence" binding:"required" example:"0 8 1 * *"`
+	}
+
+	TransactionScheduleRun struct {
+		Id            int64     `json:"id"`
+		ScheduleId    string    `json:"scheduleId"`
+		ScheduledFor  time.Time `json:"scheduledFor"`
+		Status        string    `json:"status"`
+		TransactionId string    `json:"transactionId,omitempty"`
+		Message       string    `json:"message,omitempty"`
+		CreatedAt     time.Time `json:"createdAt"`
+	}
+)
@@ -1,63 +1,147 @@
 package entity
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+const (
+	TopupPending   = "pending"
+	TopupPaid      = "paid"
+	TopupExpired   = "expired"
+	TopupCancelled = "cancelled"
+	TopupDenied    = "denied"
+	TopupRefunded  = "refunded"
+	TopupRejected  = "rejected"
+
+	// TopupMethodGateway topups are paid through the payment gateway, TopupMethodManual topups by a bank
+	// transfer that an admin approves against the uploaded receipt.
+	TopupMethodGateway = "gateway"
+	TopupMethodManual  = "manual"
+)
+
+// topupTransitions lists the statuses a topup may move to from each status. Expired, cancelled,
+// denied, rejected and refunded topups are final.
+var topupTransitions = map[string][]string{
+	TopupPending: {TopupPaid, TopupExpired, TopupCancelled, TopupDenied, TopupRejected},
+	TopupPaid:    {TopupRefunded},
+}
+
+// CanTransitionTopup reports whether a topup in status from may move to status to.
+func CanTransitionTopup(from, to string) bool {
+	for _, next := range topupTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
 
 type TopupRequest struct {
-	Id            string    `json:"id"`
-	IdMerchant    string    `json:"id_merchant"`
-	IdSupliyer    string    `json:"id_supliyer"`
-	Item_name     string    `json:"item_name"`
-	Amount        int       `json:"amount"`
-	PaymentMethod string    `json:"va_numbers,omitempty"`
-	Status        string    `json:"status,omitempty"`
-	CreatedAt     time.Time `json:"created_at,omitempty"`
+	Id              string    `json:"id"`
+	IdMerchant      string    `json:"id_merchant"`
+	IdSupliyer      string    `json:"id_supliyer"`
+	Item_name       string    `json:"item_name"`
+	Amount          int       `json:"amount"`
+	PaymentMethod   string    `json:"va_numbers,omitempty"`
+	Status          string    `json:"status,omitempty"`
+	Method          string    `json:"method,omitempty"`
+	RejectionReason string    `json:"rejection_reason,omitempty"`
+	ReviewedBy      string    `json:"reviewed_by,omitempty"`
+	Fee             int       `json:"fee"`
+	FeeChargedTo    string    `json:"fee_charged_to,omitempty"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+}
+
+const (
+	TopupFeeFlat    = "flat"
+	TopupFeePercent = "percent"
+
+	// A fee charged to the merchant is deducted from the credited balance; a platform fee is absorbed.
+	TopupFeeMerchant = "merchant"
+	TopupFeePlatform = "platform"
+
+	// TopupFeeDefault is the fee rule used for payment methods without their own rule.
+	TopupFeeDefault = "default"
+)
+
+// TopupLimit caps the pending and paid topups a merchant creates since a point in time, like the daily limit.
+type TopupLimit struct {
+	Name  string
+	Limit int
+	Since time.Time
+}
+
+// TopupSetting holds the admin managed topup limits. A zero maximum or limit means unlimited.
+type TopupSetting struct {
+	MinAmount    int       `json:"min_amount"`
+	MaxAmount    int       `json:"max_amount"`
+	DailyLimit   int       `json:"daily_limit"`
+	MonthlyLimit int       `json:"monthly_limit"`
+	UpdatedBy    string    `json:"updated_by,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// TopupFee prices a payment method, either as a flat amount or as a percentage of the topup.
+type TopupFee struct {
+	PaymentMethod string    `json:"payment_method"`
+	FeeType       string    `json:"fee_type" binding:"required"`
+	FeeValue      float64   `json:"fee_value"`
+	ChargedTo     string    `json:"charged_to" binding:"required"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"`
 }
 
-type MidtransRequest struct {
-	TransactionDetails TransactionDetails `json:"transaction_details"`
+// TopupProof is the transfer receipt image uploaded with a manual topup.
+type TopupProof struct {
+	IdTopup     string    `json:"id_topup"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Content     []byte    `json:"-"`
+	UploadedAt  time.Time `json:"uploaded_at,omitempty"`
 }
 
-type TransactionDetails struct {
-	OrderId     string  `json:"order_id"`
-	GrossAmount float64 `json:"gross_amount"`
+// ManualTopupReview is an admin decision on a manual topup. A rejection needs a reason.
+type ManualTopupReview struct {
+	Approve bool   `json:"approve"`
+	Reason  string `json:"reason"`
 }
 
-type MidtransResponse struct {
+// PaymentSession tells the payer where to complete a topup, as returned by the payment gateway.
+type PaymentSession struct {
 	Token       string `json:"token,omitempty"`
 	RedirectURL string `json:"redirect_url"`
 }
 
-type CallbackPayment struct {
-	VANumber          []VANumber `json:"va_numbers"`
-	TransactionTime   string     `json:"transaction_time"`
-	TransactionStatus string     `json:"transaction_status"`
-	TransactionID     string     `json:"transaction_id"`
-	StatusMessage     string     `json:"status_message"`
-	StatusCode        string     `json:"status_code"`
-	SignatureKey      string     `json:"signature_key"`
-	PaymentType       string     `json:"payment_type"`
-	PaymentAmount     []string   `json:"payment_amount,omitempty"`
-	OrderID           string     `json:"order_id"`
-	MerchantID        string     `json:"merchant_id"`
-	GrossAmount       string     `json:"gross_amount"`
-	FraudStatus       string     `json:"fraud_status"`
-	Currency          string     `json:"currency"`
-}
+// ErrPaymentOrderNotFound is the definite answer of a payment gateway that it has no order with the id, for example
+// because the charge never reached it. Payment gateways return it so it can be told apart from a gateway that can't
+// be reached.
+var ErrPaymentOrderNotFound = errors.New("payment gateway has no such order")
 
-type VANumber struct {
-	VANumber string `json:"va_number"`
-	Bank     string `json:"bank"`
+// PaymentNotification is an authenticated payment gateway report about one topup. Status is the topup status it
+// maps to and is empty when the gateway status has no topup equivalent. PartialRefund marks a refund or chargeback
+// of only part of the payment, which leaves the topup as it is for an admin to settle.
+type PaymentNotification struct {
+	OrderId       string  `json:"order_id"`
+	Status        string  `json:"status"`
+	GatewayStatus string  `json:"gateway_status"`
+	GrossAmount   float64 `json:"gross_amount"`
+	PaymentMethod string  `json:"payment_method,omitempty"`
+	PartialRefund bool    `json:"partial_refund,omitempty"`
 }
 
 type TopupRequestDetail struct {
-	Id            string    `json:"id"`
-	IdMerchant    string    `json:"id_merchant"`
-	IdSupliyer    Supliyer  `json:"id_supliyer"`
-	Item_name     string    `json:"item_name"`
-	Amount        int       `json:"amount"`
-	PaymentMethod string    `json:"va_numbers,omitempty"`
-	Status        string    `json:"status,omitempty"`
-	CreatedAt     time.Time `json:"created_at,omitempty"`
+	Id              string    `json:"id"`
+	IdMerchant      string    `json:"id_merchant"`
+	IdSupliyer      Supliyer  `json:"id_supliyer"`
+	Item_name       string    `json:"item_name"`
+	Amount          int       `json:"amount"`
+	PaymentMethod   string    `json:"va_numbers,omitempty"`
+	Status          string    `json:"status,omitempty"`
+	Method          string    `json:"method,omitempty"`
+	RejectionReason string    `json:"rejection_reason,omitempty"`
+	Fee             int       `json:"fee"`
+	FeeChargedTo    string    `json:"fee_charged_to,omitempty"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
 }
 
 type Supliyer struct {
@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type (
 	Transactions struct {
 		TransactionsId    string              `json:"transactionId"`
@@ -8,6 +10,7 @@ type (
 		CustomerName      string              `json:"customerName"`
 		DestinationNumber string              `json:"destinationNumber"`
 		TransactionDate   string              `json:"transactionDate"`
+		Status            string              `json:"status,omitempty"`
 		TransactionDetail []TransactionDetail `json:"transactionDetail"`
 	}
 
@@ -15,7 +18,11 @@ type (
 		TransactionDetailId string  `json:"transactionDetailId"`
 		TransactionsId      string  `json:"transactionId"`
 		ProductId           string  `json:"productId"`
+		Cost                float64 `json:"cost"`
 		Price               float64 `json:"Price"`
+		Margin              float64 `json:"margin"`
+		Status              string  `json:"status,omitempty"`
+		SerialNumber        string  `json:"serialNumber,omitempty"`
 	}
 
 	TransactionReq struct {
@@ -31,6 +38,39 @@ type (
 		ProductId string `json:"productId" binding:"required" example:"eyJhbGciOiJIUzI1NiIs..."`
 	}
 
+	TransactionRefundReq struct {
+		TransactionDetailIds []string `json:"transactionDetailIds" example:"eyJhbGciOiJIUzI1NiIs..."`
+		Reason               string   `json:"reason" binding:"required" example:"wrong destination number"`
+	}
+
+	TransactionRefund struct {
+		TransactionsId       string    `json:"transactionId"`
+		TransactionDetailIds []string  `json:"transactionDetailIds"`
+		Amount               float64   `json:"amount"`
+		Reason               string    `json:"reason"`
+		RefundedBy           string    `json:"refundedBy"`
+		RefundedAt           time.Time `json:"refundedAt"`
+	}
+
+	// TransactionFilter narrows and orders the transaction history of the merchant owned by UserId.
+	// Sort is a field name optionally prefixed with "-" for descending order. When Cursor is set the
+	// page after the cursor is returned and Page is ignored.
+	TransactionFilter struct {
+		UserId            string
+		StartDate         time.Time
+		EndDate           time.Time
+		Provider          string
+		DestinationNumber string
+		CustomerName      string
+		Status            string
+		Sort              string
+		Page              int
+		Limit             int
+		Cursor            string
+		AfterValue        string
+		AfterId           string
+	}
+
 	TransactionErrorResponse struct {
 		Error string `json:"error" example:"Invalid transaction"`
 	}
@@ -4,7 +4,7 @@ type (
 	User struct {
 		Id_user  string `json:"id_user"`
 		Username string `json:"name"`
-		Password string `json:"password"`
+		Password string `json:"-"`
 		Role     string `json:"role"`
 	}
 
@@ -15,11 +15,30 @@ type (
 	}
 
 	UserResponse struct {
-		Id_user  string `json:"id_user"`
-		Username string `json:"name"`
-		Password string `json:"password,omitempty"`
-		Role     string `json:"role"`
+		Id_user    string `json:"id_user"`
+		Username   string `json:"name"`
+		Role       string `json:"role"`
+		IdMerchant string `json:"id_merchant,omitempty"`
+	}
+
+	// UserCreateRequest is an admin provisioning a user with any role, optionally as the owner of a merchant.
+	UserCreateRequest struct {
+		Username   string `json:"name" binding:"required"`
+		Password   string `json:"password" binding:"required"`
+		Role       string `json:"role" binding:"required"`
+		IdMerchant string `json:"id_merchant"`
 	}
+
+	// ProfileUpdateRequest is the part of their own account a user may change.
+	ProfileUpdateRequest struct {
+		Username string `json:"name" binding:"required"`
+	}
+
+	ChangePasswordRequest struct {
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+
 	UserErrorResponse struct {
 		Error string `json:"error" example:"Invalid product"`
 	}
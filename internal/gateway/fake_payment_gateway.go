@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"server-pulsa-app/internal/entity"
+)
+
+// FakePaymentTokenHeader carries the shared token that authenticates fake gateway notifications.
+const FakePaymentTokenHeader = "X-Fake-Payment-Token"
+
+// FakePaymentGateway is an in-process payment gateway for tests and local development. Charges never
+// move money: every order stays pending until SetStatus, Cancel or Refund changes it, and notifications
+// are plain PaymentNotification JSON authenticated by FakePaymentTokenHeader.
+type FakePaymentGateway struct {
+	mu         sync.Mutex
+	token      string
+	chargeErr  error
+	statusErr  error
+	orders     map[string]entity.PaymentNotification
+	refundedBy map[string]int
+}
+
+func (f *FakePaymentGateway) CreateCharge(topup entity.TopupRequest) (entity.PaymentSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.chargeErr != nil {
+		return entity.PaymentSession{}, f.chargeErr
+	}
+
+	f.orders[topup.Id] = entity.PaymentNotification{
+		OrderId:       topup.Id,
+		Status:        entity.TopupPending,
+		GatewayStatus: entity.TopupPending,
+		GrossAmount:   float64(topup.Amount),
+	}
+
+	return entity.PaymentSession{Token: "fake-" + topup.Id, RedirectURL: "https://payment.fake.local/" + topup.Id}, nil
+}
+
+func (f *FakePaymentGateway) GetStatus(orderId string) (entity.PaymentNotification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.statusErr != nil {
+		return entity.PaymentNotification{}, f.statusErr
+	}
+
+	order, ok := f.orders[orderId]
+	if !ok {
+		return entity.PaymentNotification{}, fmt.Errorf("%w: %s", entity.ErrPaymentOrderNotFound, orderId)
+	}
+
+	return order, nil
+}
+
+func (f *FakePaymentGateway) Cancel(orderId string) error {
+	return f.move(orderId, entity.TopupPending, entity.TopupCancelled)
+}
+
+func (f *FakePaymentGateway) Refund(orderId string, amount int, reason string) error {
+	if err := f.move(orderId, entity.TopupPaid, entity.TopupRefunded); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refundedBy[orderId] = amount
+	return nil
+}
+
+func (f *FakePaymentGateway) ParseNotification(header http.Header, body []byte) (entity.PaymentNotification, error) {
+	if f.token == "" || subtle.ConstantTimeCompare([]byte(header.Get(FakePaymentTokenHeader)), []byte(f.token)) != 1 {
+		return entity.PaymentNotification{}, errors.New("fake payment token does not match")
+	}
+
+	var notification entity.PaymentNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return entity.PaymentNotification{}, fmt.Errorf("invalid fake notification: %w", err)
+	}
+
+	return notification, nil
+}
+
+// SetStatus changes the status GetStatus reports for an order, as if the payer had acted on it.
+func (f *FakePaymentGateway) SetStatus(orderId, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order := f.orders[orderId]
+	order.OrderId = orderId
+	order.Status = status
+	order.GatewayStatus = status
+	f.orders[orderId] = order
+}
+
+// FailCharges makes every following CreateCharge return err; nil restores normal charges.
+func (f *FakePaymentGateway) FailCharges(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chargeErr = err
+}
+
+// FailStatus makes every following GetStatus return err, as if the gateway couldn't be reached; nil restores it.
+func (f *FakePaymentGateway) FailStatus(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statusErr = err
+}
+
+// Refunded returns the amount refunded for an order, or zero.
+func (f *FakePaymentGateway) Refunded(orderId string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refundedBy[orderId]
+}
+
+func (f *FakePaymentGateway) move(orderId, from, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order, ok := f.orders[orderId]
+	if !ok {
+		return fmt.Errorf("fake gateway has no order %s", orderId)
+	}
+	if order.Status != from {
+		return fmt.Errorf("fake gateway order %s is %s, not %s", orderId, order.Status, from)
+	}
+
+	order.Status = to
+	order.GatewayStatus = to
+	f.orders[orderId] = order
+	return nil
+}
+
+func NewFakePaymentGateway(token string) *FakePaymentGateway {
+	return &FakePaymentGateway{
+		token:      token,
+		orders:     make(map[string]entity.PaymentNotification),
+		refundedBy: make(map[string]int),
+	}
+}
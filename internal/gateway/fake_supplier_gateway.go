@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"server-pulsa-app/internal/entity"
+)
+
+// FakeSupplierGateway is an in-process supplier for development and tests, picked with
+// SUPPLIER_GATEWAY=fake. It never sends pulsa. Every order succeeds with a serial number derived from the transaction
+// detail id, unless its destination number was registered with FailDestination. Like a real
+// supplier it deduplicates on the transaction detail id and answers a repeated order with the
+// result of the first one.
+type FakeSupplierGateway struct {
+	mu       sync.Mutex
+	failures map[string]string
+	results  map[string]entity.SupplierResult
+	orders   []entity.SupplierOrder
+}
+
+func (f *FakeSupplierGateway) Purchase(order entity.SupplierOrder) (entity.SupplierResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.orders = append(f.orders, order)
+
+	if result, ok := f.results[order.TransactionDetailId]; ok {
+		return result, nil
+	}
+
+	result := entity.SupplierResult{Status: entity.FulfillmentFailed}
+	if reason, ok := f.failures[order.DestinationNumber]; ok {
+		result.Message = reason
+	} else {
+		sum := sha1.Sum([]byte(order.TransactionDetailId))
+		result = entity.SupplierResult{
+			Status:       entity.FulfillmentSuccess,
+			SerialNumber: strings.ToUpper(hex.EncodeToString(sum[:8])),
+			Message:      "success",
+		}
+	}
+
+	f.results[order.TransactionDetailId] = result
+	return result, nil
+}
+
+// FailDestination makes every order to the given number fail with reason.
+func (f *FakeSupplierGateway) FailDestination(number, reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[number] = reason
+}
+
+// Orders returns the orders received so far, repeated orders included.
+func (f *FakeSupplierGateway) Orders() []entity.SupplierOrder {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]entity.SupplierOrder(nil), f.orders...)
+}
+
+func NewFakeSupplierGateway() *FakeSupplierGateway {
+	return &FakeSupplierGateway{failures: make(map[string]string), results: make(map[string]entity.SupplierResult)}
+}
@@ -0,0 +1,25 @@
+package gateway
+
+import (
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+)
+
+// LogScheduleNotifier writes schedule notifications to the application log. Merchants also see
+// every run through the schedule runs endpoint; a push or e-mail notifier can replace this one.
+type LogScheduleNotifier struct {
+	log *logger.Logger
+}
+
+func (l *LogScheduleNotifier) Notify(schedule entity.TransactionSchedule, run entity.TransactionScheduleRun) {
+	l.log.Info("Scheduled transaction needs merchant attention", map[string]interface{}{
+		"merchantId": schedule.MerchantId,
+		"scheduleId": schedule.Id,
+		"status":     run.Status,
+		"message":    run.Message,
+	})
+}
+
+func NewLogScheduleNotifier(log *logger.Logger) *LogScheduleNotifier {
+	return &LogScheduleNotifier{log: log}
+}
@@ -0,0 +1,197 @@
+package gateway
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type (
+	midtransTransactionDetails struct {
+		OrderId     string  `json:"order_id"`
+		GrossAmount float64 `json:"gross_amount"`
+	}
+
+	midtransItemDetail struct {
+		Id       string  `json:"id"`
+		Name     string  `json:"name"`
+		Price    float64 `json:"price"`
+		Quantity int     `json:"quantity"`
+	}
+
+	midtransChargeRequest struct {
+		TransactionDetails midtransTransactionDetails `json:"transaction_details"`
+		ItemDetails        []midtransItemDetail       `json:"item_details,omitempty"`
+	}
+
+	midtransVANumber struct {
+		VANumber string `json:"va_number"`
+		Bank     string `json:"bank"`
+	}
+
+	// midtransStatus is the body of both the HTTP notification and the status API response.
+	midtransStatus struct {
+		VANumbers         []midtransVANumber `json:"va_numbers"`
+		TransactionStatus string             `json:"transaction_status"`
+		StatusCode        string             `json:"status_code"`
+		SignatureKey      string             `json:"signature_key"`
+		PaymentType       string             `json:"payment_type"`
+		OrderId           string             `json:"order_id"`
+		GrossAmount       string             `json:"gross_amount"`
+		FraudStatus       string             `json:"fraud_status"`
+		StatusMessage     string             `json:"status_message"`
+	}
+)
+
+// MidtransPaymentGateway charges topups through Midtrans Snap and reads their state from the Midtrans core API.
+type MidtransPaymentGateway struct {
+	cfg  config.MidtransConfig
+	snap *resty.Client
+	api  *resty.Client
+}
+
+func (m *MidtransPaymentGateway) CreateCharge(topup entity.TopupRequest) (entity.PaymentSession, error) {
+	request := midtransChargeRequest{
+		TransactionDetails: midtransTransactionDetails{OrderId: topup.Id, GrossAmount: float64(topup.Amount)},
+	}
+	if topup.Item_name != "" {
+		request.ItemDetails = []midtransItemDetail{{Id: topup.Id, Name: topup.Item_name, Price: float64(topup.Amount), Quantity: 1}}
+	}
+
+	var session entity.PaymentSession
+	resp, err := m.snap.R().SetBody(request).SetResult(&session).Post("")
+	if err != nil {
+		return entity.PaymentSession{}, fmt.Errorf("failed to reach midtrans: %w", err)
+	}
+	if resp.StatusCode() != http.StatusCreated {
+		return entity.PaymentSession{}, fmt.Errorf("midtrans rejected the charge with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return session, nil
+}
+
+func (m *MidtransPaymentGateway) GetStatus(orderId string) (entity.PaymentNotification, error) {
+	var status midtransStatus
+	resp, err := m.api.R().SetResult(&status).Get("/v2/" + orderId + "/status")
+	if err != nil {
+		return entity.PaymentNotification{}, fmt.Errorf("failed to reach midtrans: %w", err)
+	}
+	if resp.StatusCode() == http.StatusNotFound || status.StatusCode == "404" {
+		return entity.PaymentNotification{}, fmt.Errorf("%w: midtrans has no order %s", entity.ErrPaymentOrderNotFound, orderId)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return entity.PaymentNotification{}, fmt.Errorf("midtrans status request failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return midtransNotification(status)
+}
+
+func (m *MidtransPaymentGateway) Cancel(orderId string) error {
+	return m.post("/v2/"+orderId+"/cancel", nil)
+}
+
+func (m *MidtransPaymentGateway) Refund(orderId string, amount int, reason string) error {
+	body := map[string]interface{}{
+		"refund_key": orderId + "-refund",
+		"amount":     amount,
+		"reason":     reason,
+	}
+	return m.post("/v2/"+orderId+"/refund", body)
+}
+
+func (m *MidtransPaymentGateway) post(path string, body interface{}) error {
+	var status midtransStatus
+	resp, err := m.api.R().SetBody(body).SetResult(&status).Post(path)
+	if err != nil {
+		return fmt.Errorf("failed to reach midtrans: %w", err)
+	}
+	// The core API answers 200 with the outcome in status_code.
+	if resp.StatusCode() != http.StatusOK || !strings.HasPrefix(status.StatusCode, "2") {
+		return fmt.Errorf("midtrans request %s failed: %s %s", path, status.StatusCode, status.StatusMessage)
+	}
+
+	return nil
+}
+
+// ParseNotification checks signature_key, the SHA-512 of order_id + status_code + gross_amount + server key.
+func (m *MidtransPaymentGateway) ParseNotification(header http.Header, body []byte) (entity.PaymentNotification, error) {
+	var status midtransStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return entity.PaymentNotification{}, fmt.Errorf("invalid midtrans notification: %w", err)
+	}
+
+	if m.cfg.ServerKey == "" {
+		return entity.PaymentNotification{}, errors.New("midtrans server key is not configured")
+	}
+	digest := sha512.Sum512([]byte(status.OrderId + status.StatusCode + status.GrossAmount + m.cfg.ServerKey))
+	expected := hex.EncodeToString(digest[:])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(status.SignatureKey))) != 1 {
+		return entity.PaymentNotification{}, errors.New("midtrans signature does not match")
+	}
+
+	return midtransNotification(status)
+}
+
+func midtransNotification(status midtransStatus) (entity.PaymentNotification, error) {
+	amount, err := strconv.ParseFloat(status.GrossAmount, 64)
+	if err != nil {
+		return entity.PaymentNotification{}, fmt.Errorf("invalid midtrans gross_amount %q", status.GrossAmount)
+	}
+
+	notification := entity.PaymentNotification{
+		OrderId:       status.OrderId,
+		Status:        midtransTopupStatus(status.TransactionStatus, status.FraudStatus),
+		GatewayStatus: status.TransactionStatus,
+		GrossAmount:   amount,
+		PaymentMethod: status.PaymentType,
+		PartialRefund: status.TransactionStatus == "partial_refund" || status.TransactionStatus == "partial_chargeback",
+	}
+	if len(status.VANumbers) > 0 {
+		notification.PaymentMethod = status.VANumbers[0].Bank
+	}
+
+	return notification, nil
+}
+
+// midtransTopupStatus maps a Midtrans transaction status to the topup status it moves the topup to.
+// A captured card payment only counts as paid once the fraud check accepted it. Partial refunds and chargebacks
+// have no topup status since the merchant keeps part of the credit.
+func midtransTopupStatus(transactionStatus, fraudStatus string) string {
+	switch transactionStatus {
+	case "settlement":
+		return entity.TopupPaid
+	case "capture":
+		if fraudStatus == "" || fraudStatus == "accept" {
+			return entity.TopupPaid
+		}
+		return entity.TopupPending
+	case "pending":
+		return entity.TopupPending
+	case "expire":
+		return entity.TopupExpired
+	case "cancel":
+		return entity.TopupCancelled
+	case "deny":
+		return entity.TopupDenied
+	case "refund", "chargeback":
+		return entity.TopupRefunded
+	}
+	return ""
+}
+
+func NewMidtransPaymentGateway(cfg config.MidtransConfig) *MidtransPaymentGateway {
+	snap := resty.New().SetBaseURL(cfg.SnapURL).SetBasicAuth(cfg.ServerKey, "")
+	api := resty.New().SetBaseURL(cfg.ApiURL).SetBasicAuth(cfg.ServerKey, "")
+	return &MidtransPaymentGateway{cfg: cfg, snap: snap, api: api}
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const testServerKey = "SB-Mid-server-test"
+
+type midtransPaymentGatewaySuite struct {
+	suite.Suite
+	gateway *MidtransPaymentGateway
+}
+
+func (m *midtransPaymentGatewaySuite) SetupTest() {
+	m.gateway = NewMidtransPaymentGateway(config.MidtransConfig{ServerKey: testServerKey})
+}
+
+func TestMidtransPaymentGatewaySuite(t *testing.T) {
+	suite.Run(t, new(midtransPaymentGatewaySuite))
+}
+
+func signedMidtransStatus(transactionStatus, grossAmount string) midtransStatus {
+	digest := sha512.Sum512([]byte("topup-1" + "200" + grossAmount + testServerKey))
+	return midtransStatus{
+		OrderId:           "topup-1",
+		StatusCode:        "200",
+		GrossAmount:       grossAmount,
+		TransactionStatus: transactionStatus,
+		SignatureKey:      hex.EncodeToString(digest[:]),
+		VANumbers:         []midtransVANumber{{Bank: "bca"}},
+	}
+}
+
+func (m *midtransPaymentGatewaySuite) parse(status midtransStatus) (entity.PaymentNotification, error) {
+	body, err := json.Marshal(status)
+	m.NoError(err)
+	return m.gateway.ParseNotification(nil, body)
+}
+
+func (m *midtransPaymentGatewaySuite) TestParseNotification_Valid() {
+	notification, err := m.parse(signedMidtransStatus("settlement", "50000.00"))
+
+	m.NoError(err)
+	m.Equal(entity.PaymentNotification{
+		OrderId:       "topup-1",
+		Status:        entity.TopupPaid,
+		GatewayStatus: "settlement",
+		GrossAmount:   50000,
+		PaymentMethod: "bca",
+	}, notification)
+}
+
+func (m *midtransPaymentGatewaySuite) TestParseNotification_PartialRefund() {
+	notification, err := m.parse(signedMidtransStatus("partial_refund", "50000.00"))
+
+	m.NoError(err)
+	m.Empty(notification.Status)
+	m.True(notification.PartialRefund)
+}
+
+func (m *midtransPaymentGatewaySuite) TestParseNotification_TamperedAmount() {
+	status := signedMidtransStatus("settlement", "50000.00")
+	status.GrossAmount = "500000.00"
+
+	_, err := m.parse(status)
+
+	m.Error(err)
+}
+
+func (m *midtransPaymentGatewaySuite) TestParseNotification_MissingServerKey() {
+	m.gateway = NewMidtransPaymentGateway(config.MidtransConfig{})
+
+	_, err := m.parse(signedMidtransStatus("settlement", "50000.00"))
+
+	m.Error(err)
+}
+
+func (m *midtransPaymentGatewaySuite) TestTopupStatus() {
+	cases := map[[2]string]string{
+		{"settlement", ""}:       entity.TopupPaid,
+		{"capture", "accept"}:    entity.TopupPaid,
+		{"capture", "challenge"}: entity.TopupPending,
+		{"expire", ""}:           entity.TopupExpired,
+		{"deny", ""}:             entity.TopupDenied,
+		{"chargeback", ""}:       entity.TopupRefunded,
+		{"partial_refund", ""}:   "",
+		{"authorize", ""}:        "",
+	}
+	for input, expected := range cases {
+		m.Equal(expected, midtransTopupStatus(input[0], input[1]), input[0])
+	}
+}
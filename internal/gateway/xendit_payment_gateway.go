@@ -0,0 +1,256 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// xenditInvoice is the body of both the invoice API responses and the invoice callback.
+type xenditInvoice struct {
+	Id             string  `json:"id"`
+	ExternalId     string  `json:"external_id"`
+	Status         string  `json:"status"`
+	Amount         float64 `json:"amount"`
+	InvoiceURL     string  `json:"invoice_url"`
+	PaymentMethod  string  `json:"payment_method"`
+	PaymentChannel string  `json:"payment_channel"`
+}
+
+// xenditRefund is a refund of an invoice payment, as listed by the refunds API and sent in refund callbacks.
+type xenditRefund struct {
+	Id        string  `json:"id"`
+	InvoiceId string  `json:"invoice_id"`
+	Status    string  `json:"status"`
+	Amount    float64 `json:"amount"`
+}
+
+// xenditRefundEvent is the body of a refund callback. Unlike invoice callbacks it wraps the refund in data.
+type xenditRefundEvent struct {
+	Event string        `json:"event"`
+	Data  *xenditRefund `json:"data"`
+}
+
+// XenditPaymentGateway charges topups through Xendit invoices, using the topup id as the invoice external_id.
+type XenditPaymentGateway struct {
+	cfg    config.XenditConfig
+	client *resty.Client
+}
+
+func (x *XenditPaymentGateway) CreateCharge(topup entity.TopupRequest) (entity.PaymentSession, error) {
+	request := map[string]interface{}{
+		"external_id": topup.Id,
+		"amount":      topup.Amount,
+		"description": topup.Item_name,
+		"currency":    "IDR",
+	}
+
+	var invoice xenditInvoice
+	resp, err := x.client.R().SetBody(request).SetResult(&invoice).Post("/v2/invoices")
+	if err != nil {
+		return entity.PaymentSession{}, fmt.Errorf("failed to reach xendit: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return entity.PaymentSession{}, fmt.Errorf("xendit rejected the invoice with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return entity.PaymentSession{Token: invoice.Id, RedirectURL: invoice.InvoiceURL}, nil
+}
+
+// GetStatus reports the status of the invoice of a topup. Xendit keeps a refunded invoice paid, so the refunds of a
+// paid invoice are looked up too.
+func (x *XenditPaymentGateway) GetStatus(orderId string) (entity.PaymentNotification, error) {
+	invoice, err := x.findInvoice(orderId)
+	if err != nil {
+		return entity.PaymentNotification{}, err
+	}
+
+	return x.invoiceNotification(invoice)
+}
+
+func (x *XenditPaymentGateway) Cancel(orderId string) error {
+	invoice, err := x.findInvoice(orderId)
+	if err != nil {
+		return err
+	}
+
+	resp, err := x.client.R().Post("/invoices/" + invoice.Id + "/expire!")
+	if err != nil {
+		return fmt.Errorf("failed to reach xendit: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("xendit failed to expire invoice with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return nil
+}
+
+func (x *XenditPaymentGateway) Refund(orderId string, amount int, reason string) error {
+	invoice, err := x.findInvoice(orderId)
+	if err != nil {
+		return err
+	}
+
+	request := map[string]interface{}{
+		"invoice_id":   invoice.Id,
+		"reference_id": orderId + "-refund",
+		"amount":       amount,
+		"currency":     "IDR",
+		"reason":       "OTHERS",
+		"metadata":     map[string]string{"reason": reason},
+	}
+
+	resp, err := x.client.R().SetBody(request).Post("/refunds")
+	if err != nil {
+		return fmt.Errorf("failed to reach xendit: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusCreated {
+		return fmt.Errorf("xendit rejected the refund with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return nil
+}
+
+// ParseNotification authenticates an invoice or refund callback by its x-callback-token header. A refund callback
+// only names the invoice, so the invoice and all its refunds are looked up to report the refunded topup.
+func (x *XenditPaymentGateway) ParseNotification(header http.Header, body []byte) (entity.PaymentNotification, error) {
+	if x.cfg.CallbackToken == "" {
+		return entity.PaymentNotification{}, errors.New("xendit callback token is not configured")
+	}
+	if subtle.ConstantTimeCompare([]byte(header.Get("x-callback-token")), []byte(x.cfg.CallbackToken)) != 1 {
+		return entity.PaymentNotification{}, errors.New("xendit callback token does not match")
+	}
+
+	var event xenditRefundEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return entity.PaymentNotification{}, fmt.Errorf("invalid xendit notification: %w", err)
+	}
+	if event.Data != nil {
+		invoice, err := x.getInvoice(event.Data.InvoiceId)
+		if err != nil {
+			return entity.PaymentNotification{}, err
+		}
+		return x.invoiceNotification(invoice)
+	}
+
+	var invoice xenditInvoice
+	if err := json.Unmarshal(body, &invoice); err != nil {
+		return entity.PaymentNotification{}, fmt.Errorf("invalid xendit notification: %w", err)
+	}
+
+	return xenditNotification(invoice, 0), nil
+}
+
+// invoiceNotification reports an invoice together with the amount refunded from it, if it was paid.
+func (x *XenditPaymentGateway) invoiceNotification(invoice xenditInvoice) (entity.PaymentNotification, error) {
+	var refunded float64
+	if invoice.Status == "PAID" || invoice.Status == "SETTLED" {
+		var err error
+		if refunded, err = x.refundedAmount(invoice.Id); err != nil {
+			return entity.PaymentNotification{}, err
+		}
+	}
+
+	return xenditNotification(invoice, refunded), nil
+}
+
+func (x *XenditPaymentGateway) getInvoice(invoiceId string) (xenditInvoice, error) {
+	if invoiceId == "" {
+		return xenditInvoice{}, errors.New("invalid xendit notification: refund without invoice_id")
+	}
+
+	var invoice xenditInvoice
+	resp, err := x.client.R().SetResult(&invoice).Get("/v2/invoices/" + invoiceId)
+	if err != nil {
+		return xenditInvoice{}, fmt.Errorf("failed to reach xendit: %w", err)
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return xenditInvoice{}, fmt.Errorf("%w: xendit has no invoice %s", entity.ErrPaymentOrderNotFound, invoiceId)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return xenditInvoice{}, fmt.Errorf("xendit invoice lookup failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	return invoice, nil
+}
+
+// refundedAmount sums the succeeded refunds of an invoice.
+func (x *XenditPaymentGateway) refundedAmount(invoiceId string) (float64, error) {
+	var refunds struct {
+		Data []xenditRefund `json:"data"`
+	}
+	resp, err := x.client.R().SetQueryParam("invoice_id", invoiceId).SetResult(&refunds).Get("/refunds")
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach xendit: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return 0, fmt.Errorf("xendit refund lookup failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+
+	var refunded float64
+	for _, refund := range refunds.Data {
+		if refund.Status == "SUCCEEDED" {
+			refunded += refund.Amount
+		}
+	}
+	return refunded, nil
+}
+
+func (x *XenditPaymentGateway) findInvoice(orderId string) (xenditInvoice, error) {
+	var invoices []xenditInvoice
+	resp, err := x.client.R().SetQueryParam("external_id", orderId).SetResult(&invoices).Get("/v2/invoices")
+	if err != nil {
+		return xenditInvoice{}, fmt.Errorf("failed to reach xendit: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return xenditInvoice{}, fmt.Errorf("xendit invoice lookup failed with status %d: %s", resp.StatusCode(), resp.String())
+	}
+	if len(invoices) == 0 {
+		return xenditInvoice{}, fmt.Errorf("%w: xendit has no invoice for order %s", entity.ErrPaymentOrderNotFound, orderId)
+	}
+
+	return invoices[0], nil
+}
+
+// xenditNotification maps an invoice, and the amount refunded from it, to a notification. A paid invoice refunded in
+// full refunds the topup, while a partial refund has no topup status since the merchant keeps part of the credit.
+func xenditNotification(invoice xenditInvoice, refunded float64) entity.PaymentNotification {
+	notification := entity.PaymentNotification{
+		OrderId:       invoice.ExternalId,
+		GatewayStatus: invoice.Status,
+		GrossAmount:   invoice.Amount,
+		PaymentMethod: invoice.PaymentChannel,
+	}
+
+	switch invoice.Status {
+	case "PENDING":
+		notification.Status = entity.TopupPending
+	case "PAID", "SETTLED":
+		switch {
+		case refunded >= invoice.Amount:
+			notification.Status = entity.TopupRefunded
+			notification.GatewayStatus = "REFUNDED"
+		case refunded > 0:
+			notification.PartialRefund = true
+			notification.GatewayStatus = "PARTIALLY_REFUNDED"
+		default:
+			notification.Status = entity.TopupPaid
+		}
+	case "EXPIRED":
+		notification.Status = entity.TopupExpired
+	}
+
+	return notification
+}
+
+func NewXenditPaymentGateway(cfg config.XenditConfig) *XenditPaymentGateway {
+	client := resty.New().SetBaseURL(cfg.BaseURL).SetBasicAuth(cfg.SecretKey, "")
+	return &XenditPaymentGateway{cfg: cfg, client: client}
+}
@@ -0,0 +1,151 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const testCallbackToken = "xendit-callback-token"
+
+type xenditPaymentGatewaySuite struct {
+	suite.Suite
+	server  *httptest.Server
+	invoice xenditInvoice
+	refunds []xenditRefund
+	gateway *XenditPaymentGateway
+}
+
+// SetupTest serves the invoice and refunds of the suite the way the Xendit API does.
+func (x *xenditPaymentGatewaySuite) SetupTest() {
+	x.invoice = xenditInvoice{Id: "inv-1", ExternalId: "topup-1", Status: "PAID", Amount: 50000, PaymentChannel: "BCA"}
+	x.refunds = nil
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v2/invoices/inv-1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(x.invoice)
+	})
+	mux.HandleFunc("GET /refunds", func(w http.ResponseWriter, r *http.Request) {
+		x.Equal("inv-1", r.URL.Query().Get("invoice_id"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"data": x.refunds})
+	})
+	x.server = httptest.NewServer(mux)
+
+	x.gateway = NewXenditPaymentGateway(config.XenditConfig{SecretKey: "xnd_test", CallbackToken: testCallbackToken, BaseURL: x.server.URL})
+}
+
+func (x *xenditPaymentGatewaySuite) TearDownTest() {
+	x.server.Close()
+}
+
+func TestXenditPaymentGatewaySuite(t *testing.T) {
+	suite.Run(t, new(xenditPaymentGatewaySuite))
+}
+
+func (x *xenditPaymentGatewaySuite) parse(token string, body any) (entity.PaymentNotification, error) {
+	raw, err := json.Marshal(body)
+	x.NoError(err)
+	header := http.Header{}
+	header.Set("x-callback-token", token)
+	return x.gateway.ParseNotification(header, raw)
+}
+
+func refundEvent(status string, amount float64) xenditRefundEvent {
+	return xenditRefundEvent{Event: "refund." + status, Data: &xenditRefund{Id: "rfd-1", InvoiceId: "inv-1", Status: status, Amount: amount}}
+}
+
+func (x *xenditPaymentGatewaySuite) TestParseNotification_Valid() {
+	notification, err := x.parse(testCallbackToken, x.invoice)
+
+	x.NoError(err)
+	x.Equal(entity.PaymentNotification{
+		OrderId:       "topup-1",
+		Status:        entity.TopupPaid,
+		GatewayStatus: "PAID",
+		GrossAmount:   50000,
+		PaymentMethod: "BCA",
+	}, notification)
+}
+
+func (x *xenditPaymentGatewaySuite) TestParseNotification_WrongToken() {
+	_, err := x.parse("forged-token", x.invoice)
+
+	x.Error(err)
+}
+
+func (x *xenditPaymentGatewaySuite) TestParseNotification_MissingCallbackToken() {
+	x.gateway = NewXenditPaymentGateway(config.XenditConfig{BaseURL: x.server.URL})
+
+	_, err := x.parse("", x.invoice)
+
+	x.Error(err)
+}
+
+func (x *xenditPaymentGatewaySuite) TestParseNotification_Refund() {
+	x.refunds = []xenditRefund{{Id: "rfd-1", InvoiceId: "inv-1", Status: "SUCCEEDED", Amount: 50000}}
+
+	notification, err := x.parse(testCallbackToken, refundEvent("SUCCEEDED", 50000))
+
+	x.NoError(err)
+	x.Equal("topup-1", notification.OrderId)
+	x.Equal(entity.TopupRefunded, notification.Status)
+	x.Equal(float64(50000), notification.GrossAmount)
+	x.False(notification.PartialRefund)
+}
+
+func (x *xenditPaymentGatewaySuite) TestParseNotification_PartialRefund() {
+	x.refunds = []xenditRefund{
+		{Id: "rfd-1", InvoiceId: "inv-1", Status: "SUCCEEDED", Amount: 20000},
+		{Id: "rfd-2", InvoiceId: "inv-1", Status: "FAILED", Amount: 30000},
+	}
+
+	notification, err := x.parse(testCallbackToken, refundEvent("SUCCEEDED", 20000))
+
+	x.NoError(err)
+	x.Empty(notification.Status)
+	x.True(notification.PartialRefund)
+	x.Equal(float64(50000), notification.GrossAmount)
+}
+
+func (x *xenditPaymentGatewaySuite) TestParseNotification_RefundsAddUp() {
+	x.refunds = []xenditRefund{
+		{Id: "rfd-1", InvoiceId: "inv-1", Status: "SUCCEEDED", Amount: 20000},
+		{Id: "rfd-2", InvoiceId: "inv-1", Status: "SUCCEEDED", Amount: 30000},
+	}
+
+	notification, err := x.parse(testCallbackToken, refundEvent("SUCCEEDED", 30000))
+
+	x.NoError(err)
+	x.Equal(entity.TopupRefunded, notification.Status)
+}
+
+func (x *xenditPaymentGatewaySuite) TestParseNotification_FailedRefundKeepsPaid() {
+	x.refunds = []xenditRefund{{Id: "rfd-1", InvoiceId: "inv-1", Status: "FAILED", Amount: 50000}}
+
+	notification, err := x.parse(testCallbackToken, refundEvent("FAILED", 50000))
+
+	x.NoError(err)
+	x.Equal(entity.TopupPaid, notification.Status)
+	x.False(notification.PartialRefund)
+}
+
+func (x *xenditPaymentGatewaySuite) TestNotificationStatus() {
+	cases := map[string]string{
+		"PENDING": entity.TopupPending,
+		"PAID":    entity.TopupPaid,
+		"SETTLED": entity.TopupPaid,
+		"EXPIRED": entity.TopupExpired,
+		"UNKNOWN": "",
+	}
+	for status, expected := range cases {
+		x.Equal(expected, xenditNotification(xenditInvoice{Status: status, Amount: 50000}, 0).Status, status)
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/shared/common"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	usecase        usecase.APIKeyUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
+}
+
+// apiKeyErrorStatus maps a failure to manage api keys to its response status.
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidAllowedIP):
+		return 400
+	case errors.Is(err, usecase.ErrAPIKeyNotFound), errors.Is(err, repository.ErrMerchantNotFound):
+		return 404
+	case errors.Is(err, usecase.ErrAPIKeysDisabled):
+		return 503
+	}
+	return 500
+}
+
+// CreateAPIKey godoc
+// @Summary Create merchant api key
+// @Description Issue a key a merchant signs its requests to the transaction api with. The secret is only shown once
+// @Tags merchants
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Merchant ID"
+// @Param request body entity.APIKeyRequest true "Key name and allowed ips"
+// @Success 201 {object} entity.APIKeyCreated "Key and secret"
+// @Failure 400 {object} entity.MerchantErrorResponse "Invalid input"
+// @Failure 404 {object} entity.MerchantErrorResponse "Merchant not found"
+// @Failure 503 {object} entity.MerchantErrorResponse "Api keys are not configured"
+// @Router /merchant/{id}/api-key [post]
+func (a *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var payload entity.APIKeyRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	a.log.Info("Starting to create an api key in the handler layer", c.Param("id"))
+	key, err := a.usecase.Create(c.Param("id"), payload, auditActor(c))
+	if err != nil {
+		a.log.Error("Error creating the api key: ", err)
+		common.SendErrorResponse(c, apiKeyErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseCreated(c, key, "Api key created, store the secret now")
+}
+
+// ListAPIKeys godoc
+// @Summary List merchant api keys
+// @Description List the api keys of a merchant with their last use, revoked keys included
+// @Tags merchants
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Merchant ID"
+// @Success 200 {array} entity.APIKey "Api keys"
+// @Failure 401 {object} entity.MerchantErrorResponse "Unauthorized"
+// @Router /merchant/{id}/api-keys [get]
+func (a *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := a.usecase.List(c.Param("id"))
+	if err != nil {
+		a.log.Error("Error listing the api keys: ", err)
+		common.SendErrorResponse(c, apiKeyErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, keys, "Api keys")
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke merchant api key
+// @Description Revoke an api key, requests signed with it are refused from now on
+// @Tags merchants
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Merchant ID"
+// @Param key path string true "Api key ID"
+// @Success 200 {object} object "Revoked"
+// @Failure 404 {object} entity.MerchantErrorResponse "Api key not found"
+// @Router /merchant/{id}/api-key/{key} [delete]
+func (a *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	a.log.Info("Starting to revoke an api key in the handler layer", c.Param("key"))
+	if err := a.usecase.Revoke(c.Param("id"), c.Param("key"), auditActor(c)); err != nil {
+		a.log.Error("Error revoking the api key: ", err)
+		common.SendErrorResponse(c, apiKeyErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, gin.H{"id": c.Param("key")}, "Api key revoked")
+}
+
+func (a *APIKeyHandler) Route() {
+	a.rg.POST(config.PostMerchantAPIKey, a.authMiddleware.RequirePermission(entity.PermMerchantManage), a.CreateAPIKey)
+	a.rg.GET(config.ListMerchantAPIKeys, a.authMiddleware.RequirePermission(entity.PermMerchantManage), a.ListAPIKeys)
+	a.rg.DELETE(config.RevokeMerchantAPIKey, a.authMiddleware.RequirePermission(entity.PermMerchantManage), a.RevokeAPIKey)
+}
+
+func NewAPIKeyHandler(usecase usecase.APIKeyUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *APIKeyHandler {
+	return &APIKeyHandler{usecase: usecase, authMiddleware: authMiddleware, rg: rg, log: log}
+}
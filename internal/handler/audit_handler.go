@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/shared/common"
+	"server-pulsa-app/internal/shared/model"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	usecase        usecase.AuditUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
+}
+
+// auditActor is who made the request, as set by the auth middleware.
+func auditActor(ctx *gin.Context) entity.AuditActor {
+	return entity.AuditActor{
+		IdUser:   ctx.GetString("employee"),
+		Role:     ctx.GetString("role"),
+		ApiKey:   ctx.GetString("api_key"),
+		ClientIP: ctx.ClientIP(),
+	}
+}
+
+// ListAuditLogs godoc
+// @Summary List audit logs
+// @Description Page through the recorded changes to merchants, products, users, roles, api keys, topups, topup settings and transactions, newest first
+// @Tags audit
+// @Produce json
+// @Security BearerAuth
+// @Param actor query string false "ID of the user who made the change"
+// @Param entity_type query string false "merchant, product, user, role, api_key, topup, topup_setting, topup_fee or transaction"
+// @Param entity_id query string false "ID of the changed entity"
+// @Param from query string false "Start time, RFC 3339 or dd-mm-yyyy"
+// @Param to query string false "End time, exclusive, RFC 3339 or dd-mm-yyyy"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {array} entity.AuditLog "Audit logs"
+// @Failure 400 {object} entity.MerchantErrorResponse "Invalid filter"
+// @Failure 401 {object} entity.MerchantErrorResponse "Unauthorized"
+// @Router /audit-logs [get]
+func (a *AuditHandler) ListAuditLogs(ctx *gin.Context) {
+	filter, err := auditFilter(ctx)
+	if err != nil {
+		common.SendErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, paging, err := a.usecase.List(filter)
+	if err != nil {
+		a.log.Error("Error listing the audit log: ", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrInvalidAuditFilter) {
+			status = http.StatusBadRequest
+		}
+		common.SendErrorResponse(ctx, status, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Message string            `json:"message"`
+		Data    []entity.AuditLog `json:"data"`
+		Paging  model.Paging      `json:"paging"`
+	}{
+		Message: "Audit logs",
+		Data:    entries,
+		Paging:  paging,
+	})
+}
+
+// auditFilter reads the audit log filters from the query string. A date without a time is taken as the start
+// of that day.
+func auditFilter(ctx *gin.Context) (entity.AuditFilter, error) {
+	filter := entity.AuditFilter{
+		IdUser:     ctx.Query("actor"),
+		EntityType: ctx.Query("entity_type"),
+		EntityId:   ctx.Query("entity_id"),
+	}
+
+	var err error
+	for name, target := range map[string]*int{"page": &filter.Page, "limit": &filter.Limit} {
+		if value := ctx.Query(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return filter, fmt.Errorf("%s must be a number", name)
+			}
+		}
+	}
+
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := ctx.Query(name); value != "" {
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				if *target, err = time.Parse("02-01-2006", value); err != nil {
+					return filter, fmt.Errorf("%s must be formatted as RFC 3339 or dd-mm-yyyy", name)
+				}
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+func (a *AuditHandler) Route() {
+	a.rg.GET(config.ListAuditLogs, a.authMiddleware.RequirePermission(entity.PermAuditRead), a.ListAuditLogs)
+}
+
+func NewAuditHandler(usecase usecase.AuditUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *AuditHandler {
+	return &AuditHandler{usecase: usecase, authMiddleware: authMiddleware, rg: rg, log: log}
+}
@@ -1,19 +1,26 @@
 package handler
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/entity/dto"
 	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
 	"server-pulsa-app/internal/usecase"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthController struct {
-	authUsecase usecase.AuthUseCase
-	rg          *gin.RouterGroup
-	log         *logger.Logger
+	authUsecase    usecase.AuthUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
 }
 
 // Login godoc
@@ -23,9 +30,10 @@ type AuthController struct {
 // @Accept json
 // @Produce json
 // @Param request body dto.AuthRequest true "Login credentials"
-// @Success 200 {object} dto.AuthResponse "Successfully authenticated"
+// @Success 200 {object} dto.AuthResponse "Tokens, or a challenge token when a second factor is needed"
 // @Failure 400 {object} dto.ErrorResponse "Invalid input"
-// @Failure 401 {object} dto.ErrorResponse "Authentication failed"
+// @Failure 401 {object} dto.ErrorResponse "Invalid credentials"
+// @Failure 429 {object} dto.ErrorResponse "Too many failed logins, see the Retry-After header"
 // @Router /auth/login [post]
 func (a *AuthController) loginHandler(ctx *gin.Context) {
 	var payload dto.AuthRequestDto
@@ -39,10 +47,10 @@ func (a *AuthController) loginHandler(ctx *gin.Context) {
 	}
 
 	a.log.Info("Starting login", nil)
-	token, err := a.authUsecase.Login(payload)
+	token, err := a.authUsecase.Login(payload, ctx.ClientIP())
 	if err != nil {
 		a.log.Error("Failed to authenticate user: ", err)
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		sendLoginError(ctx, err)
 		return
 	}
 
@@ -58,8 +66,9 @@ func (a *AuthController) loginHandler(ctx *gin.Context) {
 // @Produce json
 // @Param request body dto.AuthRequest true "Login credentials"
 // @Success 201 {object} dto.AuthRegisterRes "Successfully registered"
-// @Failure 400 {object} dto.ErrorResponse "Invalid input"
+// @Failure 400 {object} dto.ErrorResponse "Invalid input or password too weak"
 // @Failure 401 {object} dto.ErrorResponse "Authentication failed"
+// @Failure 403 {object} dto.ErrorResponse "Public registration is disabled"
 // @Router /auth/register [post]
 func (a *AuthController) registerHandler(ctx *gin.Context) {
 	var payload dto.AuthRequestDto
@@ -72,10 +81,17 @@ func (a *AuthController) registerHandler(ctx *gin.Context) {
 	}
 
 	a.log.Info("Starting to register new user", nil)
-	user, err := a.authUsecase.Register(payload)
+	user, err := a.authUsecase.Register(payload, ctx.ClientIP())
 	if err != nil {
 		a.log.Error("Failed to register user: ", err)
-		ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		status := http.StatusConflict
+		switch {
+		case errors.Is(err, usecase.ErrRegistrationDisabled):
+			status = http.StatusForbidden
+		case errors.Is(err, usecase.ErrWeakPassword):
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -83,11 +99,186 @@ func (a *AuthController) registerHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, user)
 }
 
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. A refresh token works once, reusing it revokes the session
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} dto.AuthResponse "New tokens"
+// @Failure 400 {object} dto.ErrorResponse "Invalid input"
+// @Failure 401 {object} dto.ErrorResponse "Invalid, expired or revoked refresh token"
+// @Router /auth/refresh [post]
+func (a *AuthController) refreshHandler(ctx *gin.Context) {
+	var payload dto.RefreshTokenRequestDto
+
+	a.log.Info("Starting to refresh a token in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		a.log.Error("Invalid payload for refresh", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := a.authUsecase.Refresh(payload.RefreshToken)
+	if err != nil {
+		a.log.Error("Failed to refresh token: ", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrInvalidRefreshToken) {
+			status = http.StatusUnauthorized
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the session of a refresh token together with the access tokens issued for it
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} dto.ErrorResponse "Logged out"
+// @Failure 400 {object} dto.ErrorResponse "Invalid input"
+// @Router /auth/logout [post]
+func (a *AuthController) logoutHandler(ctx *gin.Context) {
+	var payload dto.RefreshTokenRequestDto
+
+	a.log.Info("Starting to logout in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		a.log.Error("Invalid payload for logout", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := a.authUsecase.Logout(payload.RefreshToken); err != nil {
+		a.log.Error("Failed to logout: ", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// VerifyMFA godoc
+// @Summary Verify second factor
+// @Description Exchange the challenge token of a login and a TOTP or recovery code for the tokens. During a required enrollment the code confirms it and the recovery codes are returned once
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.MFAVerifyRequest true "Challenge token and code"
+// @Success 200 {object} dto.AuthResponse "Successfully authenticated"
+// @Failure 400 {object} dto.ErrorResponse "Invalid input"
+// @Failure 401 {object} dto.ErrorResponse "Invalid code or challenge"
+// @Failure 429 {object} dto.ErrorResponse "Too many failed logins, see the Retry-After header"
+// @Router /auth/mfa/verify [post]
+func (a *AuthController) verifyMFAHandler(ctx *gin.Context) {
+	var payload dto.MFAVerifyRequestDto
+
+	a.log.Info("Starting to verify a second factor in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := a.authUsecase.VerifyMFA(payload, ctx.ClientIP())
+	if err != nil {
+		a.log.Error("Failed to verify the second factor: ", err)
+		sendLoginError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, token)
+}
+
+// EnrollMFA godoc
+// @Summary Enroll second factor during login
+// @Description Start the TOTP enrollment a role requires, with the challenge token of the login
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.MFAChallengeRequest true "Challenge token"
+// @Success 200 {object} entity.MFAEnrollment "Secret and provisioning URI"
+// @Failure 400 {object} dto.ErrorResponse "Invalid input"
+// @Failure 401 {object} dto.ErrorResponse "Invalid challenge"
+// @Failure 409 {object} dto.ErrorResponse "Already enabled"
+// @Router /auth/mfa/enroll [post]
+func (a *AuthController) enrollMFAHandler(ctx *gin.Context) {
+	var payload dto.MFAChallengeRequestDto
+
+	a.log.Info("Starting to enroll a second factor in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := a.authUsecase.EnrollMFA(payload.ChallengeToken)
+	if err != nil {
+		a.log.Error("Failed to enroll the second factor: ", err)
+		sendLoginError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// sendLoginError responds to a failed login step. Unexpected failures are not detailed to the client.
+func sendLoginError(ctx *gin.Context, err error) {
+	var blocked *usecase.LoginBlockedError
+	switch {
+	case errors.As(err, &blocked):
+		retryAfter := math.Ceil(time.Until(blocked.Until).Seconds())
+		ctx.Header("Retry-After", strconv.Itoa(max(int(retryAfter), 1)))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, usecase.ErrInvalidCredentials):
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case mfaErrorStatus(err) != http.StatusInternalServerError:
+		ctx.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+	}
+}
+
+// UnlockUser godoc
+// @Summary Unlock user
+// @Description Lift the lockout of a user after too many failed logins
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} dto.ErrorResponse "User unlocked"
+// @Failure 401 {object} dto.ErrorResponse "Unauthorized"
+// @Failure 404 {object} dto.ErrorResponse "User not found"
+// @Router /user/{id}/unlock [post]
+func (a *AuthController) unlockHandler(ctx *gin.Context) {
+	a.log.Info("Starting to unlock a user in the handler layer", nil)
+
+	if err := a.authUsecase.UnlockUser(ctx.Param("id"), auditActor(ctx)); err != nil {
+		a.log.Error("Failed to unlock user: ", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
 func (a *AuthController) Route() {
 	a.rg.POST(config.Login, a.loginHandler)
 	a.rg.POST(config.Register, a.registerHandler)
+	a.rg.POST(config.Refresh, a.refreshHandler)
+	a.rg.POST(config.Logout, a.logoutHandler)
+	a.rg.POST(config.VerifyMFA, a.verifyMFAHandler)
+	a.rg.POST(config.EnrollMFA, a.enrollMFAHandler)
+	a.rg.POST(config.UnlockUser, a.authMiddleware.RequirePermission(entity.PermUserManage), a.unlockHandler)
 }
 
-func NewAuthController(authUc usecase.AuthUseCase, rg *gin.RouterGroup, log *logger.Logger) *AuthController {
-	return &AuthController{authUsecase: authUc, rg: rg, log: log}
+func NewAuthController(authUc usecase.AuthUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *AuthController {
+	return &AuthController{authUsecase: authUc, authMiddleware: authMiddleware, rg: rg, log: log}
 }
@@ -8,10 +8,12 @@ import (
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/entity/dto"
 	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/mock/middleware_mock"
 	"server-pulsa-app/internal/mock/usecase_mock"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -31,7 +33,7 @@ func (a *AuthHandlerTest) SetupTest() {
 
 	rg := a.router.Group("/api/v1")
 
-	a.AuthController = NewAuthController(a.authUc, rg, a.log)
+	a.AuthController = NewAuthController(a.authUc, new(middleware_mock.AuthMiddlewareMock), rg, a.log)
 
 	a.AuthController.Route()
 }
@@ -57,7 +59,7 @@ func (a *AuthHandlerTest) TestLogin() {
 
 func (a *AuthHandlerTest) TestRegister() {
 	user := entity.User{Username: "testuser", Password: "password"}
-	a.authUc.On("Register", user).Return(user, nil)
+	a.authUc.On("Register", user, mock.Anything).Return(user, nil)
 
 	request, err := http.NewRequest("POST", "/auth/register", bytes.NewBuffer([]byte(`{"username": "testuser", "password": "password"}`)))
 	if err != nil {
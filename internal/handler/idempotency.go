@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+// idempotencyKey reads the Idempotency-Key header. Keys are scoped per endpoint and per user
+// so two cashiers can never collide on the same key.
+func idempotencyKey(ctx *gin.Context, endpoint string) (entity.IdempotencyKey, bool) {
+	key := strings.TrimSpace(ctx.GetHeader(idempotencyKeyHeader))
+	if key == "" {
+		return entity.IdempotencyKey{}, false
+	}
+
+	return entity.IdempotencyKey{
+		Key:   key,
+		Scope: endpoint + ":" + ctx.GetString("employee"),
+	}, true
+}
+
+// idempotencyErrorStatus maps idempotency errors to their HTTP status, falling back to fallback.
+func idempotencyErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, usecase.ErrIdempotencyKeyInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrIdempotencyKeyMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, usecase.ErrIdempotencyKeyInProgress):
+		return http.StatusConflict
+	default:
+		return fallback
+	}
+}
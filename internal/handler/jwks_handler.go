@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/shared/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JwksHandler struct {
+	jwtService service.JwtService
+	rg         *gin.RouterGroup
+}
+
+// GetJwks godoc
+// @Summary Get token signing keys
+// @Description Public keys the access tokens are signed with, as a JSON Web Key Set. Tokens name their key in the kid header
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.JWKSet "Key set"
+// @Router /.well-known/jwks.json [get]
+func (j *JwksHandler) GetJwks(c *gin.Context) {
+	// verifiers fetch the set again when a token names an unknown kid, so it can be cached for a while
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, j.jwtService.JWKS())
+}
+
+func (j *JwksHandler) Route() {
+	j.rg.GET(config.Jwks, j.GetJwks)
+}
+
+func NewJwksHandler(jwtService service.JwtService, rg *gin.RouterGroup) *JwksHandler {
+	return &JwksHandler{jwtService: jwtService, rg: rg}
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/shared/model"
 	"server-pulsa-app/internal/usecase"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -51,7 +55,7 @@ func (m *MerchantHandler) createHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, response)
 		return
 	}
-	merchant, err := m.merchantUc.RegisterNewMerchant(payload)
+	merchant, err := m.merchantUc.RegisterNewMerchant(payload, auditActor(ctx))
 	if err != nil {
 		response := struct {
 			Message string
@@ -208,7 +212,7 @@ func (m *MerchantHandler) updateHandler(ctx *gin.Context) {
 
 	payload.IdMerchant = id
 
-	merchant, err := m.merchantUc.UpdateMerchant(payload)
+	merchant, err := m.merchantUc.UpdateMerchant(payload, auditActor(ctx))
 	if err != nil {
 		response := struct {
 			Message string
@@ -245,13 +249,24 @@ func (m *MerchantHandler) updateHandler(ctx *gin.Context) {
 // @Success 204 "Successfully deleted"
 // @Failure 401 {object} entity.MerchantErrorResponse "Unauthorized"
 // @Failure 404 {object} entity.MerchantErrorResponse "Merchant not found"
+// @Failure 409 {object} entity.MerchantErrorResponse "Merchant still has transactions, topups or balance mutations"
 // @Router /merchant/{id} [delete]
 func (m *MerchantHandler) deleteHandler(ctx *gin.Context) {
 	id := ctx.Param("id")
 
 	m.log.Info("Starting to delete merchant with id in the handler layer", nil)
-	err := m.merchantUc.DeleteMerchant(id)
-	if err != nil {
+	err := m.merchantUc.DeleteMerchant(id, auditActor(ctx))
+	if errors.Is(err, repository.ErrMerchantInUse) {
+		response := struct {
+			Message string
+		}{
+			Message: "Merchant of Id " + id + " can't be deleted: " + err.Error(),
+		}
+
+		m.log.Error("Merchant is still in use: ", response)
+		ctx.JSON(http.StatusConflict, response)
+		return
+	} else if err != nil {
 		response := struct {
 			Message string
 			Data    entity.Merchant
@@ -274,12 +289,152 @@ func (m *MerchantHandler) deleteHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
+// ListMerchantMutations godoc
+// @Summary List merchant balance mutations
+// @Description Page through the balance ledger (mutasi saldo) of a merchant, newest first
+// @Tags merchants
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Merchant ID"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {array} []entity.LedgerEntry "List of mutations"
+// @Failure 401 {object} entity.MerchantErrorResponse "Unauthorized"
+// @Failure 404 {object} entity.MerchantErrorResponse "Merchant not found"
+// @Router /merchant/{id}/mutations [get]
+func (m *MerchantHandler) mutationsHandler(ctx *gin.Context) {
+	m.listMutations(ctx, m.merchantUc.FindMutations)
+}
+
+// ListOwnMerchantMutations godoc
+// @Summary List own merchant balance mutations
+// @Description Page through the balance ledger (mutasi saldo) of a merchant of the logged in user, newest first
+// @Tags merchants
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Merchant ID"
+// @Param page query int false "Page number"
+// @Param limit query int false "Items per page"
+// @Success 200 {array} []entity.LedgerEntry "List of mutations"
+// @Failure 401 {object} entity.MerchantErrorResponse "Unauthorized"
+// @Failure 404 {object} entity.MerchantErrorResponse "Merchant not found"
+// @Router /me/merchant/{id}/mutations [get]
+func (m *MerchantHandler) ownMutationsHandler(ctx *gin.Context) {
+	m.listMutations(ctx, func(id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error) {
+		return m.merchantUc.FindOwnMutations(ctx.GetString("employee"), id, page, limit)
+	})
+}
+
+func (m *MerchantHandler) listMutations(ctx *gin.Context, find func(id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error)) {
+	id := ctx.Param("id")
+	page, _ := strconv.Atoi(ctx.Query("page"))
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+
+	m.log.Info("Starting to retrieve merchant mutations in the handler layer", nil)
+	mutations, paging, err := find(id, page, limit)
+	if err != nil {
+		response := struct {
+			Message string
+			Data    []entity.LedgerEntry
+		}{
+			Message: err.Error(),
+			Data:    []entity.LedgerEntry{},
+		}
+
+		m.log.Error("Merchant mutations not found: ", response)
+		ctx.JSON(http.StatusNotFound, response)
+		return
+	}
+
+	response := struct {
+		Message string
+		Data    []entity.LedgerEntry
+		Paging  model.Paging
+	}{
+		Message: "Merchant Mutations Found",
+		Data:    mutations,
+		Paging:  paging,
+	}
+
+	m.log.Info("Merchant mutations found successfully", nil)
+	ctx.JSON(http.StatusOK, response)
+}
+
+// AdjustMerchantBalance godoc
+// @Summary Adjust merchant balance
+// @Description Credit (positive amount) or debit (negative amount) a merchant balance with a ledger entry
+// @Tags merchants
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Merchant ID"
+// @Param request body entity.BalanceAdjustmentRequest true "Adjustment details"
+// @Success 201 {object} entity.LedgerEntry "Successfully adjusted"
+// @Failure 400 {object} entity.MerchantErrorResponse "Invalid input"
+// @Failure 401 {object} entity.MerchantErrorResponse "Unauthorized"
+// @Router /merchant/{id}/adjustment [post]
+func (m *MerchantHandler) adjustmentHandler(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var payload entity.BalanceAdjustmentRequest
+
+	m.log.Info("Starting to adjust merchant balance in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		response := struct {
+			Message string
+			Data    entity.LedgerEntry
+		}{
+			Message: "Invalid Payload for Balance Adjustment",
+			Data:    entity.LedgerEntry{},
+		}
+
+		m.log.Error("Invalid payload for balance adjustment: ", err)
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	entry, err := m.merchantUc.AdjustBalance(entity.LedgerEntry{
+		IdMerchant:  id,
+		Amount:      payload.Amount,
+		Description: payload.Description,
+		CreatedBy:   ctx.GetString("employee"),
+	}, auditActor(ctx))
+	if err != nil {
+		response := struct {
+			Message string
+			Data    entity.LedgerEntry
+		}{
+			Message: err.Error(),
+			Data:    entity.LedgerEntry{},
+		}
+
+		m.log.Error("Balance adjustment failed", response)
+		ctx.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	response := struct {
+		Message string
+		Data    entity.LedgerEntry
+	}{
+		Message: "Merchant Balance Adjusted",
+		Data:    entry,
+	}
+
+	m.log.Info("Merchant balance adjusted successfully", response)
+	ctx.JSON(http.StatusCreated, response)
+}
+
 func (m *MerchantHandler) Route() {
-	m.rg.POST(config.PostMerchant, m.authMiddleware.RequireToken("admin"), m.createHandler)
-	m.rg.GET(config.GetMerchantList, m.authMiddleware.RequireToken("admin"), m.listHandler)
-	m.rg.GET(config.GetMerchant, m.authMiddleware.RequireToken("admin"), m.getHandler)
-	m.rg.PUT(config.PutMerchant, m.authMiddleware.RequireToken("admin"), m.updateHandler)
-	m.rg.DELETE(config.DeleteMerchant, m.authMiddleware.RequireToken("admin"), m.deleteHandler)
+	m.rg.POST(config.PostMerchant, m.authMiddleware.RequirePermission(entity.PermMerchantManage), m.createHandler)
+	m.rg.GET(config.GetMerchantList, m.authMiddleware.RequirePermission(entity.PermMerchantManage), m.listHandler)
+	m.rg.GET(config.GetMerchant, m.authMiddleware.RequirePermission(entity.PermMerchantManage), m.getHandler)
+	m.rg.PUT(config.PutMerchant, m.authMiddleware.RequirePermission(entity.PermMerchantManage), m.updateHandler)
+	m.rg.DELETE(config.DeleteMerchant, m.authMiddleware.RequirePermission(entity.PermMerchantManage), m.deleteHandler)
+	m.rg.GET(config.GetMerchantMutations, m.authMiddleware.RequirePermission(entity.PermMerchantManage), m.mutationsHandler)
+	m.rg.GET(config.GetOwnMerchantMutations, m.authMiddleware.RequirePermission(entity.PermMerchantOwn), m.ownMutationsHandler)
+	m.rg.POST(config.PostMerchantAdjustment, m.authMiddleware.RequirePermission(entity.PermMerchantManage), m.adjustmentHandler)
 }
 
 func NewMerchantHandler(merchantUc usecase.MerchantUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *MerchantHandler {
@@ -9,9 +9,11 @@ import (
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/mock/middleware_mock"
 	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/repository"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -55,7 +57,7 @@ func (m *MerchantHandlerTest) TestCreate() {
 	if err != nil {
 		m.T().Fatalf("error '%s' occured when marshaling the payload", err)
 	}
-	m.merchantUc.On("RegisterNewMerchant", payload).Return(payload, nil)
+	m.merchantUc.On("RegisterNewMerchant", payload, mock.Anything).Return(payload, nil)
 	request, err := http.NewRequest("POST", "/api/v1/merchant", bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		m.T().Fatalf("error '%s' occured when creating the request", err)
@@ -80,7 +82,7 @@ func (m *MerchantHandlerTest) TestUpdate() {
 	if err != nil {
 		m.T().Fatalf("error '%s' occured when marshaling the payload", err)
 	}
-	m.merchantUc.On("UpdateMerchant", payload).Return(payload, nil)
+	m.merchantUc.On("UpdateMerchant", payload, mock.Anything).Return(payload, nil)
 	request, err := http.NewRequest("PUT", "/api/v1/merchant/"+payload.IdMerchant, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		m.T().Fatalf("error '%s' occured when creating the request", err)
@@ -121,7 +123,7 @@ func (m *MerchantHandlerTest) TestGet() {
 
 func (m *MerchantHandlerTest) TestDelete() {
 	id := "uuid-merchant-test"
-	m.merchantUc.On("DeleteMerchant", id).Return(nil)
+	m.merchantUc.On("DeleteMerchant", id, mock.Anything).Return(nil)
 	request, err := http.NewRequest("DELETE", "/api/v1/merchant/"+id, nil)
 	if err != nil {
 		m.T().Fatalf("error '%s' occured when creating the request", err)
@@ -133,6 +135,20 @@ func (m *MerchantHandlerTest) TestDelete() {
 	m.Equal(http.StatusOK, w.Code)
 }
 
+func (m *MerchantHandlerTest) TestDelete_InUse() {
+	id := "uuid-merchant-test"
+	m.merchantUc.On("DeleteMerchant", id, mock.Anything).Return(repository.ErrMerchantInUse)
+	request, err := http.NewRequest("DELETE", "/api/v1/merchant/"+id, nil)
+	if err != nil {
+		m.T().Fatalf("error '%s' occured when creating the request", err)
+	}
+
+	w := httptest.NewRecorder()
+	m.router.ServeHTTP(w, request)
+
+	m.Equal(http.StatusConflict, w.Code)
+}
+
 func TestMerchantHandlerSuite(t *testing.T) {
 	suite.Run(t, new(MerchantHandlerTest))
 }
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"errors"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/shared/common"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	usecase        usecase.MFAUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
+}
+
+// mfaErrorStatus maps a two factor authentication failure to its response status.
+func mfaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrMFANotEnabled):
+		return 400
+	case errors.Is(err, usecase.ErrInvalidMFACode), errors.Is(err, usecase.ErrInvalidMFAChallenge):
+		return 401
+	case errors.Is(err, usecase.ErrMFARequired):
+		return 403
+	case errors.Is(err, usecase.ErrUserNotFound):
+		return 404
+	case errors.Is(err, usecase.ErrMFAAlreadyEnabled):
+		return 409
+	}
+	return 500
+}
+
+// GetMyMFA godoc
+// @Summary Get my two factor authentication
+// @Description Whether the logged in user enabled a second factor and whether the role requires one
+// @Tags account
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} entity.UserMFA "Two factor status"
+// @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Router /me/mfa [get]
+func (m *MFAHandler) GetMyMFA(c *gin.Context) {
+	mfa, err := m.usecase.Status(c.GetString("employee"))
+	if err != nil {
+		m.log.Error("Error getting the mfa status: ", err)
+		common.SendErrorResponse(c, mfaErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, mfa, "Two factor authentication")
+}
+
+// EnrollMyMFA godoc
+// @Summary Enroll two factor authentication
+// @Description Start a TOTP enrollment. Scan the provisioning URI as a QR code and confirm it with a first code
+// @Tags account
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} entity.MFAEnrollment "Secret and provisioning URI"
+// @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Failure 409 {object} entity.UserErrorResponse "Already enabled"
+// @Router /me/mfa/enroll [post]
+func (m *MFAHandler) EnrollMyMFA(c *gin.Context) {
+	m.log.Info("Starting to enroll a second factor in the handler layer", nil)
+
+	enrollment, err := m.usecase.Enroll(c.GetString("employee"))
+	if err != nil {
+		m.log.Error("Error enrolling the second factor: ", err)
+		common.SendErrorResponse(c, mfaErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, enrollment, "Confirm the enrollment with a code")
+}
+
+// ConfirmMyMFA godoc
+// @Summary Confirm two factor authentication
+// @Description Enable the enrolled TOTP secret with a first code. The recovery codes are only shown once
+// @Tags account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body entity.MFACodeRequest true "TOTP code"
+// @Success 200 {object} entity.MFARecoveryCodes "Recovery codes"
+// @Failure 400 {object} entity.UserErrorResponse "Not enrolled"
+// @Failure 401 {object} entity.UserErrorResponse "Invalid code"
+// @Failure 409 {object} entity.UserErrorResponse "Already enabled"
+// @Router /me/mfa/confirm [post]
+func (m *MFAHandler) ConfirmMyMFA(c *gin.Context) {
+	var payload entity.MFACodeRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	m.log.Info("Starting to confirm a second factor in the handler layer", nil)
+	codes, err := m.usecase.Confirm(c.GetString("employee"), payload.Code)
+	if err != nil {
+		m.log.Error("Error confirming the second factor: ", err)
+		common.SendErrorResponse(c, mfaErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, entity.MFARecoveryCodes{RecoveryCodes: codes}, "Two factor authentication enabled")
+}
+
+// DisableMyMFA godoc
+// @Summary Disable two factor authentication
+// @Description Turn off the second factor with a TOTP or recovery code, unless the role requires it
+// @Tags account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body entity.MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} object "Disabled"
+// @Failure 401 {object} entity.UserErrorResponse "Invalid code"
+// @Failure 403 {object} entity.UserErrorResponse "Required by the role"
+// @Router /me/mfa/disable [post]
+func (m *MFAHandler) DisableMyMFA(c *gin.Context) {
+	var payload entity.MFACodeRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	m.log.Info("Starting to disable a second factor in the handler layer", nil)
+	if err := m.usecase.Disable(c.GetString("employee"), payload.Code); err != nil {
+		m.log.Error("Error disabling the second factor: ", err)
+		common.SendErrorResponse(c, mfaErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, nil, "Two factor authentication disabled")
+}
+
+// ResetUserMFA godoc
+// @Summary Reset two factor authentication of a user
+// @Description Remove the second factor of a user who lost it
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} object "Reset"
+// @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Failure 404 {object} entity.UserErrorResponse "User not found"
+// @Router /user/{id}/mfa [delete]
+func (m *MFAHandler) ResetUserMFA(c *gin.Context) {
+	m.log.Info("Starting to reset a second factor in the handler layer", c.Param("id"))
+	if err := m.usecase.Reset(c.Param("id"), auditActor(c)); err != nil {
+		m.log.Error("Error resetting the second factor: ", err)
+		common.SendErrorResponse(c, mfaErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, gin.H{"id_user": c.Param("id")}, "Two factor authentication reset")
+}
+
+func (m *MFAHandler) Route() {
+	m.rg.GET(config.GetMyMFA, m.authMiddleware.RequirePermission(), m.GetMyMFA)
+	m.rg.POST(config.EnrollMyMFA, m.authMiddleware.RequirePermission(), m.EnrollMyMFA)
+	m.rg.POST(config.ConfirmMyMFA, m.authMiddleware.RequirePermission(), m.ConfirmMyMFA)
+	m.rg.POST(config.DisableMyMFA, m.authMiddleware.RequirePermission(), m.DisableMyMFA)
+	m.rg.DELETE(config.ResetUserMFA, m.authMiddleware.RequirePermission(entity.PermUserManage), m.ResetUserMFA)
+}
+
+func NewMFAHandler(usecase usecase.MFAUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *MFAHandler {
+	return &MFAHandler{usecase: usecase, authMiddleware: authMiddleware, rg: rg, log: log}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @title Operator Prefix API
+// @version 1.0
+// @description Admin endpoints to maintain the destination number prefix of each operator
+type OperatorPrefixHandler struct {
+	usecase        usecase.OperatorPrefixUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
+}
+
+// ListOperatorPrefixes godoc
+// @Summary List operator prefixes
+// @Description Get every destination number prefix with its operator
+// @Tags operator prefixes
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} []entity.OperatorPrefix "List of operator prefixes"
+// @Failure 401 {object} entity.OperatorPrefixErrorResponse "Unauthorized"
+// @Router /operator-prefixes [get]
+func (h *OperatorPrefixHandler) listHandler(ctx *gin.Context) {
+	h.log.Info("Starting to get operator prefixes in the handler layer", nil)
+
+	prefixes, err := h.usecase.FindAll()
+	if err != nil {
+		h.log.Error("failed to retrieve operator prefixes", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Message string                  `json:"message"`
+		Data    []entity.OperatorPrefix `json:"data"`
+	}{
+		Message: "Operator prefix list",
+		Data:    prefixes,
+	})
+}
+
+// SaveOperatorPrefix godoc
+// @Summary Create or update an operator prefix
+// @Description Assign a destination number prefix (08xx, 628xx or +628xx form) to an operator: Telkomsel, Indosat, XL, Axis, Tri or Smartfren, under any of their brand names
+// @Tags operator prefixes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param prefix path string true "Number prefix"
+// @Param request body entity.OperatorPrefixRequest true "Operator"
+// @Success 200 {object} entity.OperatorPrefix "Saved operator prefix"
+// @Failure 400 {object} entity.OperatorPrefixErrorResponse "Invalid input"
+// @Failure 401 {object} entity.OperatorPrefixErrorResponse "Unauthorized"
+// @Router /operator-prefix/{prefix} [put]
+func (h *OperatorPrefixHandler) saveHandler(ctx *gin.Context) {
+	var payload entity.OperatorPrefixRequest
+
+	h.log.Info("Starting to save an operator prefix in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		h.log.Error("invalid payload for operator prefix", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefix, err := h.usecase.Save(ctx.Param("prefix"), payload.Operator)
+	if err != nil {
+		h.log.Error("failed to save operator prefix", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrInvalidDestinationNumber) || errors.Is(err, usecase.ErrInvalidOperator) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Message string                `json:"message"`
+		Data    entity.OperatorPrefix `json:"data"`
+	}{
+		Message: "Operator prefix saved",
+		Data:    prefix,
+	})
+}
+
+// DeleteOperatorPrefix godoc
+// @Summary Delete an operator prefix
+// @Description Remove a destination number prefix, numbers starting with it are no longer accepted
+// @Tags operator prefixes
+// @Produce json
+// @Security BearerAuth
+// @Param prefix path string true "Number prefix"
+// @Success 200 {object} entity.OperatorPrefixErrorResponse "Operator prefix deleted"
+// @Failure 404 {object} entity.OperatorPrefixErrorResponse "Operator prefix not found"
+// @Failure 401 {object} entity.OperatorPrefixErrorResponse "Unauthorized"
+// @Router /operator-prefix/{prefix} [delete]
+func (h *OperatorPrefixHandler) deleteHandler(ctx *gin.Context) {
+	h.log.Info("Starting to delete an operator prefix in the handler layer", nil)
+
+	if err := h.usecase.Delete(ctx.Param("prefix")); err != nil {
+		h.log.Error("failed to delete operator prefix", err)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, usecase.ErrInvalidDestinationNumber):
+			status = http.StatusBadRequest
+		case errors.Is(err, usecase.ErrOperatorPrefixNotFound):
+			status = http.StatusNotFound
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Operator prefix deleted"})
+}
+
+func (h *OperatorPrefixHandler) Route() {
+	h.rg.GET(config.GetOperatorPrefixList, h.authMiddleware.RequirePermission(entity.PermOperatorManage), h.listHandler)
+	h.rg.PUT(config.PutOperatorPrefix, h.authMiddleware.RequirePermission(entity.PermOperatorManage), h.saveHandler)
+	h.rg.DELETE(config.DeleteOperatorPrefix, h.authMiddleware.RequirePermission(entity.PermOperatorManage), h.deleteHandler)
+}
+
+func NewOperatorPrefixHandler(usecase usecase.OperatorPrefixUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *OperatorPrefixHandler {
+	return &OperatorPrefixHandler{usecase: usecase, authMiddleware: authMiddleware, rg: rg, log: log}
+}
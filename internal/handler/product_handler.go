@@ -23,11 +23,11 @@ type ProductController struct {
 }
 
 func (p *ProductController) Route() {
-	p.rg.POST(config.PostProduct, p.authMiddleware.RequireToken("admin"), p.CreateProduct)
-	p.rg.GET(config.GetProductList, p.authMiddleware.RequireToken("admin"), p.GetAllProduct)
-	p.rg.GET(config.GetProduct, p.authMiddleware.RequireToken("admin"), p.GetProductById)
-	p.rg.PUT(config.PutProduct, p.authMiddleware.RequireToken("admin"), p.UpdateProduct)
-	p.rg.DELETE(config.DeleteProduct, p.authMiddleware.RequireToken("admin"), p.DeleteProduct)
+	p.rg.POST(config.PostProduct, p.authMiddleware.RequirePermission(entity.PermProductManage), p.CreateProduct)
+	p.rg.GET(config.GetProductList, p.authMiddleware.RequirePermission(entity.PermProductManage), p.GetAllProduct)
+	p.rg.GET(config.GetProduct, p.authMiddleware.RequirePermission(entity.PermProductManage), p.GetProductById)
+	p.rg.PUT(config.PutProduct, p.authMiddleware.RequirePermission(entity.PermProductManage), p.UpdateProduct)
+	p.rg.DELETE(config.DeleteProduct, p.authMiddleware.RequirePermission(entity.PermProductManage), p.DeleteProduct)
 }
 
 // CreateProduct godoc
@@ -53,7 +53,7 @@ func (p *ProductController) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	Product, err := p.useCase.CreateNewProduct(payload)
+	Product, err := p.useCase.CreateNewProduct(payload, auditActor(c))
 	if err != nil {
 		p.log.Error("Product creation failed", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"err": err.Error()})
@@ -176,7 +176,7 @@ func (p *ProductController) UpdateProduct(c *gin.Context) {
 	payload.IdProduct = id
 
 	p.log.Info("Updating product ID %s", id)
-	product, err := p.useCase.UpdateProduct(payload)
+	product, err := p.useCase.UpdateProduct(payload, auditActor(c))
 	if err != nil {
 
 		c.JSON(http.StatusInternalServerError, gin.H{"err": err.Error()})
@@ -210,7 +210,7 @@ func (p *ProductController) DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
 
 	p.log.Info("Starting to delete product with id in the handler layer", nil)
-	err := p.useCase.DeleteProduct(id)
+	err := p.useCase.DeleteProduct(id, auditActor(c))
 	if err != nil {
 		p.log.Error("Product ID %s not found: ", id)
 		c.JSON(http.StatusNotFound, err.Error())
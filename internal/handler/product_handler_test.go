@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	testifymock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -54,7 +55,7 @@ func (suite *ProductControllerTestSuite) TestCreateProduct() {
 		panic(err)
 	}
 
-	suite.mockProductUC.On("CreateNewProduct", payload).Return(payload, nil)
+	suite.mockProductUC.On("CreateNewProduct", payload, testifymock.Anything).Return(payload, nil)
 
 	req, err := http.NewRequest("POST", "/api/v1/product", bytes.NewBuffer(jsonPayload))
 
@@ -99,7 +100,7 @@ func (suite *ProductControllerTestSuite) TestUpdateProduct() {
 		IdSupliyer:   "1",
 	}
 
-	suite.mockProductUC.On("UpdateProduct", payload).Return(payload, nil)
+	suite.mockProductUC.On("UpdateProduct", payload, testifymock.Anything).Return(payload, nil)
 
 	jsonPayload, err := json.Marshal(payload)
 
@@ -124,7 +125,7 @@ func (suite *ProductControllerTestSuite) TestDeleteProduct() {
 	id := "1"
 	intID := "1"
 
-	suite.mockProductUC.On("DeleteProduct", intID).Return(nil)
+	suite.mockProductUC.On("DeleteProduct", intID, testifymock.Anything).Return(nil)
 
 	req, err := http.NewRequest("DELETE", "/api/v1/product/"+id, nil)
 
@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/middleware"
 	"server-pulsa-app/internal/shared/custom"
@@ -46,8 +48,46 @@ func (r *ReportHandler) listHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
+// ProfitReport godoc
+// @Summary Profit report
+// @Description Gross sales, cost and profit of successful sales per merchant, provider and day
+// @Tags reports
+// @Produce json
+// @Security BearerAuth
+// @Param startDate query string true "First day (dd-mm-yyyy)"
+// @Param endDate query string true "Last day (dd-mm-yyyy)"
+// @Param merchantId query string false "Only report this merchant"
+// @Success 200 {object} custom.ProfitSummary "Profit report"
+// @Failure 400 {object} entity.TransactionErrorResponse "Invalid period"
+// @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Router /report/profit [get]
+func (r *ReportHandler) profitHandler(ctx *gin.Context) {
+	r.log.Info("Starting to retrieve profit report in the handler layer", nil)
+
+	summary, err := r.reportUc.Profit(ctx.Query("startDate"), ctx.Query("endDate"), ctx.Query("merchantId"))
+	if err != nil {
+		r.log.Error("Failed to retrieve profit report", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, usecase.ErrInvalidReportPeriod) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := struct {
+		Message string
+		Data    custom.ProfitSummary
+	}{
+		Message: "Profit Report",
+		Data:    summary,
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
 func (m *ReportHandler) Route() {
-	m.rg.GET(config.GetReport, m.authMiddleware.RequireToken("employee"), m.listHandler)
+	m.rg.GET(config.GetReport, m.authMiddleware.RequirePermission(entity.PermReportRead), m.listHandler)
+	m.rg.GET(config.GetProfitReport, m.authMiddleware.RequirePermission(entity.PermReportProfit), m.profitHandler)
 }
 
 func NewReportHandler(reportUc usecase.ReportUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *ReportHandler {
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/shared/common"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	usecase        usecase.RoleUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
+}
+
+// roleErrorStatus maps a role usecase failure to its response status.
+func roleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidRole):
+		return 400
+	case errors.Is(err, usecase.ErrRoleNotFound):
+		return 404
+	case errors.Is(err, usecase.ErrBuiltInRole), errors.Is(err, usecase.ErrRoleConflict):
+		return 409
+	}
+	return 500
+}
+
+func (r *RoleHandler) ListPermissions(c *gin.Context) {
+	common.SendSingleResponseOk(c, r.usecase.ListPermissions(), "Permissions")
+}
+
+func (r *RoleHandler) ListRoles(c *gin.Context) {
+	r.log.Info("Starting to get the roles in the handler layer", nil)
+	roles, err := r.usecase.ListRoles()
+	if err != nil {
+		r.log.Error("Error getting the roles: ", err)
+		common.SendErrorResponse(c, 500, err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, roles, "Roles")
+}
+
+func (r *RoleHandler) CreateRole(c *gin.Context) {
+	var payload entity.RoleRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	r.log.Info("Starting to create a role in the handler layer", payload)
+	role, err := r.usecase.CreateRole(payload, auditActor(c))
+	if err != nil {
+		r.log.Error("Error creating the role: ", err)
+		common.SendErrorResponse(c, roleErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseCreated(c, role, "Role created")
+}
+
+func (r *RoleHandler) UpdateRole(c *gin.Context) {
+	var payload entity.RoleRequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	r.log.Info("Starting to update a role in the handler layer", c.Param("name"))
+	role, err := r.usecase.UpdateRole(c.Param("name"), payload, auditActor(c))
+	if err != nil {
+		r.log.Error("Error updating the role: ", err)
+		common.SendErrorResponse(c, roleErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, role, "Role updated")
+}
+
+func (r *RoleHandler) DeleteRole(c *gin.Context) {
+	r.log.Info("Starting to delete a role in the handler layer", c.Param("name"))
+	if err := r.usecase.DeleteRole(c.Param("name"), auditActor(c)); err != nil {
+		r.log.Error("Error deleting the role: ", err)
+		common.SendErrorResponse(c, roleErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, gin.H{"name": c.Param("name")}, "Role deleted")
+}
+
+func (r *RoleHandler) SetRoleMFA(c *gin.Context) {
+	var payload entity.RoleMFARequest
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	r.log.Info("Starting to set the mfa requirement of a role in the handler layer", c.Param("name"))
+	role, err := r.usecase.SetRoleMFA(c.Param("name"), *payload.Required, auditActor(c))
+	if err != nil {
+		r.log.Error("Error setting the mfa requirement: ", err)
+		common.SendErrorResponse(c, roleErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, role, "Role mfa requirement updated")
+}
+
+func (r *RoleHandler) Route() {
+	r.rg.GET(config.GetPermissionList, r.authMiddleware.RequirePermission(entity.PermRoleManage), r.ListPermissions)
+	r.rg.GET(config.GetRoleList, r.authMiddleware.RequirePermission(entity.PermRoleManage), r.ListRoles)
+	r.rg.POST(config.PostRole, r.authMiddleware.RequirePermission(entity.PermRoleManage), r.CreateRole)
+	r.rg.PUT(config.PutRole, r.authMiddleware.RequirePermission(entity.PermRoleManage), r.UpdateRole)
+	r.rg.DELETE(config.DeleteRole, r.authMiddleware.RequirePermission(entity.PermRoleManage), r.DeleteRole)
+	r.rg.PUT(config.PutRoleMFA, r.authMiddleware.RequirePermission(entity.PermRoleManage), r.SetRoleMFA)
+}
+
+func NewRoleHandler(usecase usecase.RoleUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *RoleHandler {
+	return &RoleHandler{usecase: usecase, authMiddleware: authMiddleware, rg: rg, log: log}
+}
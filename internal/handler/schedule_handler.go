@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @title Transaction Schedule API
+// @version 1.0
+// @description Recurring transactions for regular customers
+type ScheduleHandler struct {
+	usecase        usecase.ScheduleUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
+}
+
+// scheduleErrorStatus maps schedule errors to their HTTP status, falling back to fallback.
+func scheduleErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, usecase.ErrScheduleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecase.ErrInvalidRecurrence), errors.Is(err, usecase.ErrInvalidDestinationNumber),
+		errors.Is(err, usecase.ErrTopupMerchantRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrTopupMerchantForbidden):
+		return http.StatusForbidden
+	default:
+		return fallback
+	}
+}
+
+// CreateSchedule godoc
+// @Summary Create a transaction schedule
+// @Description Send a product to a customer on every occurrence of a five field cron expression, e.g. "0 8 1 * *" for 08:00 on the 1st of every month
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body entity.TransactionScheduleReq true "Schedule details"
+// @Success 201 {object} entity.TransactionSchedule "Schedule created"
+// @Failure 400 {object} entity.TransactionErrorResponse "Invalid input"
+// @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Failure 403 {object} entity.TransactionErrorResponse "Merchant not owned by the user"
+// @Router /schedule [post]
+func (h *ScheduleHandler) createHandler(ctx *gin.Context) {
+	var payload entity.TransactionScheduleReq
+
+	h.log.Info("Starting to create a new transaction schedule in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		h.log.Error("invalid payload for transaction schedule", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.usecase.Create(entity.TransactionSchedule{
+		MerchantId:        payload.MerchantId,
+		UserId:            ctx.GetString("employee"),
+		CustomerName:      payload.CustomerName,
+		DestinationNumber: payload.DestinationNumber,
+		ProductId:         payload.ProductId,
+		Recurrence:        payload.Recurrence,
+	})
+	if err != nil {
+		h.log.Error("failed to create a transaction schedule", err)
+		ctx.JSON(scheduleErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, struct {
+		Message string                     `json:"message"`
+		Data    entity.TransactionSchedule `json:"data"`
+	}{
+		Message: "Schedule Created",
+		Data:    schedule,
+	})
+}
+
+// ListSchedules godoc
+// @Summary List transaction schedules
+// @Description Get the transaction schedules created by the logged in user for the merchants the user owns
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} []entity.TransactionSchedule "List of schedules"
+// @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Router /schedules [get]
+func (h *ScheduleHandler) listHandler(ctx *gin.Context) {
+	h.log.Info("Starting to get transaction schedules in the handler layer", nil)
+
+	schedules, err := h.usecase.FindAll(ctx.GetString("employee"))
+	if err != nil {
+		h.log.Error("failed to retrieve transaction schedules", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Message string                       `json:"message"`
+		Data    []entity.TransactionSchedule `json:"data"`
+	}{
+		Message: "Schedule list",
+		Data:    schedules,
+	})
+}
+
+// ListScheduleRuns godoc
+// @Summary List the runs of a transaction schedule
+// @Description Get the latest runs of a schedule with the created transaction or the reason it was retried, skipped or failed
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Schedule ID"
+// @Success 200 {array} []entity.TransactionScheduleRun "List of runs"
+// @Failure 404 {object} entity.TransactionErrorResponse "Schedule not found"
+// @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Router /schedule/{id}/runs [get]
+func (h *ScheduleHandler) runsHandler(ctx *gin.Context) {
+	h.log.Info("Starting to get transaction schedule runs in the handler layer", nil)
+
+	runs, err := h.usecase.FindRuns(ctx.Param("id"), ctx.GetString("employee"))
+	if err != nil {
+		h.log.Error("failed to retrieve transaction schedule runs", err)
+		ctx.JSON(scheduleErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		Message string                          `json:"message"`
+		Data    []entity.TransactionScheduleRun `json:"data"`
+	}{
+		Message: "Schedule runs",
+		Data:    runs,
+	})
+}
+
+// DeleteSchedule godoc
+// @Summary Stop a transaction schedule
+// @Description Deactivate a schedule so no further transactions are created, its runs are kept
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} entity.TransactionErrorResponse "Schedule stopped"
+// @Failure 404 {object} entity.TransactionErrorResponse "Schedule not found"
+// @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Router /schedule/{id} [delete]
+func (h *ScheduleHandler) deleteHandler(ctx *gin.Context) {
+	h.log.Info("Starting to deactivate a transaction schedule in the handler layer", nil)
+
+	if err := h.usecase.Deactivate(ctx.Param("id"), ctx.GetString("employee")); err != nil {
+		h.log.Error("failed to deactivate transaction schedule", err)
+		ctx.JSON(scheduleErrorStatus(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Schedule stopped"})
+}
+
+func (h *ScheduleHandler) Route() {
+	h.rg.POST(config.PostSchedule, h.authMiddleware.RequirePermission(entity.PermScheduleManage), h.createHandler)
+	h.rg.GET(config.ListSchedules, h.authMiddleware.RequirePermission(entity.PermScheduleManage), h.listHandler)
+	h.rg.GET(config.ScheduleRuns, h.authMiddleware.RequirePermission(entity.PermScheduleManage), h.runsHandler)
+	h.rg.DELETE(config.DeleteSchedule, h.authMiddleware.RequirePermission(entity.PermScheduleManage), h.deleteHandler)
+}
+
+func NewScheduleHandler(usecase usecase.ScheduleUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *ScheduleHandler {
+	return &ScheduleHandler{usecase: usecase, authMiddleware: authMiddleware, rg: rg, log: log}
+}
@@ -1,18 +1,27 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"net/http"
 	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/repository"
 	"server-pulsa-app/internal/shared/common"
 	"server-pulsa-app/internal/usecase"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-resty/resty/v2"
+)
+
+const topupPaymentMessage = "Please make a balance payment at the link above using the virtual account payment method from BCA, BRI, or BNI"
+
+const (
+	maxTopupProofSize    = 2 << 20
+	maxTopupFormOverhead = 64 << 10
 )
 
 type TopupHandler struct {
@@ -22,14 +31,16 @@ type TopupHandler struct {
 	log            *logger.Logger
 }
 
-func initRestyClient() *resty.Client {
-	client := resty.New()
-	client.SetBaseURL(os.Getenv("BASE_URL_MIDTRANS"))
-	client.SetHeader("Authorization", "Basic "+os.Getenv("SERVER_KEY_MIDTRANS"))
-	return client
+func (t *TopupHandler) CreateTopup(c *gin.Context) {
+	t.createTopup(c, config.PostTopup, false)
 }
 
-func (t *TopupHandler) CreateTopup(c *gin.Context) {
+// CreateOwnTopup tops up the merchant of the logged in employee, whatever id_merchant the body names.
+func (t *TopupHandler) CreateOwnTopup(c *gin.Context) {
+	t.createTopup(c, config.PostOwnTopup, true)
+}
+
+func (t *TopupHandler) createTopup(c *gin.Context, endpoint string, own bool) {
 	var payload entity.TopupRequest
 
 	t.log.Info("Starting to create a new topup in the handler layer", nil)
@@ -41,108 +52,250 @@ func (t *TopupHandler) CreateTopup(c *gin.Context) {
 
 	payload.Status = "pending"
 
-	t.log.Info("Start validating if the top-up amount is less than 10,000", payload.Amount)
-	if payload.Amount < 10000 {
-		t.log.Error("Invalid topup amount", payload.Amount)
-		common.SendErrorResponse(c, 400, "minimum amount for topup is 10000")
+	if own && !t.bindOwnMerchant(c, &payload) {
 		return
 	}
 
-	t.log.Info("Starting validate if merchant and supliyer exist", nil)
-	if payload.IdMerchant == "" || payload.IdSupliyer == "" || payload.Item_name == "" {
-		t.log.Error("id_merchant, id_supliyer, and item_name are required", nil)
-		common.SendErrorResponse(c, 400, "id_merchant and id_supliyer are required")
+	t.log.Info("Start validating the topup amount, merchant and supliyer", payload.Amount)
+	if message := topupRequestError(payload); message != "" {
+		t.log.Error("Invalid topup request", message)
+		common.SendErrorResponse(c, 400, message)
 		return
 	}
 
+	key, idempotent := idempotencyKey(c, endpoint)
+
 	t.log.Info("Starting to send a payload to the usecase layer", nil)
-	id, err := t.usecase.CreateTopup(payload)
+	var (
+		session  entity.PaymentSession
+		replayed bool
+		err      error
+	)
+	if idempotent {
+		session, replayed, err = t.usecase.CreateTopupIdempotent(payload, key, auditActor(c))
+	} else {
+		session, err = t.usecase.CreateTopup(payload, auditActor(c))
+	}
 	if err != nil {
 		t.log.Error("Topup creation failed", err)
-		common.SendErrorResponse(c, 500, err.Error())
+		common.SendErrorResponse(c, topupErrorStatus(err), err.Error())
 		return
 	}
+	if replayed {
+		t.log.Info("Replaying topup for idempotency key", key.Key)
+		c.Header(idempotencyReplayedHeader, "true")
+	}
+
+	t.log.Info("Request topup successfully", session)
+	common.SendSingleResponseCreated(c, &session, topupPaymentMessage)
+}
+
+// topupRequestError returns why a topup request is invalid, or an empty string when it is valid.
+func topupRequestError(payload entity.TopupRequest) string {
+	if payload.Amount <= 0 {
+		return "amount must be positive"
+	}
+	if payload.IdMerchant == "" || payload.IdSupliyer == "" || payload.Item_name == "" {
+		return "id_merchant, id_supliyer, and item_name are required"
+	}
+	return ""
+}
+
+// topupErrorStatus maps a topup usecase failure to its response status.
+func topupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrPaymentGateway):
+		return 502
+	case errors.Is(err, usecase.ErrInvalidManualTopup), errors.Is(err, usecase.ErrTopupMerchantRequired):
+		return 400
+	case errors.Is(err, usecase.ErrTopupLimitExceeded):
+		return 422
+	case errors.Is(err, usecase.ErrTopupMerchantForbidden):
+		return 403
+	case errors.Is(err, usecase.ErrTopupNotFound):
+		return 404
+	case errors.Is(err, repository.ErrNotManualTopup), errors.Is(err, repository.ErrIllegalTopupTransition):
+		return 409
+	}
+	return idempotencyErrorStatus(err, 500)
+}
 
-	client := initRestyClient()
-	t.log.Info("Starting to send a payload to Midtrans", nil)
-	midtransReq := entity.MidtransRequest{
-		TransactionDetails: entity.TransactionDetails{
-			OrderId:     id,
-			GrossAmount: float64(payload.Amount),
-		},
+// bindOwnMerchant replaces payload.IdMerchant with a merchant owned by the logged in user. It writes the error
+// response and returns false when the user may not top up that merchant.
+func (t *TopupHandler) bindOwnMerchant(c *gin.Context, payload *entity.TopupRequest) bool {
+	idMerchant, err := t.usecase.MerchantForUser(c.GetString("employee"), payload.IdMerchant)
+	if err != nil {
+		t.log.Error("Employee cannot top up this merchant", err)
+		common.SendErrorResponse(c, topupErrorStatus(err), err.Error())
+		return false
 	}
 
-	fmt.Printf("Payload yang dikirim ke Midtrans: %+v\n", midtransReq)
+	payload.IdMerchant = idMerchant
+	return true
+}
+
+func (t *TopupHandler) CreateManualTopup(c *gin.Context) {
+	t.createManualTopup(c, false)
+}
+
+func (t *TopupHandler) CreateOwnManualTopup(c *gin.Context) {
+	t.createManualTopup(c, true)
+}
 
-	resp, err := client.R().
-		SetBody(midtransReq).
-		SetResult(&entity.MidtransResponse{}).
-		Post("")
+func (t *TopupHandler) createManualTopup(c *gin.Context, own bool) {
+	t.log.Info("Starting to create a manual topup in the handler layer", nil)
 
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTopupProofSize+maxTopupFormOverhead)
+	fileHeader, err := c.FormFile("receipt")
 	if err != nil {
-		t.log.Error("Error sending payload to Midtrans: ", err)
-		common.SendErrorResponse(c, 500, err.Error())
+		t.log.Error("Invalid manual topup receipt: ", err)
+		common.SendErrorResponse(c, 400, "a receipt image no larger than 2MB is required: "+err.Error())
 		return
 	}
 
-	t.log.Info("Starting to validate status code", nil)
-	if resp.StatusCode() != 201 {
-		common.SendErrorResponse(c, resp.StatusCode(), resp.String())
+	amount, _ := strconv.Atoi(c.PostForm("amount"))
+	payload := entity.TopupRequest{
+		IdMerchant:    c.PostForm("id_merchant"),
+		IdSupliyer:    c.PostForm("id_supliyer"),
+		Item_name:     c.PostForm("item_name"),
+		Amount:        amount,
+		PaymentMethod: c.PostForm("bank"),
+	}
+	if own && !t.bindOwnMerchant(c, &payload) {
+		return
+	}
+	if message := topupRequestError(payload); message != "" {
+		t.log.Error("Invalid manual topup request", message)
+		common.SendErrorResponse(c, 400, message)
 		return
 	}
 
-	midtransResponse := resp.Result().(*entity.MidtransResponse)
-	t.log.Info("Request topup successfully", midtransResponse)
-	common.SendSingleResponseCreated(c, midtransResponse, "Please make a balance payment at the link above using the virtual account payment method from BCA, BRI, or BNI")
-}
+	file, err := fileHeader.Open()
+	if err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+	defer file.Close()
 
-func (t *TopupHandler) PaymentCallbackHandler(c *gin.Context) {
-	var notifPayment entity.CallbackPayment
+	content, err := io.ReadAll(io.LimitReader(file, maxTopupProofSize+1))
+	if err != nil || len(content) > maxTopupProofSize {
+		common.SendErrorResponse(c, 400, "a receipt image no larger than 2MB is required")
+		return
+	}
 
-	t.log.Info("Starting to handle payment callback", nil)
-	if err := c.ShouldBindJSON(&notifPayment); err != nil {
-		common.SendErrorResponse(c, 400, err.Error())
+	id, err := t.usecase.CreateManualTopup(payload, entity.TopupProof{FileName: fileHeader.Filename, Content: content}, auditActor(c))
+	if err != nil {
+		t.log.Error("Manual topup creation failed", err)
+		common.SendErrorResponse(c, topupErrorStatus(err), err.Error())
 		return
 	}
 
-	t.log.Info("Get the data needed for the update", nil)
-	idTopup := notifPayment.OrderID
-	status := notifPayment.TransactionStatus
-	amount, err := strconv.ParseFloat(notifPayment.GrossAmount, 64)
+	t.log.Info("Manual topup is waiting for review", id)
+	common.SendSingleResponseCreated(c, gin.H{"id": id, "status": entity.TopupPending}, "Manual topup is waiting for admin approval")
+}
+
+func (t *TopupHandler) ListManualTopups(c *gin.Context) {
+	t.log.Info("Starting to list manual topups waiting for review", nil)
+	topups, err := t.usecase.ListManualTopups()
 	if err != nil {
+		t.log.Error("Error listing manual topups: ", err)
+		common.SendErrorResponse(c, 500, err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, topups, "Manual topups waiting for review")
+}
+
+func (t *TopupHandler) GetManualTopupProof(c *gin.Context) {
+	proof, err := t.usecase.GetTopupProof(c.Param("id"))
+	if err != nil {
+		t.log.Error("Error getting manual topup proof: ", err)
+		common.SendErrorResponse(c, topupErrorStatus(err), err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", proof.FileName))
+	c.Data(http.StatusOK, proof.ContentType, proof.Content)
+}
+
+func (t *TopupHandler) ReviewManualTopup(c *gin.Context) {
+	var review entity.ManualTopupReview
+	if err := c.ShouldBindJSON(&review); err != nil {
 		common.SendErrorResponse(c, 400, err.Error())
 		return
 	}
-	var paymentMethod string
-	if len(notifPayment.VANumber) > 0 {
-		paymentMethod = notifPayment.VANumber[0].Bank
+
+	t.log.Info("Starting to review manual topup", c.Param("id"))
+	status, err := t.usecase.ReviewManualTopup(c.Param("id"), review, auditActor(c))
+	if err != nil {
+		t.log.Error("Error reviewing manual topup: ", err)
+		common.SendErrorResponse(c, topupErrorStatus(err), err.Error())
+		return
 	}
 
-	t.log.Info("Update the status and payment method if status condition = settlement", nil)
-	if status == "settlement" {
-		payload := entity.TopupRequest{
-			Id:            idTopup,
-			Status:        status,
-			Amount:        int(amount),
-			PaymentMethod: paymentMethod,
-		}
+	t.log.Info("Manual topup reviewed", status)
+	common.SendSingleResponseOk(c, gin.H{"id": c.Param("id"), "status": status}, "Manual topup reviewed")
+}
 
-		t.log.Info("Starting to update the topup data", nil)
-		idTopupSuccess, err := t.usecase.UpdateAfterPayment(payload)
-		if err != nil {
+func (t *TopupHandler) PaymentCallbackHandler(c *gin.Context) {
+	t.log.Info("Starting to handle payment callback", nil)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	notification, err := t.usecase.HandlePaymentNotification(c.Request.Header, body, entity.AuditActor{System: "payment callback", ClientIP: c.ClientIP()})
+	// a trusted notification that doesn't move the topup, like a resent one, is acknowledged so the gateway stops
+	// resending it
+	if errors.Is(err, repository.ErrIllegalTopupTransition) {
+		t.log.Info("Ignoring payment callback that doesn't change the topup", map[string]any{
+			"order_id": notification.OrderId,
+			"status":   notification.GatewayStatus,
+			"reason":   err.Error(),
+		})
+		common.SendSingleResponseOk(c, gin.H{"id": notification.OrderId, "status": notification.Status}, "Topup status unchanged")
+		return
+	}
+	if err != nil {
+		status := callbackErrorStatus(err)
+		if isRejectedCallback(err) {
+			t.log.Error("Security event: rejected payment callback", map[string]any{
+				"order_id":     notification.OrderId,
+				"status":       notification.GatewayStatus,
+				"gross_amount": notification.GrossAmount,
+				"client_ip":    c.ClientIP(),
+				"reason":       err.Error(),
+			})
+		} else {
 			t.log.Error("Error updating topup data: ", err)
-			common.SendErrorResponse(c, 500, err.Error())
-			return
 		}
-
-		t.log.Info("Topup data updated successfully", nil)
-		common.SendSingleResponseOk(c, idTopupSuccess, "Topup berhasil")
+		common.SendErrorResponse(c, status, err.Error())
 		return
 	}
 
-	t.log.Info("Topup status is not settlement", nil)
-	common.SendErrorResponse(c, 400, "Topup gagal")
+	t.log.Info("Topup data updated successfully", notification)
+	common.SendSingleResponseOk(c, gin.H{"id": notification.OrderId, "status": notification.Status}, "Topup status updated")
+}
 
+// isRejectedCallback reports whether a callback was refused because it could not be trusted.
+func isRejectedCallback(err error) bool {
+	return errors.Is(err, usecase.ErrInvalidCallbackSignature) ||
+		errors.Is(err, usecase.ErrCallbackAmountMismatch) ||
+		errors.Is(err, usecase.ErrTopupNotFound)
+}
+
+// callbackErrorStatus maps a payment callback failure to its response status.
+func callbackErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidCallbackSignature):
+		return 401
+	case errors.Is(err, usecase.ErrCallbackAmountMismatch), errors.Is(err, usecase.ErrUnknownTopupStatus):
+		return 400
+	case errors.Is(err, usecase.ErrTopupNotFound):
+		return 404
+	}
+	return 500
 }
 
 func (t *TopupHandler) GetTopupByMerchantId(c *gin.Context) {
@@ -166,10 +319,31 @@ func (t *TopupHandler) GetTopupByMerchantId(c *gin.Context) {
 	common.SendSingleResponseOk(c, topups, "Data topup")
 }
 
+func (t *TopupHandler) GetOwnTopups(c *gin.Context) {
+	t.log.Info("Starting to get topups of the employee merchants", nil)
+	topups, err := t.usecase.GetTopupByUser(c.GetString("employee"))
+	if err != nil {
+		t.log.Error("Error getting topups of the employee merchants: ", err)
+		common.SendErrorResponse(c, 500, err.Error())
+		return
+	}
+
+	t.log.Info("Topup data retrieved successfully", nil)
+	common.SendSingleResponseOk(c, topups, "Data topup")
+}
+
 func (t *TopupHandler) Route() {
-	t.rg.POST(config.PostTopup, t.authMiddleware.RequireToken("admin"), t.CreateTopup)
+	t.rg.POST(config.PostTopup, t.authMiddleware.RequirePermission(entity.PermTopupCreate), t.CreateTopup)
 	t.rg.POST(config.PostCallback, t.PaymentCallbackHandler)
-	t.rg.GET(config.GetTopupByMerchantId, t.authMiddleware.RequireToken("admin"), t.GetTopupByMerchantId)
+	t.rg.GET(config.GetTopupByMerchantId, t.authMiddleware.RequirePermission(entity.PermTopupRead), t.GetTopupByMerchantId)
+	t.rg.POST(config.PostManualTopup, t.authMiddleware.RequirePermission(entity.PermTopupCreate), t.CreateManualTopup)
+	t.rg.GET(config.ListManualTopups, t.authMiddleware.RequirePermission(entity.PermTopupReview), t.ListManualTopups)
+	t.rg.GET(config.GetManualTopupProof, t.authMiddleware.RequirePermission(entity.PermTopupReview), t.GetManualTopupProof)
+	t.rg.POST(config.ReviewManualTopup, t.authMiddleware.RequirePermission(entity.PermTopupReview), t.ReviewManualTopup)
+
+	t.rg.POST(config.PostOwnTopup, t.authMiddleware.RequirePermission(entity.PermTopupOwn), t.CreateOwnTopup)
+	t.rg.POST(config.PostOwnManualTopup, t.authMiddleware.RequirePermission(entity.PermTopupOwn), t.CreateOwnManualTopup)
+	t.rg.GET(config.ListOwnTopups, t.authMiddleware.RequirePermission(entity.PermTopupOwn), t.GetOwnTopups)
 }
 
 func NewTopupHandler(usecase usecase.TopupUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *TopupHandler {
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/gateway"
+	"server-pulsa-app/internal/logger"
+	am "server-pulsa-app/internal/mock/auth_mock"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/usecase"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const testCallbackToken = "callback-token"
+
+type TopupHandlerTestSuite struct {
+	suite.Suite
+	topupRepo *repositorymock.MockTopupRepository
+	router    *gin.Engine
+	log       logger.Logger
+}
+
+func (t *TopupHandlerTestSuite) SetupTest() {
+	t.topupRepo = new(repositorymock.MockTopupRepository)
+	audit := new(usecase_mock.AuditUseCaseMock)
+	audit.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	t.log = logger.NewLogger()
+	topupUc := usecase.NewTopupUsecase(t.topupRepo, new(repositorymock.MockTopupSettingRepository), gateway.NewFakePaymentGateway(testCallbackToken), audit, config.TopupConfig{}, &t.log)
+
+	gin.SetMode(gin.TestMode)
+	t.router = gin.New()
+	NewTopupHandler(topupUc, new(am.AuthMiddlewareMock), t.router.Group("/api/v1"), &t.log).Route()
+}
+
+func TestTopupHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(TopupHandlerTestSuite))
+}
+
+func (t *TopupHandlerTestSuite) callback(notification entity.PaymentNotification, token string) *httptest.ResponseRecorder {
+	body, err := json.Marshal(notification)
+	t.NoError(err)
+	req, err := http.NewRequest("POST", "/api/v1"+config.PostCallback, bytes.NewBuffer(body))
+	t.NoError(err)
+	req.Header.Set(gateway.FakePaymentTokenHeader, token)
+
+	w := httptest.NewRecorder()
+	t.router.ServeHTTP(w, req)
+	return w
+}
+
+func (t *TopupHandlerTestSuite) TestPaymentCallback_ResentNotificationIsAcknowledged() {
+	t.topupRepo.On("FindTopup", "topup-1").Return(entity.TopupRequest{Id: "topup-1", Amount: 50000, Status: entity.TopupPaid}, nil).Once()
+	t.topupRepo.On("TxTopupUpdateAfterPayment", mock.Anything, mock.Anything).
+		Return(fmt.Errorf("%w: paid to paid", repository.ErrIllegalTopupTransition)).Once()
+
+	w := t.callback(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 50000}, testCallbackToken)
+
+	t.Equal(http.StatusOK, w.Code)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *TopupHandlerTestSuite) TestPaymentCallback_InvalidSignature() {
+	w := t.callback(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 50000}, "forged-token")
+
+	t.Equal(http.StatusUnauthorized, w.Code)
+	t.topupRepo.AssertNotCalled(t.T(), "FindTopup", mock.Anything)
+}
+
+func (t *TopupHandlerTestSuite) TestPaymentCallback_AmountMismatch() {
+	t.topupRepo.On("FindTopup", "topup-1").Return(entity.TopupRequest{Id: "topup-1", Amount: 50000}, nil).Once()
+
+	w := t.callback(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 10000}, testCallbackToken)
+
+	t.Equal(http.StatusBadRequest, w.Code)
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/shared/common"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TopupSettingHandler struct {
+	usecase        usecase.TopupSettingUseCase
+	rg             *gin.RouterGroup
+	authMiddleware middleware.AuthMiddleware
+	log            *logger.Logger
+}
+
+// topupSettingErrorStatus maps a topup setting usecase failure to its response status.
+func topupSettingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidTopupSetting):
+		return 400
+	case errors.Is(err, usecase.ErrTopupFeeNotFound):
+		return 404
+	}
+	return 500
+}
+
+func (t *TopupSettingHandler) GetSettings(c *gin.Context) {
+	t.log.Info("Starting to get the topup settings in the handler layer", nil)
+	setting, err := t.usecase.GetSettings()
+	if err != nil {
+		t.log.Error("Error getting the topup settings: ", err)
+		common.SendErrorResponse(c, 500, err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, setting, "Topup settings")
+}
+
+func (t *TopupSettingHandler) SaveSettings(c *gin.Context) {
+	var payload entity.TopupSetting
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	t.log.Info("Starting to save the topup settings in the handler layer", payload)
+	setting, err := t.usecase.SaveSettings(payload, auditActor(c))
+	if err != nil {
+		t.log.Error("Error saving the topup settings: ", err)
+		common.SendErrorResponse(c, topupSettingErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, setting, "Topup settings saved")
+}
+
+func (t *TopupSettingHandler) ListFees(c *gin.Context) {
+	t.log.Info("Starting to get the topup fees in the handler layer", nil)
+	fees, err := t.usecase.ListFees()
+	if err != nil {
+		t.log.Error("Error getting the topup fees: ", err)
+		common.SendErrorResponse(c, 500, err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, fees, "Topup fees")
+}
+
+func (t *TopupSettingHandler) SaveFee(c *gin.Context) {
+	var payload entity.TopupFee
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		common.SendErrorResponse(c, 400, err.Error())
+		return
+	}
+
+	t.log.Info("Starting to save a topup fee in the handler layer", c.Param("method"))
+	fee, err := t.usecase.SaveFee(c.Param("method"), payload, auditActor(c))
+	if err != nil {
+		t.log.Error("Error saving the topup fee: ", err)
+		common.SendErrorResponse(c, topupSettingErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, fee, "Topup fee saved")
+}
+
+func (t *TopupSettingHandler) DeleteFee(c *gin.Context) {
+	t.log.Info("Starting to delete a topup fee in the handler layer", c.Param("method"))
+	if err := t.usecase.DeleteFee(c.Param("method"), auditActor(c)); err != nil {
+		t.log.Error("Error deleting the topup fee: ", err)
+		common.SendErrorResponse(c, topupSettingErrorStatus(err), err.Error())
+		return
+	}
+
+	common.SendSingleResponseOk(c, gin.H{"payment_method": c.Param("method")}, "Topup fee deleted")
+}
+
+func (t *TopupSettingHandler) Route() {
+	t.rg.GET(config.GetTopupSettings, t.authMiddleware.RequirePermission(entity.PermTopupSetting), t.GetSettings)
+	t.rg.PUT(config.PutTopupSettings, t.authMiddleware.RequirePermission(entity.PermTopupSetting), t.SaveSettings)
+	t.rg.GET(config.GetTopupFees, t.authMiddleware.RequirePermission(entity.PermTopupSetting), t.ListFees)
+	t.rg.PUT(config.PutTopupFee, t.authMiddleware.RequirePermission(entity.PermTopupSetting), t.SaveFee)
+	t.rg.DELETE(config.DeleteTopupFee, t.authMiddleware.RequirePermission(entity.PermTopupSetting), t.DeleteFee)
+}
+
+func NewTopupSettingHandler(usecase usecase.TopupSettingUseCase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *TopupSettingHandler {
+	return &TopupSettingHandler{usecase: usecase, authMiddleware: authMiddleware, rg: rg, log: log}
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/middleware"
 	"server-pulsa-app/internal/shared/custom"
+	"server-pulsa-app/internal/shared/model"
 	"server-pulsa-app/internal/usecase"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,10 +39,18 @@ func NewTransactionHandler(usecase usecase.TransactionUseCase, authMiddleware mi
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param Idempotency-Key header string false "Unique key to safely retry the request"
+// @Param X-Api-Key header string false "Merchant api key, instead of a bearer token"
+// @Param X-Api-Timestamp header string false "Unix time the request was signed at"
+// @Param X-Api-Nonce header string false "Unique value per signed request"
+// @Param X-Api-Signature header string false "Hex HMAC-SHA256 of method, uri, timestamp, nonce and body hash"
 // @Param request body entity.TransactionReq true "Transaction details"
 // @Success 201 {object} entity.Transactions "Successfully created transaction"
 // @Failure 400 {object} entity.TransactionErrorResponse "Invalid input"
 // @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Failure 403 {object} entity.TransactionErrorResponse "Merchant not owned by the user"
+// @Failure 409 {object} entity.TransactionErrorResponse "Request with the same key is still in progress"
+// @Failure 422 {object} entity.TransactionErrorResponse "Idempotency key reused with a different body, or product not sold by the destination operator"
 // @Router /transaction [post]
 func (h *TransactionHandler) createHandler(ctx *gin.Context) {
 	var payload entity.Transactions
@@ -49,12 +62,34 @@ func (h *TransactionHandler) createHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	transaction, err := h.usecase.Create(payload)
+	// a merchant api key only sells for its own merchant, as the user who created it; a logged in user
+	// only sells for one of the merchants they own
+	payload.UserId = ctx.GetString("employee")
+	if merchant := ctx.GetString("merchant"); merchant != "" {
+		payload.MerchantId = merchant
+	} else if payload.MerchantId, err = h.usecase.MerchantForUser(payload.UserId, payload.MerchantId); err != nil {
+		h.log.Error("user cannot sell for this merchant", err)
+		ctx.JSON(merchantOwnerErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var (
+		transaction entity.Transactions
+		replayed    bool
+	)
+	if key, ok := idempotencyKey(ctx, config.PostTransaction); ok {
+		transaction, replayed, err = h.usecase.CreateIdempotent(payload, key, auditActor(ctx))
+	} else {
+		transaction, err = h.usecase.Create(payload, auditActor(ctx))
+	}
 	if err != nil {
 		h.log.Error("failed to create a transaction", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create a transaction " + err.Error()})
+		ctx.JSON(idempotencyErrorStatus(err, destinationErrorStatus(err, http.StatusInternalServerError)), gin.H{"error": "failed to create a transaction " + err.Error()})
 		return
 	}
+	if replayed {
+		ctx.Header(idempotencyReplayedHeader, "true")
+	}
 	response := struct {
 		Message string              `json:"message"`
 		Data    entity.Transactions `json:"data"`
@@ -67,25 +102,68 @@ func (h *TransactionHandler) createHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, response)
 }
 
+// merchantOwnerErrorStatus maps a failed merchant ownership check to its HTTP status.
+func merchantOwnerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrTopupMerchantRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrTopupMerchantForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// destinationErrorStatus maps destination number errors to their HTTP status, falling back to fallback.
+func destinationErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidDestinationNumber):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrUnknownOperator), errors.Is(err, usecase.ErrOperatorMismatch):
+		return http.StatusUnprocessableEntity
+	default:
+		return fallback
+	}
+}
+
 // ListTransactions godoc
 // @Summary List all transactions
-// @Description Get a list of all transactions
+// @Description Get a page of the merchant transactions, optionally filtered and sorted
 // @Tags transactions
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param page query int false "Page number"
 // @Param limit query int false "Items per page"
+// @Param cursor query string false "Cursor of the next page, takes precedence over page"
+// @Param start_date query string false "First transaction date (dd-mm-yyyy)"
+// @Param end_date query string false "Last transaction date (dd-mm-yyyy)"
+// @Param provider query string false "Provider name"
+// @Param destination_number query string false "Destination number prefix"
+// @Param customer_name query string false "Part of the customer name"
+// @Param status query string false "Transaction status"
+// @Param sort query string false "date, customer_name, destination_number or status, prefix with - for descending" default(-date)
 // @Success 200 {array} []entity.Transactions "List of transactions"
+// @Failure 400 {object} entity.TransactionErrorResponse "Invalid filter"
 // @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
 // @Router /transactions [get]
 func (h *TransactionHandler) listHandler(ctx *gin.Context) {
 	h.log.Info("Starting to get transactions list in the handler layer", nil)
 
-	userId, _ := ctx.Get("employee")
-	transactions, err := h.usecase.GetAll(userId.(string))
+	filter, err := transactionFilter(ctx)
+	if err != nil {
+		h.log.Error("invalid transactions filter", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactions, paging, err := h.usecase.GetAll(filter)
 	if err != nil {
 		h.log.Error("failed to retrieve a transactions", err)
+		if errors.Is(err, usecase.ErrInvalidTransactionFilter) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve transactions " + err.Error()})
 		return
 	}
@@ -94,11 +172,13 @@ func (h *TransactionHandler) listHandler(ctx *gin.Context) {
 		response := struct {
 			Message string                   `json:"message"`
 			Data    []custom.TransactionsReq `json:"data"`
+			Paging  model.Paging             `json:"paging"`
 		}{
 			Message: "Transaction list",
 			Data:    transactions,
+			Paging:  paging,
 		}
-		h.log.Info("transactions list found", response)
+		h.log.Info("transactions list found", paging)
 		ctx.JSON(http.StatusOK, response)
 	} else {
 		h.log.Error("transactions not found", err)
@@ -106,6 +186,38 @@ func (h *TransactionHandler) listHandler(ctx *gin.Context) {
 	}
 }
 
+// transactionFilter reads the list filters from the query string.
+func transactionFilter(ctx *gin.Context) (entity.TransactionFilter, error) {
+	filter := entity.TransactionFilter{
+		UserId:            ctx.GetString("employee"),
+		Provider:          ctx.Query("provider"),
+		DestinationNumber: ctx.Query("destination_number"),
+		CustomerName:      ctx.Query("customer_name"),
+		Status:            ctx.Query("status"),
+		Sort:              ctx.Query("sort"),
+		Cursor:            ctx.Query("cursor"),
+	}
+
+	var err error
+	for name, target := range map[string]*int{"page": &filter.Page, "limit": &filter.Limit} {
+		if value := ctx.Query(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return filter, fmt.Errorf("%s must be a number", name)
+			}
+		}
+	}
+
+	for name, target := range map[string]*time.Time{"start_date": &filter.StartDate, "end_date": &filter.EndDate} {
+		if value := ctx.Query(name); value != "" {
+			if *target, err = time.Parse("02-01-2006", value); err != nil {
+				return filter, fmt.Errorf("%s must be formatted as dd-mm-yyyy", name)
+			}
+		}
+	}
+
+	return filter, nil
+}
+
 // GetTransaction godoc
 // @Summary Get transaction by ID
 // @Description Retrieve a transaction by its ID
@@ -114,6 +226,10 @@ func (h *TransactionHandler) listHandler(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Transaction ID"
+// @Param X-Api-Key header string false "Merchant api key, instead of a bearer token"
+// @Param X-Api-Timestamp header string false "Unix time the request was signed at"
+// @Param X-Api-Nonce header string false "Unique value per signed request"
+// @Param X-Api-Signature header string false "Hex HMAC-SHA256 of method, uri, timestamp, nonce and body hash"
 // @Success 200 {object} entity.Transactions "Transaction found"
 // @Failure 404 {object} entity.TransactionErrorResponse "Transaction not found"
 // @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
@@ -128,6 +244,20 @@ func (h *TransactionHandler) getByIdHandler(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve a transaction" + err.Error()})
 		return
 	}
+	// api keys and logged in users only read the transactions of their own merchants
+	if merchant := ctx.GetString("merchant"); merchant != "" && transaction.Merchant.IdMerchant != merchant {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	} else if merchant == "" {
+		if _, err := h.usecase.MerchantForUser(ctx.GetString("employee"), transaction.Merchant.IdMerchant); errors.Is(err, usecase.ErrTopupMerchantForbidden) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+			return
+		} else if err != nil {
+			h.log.Error("failed to check the merchant of a transaction", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve a transaction " + err.Error()})
+			return
+		}
+	}
 	response := struct {
 		Message string                 `json:"message"`
 		Data    custom.TransactionsReq `json:"data"`
@@ -139,8 +269,125 @@ func (h *TransactionHandler) getByIdHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
+// RefundTransaction godoc
+// @Summary Refund transaction
+// @Description Refund a whole transaction or some of its details and restore the merchant balance
+// @Tags transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID"
+// @Param request body entity.TransactionRefundReq true "Refund details"
+// @Success 200 {object} entity.TransactionRefund "Transaction refunded"
+// @Failure 400 {object} entity.TransactionErrorResponse "Invalid input"
+// @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Failure 404 {object} entity.TransactionErrorResponse "Transaction not found"
+// @Failure 409 {object} entity.TransactionErrorResponse "Transaction cannot be refunded"
+// @Router /transaction/{id}/refund [post]
+func (h *TransactionHandler) refundHandler(ctx *gin.Context) {
+	var payload entity.TransactionRefundReq
+
+	h.log.Info("Starting to refund a transaction in the handler layer", nil)
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		h.log.Error("invalid payload for refund", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	refund, err := h.usecase.Refund(entity.TransactionRefund{
+		TransactionsId:       ctx.Param("id"),
+		TransactionDetailIds: payload.TransactionDetailIds,
+		Reason:               payload.Reason,
+		RefundedBy:           ctx.GetString("employee"),
+	}, auditActor(ctx))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, usecase.ErrTransactionNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, usecase.ErrRefundNotAllowed):
+			status = http.StatusConflict
+		}
+		h.log.Error("failed to refund a transaction", err)
+		ctx.JSON(status, gin.H{"error": "failed to refund a transaction " + err.Error()})
+		return
+	}
+
+	response := struct {
+		Message string                   `json:"message"`
+		Data    entity.TransactionRefund `json:"data"`
+	}{
+		Message: "Transaction Refunded",
+		Data:    refund,
+	}
+	h.log.Info("transaction refunded", response)
+	ctx.JSON(http.StatusOK, response)
+}
+
+const maxBulkUploadSize = 5 << 20
+
+// BulkCreateTransactions godoc
+// @Summary Create transactions from a spreadsheet
+// @Description Upload a CSV or XLSX file with a header row followed by customer name, destination number and product id columns.
+// @Description Every row is created as its own transaction; the response is the same sheet with status, transaction id and error columns.
+// @Description Uploading the same file again for the same date returns the transactions already created instead of selling twice.
+// @Tags transactions
+// @Accept multipart/form-data
+// @Produce application/octet-stream
+// @Security BearerAuth
+// @Param file formData file true "CSV or XLSX file"
+// @Param merchantId formData string false "Merchant of every transaction, may be left out when the user owns one merchant"
+// @Param transactionDate formData string false "Transaction date (dd-mm-yyyy), defaults to today"
+// @Success 200 {file} file "Per-row result file"
+// @Failure 400 {object} entity.TransactionErrorResponse "Invalid file"
+// @Failure 401 {object} entity.TransactionErrorResponse "Unauthorized"
+// @Failure 403 {object} entity.TransactionErrorResponse "Merchant not owned by the user"
+// @Router /transactions/bulk [post]
+func (h *TransactionHandler) bulkCreateHandler(ctx *gin.Context) {
+	h.log.Info("Starting to create bulk transactions in the handler layer", nil)
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBulkUploadSize)
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		h.log.Error("invalid bulk transaction upload", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "a file no larger than 5MB is required: " + err.Error()})
+		return
+	}
+
+	template := entity.Transactions{
+		MerchantId:      ctx.PostForm("merchantId"),
+		UserId:          ctx.GetString("employee"),
+		TransactionDate: ctx.DefaultPostForm("transactionDate", time.Now().Format("02-01-2006")),
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		h.log.Error("failed to open bulk transaction upload", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	result, err := h.usecase.BulkCreate(template, fileHeader.Filename, file, auditActor(ctx))
+	if err != nil {
+		h.log.Error("failed to create bulk transactions", err)
+		status := merchantOwnerErrorStatus(err)
+		if errors.Is(err, usecase.ErrInvalidBulkFile) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", result.FileName))
+	ctx.Header("X-Bulk-Succeeded", strconv.Itoa(result.Succeeded))
+	ctx.Header("X-Bulk-Failed", strconv.Itoa(result.Failed))
+	ctx.Data(http.StatusOK, result.ContentType, result.File)
+}
+
 func (h *TransactionHandler) Route() {
-	h.rg.POST(config.PostTransaction, h.authMiddleware.RequireToken("employee"), h.createHandler)
-	h.rg.GET(config.ListTransactions, h.authMiddleware.RequireToken("employee"), h.listHandler)
-	h.rg.GET(config.DetailTransaction, h.authMiddleware.RequireToken("employee"), h.getByIdHandler)
+	h.rg.POST(config.PostTransaction, h.authMiddleware.RequirePermissionOrAPIKey(entity.PermTransactionCreate), h.createHandler)
+	h.rg.GET(config.ListTransactions, h.authMiddleware.RequirePermission(entity.PermTransactionRead), h.listHandler)
+	h.rg.POST(config.BulkTransactions, h.authMiddleware.RequirePermission(entity.PermTransactionCreate), h.bulkCreateHandler)
+	h.rg.GET(config.DetailTransaction, h.authMiddleware.RequirePermissionOrAPIKey(entity.PermTransactionRead), h.getByIdHandler)
+	h.rg.POST(config.RefundTransaction, h.authMiddleware.RequirePermission(entity.PermTransactionRefund), h.refundHandler)
 }
@@ -11,10 +11,13 @@ import (
 	am "server-pulsa-app/internal/mock/auth_mock"
 	mock "server-pulsa-app/internal/mock/usecase_mock"
 	"server-pulsa-app/internal/shared/custom"
+	"server-pulsa-app/internal/shared/model"
+	"server-pulsa-app/internal/usecase"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	testifymock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -30,8 +33,10 @@ type TransactionHandlerTestSuite struct {
 func (suite *TransactionHandlerTestSuite) SetupTest() {
 	suite.mockTxUc = new(mock.MockTransactionUseCase)
 	suite.mockAuthMiddleware = new(am.AuthMiddlewareMock)
+	suite.log = logger.NewLogger()
 	gin.SetMode(gin.TestMode)
 	suite.router = gin.New()
+	suite.router.Use(func(ctx *gin.Context) { ctx.Set("employee", "uuid-test1") })
 
 	rg := suite.router.Group("/api/v1")
 	suite.transactionHandler = NewTransactionHandler(suite.mockTxUc, suite.mockAuthMiddleware, rg, &suite.log)
@@ -70,7 +75,8 @@ func (suite *TransactionHandlerTestSuite) TestCreate_Success() {
 		},
 	}
 
-	suite.mockTxUc.On("Create", payload).Return(expectedResponse, nil)
+	suite.mockTxUc.On("MerchantForUser", "uuid-test1", "uuid-test1").Return("uuid-test1", nil)
+	suite.mockTxUc.On("Create", payload, testifymock.Anything).Return(expectedResponse, nil)
 
 	jsonPayload, err := json.Marshal(payload)
 	suite.NoError(err)
@@ -122,7 +128,8 @@ func (suite *TransactionHandlerTestSuite) TestCreate_UseCaseError() {
 		},
 	}
 
-	suite.mockTxUc.On("Create", payload).Return(entity.Transactions{}, errors.New("usecase error"))
+	suite.mockTxUc.On("MerchantForUser", "uuid-test1", "uuid-test1").Return("uuid-test1", nil)
+	suite.mockTxUc.On("Create", payload, testifymock.Anything).Return(entity.Transactions{}, errors.New("usecase error"))
 
 	jsonPayload, err := json.Marshal(payload)
 	suite.NoError(err)
@@ -143,7 +150,7 @@ func (suite *TransactionHandlerTestSuite) TestGetAll_Success() {
 			TransactionsId:    "tx-uuid",
 			CustomerName:      "test",
 			DestinationNumber: "087654321",
-			TransactionDate:   time.Now(),
+			TransactionDate:   time.Date(2024, 10, 25, 0, 0, 0, 0, time.UTC),
 			User: custom.UserRes{
 				Id_user:  "user-uuid",
 				Username: "testuser",
@@ -169,9 +176,9 @@ func (suite *TransactionHandlerTestSuite) TestGetAll_Success() {
 		},
 	}
 
-	suite.mockTxUc.On("GetAll").Return(expectedTransactions, nil)
+	suite.mockTxUc.On("GetAll", testifymock.Anything).Return(expectedTransactions, model.Paging{Page: 1, Limit: 10, TotalRows: 1, TotalPages: 1}, nil)
 
-	req, err := http.NewRequest("GET", "/api/v1/transactions/history", nil)
+	req, err := http.NewRequest("GET", "/api/v1/transactions", nil)
 	suite.NoError(err)
 
 	w := httptest.NewRecorder()
@@ -190,9 +197,9 @@ func (suite *TransactionHandlerTestSuite) TestGetAll_Success() {
 }
 
 func (suite *TransactionHandlerTestSuite) TestGetAll_Empty() {
-	suite.mockTxUc.On("GetAll").Return([]custom.TransactionsReq{}, nil)
+	suite.mockTxUc.On("GetAll", testifymock.Anything).Return([]custom.TransactionsReq{}, model.Paging{}, nil)
 
-	req, err := http.NewRequest("GET", "/api/v1/transactions/history", nil)
+	req, err := http.NewRequest("GET", "/api/v1/transactions", nil)
 	suite.NoError(err)
 
 	w := httptest.NewRecorder()
@@ -202,9 +209,9 @@ func (suite *TransactionHandlerTestSuite) TestGetAll_Empty() {
 }
 
 func (suite *TransactionHandlerTestSuite) TestGetAll_Error() {
-	suite.mockTxUc.On("GetAll").Return([]custom.TransactionsReq{}, errors.New("usecase error"))
+	suite.mockTxUc.On("GetAll", testifymock.Anything).Return([]custom.TransactionsReq{}, model.Paging{}, errors.New("usecase error"))
 
-	req, err := http.NewRequest("GET", "/api/v1/transactions/history", nil)
+	req, err := http.NewRequest("GET", "/api/v1/transactions", nil)
 	suite.NoError(err)
 
 	w := httptest.NewRecorder()
@@ -219,7 +226,7 @@ func (suite *TransactionHandlerTestSuite) TestGetById_Success() {
 		TransactionsId:    id,
 		CustomerName:      "test",
 		DestinationNumber: "087654321",
-		TransactionDate:   time.Now(),
+		TransactionDate:   time.Date(2024, 10, 25, 0, 0, 0, 0, time.UTC),
 		User: custom.UserRes{
 			Id_user:  "user-uuid",
 			Username: "testuser",
@@ -245,8 +252,9 @@ func (suite *TransactionHandlerTestSuite) TestGetById_Success() {
 	}
 
 	suite.mockTxUc.On("GetById", id).Return(expectedTransaction, nil)
+	suite.mockTxUc.On("MerchantForUser", "uuid-test1", "merchant-uuid").Return("merchant-uuid", nil)
 
-	req, err := http.NewRequest("GET", "/api/v1/transactions/history/"+id, nil)
+	req, err := http.NewRequest("GET", "/api/v1/transaction/"+id, nil)
 	suite.NoError(err)
 
 	w := httptest.NewRecorder()
@@ -268,7 +276,7 @@ func (suite *TransactionHandlerTestSuite) TestGetById_Error() {
 	id := "non-existent-id"
 	suite.mockTxUc.On("GetById", id).Return(custom.TransactionsReq{}, errors.New("usecase error"))
 
-	req, err := http.NewRequest("GET", "/api/v1/transactions/history/"+id, nil)
+	req, err := http.NewRequest("GET", "/api/v1/transaction/"+id, nil)
 	suite.NoError(err)
 
 	w := httptest.NewRecorder()
@@ -277,6 +285,57 @@ func (suite *TransactionHandlerTestSuite) TestGetById_Error() {
 	suite.Equal(http.StatusInternalServerError, w.Code)
 }
 
+func (suite *TransactionHandlerTestSuite) TestCreate_UserFromToken() {
+	payload := entity.Transactions{MerchantId: "uuid-test1", UserId: "uuid-other", CustomerName: "test", DestinationNumber: "087654321"}
+	expected := payload
+	expected.UserId = "uuid-test1"
+	suite.mockTxUc.On("MerchantForUser", "uuid-test1", "uuid-test1").Return("uuid-test1", nil)
+	suite.mockTxUc.On("Create", expected, testifymock.Anything).Return(entity.Transactions{TransactionsId: "tx-uuid"}, nil).Once()
+
+	jsonPayload, err := json.Marshal(payload)
+	suite.NoError(err)
+	req, err := http.NewRequest("POST", "/api/v1/transaction", bytes.NewBuffer(jsonPayload))
+	suite.NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusCreated, w.Code)
+	suite.mockTxUc.AssertExpectations(suite.T())
+}
+
+func (suite *TransactionHandlerTestSuite) TestCreate_OtherMerchant() {
+	payload := entity.Transactions{MerchantId: "uuid-other", CustomerName: "test", DestinationNumber: "087654321"}
+	suite.mockTxUc.On("MerchantForUser", "uuid-test1", "uuid-other").Return("", usecase.ErrTopupMerchantForbidden)
+
+	jsonPayload, err := json.Marshal(payload)
+	suite.NoError(err)
+	req, err := http.NewRequest("POST", "/api/v1/transaction", bytes.NewBuffer(jsonPayload))
+	suite.NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusForbidden, w.Code)
+	suite.mockTxUc.AssertNotCalled(suite.T(), "Create", testifymock.Anything, testifymock.Anything)
+}
+
+func (suite *TransactionHandlerTestSuite) TestGetById_OtherMerchant() {
+	id := "tx-uuid"
+	suite.mockTxUc.On("GetById", id).Return(custom.TransactionsReq{TransactionsId: id, Merchant: custom.MerchantRes{IdMerchant: "merchant-other"}}, nil)
+	suite.mockTxUc.On("MerchantForUser", "uuid-test1", "merchant-other").Return("", usecase.ErrTopupMerchantForbidden)
+
+	req, err := http.NewRequest("GET", "/api/v1/transaction/"+id, nil)
+	suite.NoError(err)
+
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	suite.Equal(http.StatusNotFound, w.Code)
+}
+
 func TestTransactionHandlerTestSuite(t *testing.T) {
 	suite.Run(t, new(TransactionHandlerTestSuite))
 }
@@ -1,12 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/middleware"
+	"server-pulsa-app/internal/repository"
 	"server-pulsa-app/internal/usecase"
 
 	// "server-pulsa-app/config"
@@ -61,6 +63,47 @@ func (u *UserHandler) ListHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, reponse)
 }
 
+// CreateUser godoc
+// @Summary Create user
+// @Description Provision a user, optionally as the owner of a merchant. Without role:manage the role may only grant permissions the caller has
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body entity.UserCreateRequest true "User details"
+// @Success 201 {object} entity.UserResponse "Successfully created user"
+// @Failure 400 {object} entity.UserErrorResponse "Invalid input or unknown role"
+// @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Failure 403 {object} entity.UserErrorResponse "Role grants permissions the caller doesn't have"
+// @Failure 404 {object} entity.UserErrorResponse "Merchant not found"
+// @Failure 409 {object} entity.UserErrorResponse "Username already exist"
+// @Router /user [post]
+func (u *UserHandler) createHandler(ctx *gin.Context) {
+	u.log.Info("Starting to create a user in the handler layer", nil)
+
+	var payload entity.UserCreateRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := u.userUc.CreateUser(payload, auditActor(ctx))
+	if err != nil {
+		ctx.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := struct {
+		Message string
+		Data    entity.UserResponse
+	}{
+		Message: "Success Create User",
+		Data:    entity.UserResponse{Id_user: user.Id_user, Username: user.Username, Role: user.Role, IdMerchant: payload.IdMerchant},
+	}
+
+	ctx.JSON(http.StatusCreated, response)
+}
+
 // GetUser godoc
 // @Summary Get user by ID
 // @Description Retrieve a user by its ID
@@ -96,7 +139,7 @@ func (u *UserHandler) getIdHandler(ctx *gin.Context) {
 
 // UpdateUser godoc
 // @Summary Update user
-// @Description Update an existing user
+// @Description Update an existing user. A new password or role logs out every session of the user
 // @Tags users
 // @Accept json
 // @Produce json
@@ -106,23 +149,23 @@ func (u *UserHandler) getIdHandler(ctx *gin.Context) {
 // @Success 200 {object} entity.UserResponse "Successfully updated user"
 // @Failure 400 {object} entity.UserErrorResponse "Invalid input"
 // @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Failure 403 {object} entity.UserErrorResponse "Role grants permissions the caller doesn't have"
 // @Failure 404 {object} entity.UserErrorResponse "User not found"
+// @Failure 409 {object} entity.UserErrorResponse "Username already exist"
 // @Router /user/{id} [put]
 func (u *UserHandler) updateHandler(ctx *gin.Context) {
 	u.log.Info("Starting to update user in the handler layer", nil)
 	id := ctx.Param("id")
-	var payload entity.User
+	var payload entity.UserReqUpdate
 	if err := ctx.ShouldBindJSON(&payload); err != nil {
 		ctx.JSON(http.StatusBadRequest, fmt.Sprintf("User with id %s not found", id))
 		return
 	}
 
-	payload.Id_user = id
-
-	user, err := u.userUc.UpdateUser(payload)
+	user, err := u.userUc.UpdateUser(entity.User{Id_user: id, Username: payload.Username, Password: payload.Password, Role: payload.Role}, auditActor(ctx))
 
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, err.Error())
+		ctx.JSON(userErrorStatus(err), err.Error())
 		return
 	}
 
@@ -154,8 +197,11 @@ func (u *UserHandler) deleteHandler(ctx *gin.Context) {
 	u.log.Info("Starting to delete user in the handler layer", nil)
 
 	id := ctx.Param("id")
-	err := u.userUc.DeleteUser(id)
-	if err != nil {
+	err := u.userUc.DeleteUser(id, auditActor(ctx))
+	if errors.Is(err, usecase.ErrRoleNotAssignable) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		ctx.JSON(http.StatusNotFound, gin.H{"message": fmt.Sprintf("User with ID %s not found", id)})
 		return
 	}
@@ -167,11 +213,128 @@ func (u *UserHandler) deleteHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response)
 }
 
+// GetMe godoc
+// @Summary Get my account
+// @Description Retrieve the account of the logged in user
+// @Tags account
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} entity.UserResponse "Logged in user"
+// @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Router /me [get]
+func (u *UserHandler) getMeHandler(ctx *gin.Context) {
+	u.log.Info("Starting to get the logged in user in the handler layer", nil)
+
+	user, err := u.userUc.GetUserByID(ctx.GetString("employee"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": usecase.ErrUserNotFound.Error()})
+		return
+	}
+
+	response := struct {
+		Message string
+		Data    entity.User
+	}{
+		Message: "Success Get My Account",
+		Data:    user,
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// UpdateMe godoc
+// @Summary Update my account
+// @Description Change the username of the logged in user
+// @Tags account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body entity.ProfileUpdateRequest true "Account details"
+// @Success 200 {object} entity.UserResponse "Successfully updated account"
+// @Failure 400 {object} entity.UserErrorResponse "Invalid input"
+// @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Failure 409 {object} entity.UserErrorResponse "Username already exist"
+// @Router /me [patch]
+func (u *UserHandler) updateMeHandler(ctx *gin.Context) {
+	u.log.Info("Starting to update the logged in user in the handler layer", nil)
+
+	var payload entity.ProfileUpdateRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := u.userUc.UpdateProfile(ctx.GetString("employee"), payload, auditActor(ctx))
+	if err != nil {
+		ctx.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := struct {
+		Message string
+		Data    entity.User
+	}{
+		Message: "Success Update My Account",
+		Data:    user,
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// ChangeMyPassword godoc
+// @Summary Change my password
+// @Description Change the password of the logged in user. Every other session of the user is logged out
+// @Tags account
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body entity.ChangePasswordRequest true "Old and new password"
+// @Success 200 {object} object "Password changed"
+// @Failure 400 {object} entity.UserErrorResponse "Invalid input or password too weak"
+// @Failure 401 {object} entity.UserErrorResponse "Unauthorized"
+// @Failure 403 {object} entity.UserErrorResponse "Old password doesn't match"
+// @Router /me/password [post]
+func (u *UserHandler) changePasswordHandler(ctx *gin.Context) {
+	u.log.Info("Starting to change the password in the handler layer", nil)
+
+	var payload entity.ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := u.userUc.ChangePassword(ctx.GetString("employee"), ctx.GetString("session"), payload, auditActor(ctx)); err != nil {
+		ctx.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidUser), errors.Is(err, usecase.ErrWeakPassword), errors.Is(err, repository.ErrUnknownUserRole):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrWrongPassword), errors.Is(err, usecase.ErrRoleNotAssignable):
+		return http.StatusForbidden
+	case errors.Is(err, repository.ErrUsernameTaken):
+		return http.StatusConflict
+	case errors.Is(err, usecase.ErrUserNotFound), errors.Is(err, repository.ErrMerchantNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
 func (u *UserHandler) Route() {
-	u.rg.GET(config.GetUserList, u.authMiddleware.RequireToken("admin"), u.ListHandler)
-	u.rg.GET(config.GetUser, u.authMiddleware.RequireToken("admin"), u.getIdHandler)
-	u.rg.PUT(config.PutUser, u.authMiddleware.RequireToken("admin"), u.updateHandler)
-	u.rg.DELETE(config.DeleteUser, u.authMiddleware.RequireToken("admin"), u.deleteHandler)
+	u.rg.GET(config.GetUserList, u.authMiddleware.RequirePermission(entity.PermUserManage), u.ListHandler)
+	u.rg.POST(config.PostUser, u.authMiddleware.RequirePermission(entity.PermUserManage), u.createHandler)
+	u.rg.GET(config.GetUser, u.authMiddleware.RequirePermission(entity.PermUserManage), u.getIdHandler)
+	u.rg.PUT(config.PutUser, u.authMiddleware.RequirePermission(entity.PermUserManage), u.updateHandler)
+	u.rg.DELETE(config.DeleteUser, u.authMiddleware.RequirePermission(entity.PermUserManage), u.deleteHandler)
+
+	u.rg.GET(config.GetMe, u.authMiddleware.RequirePermission(), u.getMeHandler)
+	u.rg.PATCH(config.PatchMe, u.authMiddleware.RequirePermission(), u.updateMeHandler)
+	u.rg.POST(config.ChangeMyPassword, u.authMiddleware.RequirePermission(), u.changePasswordHandler)
 }
 
 func NewUserHandler(userUc usecase.UserUsecase, authMiddleware middleware.AuthMiddleware, rg *gin.RouterGroup, log *logger.Logger) *UserHandler {
@@ -9,9 +9,11 @@ import (
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/mock/middleware_mock"
 	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/usecase"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -39,10 +41,19 @@ func (u *UserHandlerTest) SetupTest() {
 	u.router.GET("/api/v1/user/:id", u.userHandler.getIdHandler)
 	u.router.PUT("/api/v1/user/:id", u.userHandler.updateHandler)
 	u.router.DELETE("/api/v1/user/:id", u.userHandler.deleteHandler)
+	u.router.POST("/api/v1/me/password", func(ctx *gin.Context) {
+		ctx.Set("employee", "uuid-user-test")
+		ctx.Set("session", "session-1")
+	}, u.userHandler.changePasswordHandler)
 }
 
 func (u *UserHandlerTest) TestUpdate() {
-	payload := entity.User{
+	payload := entity.UserReqUpdate{
+		Username: "testuser",
+		Password: "password",
+		Role:     "admin",
+	}
+	user := entity.User{
 		Id_user:  "uuid-user-test",
 		Username: "testuser",
 		Password: "password",
@@ -52,8 +63,8 @@ func (u *UserHandlerTest) TestUpdate() {
 	if err != nil {
 		u.T().Fatalf("error '%s' occured when marshaling the payload", err)
 	}
-	u.userUc.On("UpdateUser", payload).Return(payload, nil)
-	request, err := http.NewRequest("PUT", "/api/v1/user/"+payload.Id_user, bytes.NewBuffer(jsonPayload))
+	u.userUc.On("UpdateUser", user, mock.Anything).Return(user, nil)
+	request, err := http.NewRequest("PUT", "/api/v1/user/"+user.Id_user, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		u.T().Fatalf("error '%s' occured when creating the request", err)
 	}
@@ -94,7 +105,21 @@ func (u *UserHandlerTest) TestGet() {
 
 func (u *UserHandlerTest) TestDelete() {
 	id := "uuid-user-test"
-	u.userUc.On("DeleteUser", id).Return(nil)
+	u.userUc.On("DeleteUser", id, mock.Anything).Return(nil)
+	request, err := http.NewRequest("DELETE", "/api/v1/user/"+id, nil)
+	if err != nil {
+		u.T().Fatalf("error '%s' occured when creating the request", err)
+	}
+
+	w := httptest.NewRecorder()
+	u.router.ServeHTTP(w, request)
+
+	u.Equal(http.StatusOK, w.Code)
+}
+
+func (u *UserHandlerTest) TestDelete_RoleNotAssignable() {
+	id := "uuid-admin"
+	u.userUc.On("DeleteUser", id, mock.Anything).Return(usecase.ErrRoleNotAssignable)
 	request, err := http.NewRequest("DELETE", "/api/v1/user/"+id, nil)
 	if err != nil {
 		u.T().Fatalf("error '%s' occured when creating the request", err)
@@ -103,7 +128,39 @@ func (u *UserHandlerTest) TestDelete() {
 	w := httptest.NewRecorder()
 	u.router.ServeHTTP(w, request)
 
+	u.Equal(http.StatusForbidden, w.Code)
+}
+
+func (u *UserHandlerTest) TestChangePassword_WrongOldPassword() {
+	payload := entity.ChangePasswordRequest{OldPassword: "guess", NewPassword: "new secret 2"}
+	u.userUc.On("ChangePassword", "uuid-user-test", "session-1", payload, mock.Anything).Return(usecase.ErrWrongPassword)
+
+	jsonPayload, _ := json.Marshal(payload)
+	request, err := http.NewRequest("POST", "/api/v1/me/password", bytes.NewBuffer(jsonPayload))
+	if err != nil {
+		u.T().Fatalf("error '%s' occured when creating the request", err)
+	}
+
+	w := httptest.NewRecorder()
+	u.router.ServeHTTP(w, request)
+
+	u.Equal(http.StatusForbidden, w.Code)
+}
+
+func (u *UserHandlerTest) TestGetById_HidesPassword() {
+	id := "uuid-user-test"
+	u.userUc.On("GetUserByID", id).Return(entity.User{Id_user: id, Username: "testuser", Password: "$2a$10$hash"}, nil)
+	request, err := http.NewRequest("GET", "/api/v1/user/"+id, nil)
+	if err != nil {
+		u.T().Fatalf("error '%s' occured when creating the request", err)
+	}
+
+	w := httptest.NewRecorder()
+	u.router.ServeHTTP(w, request)
+
 	u.Equal(http.StatusOK, w.Code)
+	u.NotContains(w.Body.String(), "password")
+	u.NotContains(w.Body.String(), "$2a$10$hash")
 }
 
 func TestUserHandlerSuite(t *testing.T) {
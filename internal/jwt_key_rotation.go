@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/shared/service"
+	"time"
+)
+
+// RotateJwtKey adds a new token signing key to TOKEN_KEYS_DIR and removes the keys no token can still need.
+// Running servers pick the new key up within a minute, so by default a retired key is kept for that minute plus
+// the access token lifetime.
+//
+//	go run . rotate-jwt-key [-alg RS256|EdDSA] [-retain 30m]
+func RotateJwtKey(args []string) error {
+	cfg, err := config.NewConfig()
+	if err != nil {
+		return err
+	}
+
+	algorithm := cfg.JwtAlgorithm
+	if algorithm == service.AlgorithmHS256 {
+		algorithm = service.AlgorithmRS256
+	}
+
+	flags := flag.NewFlagSet("rotate-jwt-key", flag.ContinueOnError)
+	flags.StringVar(&algorithm, "alg", algorithm, "algorithm of the new key, RS256 or EdDSA")
+	retain := flags.Duration("retain", cfg.JwtExpiresTime+time.Minute, "how long a retired key keeps verifying tokens")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	kid, removed, err := service.RotateSigningKey(cfg.JwtKeysDir, algorithm, *retain)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("new %s signing key %s in %s, it signs tokens once servers have reloaded their keys\n", algorithm, kid, cfg.JwtKeysDir)
+	for _, old := range removed {
+		fmt.Printf("removed retired key %s\n", old)
+	}
+	if cfg.JwtAlgorithm == service.AlgorithmHS256 {
+		fmt.Println("TOKEN_ALGORITHM is HS256, set it to RS256 or EdDSA to sign with the key")
+	}
+
+	return nil
+}
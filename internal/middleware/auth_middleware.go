@@ -1,60 +1,111 @@
 package middleware
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/repository"
 	"server-pulsa-app/internal/shared/service"
+	"server-pulsa-app/internal/usecase"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type AuthMiddleware interface {
-	RequireToken(roles ...string) gin.HandlerFunc
+	RequirePermission(permissions ...string) gin.HandlerFunc
+	RequirePermissionOrAPIKey(permissions ...string) gin.HandlerFunc
 }
 
+// Headers of a request signed with a merchant api key.
+const (
+	APIKeyHeader       = "X-Api-Key"
+	APITimestampHeader = "X-Api-Timestamp"
+	APINonceHeader     = "X-Api-Nonce"
+	APISignatureHeader = "X-Api-Signature"
+)
+
+// maxSignedBodySize bounds the body read into memory to check a signature.
+const maxSignedBodySize = 1 << 20
+
 type authMiddleware struct {
-	jwtService service.JwtService
+	jwtService  service.JwtService
+	sessionRepo repository.AuthSessionRepository
+	roleRepo    repository.RoleRepository
+	apiKeyUc    usecase.APIKeyUseCase
 }
 
 type AuthHeader struct {
 	AuthorizationHeader string `header:"Authorization"`
 }
 
-func (a *authMiddleware) RequireToken(roles ...string) gin.HandlerFunc {
+// RequirePermission accepts requests carrying a valid access token of an active session whose role grants every
+// listed permission. Without permissions any logged in user is accepted.
+func (a *authMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var authHeader AuthHeader
 		if err := ctx.ShouldBindHeader(&authHeader); err != nil {
-			log.Printf("RequireToken: Error binding header: %v \n", err)
+			log.Printf("RequirePermission: Error binding header: %v \n", err)
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		tokenHeader := strings.TrimPrefix(authHeader.AuthorizationHeader, "Bearer ")
 		if tokenHeader == "" {
-			log.Println("RequireToken: Missing token")
+			log.Println("RequirePermission: Missing token")
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		claims, err := a.jwtService.ValidateToken(tokenHeader)
 		if err != nil {
-			log.Printf("RequireToken: Error parsing token: %v \n", err)
+			log.Printf("RequirePermission: Error parsing token: %v \n", err)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		if claims.SessionId == "" {
+			log.Println("RequirePermission: Missing session in token")
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		active, err := a.sessionRepo.IsSessionActive(claims.SessionId)
+		if err != nil {
+			log.Printf("RequirePermission: Error checking session: %v \n", err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			log.Println("RequirePermission: Session revoked")
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
 		ctx.Set("employee", claims.UserId)
+		ctx.Set("session", claims.SessionId)
 
 		role := claims.Role
 		if role == "" {
-			log.Println("RequireToken: Missing role in token")
+			log.Println("RequirePermission: Missing role in token")
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
+		ctx.Set("role", role)
 
-		if !isValidRole(role, roles) {
-			log.Println("RequireToken: Invalid role")
+		granted, err := a.roleRepo.Permissions(role)
+		if err != nil {
+			log.Printf("RequirePermission: Error getting role permissions: %v \n", err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if !hasPermissions(granted, permissions) {
+			log.Println("RequirePermission: Missing permission")
 			ctx.AbortWithStatus(http.StatusForbidden)
 			return
 		}
@@ -63,15 +114,90 @@ func (a *authMiddleware) RequireToken(roles ...string) gin.HandlerFunc {
 	}
 }
 
-func isValidRole(userRole string, validRoles []string) bool {
-	for _, role := range validRoles {
-		if userRole == role {
-			return true
+// RequirePermissionOrAPIKey also accepts requests signed with a merchant api key instead of a bearer token. Such
+// requests act as the user who created the key, need the same permissions from that user's role and carry the key's
+// merchant in the context, handlers must keep them to that merchant.
+func (a *authMiddleware) RequirePermissionOrAPIKey(permissions ...string) gin.HandlerFunc {
+	bearer := a.RequirePermission(permissions...)
+
+	return func(ctx *gin.Context) {
+		keyId := ctx.GetHeader(APIKeyHeader)
+		if keyId == "" {
+			bearer(ctx)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSignedBodySize))
+		if err != nil {
+			log.Printf("RequirePermissionOrAPIKey: Error reading body: %v \n", err)
+			ctx.AbortWithStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		key, err := a.apiKeyUc.Authenticate(entity.SignedRequest{
+			KeyId:     keyId,
+			Timestamp: ctx.GetHeader(APITimestampHeader),
+			Nonce:     ctx.GetHeader(APINonceHeader),
+			Signature: ctx.GetHeader(APISignatureHeader),
+			Method:    ctx.Request.Method,
+			URI:       ctx.Request.URL.RequestURI(),
+			Body:      body,
+			ClientIP:  ctx.ClientIP(),
+		})
+		if err != nil {
+			log.Printf("RequirePermissionOrAPIKey: Error authenticating api key: %v \n", err)
+			ctx.AbortWithStatus(apiKeyErrorStatus(err))
+			return
+		}
+
+		if key.OwnerRole == "" {
+			log.Println("RequirePermissionOrAPIKey: Api key owner has no role")
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		granted, err := a.roleRepo.Permissions(key.OwnerRole)
+		if err != nil {
+			log.Printf("RequirePermissionOrAPIKey: Error getting role permissions: %v \n", err)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if !hasPermissions(granted, permissions) {
+			log.Println("RequirePermissionOrAPIKey: Missing permission")
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		ctx.Set("employee", key.IdUser)
+		ctx.Set("role", key.OwnerRole)
+		ctx.Set("merchant", key.IdMerchant)
+		ctx.Set("api_key", key.Id)
+
+		ctx.Next()
+	}
+}
+
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrAPIKeysDisabled), errors.Is(err, usecase.ErrInvalidAPIKey), errors.Is(err, usecase.ErrInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.Is(err, usecase.ErrAPIKeyIPNotAllowed):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+func hasPermissions(granted, required []string) bool {
+	for _, permission := range required {
+		if !slices.Contains(granted, permission) {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
-func NewAuthMiddleware(jwtService service.JwtService) AuthMiddleware {
-	return &authMiddleware{jwtService: jwtService}
+func NewAuthMiddleware(jwtService service.JwtService, sessionRepo repository.AuthSessionRepository, roleRepo repository.RoleRepository, apiKeyUc usecase.APIKeyUseCase) AuthMiddleware {
+	return &authMiddleware{jwtService: jwtService, sessionRepo: sessionRepo, roleRepo: roleRepo, apiKeyUc: apiKeyUc}
 }
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server-pulsa-app/internal/entity"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/usecase"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+// apiKeyUseCaseStub authenticates every signed request as key.
+type apiKeyUseCaseStub struct {
+	usecase.APIKeyUseCase
+	key entity.APIKey
+}
+
+func (a *apiKeyUseCaseStub) Authenticate(req entity.SignedRequest) (entity.APIKey, error) {
+	return a.key, nil
+}
+
+type authMiddlewareSuite struct {
+	suite.Suite
+	roleRepo *repositorymock.MockRoleRepository
+	apiKeyUc *apiKeyUseCaseStub
+	engine   *gin.Engine
+	role     string
+}
+
+func (a *authMiddlewareSuite) SetupTest() {
+	a.roleRepo = new(repositorymock.MockRoleRepository)
+	a.apiKeyUc = &apiKeyUseCaseStub{key: entity.APIKey{Id: "key-1", IdMerchant: "merchant-1", IdUser: "user-1", OwnerRole: "cashier"}}
+	a.role = ""
+
+	gin.SetMode(gin.TestMode)
+	a.engine = gin.New()
+	auth := NewAuthMiddleware(nil, nil, a.roleRepo, a.apiKeyUc)
+	a.engine.POST("/transaction", auth.RequirePermissionOrAPIKey(entity.PermTransactionCreate), func(ctx *gin.Context) {
+		a.role = ctx.GetString("role")
+		ctx.Status(http.StatusCreated)
+	})
+}
+
+func TestAuthMiddlewareSuite(t *testing.T) {
+	suite.Run(t, new(authMiddlewareSuite))
+}
+
+func (a *authMiddlewareSuite) signedRequest() *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/transaction", nil)
+	req.Header.Set(APIKeyHeader, "key-1")
+	rec := httptest.NewRecorder()
+	a.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func (a *authMiddlewareSuite) TestAPIKey_OwnerWithPermission() {
+	a.roleRepo.On("Permissions", "cashier").Return([]string{entity.PermTransactionCreate}, nil).Once()
+
+	rec := a.signedRequest()
+
+	a.Equal(http.StatusCreated, rec.Code)
+	a.Equal("cashier", a.role)
+}
+
+func (a *authMiddlewareSuite) TestAPIKey_OwnerWithoutPermission() {
+	a.roleRepo.On("Permissions", "cashier").Return([]string{entity.PermTransactionRead}, nil).Once()
+
+	rec := a.signedRequest()
+
+	a.Equal(http.StatusForbidden, rec.Code)
+}
+
+func (a *authMiddlewareSuite) TestAPIKey_OwnerGone() {
+	a.apiKeyUc.key.OwnerRole = ""
+
+	rec := a.signedRequest()
+
+	a.Equal(http.StatusUnauthorized, rec.Code)
+	a.roleRepo.AssertNotCalled(a.T(), "Permissions", "")
+}
@@ -9,6 +9,10 @@ type AuthMiddlewareMock struct {
 	mock.Mock
 }
 
-func (m *AuthMiddlewareMock) RequireToken(roles ...string) gin.HandlerFunc {
+func (m *AuthMiddlewareMock) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {}
+}
+
+func (m *AuthMiddlewareMock) RequirePermissionOrAPIKey(permissions ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {}
 }
@@ -9,6 +9,10 @@ type AuthMiddlewareMock struct {
 	mock.Mock
 }
 
-func (a *AuthMiddlewareMock) RequireToken(roles ...string) gin.HandlerFunc {
+func (a *AuthMiddlewareMock) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {}
+}
+
+func (a *AuthMiddlewareMock) RequirePermissionOrAPIKey(permissions ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {}
 }
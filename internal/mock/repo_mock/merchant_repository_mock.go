@@ -39,3 +39,13 @@ func (m *MerchantRepoMock) Delete(id string) error {
 	args := m.Called(id)
 	return args.Error(0)
 }
+
+func (m *MerchantRepoMock) ListMutations(id string, page, limit int) ([]entity.LedgerEntry, int, error) {
+	args := m.Called(id, page, limit)
+	return args.Get(0).([]entity.LedgerEntry), args.Int(1), args.Error(2)
+}
+
+func (m *MerchantRepoMock) AdjustBalance(entry entity.LedgerEntry, audit entity.AuditLog) (entity.LedgerEntry, error) {
+	args := m.Called(entry, audit)
+	return args.Get(0).(entity.LedgerEntry), args.Error(1)
+}
@@ -39,3 +39,18 @@ func (u *UserRepoMock) DeleteUser(id string) error {
 	args := u.Called(id)
 	return args.Error(0)
 }
+
+func (u *UserRepoMock) CreateUserWithMerchant(payload entity.User, idMerchant string) (entity.User, error) {
+	args := u.Called(payload, idMerchant)
+	return args.Get(0).(entity.User), args.Error(1)
+}
+
+func (u *UserRepoMock) CreateFirstUser(payload entity.User) (entity.User, bool, error) {
+	args := u.Called(payload)
+	return args.Get(0).(entity.User), args.Bool(1), args.Error(2)
+}
+
+func (u *UserRepoMock) UpdatePassword(id, passwordHash string) error {
+	args := u.Called(id, passwordHash)
+	return args.Error(0)
+}
@@ -0,0 +1,42 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) Create(key entity.APIKey) (entity.APIKey, error) {
+	args := m.Called(key)
+	return args.Get(0).(entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Get(id string) (entity.APIKey, error) {
+	args := m.Called(id)
+	return args.Get(0).(entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) List(idMerchant string) ([]entity.APIKey, error) {
+	args := m.Called(idMerchant)
+	return args.Get(0).([]entity.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Revoke(idMerchant, id string) error {
+	args := m.Called(idMerchant, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) UseNonce(id, nonce string, expiresAt time.Time) (bool, error) {
+	args := m.Called(id, nonce, expiresAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) Touch(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
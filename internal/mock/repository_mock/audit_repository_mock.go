@@ -0,0 +1,21 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Create(entry entity.AuditLog) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) List(filter entity.AuditFilter) ([]entity.AuditLog, int, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]entity.AuditLog), args.Int(1), args.Error(2)
+}
@@ -0,0 +1,37 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuthSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuthSessionRepository) CreateSession(idUser, refreshHash string, expiresAt time.Time) (string, error) {
+	args := m.Called(idUser, refreshHash, expiresAt)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAuthSessionRepository) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (entity.AuthSession, error) {
+	args := m.Called(oldHash, newHash, expiresAt)
+	return args.Get(0).(entity.AuthSession), args.Error(1)
+}
+
+func (m *MockAuthSessionRepository) RevokeByRefreshToken(refreshHash, reason string) error {
+	args := m.Called(refreshHash, reason)
+	return args.Error(0)
+}
+
+func (m *MockAuthSessionRepository) IsSessionActive(idSession string) (bool, error) {
+	args := m.Called(idSession)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAuthSessionRepository) RevokeUserSessions(idUser, exceptSession, reason string) error {
+	args := m.Called(idUser, exceptSession, reason)
+	return args.Error(0)
+}
@@ -0,0 +1,33 @@
+package repositorymock
+
+import (
+	"time"
+
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockFulfillmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockFulfillmentRepository) ClaimPending(claimedBefore time.Time, limit int) ([]entity.SupplierOrder, error) {
+	args := m.Called(claimedBefore, limit)
+	return args.Get(0).([]entity.SupplierOrder), args.Error(1)
+}
+
+func (m *MockFulfillmentRepository) MarkSuccess(order entity.SupplierOrder, result entity.SupplierResult) error {
+	args := m.Called(order, result)
+	return args.Error(0)
+}
+
+func (m *MockFulfillmentRepository) MarkFailed(order entity.SupplierOrder, result entity.SupplierResult, audit entity.AuditLog) error {
+	args := m.Called(order, result, audit)
+	return args.Error(0)
+}
+
+func (m *MockFulfillmentRepository) Release(order entity.SupplierOrder) error {
+	args := m.Called(order)
+	return args.Error(0)
+}
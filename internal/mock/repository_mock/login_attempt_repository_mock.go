@@ -0,0 +1,31 @@
+package repositorymock
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginAttemptRepository) BlockedUntil(username, ip string) (time.Time, error) {
+	args := m.Called(username, ip)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) RecordFailure(scope, key string, window time.Duration) (int, error) {
+	args := m.Called(scope, key, window)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockLoginAttemptRepository) Block(scope, key string, until time.Time) error {
+	args := m.Called(scope, key, until)
+	return args.Error(0)
+}
+
+func (m *MockLoginAttemptRepository) Reset(scope, key string) error {
+	args := m.Called(scope, key)
+	return args.Error(0)
+}
@@ -0,0 +1,57 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) Get(idUser string) (entity.UserMFA, error) {
+	args := m.Called(idUser)
+	return args.Get(0).(entity.UserMFA), args.Error(1)
+}
+
+func (m *MockMFARepository) SavePending(idUser, secret string) error {
+	args := m.Called(idUser, secret)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) Enable(idUser string, step int64, recoveryHashes []string) error {
+	args := m.Called(idUser, step, recoveryHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) UseStep(idUser string, step int64) (bool, error) {
+	args := m.Called(idUser, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) UseRecoveryCode(idUser, codeHash string) (bool, error) {
+	args := m.Called(idUser, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) Delete(idUser string) error {
+	args := m.Called(idUser)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) CreateChallenge(tokenHash, idUser string, expiresAt time.Time) error {
+	args := m.Called(tokenHash, idUser, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) ChallengeUser(tokenHash string, maxAttempts int) (string, error) {
+	args := m.Called(tokenHash, maxAttempts)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockMFARepository) DeleteChallenge(tokenHash string) error {
+	args := m.Called(tokenHash)
+	return args.Error(0)
+}
@@ -0,0 +1,31 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOperatorPrefixRepository struct {
+	mock.Mock
+}
+
+func (m *MockOperatorPrefixRepository) List() ([]entity.OperatorPrefix, error) {
+	args := m.Called()
+	return args.Get(0).([]entity.OperatorPrefix), args.Error(1)
+}
+
+func (m *MockOperatorPrefixRepository) Save(payload entity.OperatorPrefix) (entity.OperatorPrefix, error) {
+	args := m.Called(payload)
+	return args.Get(0).(entity.OperatorPrefix), args.Error(1)
+}
+
+func (m *MockOperatorPrefixRepository) Delete(prefix string) error {
+	args := m.Called(prefix)
+	return args.Error(0)
+}
+
+func (m *MockOperatorPrefixRepository) FindByNumber(number string) (entity.OperatorPrefix, error) {
+	args := m.Called(number)
+	return args.Get(0).(entity.OperatorPrefix), args.Error(1)
+}
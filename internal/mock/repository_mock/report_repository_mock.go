@@ -0,0 +1,22 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/shared/custom"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockReportRepository struct {
+	mock.Mock
+}
+
+func (m *MockReportRepository) List(userId, startDate, endDate string) ([]custom.ReportResp, error) {
+	args := m.Called(userId, startDate, endDate)
+	return args.Get(0).([]custom.ReportResp), args.Error(1)
+}
+
+func (m *MockReportRepository) Profit(startDate, endDate time.Time, merchantId string) ([]custom.ProfitReport, error) {
+	args := m.Called(startDate, endDate, merchantId)
+	return args.Get(0).([]custom.ProfitReport), args.Error(1)
+}
@@ -0,0 +1,46 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepository) List() ([]entity.Role, error) {
+	args := m.Called()
+	return args.Get(0).([]entity.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) Get(name string) (entity.Role, error) {
+	args := m.Called(name)
+	return args.Get(0).(entity.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) Create(payload entity.Role) (entity.Role, error) {
+	args := m.Called(payload)
+	return args.Get(0).(entity.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) Update(payload entity.Role) (entity.Role, error) {
+	args := m.Called(payload)
+	return args.Get(0).(entity.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) Delete(name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) Permissions(role string) ([]string, error) {
+	args := m.Called(role)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRoleRepository) SetRequireMFA(name string, required bool) error {
+	args := m.Called(name, required)
+	return args.Error(0)
+}
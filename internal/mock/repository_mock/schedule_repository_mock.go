@@ -0,0 +1,47 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduleRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduleRepository) Create(payload entity.TransactionSchedule) (entity.TransactionSchedule, error) {
+	args := m.Called(payload)
+	return args.Get(0).(entity.TransactionSchedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) List(userId string) ([]entity.TransactionSchedule, error) {
+	args := m.Called(userId)
+	return args.Get(0).([]entity.TransactionSchedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) Get(id string) (entity.TransactionSchedule, error) {
+	args := m.Called(id)
+	return args.Get(0).(entity.TransactionSchedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) Deactivate(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) ListRuns(id string) ([]entity.TransactionScheduleRun, error) {
+	args := m.Called(id)
+	return args.Get(0).([]entity.TransactionScheduleRun), args.Error(1)
+}
+
+func (m *MockScheduleRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]entity.TransactionSchedule, error) {
+	args := m.Called(now, lease, limit)
+	return args.Get(0).([]entity.TransactionSchedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) Finish(schedule entity.TransactionSchedule, run entity.TransactionScheduleRun) error {
+	args := m.Called(schedule, run)
+	return args.Error(0)
+}
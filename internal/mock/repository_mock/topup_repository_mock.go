@@ -0,0 +1,113 @@
+package repositorymock
+
+import (
+	"database/sql"
+	"server-pulsa-app/internal/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTopupRepository struct {
+	mock.Mock
+}
+
+func (m *MockTopupRepository) CreateTopup(payload entity.TopupRequest, limits []entity.TopupLimit) (string, error) {
+	args := m.Called(payload, limits)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTopupRepository) CreateTopupIdempotent(payload entity.TopupRequest, key entity.IdempotencyKey, lease time.Duration, limits []entity.TopupLimit) (string, entity.IdempotencyKey, bool, error) {
+	args := m.Called(payload, key, lease, limits)
+	return args.String(0), args.Get(1).(entity.IdempotencyKey), args.Bool(2), args.Error(3)
+}
+
+func (m *MockTopupRepository) CompleteIdempotencyKey(key entity.IdempotencyKey) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) ReleaseIdempotencyKey(key entity.IdempotencyKey, idTopup string) error {
+	args := m.Called(key, idTopup)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) CancelTopup(idTopup string) error {
+	args := m.Called(idTopup)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) GetTopupById(tx *sql.Tx, id string) (entity.TopupRequest, error) {
+	args := m.Called(tx, id)
+	return args.Get(0).(entity.TopupRequest), args.Error(1)
+}
+
+func (m *MockTopupRepository) FindTopup(id string) (entity.TopupRequest, error) {
+	args := m.Called(id)
+	return args.Get(0).(entity.TopupRequest), args.Error(1)
+}
+
+func (m *MockTopupRepository) GetTopupByMerchantId(idMerchant string) ([]entity.TopupRequestDetail, error) {
+	args := m.Called(idMerchant)
+	return args.Get(0).([]entity.TopupRequestDetail), args.Error(1)
+}
+
+func (m *MockTopupRepository) UpdateStatus(tx *sql.Tx, status, idTopup string) error {
+	args := m.Called(tx, status, idTopup)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) UpdatePaymentMethod(tx *sql.Tx, paymentMethod, idTopup string) error {
+	args := m.Called(tx, paymentMethod, idTopup)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) UpdateBalanceMerchant(tx *sql.Tx, balance int, idMerchant, idTopup string) error {
+	args := m.Called(tx, balance, idMerchant, idTopup)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) UpdateBalanceSupliyer(tx *sql.Tx, balance int, idSupliyer string) error {
+	args := m.Called(tx, balance, idSupliyer)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) TxTopupUpdateAfterPayment(payload entity.TopupRequest, audit entity.AuditLog) error {
+	args := m.Called(payload, audit)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) ClaimPending(createdBefore, checkedBefore time.Time, limit int) ([]entity.TopupRequest, error) {
+	args := m.Called(createdBefore, checkedBefore, limit)
+	return args.Get(0).([]entity.TopupRequest), args.Error(1)
+}
+
+func (m *MockTopupRepository) CreateManualTopup(payload entity.TopupRequest, proof entity.TopupProof, limits []entity.TopupLimit) (string, error) {
+	args := m.Called(payload, proof, limits)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTopupRepository) ListPendingManual() ([]entity.TopupRequest, error) {
+	args := m.Called()
+	return args.Get(0).([]entity.TopupRequest), args.Error(1)
+}
+
+func (m *MockTopupRepository) GetTopupProof(idTopup string) (entity.TopupProof, error) {
+	args := m.Called(idTopup)
+	return args.Get(0).(entity.TopupProof), args.Error(1)
+}
+
+func (m *MockTopupRepository) ReviewManualTopup(payload entity.TopupRequest, audit entity.AuditLog) error {
+	args := m.Called(payload, audit)
+	return args.Error(0)
+}
+
+func (m *MockTopupRepository) GetTopupByUser(idUser string) ([]entity.TopupRequestDetail, error) {
+	args := m.Called(idUser)
+	return args.Get(0).([]entity.TopupRequestDetail), args.Error(1)
+}
+
+func (m *MockTopupRepository) MerchantIdsByUser(idUser string) ([]string, error) {
+	args := m.Called(idUser)
+	return args.Get(0).([]string), args.Error(1)
+}
@@ -0,0 +1,41 @@
+package repositorymock
+
+import (
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockTopupSettingRepository struct {
+	mock.Mock
+}
+
+func (m *MockTopupSettingRepository) Get() (entity.TopupSetting, error) {
+	args := m.Called()
+	return args.Get(0).(entity.TopupSetting), args.Error(1)
+}
+
+func (m *MockTopupSettingRepository) Save(payload entity.TopupSetting) (entity.TopupSetting, error) {
+	args := m.Called(payload)
+	return args.Get(0).(entity.TopupSetting), args.Error(1)
+}
+
+func (m *MockTopupSettingRepository) ListFees() ([]entity.TopupFee, error) {
+	args := m.Called()
+	return args.Get(0).([]entity.TopupFee), args.Error(1)
+}
+
+func (m *MockTopupSettingRepository) SaveFee(payload entity.TopupFee) (entity.TopupFee, error) {
+	args := m.Called(payload)
+	return args.Get(0).(entity.TopupFee), args.Error(1)
+}
+
+func (m *MockTopupSettingRepository) DeleteFee(paymentMethod string) error {
+	args := m.Called(paymentMethod)
+	return args.Error(0)
+}
+
+func (m *MockTopupSettingRepository) FindFee(paymentMethod string) (entity.TopupFee, error) {
+	args := m.Called(paymentMethod)
+	return args.Get(0).(entity.TopupFee), args.Error(1)
+}
@@ -16,12 +16,22 @@ func (m *MockTransactionRepository) Create(payload entity.Transactions) (entity.
 	return args.Get(0).(entity.Transactions), args.Error(1)
 }
 
-func (m *MockTransactionRepository) GetAll(userId string) ([]custom.TransactionsReq, error) {
-	args := m.Called()
-	return args.Get(0).([]custom.TransactionsReq), args.Error(1)
+func (m *MockTransactionRepository) CreateIdempotent(payload entity.Transactions, key entity.IdempotencyKey) (entity.Transactions, entity.IdempotencyKey, bool, error) {
+	args := m.Called(payload, key)
+	return args.Get(0).(entity.Transactions), args.Get(1).(entity.IdempotencyKey), args.Bool(2), args.Error(3)
+}
+
+func (m *MockTransactionRepository) GetAll(filter entity.TransactionFilter) ([]custom.TransactionsReq, int, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]custom.TransactionsReq), args.Int(1), args.Error(2)
 }
 
 func (m *MockTransactionRepository) GetById(id string) (custom.TransactionsReq, error) {
 	args := m.Called(id)
 	return args.Get(0).(custom.TransactionsReq), args.Error(1)
 }
+
+func (m *MockTransactionRepository) Refund(payload entity.TransactionRefund, audit entity.AuditLog) (entity.TransactionRefund, error) {
+	args := m.Called(payload, audit)
+	return args.Get(0).(entity.TransactionRefund), args.Error(1)
+}
@@ -12,8 +12,8 @@ type JwtServiceMock struct {
 	mock.Mock
 }
 
-func (j *JwtServiceMock) CreateToken(user entity.User) (dto.AuthResponseDto, error) {
-	args := j.Called(user)
+func (j *JwtServiceMock) CreateToken(user entity.User, sessionId string) (dto.AuthResponseDto, error) {
+	args := j.Called(user, sessionId)
 	return args.Get(0).(dto.AuthResponseDto), args.Error(1)
 }
 
@@ -21,3 +21,8 @@ func (j *JwtServiceMock) ValidateToken(tokenString string) (*model.Claim, error)
 	args := j.Called(tokenString)
 	return args.Get(0).(*model.Claim), args.Error(1)
 }
+
+func (j *JwtServiceMock) JWKS() model.JWKSet {
+	args := j.Called()
+	return args.Get(0).(model.JWKSet)
+}
@@ -0,0 +1,21 @@
+package usecase_mock
+
+import (
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/shared/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type AuditUseCaseMock struct {
+	mock.Mock
+}
+
+func (m *AuditUseCaseMock) Record(actor entity.AuditActor, action, entityType, entityId string, before, after any) {
+	m.Called(actor, action, entityType, entityId, before, after)
+}
+
+func (m *AuditUseCaseMock) List(filter entity.AuditFilter) ([]entity.AuditLog, model.Paging, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]entity.AuditLog), args.Get(1).(model.Paging), args.Error(2)
+}
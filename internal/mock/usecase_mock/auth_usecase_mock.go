@@ -11,12 +11,37 @@ type AuthUseCaseMock struct {
 	mock.Mock
 }
 
-func (a *AuthUseCaseMock) Login(payload dto.AuthRequestDto) (dto.AuthResponseDto, error) {
-	args := a.Called(payload)
+func (a *AuthUseCaseMock) Login(payload dto.AuthRequestDto, clientIP string) (dto.AuthResponseDto, error) {
+	args := a.Called(payload, clientIP)
 	return args.Get(0).(dto.AuthResponseDto), args.Error(1)
 }
 
-func (a *AuthUseCaseMock) Register(payload dto.AuthRequestDto) (entity.User, error) {
-	args := a.Called(payload)
+func (a *AuthUseCaseMock) Register(payload dto.AuthRequestDto, clientIP string) (entity.User, error) {
+	args := a.Called(payload, clientIP)
 	return args.Get(0).(entity.User), args.Error(1)
 }
+
+func (a *AuthUseCaseMock) Refresh(refreshToken string) (dto.AuthResponseDto, error) {
+	args := a.Called(refreshToken)
+	return args.Get(0).(dto.AuthResponseDto), args.Error(1)
+}
+
+func (a *AuthUseCaseMock) Logout(refreshToken string) error {
+	args := a.Called(refreshToken)
+	return args.Error(0)
+}
+
+func (a *AuthUseCaseMock) UnlockUser(idUser string, actor entity.AuditActor) error {
+	args := a.Called(idUser, actor)
+	return args.Error(0)
+}
+
+func (a *AuthUseCaseMock) VerifyMFA(payload dto.MFAVerifyRequestDto, clientIP string) (dto.AuthResponseDto, error) {
+	args := a.Called(payload, clientIP)
+	return args.Get(0).(dto.AuthResponseDto), args.Error(1)
+}
+
+func (a *AuthUseCaseMock) EnrollMFA(challengeToken string) (entity.MFAEnrollment, error) {
+	args := a.Called(challengeToken)
+	return args.Get(0).(entity.MFAEnrollment), args.Error(1)
+}
@@ -2,6 +2,7 @@ package usecase_mock
 
 import (
 	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/shared/model"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -10,8 +11,8 @@ type MerchantUsecaseMock struct {
 	mock.Mock
 }
 
-func (m *MerchantUsecaseMock) RegisterNewMerchant(payload entity.Merchant) (entity.Merchant, error) {
-	args := m.Called(payload)
+func (m *MerchantUsecaseMock) RegisterNewMerchant(payload entity.Merchant, actor entity.AuditActor) (entity.Merchant, error) {
+	args := m.Called(payload, actor)
 	return args.Get(0).(entity.Merchant), args.Error(1)
 }
 
@@ -25,12 +26,27 @@ func (m *MerchantUsecaseMock) FindMerchantByID(id string) (entity.Merchant, erro
 	return args.Get(0).(entity.Merchant), args.Error(1)
 }
 
-func (m *MerchantUsecaseMock) UpdateMerchant(payload entity.Merchant) (entity.Merchant, error) {
-	args := m.Called(payload)
+func (m *MerchantUsecaseMock) UpdateMerchant(payload entity.Merchant, actor entity.AuditActor) (entity.Merchant, error) {
+	args := m.Called(payload, actor)
 	return args.Get(0).(entity.Merchant), args.Error(1)
 }
 
-func (m *MerchantUsecaseMock) DeleteMerchant(id string) error {
-	args := m.Called(id)
+func (m *MerchantUsecaseMock) DeleteMerchant(id string, actor entity.AuditActor) error {
+	args := m.Called(id, actor)
 	return args.Error(0)
 }
+
+func (m *MerchantUsecaseMock) FindMutations(id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error) {
+	args := m.Called(id, page, limit)
+	return args.Get(0).([]entity.LedgerEntry), args.Get(1).(model.Paging), args.Error(2)
+}
+
+func (m *MerchantUsecaseMock) FindOwnMutations(idUser, id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error) {
+	args := m.Called(idUser, id, page, limit)
+	return args.Get(0).([]entity.LedgerEntry), args.Get(1).(model.Paging), args.Error(2)
+}
+
+func (m *MerchantUsecaseMock) AdjustBalance(payload entity.LedgerEntry, actor entity.AuditActor) (entity.LedgerEntry, error) {
+	args := m.Called(payload, actor)
+	return args.Get(0).(entity.LedgerEntry), args.Error(1)
+}
@@ -0,0 +1,57 @@
+package usecase_mock
+
+import (
+	"server-pulsa-app/internal/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MFAUseCaseMock struct {
+	mock.Mock
+}
+
+func (m *MFAUseCaseMock) Status(idUser string) (entity.UserMFA, error) {
+	args := m.Called(idUser)
+	return args.Get(0).(entity.UserMFA), args.Error(1)
+}
+
+func (m *MFAUseCaseMock) Enroll(idUser string) (entity.MFAEnrollment, error) {
+	args := m.Called(idUser)
+	return args.Get(0).(entity.MFAEnrollment), args.Error(1)
+}
+
+func (m *MFAUseCaseMock) Confirm(idUser, code string) ([]string, error) {
+	args := m.Called(idUser, code)
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
+func (m *MFAUseCaseMock) Verify(idUser, code string) error {
+	args := m.Called(idUser, code)
+	return args.Error(0)
+}
+
+func (m *MFAUseCaseMock) Disable(idUser, code string) error {
+	args := m.Called(idUser, code)
+	return args.Error(0)
+}
+
+func (m *MFAUseCaseMock) Reset(idUser string, actor entity.AuditActor) error {
+	args := m.Called(idUser, actor)
+	return args.Error(0)
+}
+
+func (m *MFAUseCaseMock) NewChallenge(idUser string) (string, error) {
+	args := m.Called(idUser)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MFAUseCaseMock) ChallengeUser(token string) (string, error) {
+	args := m.Called(token)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MFAUseCaseMock) CloseChallenge(token string) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
@@ -11,8 +11,8 @@ type ProductUseCaseMock struct {
 }
 
 // Create adalah mock dari metode Create
-func (m *ProductUseCaseMock) CreateNewProduct(product entity.Product) (entity.Product, error) {
-	args := m.Called(product)
+func (m *ProductUseCaseMock) CreateNewProduct(product entity.Product, actor entity.AuditActor) (entity.Product, error) {
+	args := m.Called(product, actor)
 	return args.Get(0).(entity.Product), args.Error(1)
 }
 
@@ -29,13 +29,13 @@ func (m *ProductUseCaseMock) FindProductById(id string) (entity.Product, error)
 }
 
 // Update adalah mock dari metode Update
-func (m *ProductUseCaseMock) UpdateProduct(product entity.Product) (entity.Product, error) {
-	args := m.Called(product)
+func (m *ProductUseCaseMock) UpdateProduct(product entity.Product, actor entity.AuditActor) (entity.Product, error) {
+	args := m.Called(product, actor)
 	return args.Get(0).(entity.Product), args.Error(1)
 }
 
 // Delete adalah mock dari metode Delete
-func (m *ProductUseCaseMock) DeleteProduct(id string) error {
-	args := m.Called(id)
+func (m *ProductUseCaseMock) DeleteProduct(id string, actor entity.AuditActor) error {
+	args := m.Called(id, actor)
 	return args.Error(0)
 }
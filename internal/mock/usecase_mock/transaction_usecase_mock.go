@@ -1,8 +1,10 @@
 package usecase_mock
 
 import (
+	"io"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/shared/custom"
+	"server-pulsa-app/internal/shared/model"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -11,17 +13,37 @@ type MockTransactionUseCase struct {
 	mock.Mock
 }
 
-func (m *MockTransactionUseCase) Create(payload entity.Transactions) (entity.Transactions, error) {
-	args := m.Called(payload)
+func (m *MockTransactionUseCase) Create(payload entity.Transactions, actor entity.AuditActor) (entity.Transactions, error) {
+	args := m.Called(payload, actor)
 	return args.Get(0).(entity.Transactions), args.Error(1)
 }
 
-func (m *MockTransactionUseCase) GetAll(userId string) ([]custom.TransactionsReq, error) {
-	args := m.Called()
-	return args.Get(0).([]custom.TransactionsReq), args.Error(1)
+func (m *MockTransactionUseCase) CreateIdempotent(payload entity.Transactions, key entity.IdempotencyKey, actor entity.AuditActor) (entity.Transactions, bool, error) {
+	args := m.Called(payload, key, actor)
+	return args.Get(0).(entity.Transactions), args.Bool(1), args.Error(2)
+}
+
+func (m *MockTransactionUseCase) GetAll(filter entity.TransactionFilter) ([]custom.TransactionsReq, model.Paging, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]custom.TransactionsReq), args.Get(1).(model.Paging), args.Error(2)
 }
 
 func (m *MockTransactionUseCase) GetById(id string) (custom.TransactionsReq, error) {
 	args := m.Called(id)
 	return args.Get(0).(custom.TransactionsReq), args.Error(1)
 }
+
+func (m *MockTransactionUseCase) Refund(payload entity.TransactionRefund, actor entity.AuditActor) (entity.TransactionRefund, error) {
+	args := m.Called(payload, actor)
+	return args.Get(0).(entity.TransactionRefund), args.Error(1)
+}
+
+func (m *MockTransactionUseCase) MerchantForUser(idUser, idMerchant string) (string, error) {
+	args := m.Called(idUser, idMerchant)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTransactionUseCase) BulkCreate(template entity.Transactions, filename string, file io.Reader, actor entity.AuditActor) (entity.BulkTransactionResult, error) {
+	args := m.Called(template, filename, file, actor)
+	return args.Get(0).(entity.BulkTransactionResult), args.Error(1)
+}
@@ -10,8 +10,8 @@ type UserUseCaseMock struct {
 	mock.Mock
 }
 
-func (u *UserUseCaseMock) RegisterUser(payload entity.User) (entity.User, error) {
-	args := u.Called(payload)
+func (u *UserUseCaseMock) RegisterUser(payload entity.User, actor entity.AuditActor) (entity.User, error) {
+	args := u.Called(payload, actor)
 	return args.Get(0).(entity.User), args.Error(1)
 }
 
@@ -35,12 +35,32 @@ func (u *UserUseCaseMock) ListUser() ([]entity.User, error) {
 	return args.Get(0).([]entity.User), args.Error(1)
 }
 
-func (u *UserUseCaseMock) UpdateUser(payload entity.User) (entity.User, error) {
-	args := u.Called(payload)
+func (u *UserUseCaseMock) UpdateUser(payload entity.User, actor entity.AuditActor) (entity.User, error) {
+	args := u.Called(payload, actor)
 	return args.Get(0).(entity.User), args.Error(1)
 }
 
-func (u *UserUseCaseMock) DeleteUser(id string) error {
-	args := u.Called(id)
+func (u *UserUseCaseMock) DeleteUser(id string, actor entity.AuditActor) error {
+	args := u.Called(id, actor)
+	return args.Error(0)
+}
+
+func (u *UserUseCaseMock) CreateUser(payload entity.UserCreateRequest, actor entity.AuditActor) (entity.User, error) {
+	args := u.Called(payload, actor)
+	return args.Get(0).(entity.User), args.Error(1)
+}
+
+func (u *UserUseCaseMock) BootstrapAdmin(username, password string) (bool, error) {
+	args := u.Called(username, password)
+	return args.Bool(0), args.Error(1)
+}
+
+func (u *UserUseCaseMock) UpdateProfile(id string, payload entity.ProfileUpdateRequest, actor entity.AuditActor) (entity.User, error) {
+	args := u.Called(id, payload, actor)
+	return args.Get(0).(entity.User), args.Error(1)
+}
+
+func (u *UserUseCaseMock) ChangePassword(id, idSession string, payload entity.ChangePasswordRequest, actor entity.AuditActor) error {
+	args := u.Called(id, idSession, payload, actor)
 	return args.Error(0)
 }
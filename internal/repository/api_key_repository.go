@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository interface {
+	Create(key entity.APIKey) (entity.APIKey, error)
+	Get(id string) (entity.APIKey, error)
+	List(idMerchant string) ([]entity.APIKey, error)
+	Revoke(idMerchant, id string) error
+	UseNonce(id, nonce string, expiresAt time.Time) (bool, error)
+	Touch(id string) error
+}
+
+type apiKeyRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+const apiKeyColumns = "id, id_merchant, id_user, name, secret_hash, allowed_ips, created_at, last_used_at, revoked_at"
+
+// Create stores a new key. It returns ErrMerchantNotFound when the merchant doesn't exist.
+func (a *apiKeyRepository) Create(key entity.APIKey) (entity.APIKey, error) {
+	err := a.db.QueryRow(`
+		INSERT INTO merchant_api_key (id, id_merchant, id_user, name, secret_hash, allowed_ips)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`,
+		key.Id, key.IdMerchant, key.IdUser, key.Name, key.SecretHash, pq.Array(key.AllowedIPs),
+	).Scan(&key.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return entity.APIKey{}, ErrMerchantNotFound
+	} else if err != nil {
+		a.log.Error("Failed to create the api key: ", err)
+		return entity.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// Get returns the key with the current role of its owner, empty when the owner no longer exists.
+func (a *apiKeyRepository) Get(id string) (entity.APIKey, error) {
+	var ownerRole string
+	key, err := scanAPIKey(a.db.QueryRow("SELECT "+apiKeyColumns+", COALESCE((SELECT role FROM mst_user u WHERE u.id_user = k.id_user), '') FROM merchant_api_key k WHERE k.id = $1", id), &ownerRole)
+	key.OwnerRole = ownerRole
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		a.log.Error("Failed to get the api key: ", err)
+	}
+
+	return key, err
+}
+
+// List returns the keys of a merchant, revoked ones included, newest first.
+func (a *apiKeyRepository) List(idMerchant string) ([]entity.APIKey, error) {
+	rows, err := a.db.Query("SELECT "+apiKeyColumns+" FROM merchant_api_key WHERE id_merchant = $1 ORDER BY created_at DESC", idMerchant)
+	if err != nil {
+		a.log.Error("Failed to list the api keys: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []entity.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			a.log.Error("Failed to scan the api key: ", err)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Revoke disables a key of a merchant for good. It returns sql.ErrNoRows when the merchant has no such active key.
+func (a *apiKeyRepository) Revoke(idMerchant, id string) error {
+	res, err := a.db.Exec("UPDATE merchant_api_key SET revoked_at = NOW() WHERE id = $1 AND id_merchant = $2 AND revoked_at IS NULL", id, idMerchant)
+	if err != nil {
+		a.log.Error("Failed to revoke the api key: ", err)
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UseNonce remembers the nonce of a signed request until expiresAt. It returns false when the key already used
+// it, so a captured request can't be replayed.
+func (a *apiKeyRepository) UseNonce(id, nonce string, expiresAt time.Time) (bool, error) {
+	if _, err := a.db.Exec("DELETE FROM api_key_nonce WHERE id_key = $1 AND expires_at < NOW()", id); err != nil {
+		a.log.Error("Failed to clear the expired nonces: ", err)
+		return false, err
+	}
+
+	res, err := a.db.Exec("INSERT INTO api_key_nonce (id_key, nonce, expires_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", id, nonce, expiresAt)
+	if err != nil {
+		a.log.Error("Failed to store the nonce: ", err)
+		return false, err
+	}
+
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+func (a *apiKeyRepository) Touch(id string) error {
+	if _, err := a.db.Exec("UPDATE merchant_api_key SET last_used_at = NOW() WHERE id = $1", id); err != nil {
+		a.log.Error("Failed to update the api key last use: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// scanAPIKey scans the apiKeyColumns of a row, followed by any extra columns into extra.
+func scanAPIKey(row interface{ Scan(...any) error }, extra ...any) (entity.APIKey, error) {
+	var key entity.APIKey
+	dest := []any{&key.Id, &key.IdMerchant, &key.IdUser, &key.Name, &key.SecretHash, pq.Array(&key.AllowedIPs), &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return entity.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func NewAPIKeyRepository(db *sql.DB, log *logger.Logger) APIKeyRepository {
+	return &apiKeyRepository{db: db, log: log}
+}
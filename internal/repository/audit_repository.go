@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"strings"
+)
+
+type AuditRepository interface {
+	Create(entry entity.AuditLog) error
+	List(filter entity.AuditFilter) ([]entity.AuditLog, int, error)
+}
+
+type auditRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+const insertAuditLog = `
+	INSERT INTO audit_log (id_user, actor_role, api_key, system, action, entity_type, entity_id, before_value, after_value, client_ip)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+func auditLogArgs(entry entity.AuditLog) []interface{} {
+	return []interface{}{
+		nullString(entry.IdUser), nullString(entry.Role), nullString(entry.ApiKey), nullString(entry.System),
+		entry.Action, entry.EntityType, entry.EntityId, nullJSON(entry.Before), nullJSON(entry.After), nullString(entry.ClientIP),
+	}
+}
+
+func (a *auditRepository) Create(entry entity.AuditLog) error {
+	if _, err := a.db.Exec(insertAuditLog, auditLogArgs(entry)...); err != nil {
+		a.log.Error("Failed to write the audit log: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// recordAudit writes entry inside tx, so a change that moves money commits or rolls back together with its audit
+// entry. after, and before unless it is nil, replace the values of entry with their JSON encoding.
+func recordAudit(tx *sql.Tx, entry entity.AuditLog, before, after any) error {
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return fmt.Errorf("failed to encode audit value: %w", err)
+		}
+	}
+	if entry.After, err = json.Marshal(after); err != nil {
+		return fmt.Errorf("failed to encode audit value: %w", err)
+	}
+
+	if _, err := tx.Exec(insertAuditLog, auditLogArgs(entry)...); err != nil {
+		return fmt.Errorf("failed to write the audit log: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of the audit log, newest first, and the number of entries matching the filter.
+func (a *auditRepository) List(filter entity.AuditFilter) ([]entity.AuditLog, int, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.IdUser != "" {
+		addCondition("id_user::text = $%d", filter.IdUser)
+	}
+	if filter.EntityType != "" {
+		addCondition("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityId != "" {
+		addCondition("entity_id = $%d", filter.EntityId)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+
+	where := " FROM audit_log WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := a.db.QueryRow("SELECT COUNT(*)"+where, args...).Scan(&total); err != nil {
+		a.log.Error("Failed to count the audit log: ", err)
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, (filter.Page-1)*filter.Limit)
+	rows, err := a.db.Query(`
+		SELECT id, COALESCE(id_user::text, ''), COALESCE(actor_role, ''), COALESCE(api_key, ''), COALESCE(system, ''),
+			action, entity_type, entity_id, before_value, after_value, COALESCE(client_ip, ''), created_at`+where+
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		a.log.Error("Failed to list the audit log: ", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []entity.AuditLog{}
+	for rows.Next() {
+		var entry entity.AuditLog
+		var before, after []byte
+		if err := rows.Scan(&entry.Id, &entry.IdUser, &entry.Role, &entry.ApiKey, &entry.System, &entry.Action, &entry.EntityType,
+			&entry.EntityId, &before, &after, &entry.ClientIP, &entry.CreatedAt); err != nil {
+			a.log.Error("Failed to scan the audit log: ", err)
+			return nil, 0, err
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+
+	return entries, total, rows.Err()
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullJSON(value []byte) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
+
+func NewAuditRepository(db *sql.DB, log *logger.Logger) AuditRepository {
+	return &auditRepository{db: db, log: log}
+}
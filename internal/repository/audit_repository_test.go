@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+// jsonContaining matches an encoded audit value that contains the fragment.
+type jsonContaining string
+
+func (j jsonContaining) Match(value driver.Value) bool {
+	encoded, ok := value.(string)
+	return ok && strings.Contains(encoded, string(j))
+}
+
+// expectAuditLog expects the audit entry of a change to be written in the db transaction of the change.
+func expectAuditLog(mockSql sqlmock.Sqlmock, action, entityType, entityId string, before, after interface{}) *sqlmock.ExpectedExec {
+	return mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO audit_log`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), action, entityType, entityId, before, after, sqlmock.AnyArg())
+}
+
+type auditRepositoryTestSuite struct {
+	suite.Suite
+	mockDb    *sql.DB
+	mockSql   sqlmock.Sqlmock
+	log       logger.Logger
+	auditRepo AuditRepository
+}
+
+func TestAuditRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(auditRepositoryTestSuite))
+}
+
+func (s *auditRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.log = logger.NewLogger()
+	s.auditRepo = NewAuditRepository(mockDb, &s.log)
+}
+
+func (s *auditRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+func (s *auditRepositoryTestSuite) TestCreate() {
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO audit_log`)).
+		WithArgs(sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{String: "payment callback", Valid: true},
+			entity.AuditPayment, entity.AuditTopup, "topup-1", nil, `{"status":"paid"}`, sql.NullString{String: "10.0.0.1", Valid: true}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.auditRepo.Create(entity.AuditLog{
+		System:     "payment callback",
+		Action:     entity.AuditPayment,
+		EntityType: entity.AuditTopup,
+		EntityId:   "topup-1",
+		After:      []byte(`{"status":"paid"}`),
+		ClientIP:   "10.0.0.1",
+	})
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *auditRepositoryTestSuite) TestList_Filters() {
+	from := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := from.Add(time.Hour)
+
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM audit_log WHERE TRUE AND id_user::text = $1 AND entity_type = $2 AND created_at >= $3`)).
+		WithArgs("admin-1", entity.AuditMerchant, from).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`ORDER BY created_at DESC, id DESC LIMIT $4 OFFSET $5`)).
+		WithArgs("admin-1", entity.AuditMerchant, from, 10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id_user", "actor_role", "api_key", "system", "action", "entity_type", "entity_id",
+			"before_value", "after_value", "client_ip", "created_at"}).
+			AddRow(7, "admin-1", "admin", "", "", entity.AuditDelete, entity.AuditMerchant, "merchant-1", []byte(`{"balance":0}`), nil, "10.0.0.1", createdAt))
+
+	entries, total, err := s.auditRepo.List(entity.AuditFilter{IdUser: "admin-1", EntityType: entity.AuditMerchant, From: from, Page: 2, Limit: 10})
+
+	s.NoError(err)
+	s.Equal(11, total)
+	s.Equal([]entity.AuditLog{{
+		Id:         7,
+		IdUser:     "admin-1",
+		Role:       "admin",
+		Action:     entity.AuditDelete,
+		EntityType: entity.AuditMerchant,
+		EntityId:   "merchant-1",
+		Before:     []byte(`{"balance":0}`),
+		ClientIP:   "10.0.0.1",
+		CreatedAt:  createdAt,
+	}}, entries)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
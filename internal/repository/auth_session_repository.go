@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"time"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenExpired  = errors.New("refresh token expired")
+	ErrRefreshTokenReused   = errors.New("refresh token was already used")
+	ErrSessionRevoked       = errors.New("session revoked")
+)
+
+// Reasons recorded on auth_session.revoke_reason.
+const (
+	SessionRevokedLogout         = "logout"
+	SessionRevokedReuse          = "refresh token reuse"
+	SessionRevokedPasswordChange = "password change"
+	SessionRevokedRoleChange     = "role change"
+)
+
+type AuthSessionRepository interface {
+	CreateSession(idUser, refreshHash string, expiresAt time.Time) (string, error)
+	RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (entity.AuthSession, error)
+	RevokeByRefreshToken(refreshHash, reason string) error
+	IsSessionActive(idSession string) (bool, error)
+	RevokeUserSessions(idUser, exceptSession, reason string) error
+}
+
+type authSessionRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+// CreateSession starts a login session with its first refresh token and returns the session id.
+func (a *authSessionRepository) CreateSession(idUser, refreshHash string, expiresAt time.Time) (string, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var idSession string
+	if err = tx.QueryRow("INSERT INTO auth_session (id_user) VALUES ($1) RETURNING id", idUser).Scan(&idSession); err != nil {
+		a.log.Error("Failed to create the auth session: ", err)
+		return "", err
+	}
+
+	if _, err = tx.Exec("INSERT INTO refresh_token (token_hash, id_session, expires_at) VALUES ($1, $2, $3)", refreshHash, idSession, expiresAt); err != nil {
+		a.log.Error("Failed to store the refresh token: ", err)
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return idSession, nil
+}
+
+// RotateRefreshToken spends the refresh token oldHash and stores newHash in its place within the same session.
+// Presenting a token that was already spent means it leaked, so the whole session is revoked and
+// ErrRefreshTokenReused returned.
+func (a *authSessionRepository) RotateRefreshToken(oldHash, newHash string, expiresAt time.Time) (entity.AuthSession, error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		return entity.AuthSession{}, fmt.Errorf("failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var session entity.AuthSession
+	var used, revoked, expired bool
+	err = tx.QueryRow(`
+		SELECT s.id, s.id_user, r.used_at IS NOT NULL, s.revoked_at IS NOT NULL, r.expires_at <= NOW()
+		FROM refresh_token r JOIN auth_session s ON s.id = r.id_session
+		WHERE r.token_hash = $1
+		FOR UPDATE OF r, s`,
+		oldHash,
+	).Scan(&session.Id, &session.IdUser, &used, &revoked, &expired)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrRefreshTokenNotFound
+		return entity.AuthSession{}, err
+	} else if err != nil {
+		a.log.Error("Failed to get the refresh token: ", err)
+		return entity.AuthSession{}, err
+	}
+
+	switch {
+	case revoked:
+		err = ErrSessionRevoked
+		return entity.AuthSession{}, err
+	case used:
+		if err = a.revoke(tx, session.Id, SessionRevokedReuse); err != nil {
+			return entity.AuthSession{}, err
+		}
+		if err = tx.Commit(); err != nil {
+			return entity.AuthSession{}, err
+		}
+		return session, ErrRefreshTokenReused
+	case expired:
+		err = ErrRefreshTokenExpired
+		return entity.AuthSession{}, err
+	}
+
+	if _, err = tx.Exec("UPDATE refresh_token SET used_at = NOW() WHERE token_hash = $1", oldHash); err != nil {
+		a.log.Error("Failed to spend the refresh token: ", err)
+		return entity.AuthSession{}, err
+	}
+
+	if _, err = tx.Exec("INSERT INTO refresh_token (token_hash, id_session, expires_at) VALUES ($1, $2, $3)", newHash, session.Id, expiresAt); err != nil {
+		a.log.Error("Failed to store the refresh token: ", err)
+		return entity.AuthSession{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return entity.AuthSession{}, err
+	}
+
+	return session, nil
+}
+
+// RevokeByRefreshToken revokes the session a refresh token belongs to. Unknown tokens and sessions that were
+// already revoked are ignored.
+func (a *authSessionRepository) RevokeByRefreshToken(refreshHash, reason string) error {
+	_, err := a.db.Exec(`
+		UPDATE auth_session SET revoked_at = NOW(), revoke_reason = $2
+		WHERE id = (SELECT id_session FROM refresh_token WHERE token_hash = $1) AND revoked_at IS NULL`,
+		refreshHash, reason,
+	)
+	if err != nil {
+		a.log.Error("Failed to revoke the auth session: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// IsSessionActive reports whether a session exists and was not revoked.
+func (a *authSessionRepository) IsSessionActive(idSession string) (bool, error) {
+	var active bool
+
+	err := a.db.QueryRow("SELECT revoked_at IS NULL FROM auth_session WHERE id = $1", idSession).Scan(&active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		a.log.Error("Failed to get the auth session: ", err)
+		return false, err
+	}
+
+	return active, nil
+}
+
+// RevokeUserSessions revokes every active session of a user except exceptSession, which may be empty.
+func (a *authSessionRepository) RevokeUserSessions(idUser, exceptSession, reason string) error {
+	_, err := a.db.Exec(`
+		UPDATE auth_session SET revoked_at = NOW(), revoke_reason = $3
+		WHERE id_user = $1 AND id::text <> $2 AND revoked_at IS NULL`,
+		idUser, exceptSession, reason,
+	)
+	if err != nil {
+		a.log.Error("Failed to revoke the user sessions: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (a *authSessionRepository) revoke(tx *sql.Tx, idSession, reason string) error {
+	_, err := tx.Exec("UPDATE auth_session SET revoked_at = NOW(), revoke_reason = $1 WHERE id = $2", reason, idSession)
+	if err != nil {
+		a.log.Error("Failed to revoke the auth session: ", err)
+		return fmt.Errorf("failed to revoke session")
+	}
+
+	return nil
+}
+
+func NewAuthSessionRepository(db *sql.DB, log *logger.Logger) AuthSessionRepository {
+	return &authSessionRepository{db: db, log: log}
+}
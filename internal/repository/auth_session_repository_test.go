@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type authSessionRepositoryTestSuite struct {
+	suite.Suite
+	mockDb      *sql.DB
+	mockSql     sqlmock.Sqlmock
+	log         logger.Logger
+	sessionRepo AuthSessionRepository
+}
+
+func TestAuthSessionRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(authSessionRepositoryTestSuite))
+}
+
+func (s *authSessionRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.log = logger.NewLogger()
+	s.sessionRepo = NewAuthSessionRepository(mockDb, &s.log)
+}
+
+func (s *authSessionRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+func (s *authSessionRepositoryTestSuite) expectRefreshToken(used, revoked, expired bool) {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`FROM refresh_token r JOIN auth_session s ON s.id = r.id_session`)).
+		WithArgs("old-hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id_user", "used", "revoked", "expired"}).
+			AddRow("session-uuid", "user-uuid", used, revoked, expired))
+}
+
+func (s *authSessionRepositoryTestSuite) TestRotateRefreshToken() {
+	expiresAt := time.Date(2024, 11, 8, 12, 0, 0, 0, time.UTC)
+	s.mockSql.ExpectBegin()
+	s.expectRefreshToken(false, false, false)
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_token SET used_at = NOW() WHERE token_hash = $1`)).
+		WithArgs("old-hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO refresh_token (token_hash, id_session, expires_at) VALUES ($1, $2, $3)`)).
+		WithArgs("new-hash", "session-uuid", expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectCommit()
+
+	session, err := s.sessionRepo.RotateRefreshToken("old-hash", "new-hash", expiresAt)
+
+	s.NoError(err)
+	s.Equal("session-uuid", session.Id)
+	s.Equal("user-uuid", session.IdUser)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *authSessionRepositoryTestSuite) TestRotateRefreshToken_ReuseRevokesSession() {
+	s.mockSql.ExpectBegin()
+	s.expectRefreshToken(true, false, false)
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE auth_session SET revoked_at = NOW(), revoke_reason = $1 WHERE id = $2`)).
+		WithArgs(SessionRevokedReuse, "session-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectCommit()
+
+	_, err := s.sessionRepo.RotateRefreshToken("old-hash", "new-hash", time.Now())
+
+	s.ErrorIs(err, ErrRefreshTokenReused)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *authSessionRepositoryTestSuite) TestRotateRefreshToken_RevokedSession() {
+	s.mockSql.ExpectBegin()
+	s.expectRefreshToken(false, true, false)
+	s.mockSql.ExpectRollback()
+
+	_, err := s.sessionRepo.RotateRefreshToken("old-hash", "new-hash", time.Now())
+
+	s.ErrorIs(err, ErrSessionRevoked)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+)
+
+type FulfillmentRepository interface {
+	ClaimPending(claimedBefore time.Time, limit int) ([]entity.SupplierOrder, error)
+	MarkSuccess(order entity.SupplierOrder, result entity.SupplierResult) error
+	MarkFailed(order entity.SupplierOrder, result entity.SupplierResult, audit entity.AuditLog) error
+	Release(order entity.SupplierOrder) error
+}
+
+type fulfillmentRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+// refreshTransactionStatus derives the status of a transaction from the status of its details:
+// pending until the first detail is claimed, processing while any detail is still open, and once
+// every detail is final either success, failed, refunded or partially_refunded.
+const refreshTransactionStatus = `
+	UPDATE transactions t
+	SET status = CASE
+		WHEN s.processing_count > 0 THEN 'processing'
+		WHEN s.pending_count > 0 AND s.pending_count + s.refunded_count = s.total_count THEN 'pending'
+		WHEN s.pending_count > 0 THEN 'processing'
+		WHEN s.refunded_count = s.total_count THEN 'refunded'
+		WHEN s.failed_count = 0 AND s.refunded_count = 0 THEN 'success'
+		WHEN s.failed_count = 0 THEN 'partially_refunded'
+		ELSE 'failed'
+	END
+	FROM (
+		SELECT
+			COUNT(*) AS total_count,
+			COUNT(*) FILTER (WHERE status = 'pending') AS pending_count,
+			COUNT(*) FILTER (WHERE status = 'processing') AS processing_count,
+			COUNT(*) FILTER (WHERE status = 'failed') AS failed_count,
+			COUNT(*) FILTER (WHERE status = 'refunded') AS refunded_count
+		FROM transaction_detail
+		WHERE transaction_id = $1
+	) s
+	WHERE t.transaction_id = $1`
+
+// ClaimPending marks up to limit transaction details as processing and returns them. Besides pending
+// details it takes over details that were claimed before claimedBefore and never finished, so an order
+// survives a worker that crashed in the middle of it; the supplier deduplicates the repeated purchase.
+// The details waiting longest are claimed first, so none of them starves under load.
+func (f *fulfillmentRepository) ClaimPending(claimedBefore time.Time, limit int) ([]entity.SupplierOrder, error) {
+	f.log.Info("Starting to claim pending transaction details in the repository layer", nil)
+
+	tx, err := f.db.Begin()
+	if err != nil {
+		f.log.Error("Failed start db transaction", err)
+		return nil, err
+	}
+
+	claimQuery := `
+		WITH claimed AS (
+			UPDATE transaction_detail
+			SET status = 'processing', claimed_at = NOW(), updated_at = NOW()
+			WHERE transaction_detail_id IN (
+				SELECT transaction_detail_id
+				FROM transaction_detail
+				WHERE status = 'pending' OR (status = 'processing' AND claimed_at < $2)
+				ORDER BY updated_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING transaction_detail_id, transaction_id, id_product, cost
+		)
+		SELECT
+			c.transaction_detail_id, c.transaction_id, t.id_merchant, t.destination_number,
+			p.id_product, p.name_provider, p.nominal, p.id_supliyer, c.cost
+		FROM claimed c
+		JOIN transactions t ON c.transaction_id = t.transaction_id
+		JOIN mst_product p ON c.id_product = p.id_product`
+
+	rows, err := tx.Query(claimQuery, limit, claimedBefore)
+	if err != nil {
+		tx.Rollback()
+		f.log.Error("Failed to claim pending transaction details", err)
+		return nil, err
+	}
+
+	var orders []entity.SupplierOrder
+	for rows.Next() {
+		var order entity.SupplierOrder
+		if err := rows.Scan(
+			&order.TransactionDetailId, &order.TransactionsId, &order.MerchantId, &order.DestinationNumber,
+			&order.ProductId, &order.NameProvider, &order.Nominal, &order.SupplierId, &order.Cost,
+		); err != nil {
+			rows.Close()
+			tx.Rollback()
+			f.log.Error("Failed to scan claimed transaction detail", err)
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		f.log.Error("Failed to read claimed transaction details", err)
+		return nil, err
+	}
+
+	refreshed := make(map[string]bool)
+	for _, order := range orders {
+		if refreshed[order.TransactionsId] {
+			continue
+		}
+		if _, err := tx.Exec(refreshTransactionStatus, order.TransactionsId); err != nil {
+			tx.Rollback()
+			f.log.Error("Failed to refresh transaction status", err)
+			return nil, err
+		}
+		refreshed[order.TransactionsId] = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		f.log.Error("Failed to commit transaction", err)
+		return nil, err
+	}
+
+	f.log.Info("Pending transaction details claimed successfully", len(orders))
+	return orders, nil
+}
+
+func (f *fulfillmentRepository) MarkSuccess(order entity.SupplierOrder, result entity.SupplierResult) error {
+	f.log.Info("Starting to mark transaction detail as success in the repository layer", order.TransactionDetailId)
+
+	tx, err := f.db.Begin()
+	if err != nil {
+		f.log.Error("Failed start db transaction", err)
+		return err
+	}
+
+	if err := f.finish(tx, order, entity.FulfillmentSuccess, result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		f.log.Error("Failed to commit transaction", err)
+		return err
+	}
+
+	f.log.Info("Transaction detail fulfilled successfully", result)
+	return nil
+}
+
+// MarkFailed fails the detail and refunds its cost to the merchant, recording audit in the same db transaction.
+func (f *fulfillmentRepository) MarkFailed(order entity.SupplierOrder, result entity.SupplierResult, audit entity.AuditLog) error {
+	f.log.Info("Starting to mark transaction detail as failed in the repository layer", order.TransactionDetailId)
+
+	tx, err := f.db.Begin()
+	if err != nil {
+		f.log.Error("Failed start db transaction", err)
+		return err
+	}
+
+	if err := f.finish(tx, order, entity.FulfillmentFailed, result); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Refund the cost that was debited from the merchant when the sale was created
+	entry, err := postLedgerEntry(tx, entity.LedgerEntry{
+		IdMerchant:    order.MerchantId,
+		EntryType:     entity.LedgerRefund,
+		Amount:        order.Cost,
+		ReferenceType: "transaction_detail",
+		ReferenceId:   order.TransactionDetailId,
+		Description:   "supplier failed: " + result.Message,
+	})
+	if err != nil {
+		tx.Rollback()
+		f.log.Error("Failed to refund merchant balance", err)
+		return err
+	}
+
+	if err := recordAudit(tx, audit, nil, entry); err != nil {
+		tx.Rollback()
+		f.log.Error("Failed to audit the refund", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		f.log.Error("Failed to commit transaction", err)
+		return err
+	}
+
+	f.log.Info("Transaction detail failed and merchant balance refunded", order)
+	return nil
+}
+
+// Release puts a processing detail back to pending and refreshes the status of its transaction with it.
+func (f *fulfillmentRepository) Release(order entity.SupplierOrder) error {
+	f.log.Info("Starting to release transaction detail back to pending in the repository layer", order.TransactionDetailId)
+
+	tx, err := f.db.Begin()
+	if err != nil {
+		f.log.Error("Failed start db transaction", err)
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE transaction_detail SET status = 'pending', updated_at = NOW() WHERE transaction_detail_id = $1 AND status = 'processing'",
+		order.TransactionDetailId,
+	)
+	if err != nil {
+		tx.Rollback()
+		f.log.Error("Failed to release transaction detail", err)
+		return err
+	}
+
+	if _, err := tx.Exec(refreshTransactionStatus, order.TransactionsId); err != nil {
+		tx.Rollback()
+		f.log.Error("Failed to refresh transaction status", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		f.log.Error("Failed to commit transaction", err)
+		return err
+	}
+
+	return nil
+}
+
+// finish moves a processing detail into a final status. The status guard makes sure a detail
+// is only finished once, so a failed detail can never be refunded twice.
+func (f *fulfillmentRepository) finish(tx *sql.Tx, order entity.SupplierOrder, status string, result entity.SupplierResult) error {
+	res, err := tx.Exec(
+		`UPDATE transaction_detail
+		SET status = $1, serial_number = $2, supplier_message = $3, updated_at = NOW()
+		WHERE transaction_detail_id = $4 AND status = 'processing'`,
+		status, result.SerialNumber, result.Message, order.TransactionDetailId,
+	)
+	if err != nil {
+		f.log.Error("Failed to update transaction detail status", err)
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		f.log.Error("Failed to read affected rows", err)
+		return err
+	}
+	if affected == 0 {
+		err := fmt.Errorf("transaction detail %s is not being processed", order.TransactionDetailId)
+		f.log.Error("Failed to finish transaction detail", err)
+		return err
+	}
+
+	if _, err := tx.Exec(refreshTransactionStatus, order.TransactionsId); err != nil {
+		f.log.Error("Failed to refresh transaction status", err)
+		return err
+	}
+
+	return nil
+}
+
+func NewFulfillmentRepository(db *sql.DB, log *logger.Logger) FulfillmentRepository {
+	return &fulfillmentRepository{db: db, log: log}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+var expectedSupplierOrder = entity.SupplierOrder{
+	TransactionDetailId: "detail-uuid",
+	TransactionsId:      "tx-uuid",
+	MerchantId:          "merchant-uuid",
+	SupplierId:          "supplier-uuid",
+	ProductId:           "product-uuid",
+	NameProvider:        "Telkomsel",
+	Nominal:             10000,
+	Cost:                9500,
+	DestinationNumber:   "081234567890",
+}
+
+var testRefundAudit = entity.AuditLog{System: "fulfillment", Action: entity.AuditRefund, EntityType: entity.AuditTransaction, EntityId: expectedSupplierOrder.TransactionsId}
+
+type fulfillmentRepositoryTestSuite struct {
+	suite.Suite
+	mockDb          *sql.DB
+	mockSql         sqlmock.Sqlmock
+	fulfillmentRepo FulfillmentRepository
+	log             logger.Logger
+}
+
+func TestFulfillmentRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(fulfillmentRepositoryTestSuite))
+}
+
+func (s *fulfillmentRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.log = logger.NewLogger()
+	s.fulfillmentRepo = NewFulfillmentRepository(mockDb, &s.log)
+}
+
+func (s *fulfillmentRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+func (s *fulfillmentRepositoryTestSuite) TestMarkFailed_RefundsMerchant() {
+	result := entity.SupplierResult{Status: entity.FulfillmentFailed, Message: "number inactive"}
+
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transaction_detail`)).
+		WithArgs(entity.FulfillmentFailed, "", result.Message, expectedSupplierOrder.TransactionDetailId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transactions t`)).
+		WithArgs(expectedSupplierOrder.TransactionsId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance`)).
+		WithArgs(expectedSupplierOrder.Cost, expectedSupplierOrder.MerchantId).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(60000))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO merchant_ledger`)).
+		WithArgs(expectedSupplierOrder.MerchantId, entity.LedgerRefund, expectedSupplierOrder.Cost, float64(60000),
+			"transaction_detail", expectedSupplierOrder.TransactionDetailId, "supplier failed: number inactive", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	expectAuditLog(s.mockSql, entity.AuditRefund, entity.AuditTransaction, expectedSupplierOrder.TransactionsId, nil, jsonContaining(`"balanceAfter":60000`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockSql.ExpectCommit()
+
+	err := s.fulfillmentRepo.MarkFailed(expectedSupplierOrder, result, testRefundAudit)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *fulfillmentRepositoryTestSuite) TestMarkFailed_AlreadyFinished() {
+	result := entity.SupplierResult{Status: entity.FulfillmentFailed, Message: "number inactive"}
+
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transaction_detail`)).
+		WithArgs(entity.FulfillmentFailed, "", result.Message, expectedSupplierOrder.TransactionDetailId).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mockSql.ExpectRollback()
+
+	err := s.fulfillmentRepo.MarkFailed(expectedSupplierOrder, result, testRefundAudit)
+
+	s.Error(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *fulfillmentRepositoryTestSuite) TestClaimPending_ReclaimsStaleProcessing() {
+	claimedBefore := time.Now().Add(-10 * time.Minute)
+
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`WHERE status = 'pending' OR (status = 'processing' AND claimed_at < $2) ORDER BY updated_at LIMIT $1`)).
+		WithArgs(20, claimedBefore).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"transaction_detail_id", "transaction_id", "id_merchant", "destination_number",
+			"id_product", "name_provider", "nominal", "id_supliyer", "cost",
+		}).AddRow(
+			expectedSupplierOrder.TransactionDetailId, expectedSupplierOrder.TransactionsId, expectedSupplierOrder.MerchantId,
+			expectedSupplierOrder.DestinationNumber, expectedSupplierOrder.ProductId, expectedSupplierOrder.NameProvider,
+			expectedSupplierOrder.Nominal, expectedSupplierOrder.SupplierId, expectedSupplierOrder.Cost,
+		))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transactions t`)).
+		WithArgs(expectedSupplierOrder.TransactionsId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectCommit()
+
+	orders, err := s.fulfillmentRepo.ClaimPending(claimedBefore, 20)
+
+	s.NoError(err)
+	s.Equal([]entity.SupplierOrder{expectedSupplierOrder}, orders)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *fulfillmentRepositoryTestSuite) TestRelease_RefreshesTransaction() {
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transaction_detail SET status = 'pending'`)).
+		WithArgs(expectedSupplierOrder.TransactionDetailId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transactions t`)).
+		WithArgs(expectedSupplierOrder.TransactionsId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectCommit()
+
+	err := s.fulfillmentRepo.Release(expectedSupplierOrder)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
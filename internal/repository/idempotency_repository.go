@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+)
+
+// reserveIdempotencyKey claims key inside tx. When the key was already used it returns the stored
+// record and true; a concurrent request holding the same key blocks here until the first one commits.
+func reserveIdempotencyKey(tx *sql.Tx, key entity.IdempotencyKey) (entity.IdempotencyKey, bool, error) {
+	result, err := tx.Exec(
+		"INSERT INTO idempotency_key (scope, idempotency_key, request_hash) VALUES ($1, $2, $3) ON CONFLICT (scope, idempotency_key) DO NOTHING",
+		key.Scope, key.Key, key.RequestHash,
+	)
+	if err != nil {
+		return entity.IdempotencyKey{}, false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return entity.IdempotencyKey{}, false, err
+	}
+	if inserted == 1 {
+		return key, false, nil
+	}
+
+	stored := entity.IdempotencyKey{Key: key.Key, Scope: key.Scope}
+	if err := tx.QueryRow(
+		"SELECT request_hash, response, created_at FROM idempotency_key WHERE scope = $1 AND idempotency_key = $2",
+		key.Scope, key.Key,
+	).Scan(&stored.RequestHash, &stored.Response, &stored.CreatedAt); err != nil {
+		return entity.IdempotencyKey{}, false, err
+	}
+
+	return stored, true, nil
+}
+
+// takeOverIdempotencyKey reclaims a key of the same request that has been in progress for longer than lease, because
+// the request holding it died before storing a response. It returns false while the lease still runs.
+func takeOverIdempotencyKey(tx *sql.Tx, key entity.IdempotencyKey, lease time.Duration) (bool, error) {
+	result, err := tx.Exec(
+		"UPDATE idempotency_key SET created_at = NOW() WHERE scope = $1 AND idempotency_key = $2 AND request_hash = $3 AND response IS NULL AND created_at < NOW() - $4 * INTERVAL '1 second'",
+		key.Scope, key.Key, key.RequestHash, lease.Seconds(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	taken, err := result.RowsAffected()
+	return taken == 1, err
+}
+
+func completeIdempotencyKey(tx *sql.Tx, key entity.IdempotencyKey) error {
+	_, err := tx.Exec(
+		"UPDATE idempotency_key SET response = $3 WHERE scope = $1 AND idempotency_key = $2",
+		key.Scope, key.Key, key.Response,
+	)
+	return err
+}
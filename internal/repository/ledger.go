@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"server-pulsa-app/internal/entity"
+)
+
+// ErrInsufficientBalance is returned when a debit would take the merchant balance below zero.
+var ErrInsufficientBalance = errors.New("insufficient merchant balance")
+
+// postLedgerEntry applies entry.Amount to the merchant balance and appends the movement to the
+// merchant ledger inside tx. Every change of mst_merchant.balance must go through here or through
+// postLedgerReversal. A debit that would take the balance below zero fails with ErrInsufficientBalance
+// and the caller must roll tx back.
+func postLedgerEntry(tx *sql.Tx, entry entity.LedgerEntry) (entity.LedgerEntry, error) {
+	return applyLedgerEntry(tx, entry, false)
+}
+
+// postLedgerReversal is postLedgerEntry for money that already left the platform, like a refunded or
+// charged back topup. The debit is recorded even when it takes the balance below zero, so the ledger
+// shows the debt instead of losing it.
+func postLedgerReversal(tx *sql.Tx, entry entity.LedgerEntry) (entity.LedgerEntry, error) {
+	return applyLedgerEntry(tx, entry, true)
+}
+
+func applyLedgerEntry(tx *sql.Tx, entry entity.LedgerEntry, allowNegative bool) (entity.LedgerEntry, error) {
+	if err := tx.QueryRow(
+		"UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance",
+		entry.Amount, entry.IdMerchant,
+	).Scan(&entry.BalanceAfter); err != nil {
+		return entity.LedgerEntry{}, err
+	}
+
+	if entry.Amount < 0 && entry.BalanceAfter < 0 && !allowNegative {
+		return entity.LedgerEntry{}, fmt.Errorf("%w: debit %v leaves a balance of %v", ErrInsufficientBalance, entry.Amount, entry.BalanceAfter)
+	}
+
+	insertEntry := `
+		INSERT INTO merchant_ledger (id_merchant, entry_type, amount, balance_after, reference_type, reference_id, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid)
+		RETURNING id, created_at`
+
+	if err := tx.QueryRow(
+		insertEntry,
+		entry.IdMerchant, entry.EntryType, entry.Amount, entry.BalanceAfter,
+		entry.ReferenceType, entry.ReferenceId, entry.Description, entry.CreatedBy,
+	).Scan(&entry.Id, &entry.CreatedAt); err != nil {
+		return entity.LedgerEntry{}, err
+	}
+
+	return entry, nil
+}
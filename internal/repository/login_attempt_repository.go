@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"server-pulsa-app/internal/logger"
+	"time"
+)
+
+// Scopes failed logins are counted under.
+const (
+	LoginScopeUsername = "username"
+	LoginScopeIP       = "ip"
+)
+
+type LoginAttemptRepository interface {
+	BlockedUntil(username, ip string) (time.Time, error)
+	RecordFailure(scope, key string, window time.Duration) (int, error)
+	Block(scope, key string, until time.Time) error
+	Reset(scope, key string) error
+}
+
+type loginAttemptRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+// BlockedUntil returns until when logins of a username or from an ip are refused, zero when they are not.
+func (l *loginAttemptRepository) BlockedUntil(username, ip string) (time.Time, error) {
+	var until sql.NullTime
+
+	err := l.db.QueryRow(`
+		SELECT MAX(blocked_until) FROM login_attempt
+		WHERE (scope = $1 AND key = $2) OR (scope = $3 AND key = $4)`,
+		LoginScopeUsername, username, LoginScopeIP, ip,
+	).Scan(&until)
+	if err != nil {
+		l.log.Error("Failed to get the login block: ", err)
+		return time.Time{}, err
+	}
+
+	return until.Time, nil
+}
+
+// RecordFailure counts a failed login and returns the failures so far. The count restarts when the previous
+// failure is older than window.
+func (l *loginAttemptRepository) RecordFailure(scope, key string, window time.Duration) (int, error) {
+	var failures int
+
+	err := l.db.QueryRow(`
+		INSERT INTO login_attempt (scope, key, failures, last_failed_at) VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_attempt.last_failed_at < NOW() - $3 * INTERVAL '1 second' THEN 1 ELSE login_attempt.failures + 1 END,
+			last_failed_at = NOW()
+		RETURNING failures`,
+		scope, key, int64(window.Seconds()),
+	).Scan(&failures)
+	if err != nil {
+		l.log.Error("Failed to record the failed login: ", err)
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (l *loginAttemptRepository) Block(scope, key string, until time.Time) error {
+	if _, err := l.db.Exec("UPDATE login_attempt SET blocked_until = $3 WHERE scope = $1 AND key = $2", scope, key, until); err != nil {
+		l.log.Error("Failed to block the login: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// Reset forgets the failed logins of a username or an ip, lifting its block.
+func (l *loginAttemptRepository) Reset(scope, key string) error {
+	if _, err := l.db.Exec("DELETE FROM login_attempt WHERE scope = $1 AND key = $2", scope, key); err != nil {
+		l.log.Error("Failed to reset the failed logins: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func NewLoginAttemptRepository(db *sql.DB, log *logger.Logger) LoginAttemptRepository {
+	return &loginAttemptRepository{db: db, log: log}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type loginAttemptRepositoryTestSuite struct {
+	suite.Suite
+	mockDb       *sql.DB
+	mockSql      sqlmock.Sqlmock
+	log          logger.Logger
+	loginAttempt LoginAttemptRepository
+}
+
+func TestLoginAttemptRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(loginAttemptRepositoryTestSuite))
+}
+
+func (s *loginAttemptRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.log = logger.NewLogger()
+	s.loginAttempt = NewLoginAttemptRepository(mockDb, &s.log)
+}
+
+func (s *loginAttemptRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+func (s *loginAttemptRepositoryTestSuite) TestBlockedUntil_ChecksUsernameAndIp() {
+	until := time.Date(2024, 11, 8, 12, 15, 0, 0, time.UTC)
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT MAX(blocked_until) FROM login_attempt WHERE (scope = $1 AND key = $2) OR (scope = $3 AND key = $4)`)).
+		WithArgs(LoginScopeUsername, "cashier", LoginScopeIP, "10.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(until))
+
+	blockedUntil, err := s.loginAttempt.BlockedUntil("cashier", "10.0.0.1")
+
+	s.NoError(err)
+	s.Equal(until, blockedUntil)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *loginAttemptRepositoryTestSuite) TestBlockedUntil_NotBlocked() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT MAX(blocked_until) FROM login_attempt`)).
+		WithArgs(LoginScopeUsername, "cashier", LoginScopeIP, "10.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+
+	blockedUntil, err := s.loginAttempt.BlockedUntil("cashier", "10.0.0.1")
+
+	s.NoError(err)
+	s.True(blockedUntil.IsZero())
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *loginAttemptRepositoryTestSuite) TestRecordFailure_CountsWithinWindow() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO login_attempt (scope, key, failures, last_failed_at) VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_attempt.last_failed_at < NOW() - $3 * INTERVAL '1 second' THEN 1 ELSE login_attempt.failures + 1 END`)).
+		WithArgs(LoginScopeUsername, "cashier", int64(900)).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(5))
+
+	failures, err := s.loginAttempt.RecordFailure(LoginScopeUsername, "cashier", 15*time.Minute)
+
+	s.NoError(err)
+	s.Equal(5, failures)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *loginAttemptRepositoryTestSuite) TestRecordFailure_Error() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO login_attempt`)).
+		WithArgs(LoginScopeIP, "10.0.0.1", int64(900)).
+		WillReturnError(sql.ErrConnDone)
+
+	_, err := s.loginAttempt.RecordFailure(LoginScopeIP, "10.0.0.1", 15*time.Minute)
+
+	s.ErrorIs(err, sql.ErrConnDone)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *loginAttemptRepositoryTestSuite) TestBlock() {
+	until := time.Date(2024, 11, 8, 12, 15, 0, 0, time.UTC)
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE login_attempt SET blocked_until = $3 WHERE scope = $1 AND key = $2`)).
+		WithArgs(LoginScopeUsername, "cashier", until).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.loginAttempt.Block(LoginScopeUsername, "cashier", until)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *loginAttemptRepositoryTestSuite) TestReset() {
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`DELETE FROM login_attempt WHERE scope = $1 AND key = $2`)).
+		WithArgs(LoginScopeUsername, "cashier").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.loginAttempt.Reset(LoginScopeUsername, "cashier")
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
@@ -2,18 +2,28 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
+
+	"github.com/lib/pq"
 )
 
+// ErrMerchantInUse is returned when a merchant can't be deleted because its transactions, topups or balance
+// mutations still refer to it.
+var ErrMerchantInUse = errors.New("merchant still has transactions, topups or balance mutations")
+
 type MerchantRepository interface {
 	Create(payload entity.Merchant) (entity.Merchant, error)
 	List() ([]entity.Merchant, error)
 	Get(id string) (entity.Merchant, error)
 	Update(merchant, newMerchant entity.Merchant) (entity.Merchant, error)
 	Delete(id string) error
+	ListMutations(id string, page, limit int) ([]entity.LedgerEntry, int, error)
+	AdjustBalance(entry entity.LedgerEntry, audit entity.AuditLog) (entity.LedgerEntry, error)
 }
 
 type merchantRepository struct {
@@ -107,11 +117,16 @@ func (m *merchantRepository) Update(merchant, payload entity.Merchant) (entity.M
 	return merchant, nil
 }
 
+// Delete removes a merchant. It returns ErrMerchantInUse while other records still refer to the merchant, since its
+// append-only ledger keeps the history of every merchant that ever moved money.
 func (m *merchantRepository) Delete(id string) error {
 	m.log.Info("Starting to delete merchant in the repository layer", nil)
 
 	_, err := m.db.Exec("DELETE FROM mst_merchant WHERE id_merchant = $1", id)
-	if err != nil {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrMerchantInUse
+	} else if err != nil {
 		m.log.Error("Failed to delete the merchant: ", err)
 		return err
 	}
@@ -120,6 +135,98 @@ func (m *merchantRepository) Delete(id string) error {
 	return nil
 }
 
+func (m *merchantRepository) ListMutations(id string, page, limit int) ([]entity.LedgerEntry, int, error) {
+	m.log.Info("Starting to retrive merchant mutations in the repository layer", id)
+
+	var total int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM merchant_ledger WHERE id_merchant = $1", id).Scan(&total); err != nil {
+		m.log.Error("Failed to count the merchant mutations: ", err)
+		return nil, 0, err
+	}
+
+	selectQuery := `
+		SELECT id, id_merchant, entry_type, amount, balance_after, reference_type, COALESCE(reference_id, ''),
+			COALESCE(description, ''), COALESCE(created_by::text, ''), created_at
+		FROM merchant_ledger
+		WHERE id_merchant = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := m.db.Query(selectQuery, id, limit, (page-1)*limit)
+	if err != nil {
+		m.log.Error("Failed to retrive the merchant mutations: ", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	mutations := []entity.LedgerEntry{}
+	for rows.Next() {
+		var entry entity.LedgerEntry
+		if err := rows.Scan(&entry.Id, &entry.IdMerchant, &entry.EntryType, &entry.Amount, &entry.BalanceAfter, &entry.ReferenceType,
+			&entry.ReferenceId, &entry.Description, &entry.CreatedBy, &entry.CreatedAt); err != nil {
+			m.log.Error("Failed to scan the merchant mutation: ", err)
+			return nil, 0, err
+		}
+		mutations = append(mutations, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		m.log.Error("Failed to retrive the merchant mutations: ", err)
+		return nil, 0, err
+	}
+
+	m.log.Info("Getting merchant mutations was successfully: ", len(mutations))
+	return mutations, total, nil
+}
+
+// AdjustBalance posts a manual correction to the merchant ledger and records audit with the balance before it.
+func (m *merchantRepository) AdjustBalance(entry entity.LedgerEntry, audit entity.AuditLog) (entity.LedgerEntry, error) {
+	m.log.Info("Starting to adjust merchant balance in the repository layer", entry)
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		m.log.Error("Failed start db transaction", err)
+		return entity.LedgerEntry{}, err
+	}
+
+	var balance float64
+	if err := tx.QueryRow("SELECT balance FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE", entry.IdMerchant).Scan(&balance); err != nil {
+		tx.Rollback()
+		m.log.Error("Failed to fetch merchant balance", err)
+		return entity.LedgerEntry{}, err
+	}
+
+	if balance+entry.Amount < 0 {
+		tx.Rollback()
+		err := fmt.Errorf("%w: adjustment %v, current balance %v", ErrInsufficientBalance, entry.Amount, balance)
+		m.log.Error("Failed to adjust merchant balance", err)
+		return entity.LedgerEntry{}, err
+	}
+
+	entry.EntryType = entity.LedgerAdjustment
+	entry.ReferenceType = "adjustment"
+	entry, err = postLedgerEntry(tx, entry)
+	if err != nil {
+		tx.Rollback()
+		m.log.Error("Failed to adjust merchant balance", err)
+		return entity.LedgerEntry{}, err
+	}
+
+	if err := recordAudit(tx, audit, map[string]float64{"balance": balance}, entry); err != nil {
+		tx.Rollback()
+		m.log.Error("Failed to audit the balance adjustment", err)
+		return entity.LedgerEntry{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		m.log.Error("Failed to commit transaction", err)
+		return entity.LedgerEntry{}, err
+	}
+
+	m.log.Info("Merchant balance has been adjusted successfully: ", entry)
+	return entry, nil
+}
+
 func NewMerchantRepository(db *sql.DB, log *logger.Logger) MerchantRepository {
 	return &merchantRepository{db: db, log: log}
 }
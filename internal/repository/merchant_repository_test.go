@@ -6,8 +6,10 @@ import (
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -127,6 +129,17 @@ func (m *merchantRepositoryTestSuite) TestDelete_fail() {
 	m.NotNil(err)
 }
 
+func (m *merchantRepositoryTestSuite) TestDelete_inUse() {
+	m.mockSql.ExpectExec(regexp.QuoteMeta("DELETE FROM mst_merchant WHERE id_merchant = $1")).
+		WithArgs(expectedMerchant.IdMerchant).
+		WillReturnError(&pq.Error{Code: "23503"})
+
+	err := m.mr.Delete(expectedMerchant.IdMerchant)
+
+	m.ErrorIs(err, ErrMerchantInUse)
+	m.NoError(m.mockSql.ExpectationsWereMet())
+}
+
 func (m *merchantRepositoryTestSuite) TestUpdate_fail() {
 	merchant := entity.Merchant{
 		IdMerchant:   "uuid-merchant-test",
@@ -143,3 +156,57 @@ func (m *merchantRepositoryTestSuite) TestUpdate_fail() {
 
 	m.NotNil(err)
 }
+
+func (m *merchantRepositoryTestSuite) TestAdjustBalance_auditsInTransaction() {
+	audit := entity.AuditLog{IdUser: "uuid-admin", Action: entity.AuditAdjustBalance, EntityType: entity.AuditMerchant, EntityId: expectedMerchant.IdMerchant}
+
+	m.mockSql.ExpectBegin()
+	m.mockSql.ExpectQuery(regexp.QuoteMeta("SELECT balance FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE")).
+		WithArgs(expectedMerchant.IdMerchant).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(10000))
+	m.mockSql.ExpectQuery(regexp.QuoteMeta("UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance")).
+		WithArgs(float64(-2500), expectedMerchant.IdMerchant).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(7500))
+	m.mockSql.ExpectQuery(regexp.QuoteMeta("INSERT INTO merchant_ledger")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	expectAuditLog(m.mockSql, entity.AuditAdjustBalance, entity.AuditMerchant, expectedMerchant.IdMerchant, `{"balance":10000}`, jsonContaining(`"balanceAfter":7500`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	m.mockSql.ExpectCommit()
+
+	entry, err := m.mr.AdjustBalance(entity.LedgerEntry{IdMerchant: expectedMerchant.IdMerchant, Amount: -2500, Description: "chargeback"}, audit)
+
+	m.NoError(err)
+	m.Equal(float64(7500), entry.BalanceAfter)
+	m.NoError(m.mockSql.ExpectationsWereMet())
+}
+
+func (m *merchantRepositoryTestSuite) TestPostLedgerEntry_RejectsOverdraft() {
+	m.mockSql.ExpectBegin()
+	m.mockSql.ExpectQuery(regexp.QuoteMeta("UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance")).
+		WithArgs(float64(-12500), expectedMerchant.IdMerchant).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(-2500))
+
+	tx, err := m.mockDb.Begin()
+	m.NoError(err)
+	_, err = postLedgerEntry(tx, entity.LedgerEntry{IdMerchant: expectedMerchant.IdMerchant, Amount: -12500})
+
+	m.ErrorIs(err, ErrInsufficientBalance)
+	m.NoError(m.mockSql.ExpectationsWereMet())
+}
+
+func (m *merchantRepositoryTestSuite) TestPostLedgerReversal_RecordsNegativeBalance() {
+	m.mockSql.ExpectBegin()
+	m.mockSql.ExpectQuery(regexp.QuoteMeta("UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance")).
+		WithArgs(float64(-12500), expectedMerchant.IdMerchant).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(-2500))
+	m.mockSql.ExpectQuery(regexp.QuoteMeta("INSERT INTO merchant_ledger")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+
+	tx, err := m.mockDb.Begin()
+	m.NoError(err)
+	entry, err := postLedgerReversal(tx, entity.LedgerEntry{IdMerchant: expectedMerchant.IdMerchant, Amount: -12500})
+
+	m.NoError(err)
+	m.Equal(float64(-2500), entry.BalanceAfter)
+	m.NoError(m.mockSql.ExpectationsWereMet())
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrMFAAlreadyEnabled = errors.New("two factor authentication is already enabled")
+
+type MFARepository interface {
+	Get(idUser string) (entity.UserMFA, error)
+	SavePending(idUser, secret string) error
+	Enable(idUser string, step int64, recoveryHashes []string) error
+	UseStep(idUser string, step int64) (bool, error)
+	UseRecoveryCode(idUser, codeHash string) (bool, error)
+	Delete(idUser string) error
+	CreateChallenge(tokenHash, idUser string, expiresAt time.Time) error
+	ChallengeUser(tokenHash string, maxAttempts int) (string, error)
+	DeleteChallenge(tokenHash string) error
+}
+
+type mfaRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+// Get returns the second factor of a user, with an empty secret when the user has none, or sql.ErrNoRows when
+// the user doesn't exist.
+func (m *mfaRepository) Get(idUser string) (entity.UserMFA, error) {
+	mfa := entity.UserMFA{IdUser: idUser}
+	var secret sql.NullString
+	var lastUsedStep sql.NullInt64
+
+	err := m.db.QueryRow(`
+		SELECT r.require_mfa, f.secret, f.enabled_at, f.last_used_step
+		FROM mst_user u
+		JOIN mst_role r ON r.name = u.role
+		LEFT JOIN user_mfa f ON f.id_user = u.id_user
+		WHERE u.id_user = $1`,
+		idUser,
+	).Scan(&mfa.Required, &secret, &mfa.EnabledAt, &lastUsedStep)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			m.log.Error("Failed to get the user mfa: ", err)
+		}
+		return entity.UserMFA{}, err
+	}
+
+	mfa.Secret = secret.String
+	mfa.LastUsedStep = lastUsedStep.Int64
+	return mfa, nil
+}
+
+// SavePending stores a secret waiting for its first code, replacing an earlier pending one. It returns
+// ErrMFAAlreadyEnabled when the user already confirmed a secret.
+func (m *mfaRepository) SavePending(idUser, secret string) error {
+	res, err := m.db.Exec(`
+		INSERT INTO user_mfa (id_user, secret) VALUES ($1, $2)
+		ON CONFLICT (id_user) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+		WHERE user_mfa.enabled_at IS NULL`,
+		idUser, secret,
+	)
+	if err != nil {
+		m.log.Error("Failed to save the pending mfa secret: ", err)
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// Enable confirms the pending secret of a user and replaces the recovery codes.
+func (m *mfaRepository) Enable(idUser string, step int64, recoveryHashes []string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE user_mfa SET enabled_at = NOW(), last_used_step = $2 WHERE id_user = $1 AND enabled_at IS NULL", idUser, step)
+	if err != nil {
+		m.log.Error("Failed to enable the mfa: ", err)
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_code WHERE id_user = $1", idUser); err != nil {
+		m.log.Error("Failed to clear the recovery codes: ", err)
+		return err
+	}
+
+	if _, err := tx.Exec("INSERT INTO mfa_recovery_code (id_user, code_hash) SELECT $1, unnest($2::text[])", idUser, pq.Array(recoveryHashes)); err != nil {
+		m.log.Error("Failed to store the recovery codes: ", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records the time step of an accepted code. It returns false when that step or a later one was already
+// used, so a code can't be replayed.
+func (m *mfaRepository) UseStep(idUser string, step int64) (bool, error) {
+	res, err := m.db.Exec("UPDATE user_mfa SET last_used_step = $2 WHERE id_user = $1 AND last_used_step < $2", idUser, step)
+	if err != nil {
+		m.log.Error("Failed to record the used mfa step: ", err)
+		return false, err
+	}
+
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// UseRecoveryCode spends a recovery code and returns false when it is unknown or already spent.
+func (m *mfaRepository) UseRecoveryCode(idUser, codeHash string) (bool, error) {
+	res, err := m.db.Exec("UPDATE mfa_recovery_code SET used_at = NOW() WHERE id_user = $1 AND code_hash = $2 AND used_at IS NULL", idUser, codeHash)
+	if err != nil {
+		m.log.Error("Failed to use the recovery code: ", err)
+		return false, err
+	}
+
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// Delete removes the second factor of a user together with the recovery codes.
+func (m *mfaRepository) Delete(idUser string) error {
+	if _, err := m.db.Exec("DELETE FROM user_mfa WHERE id_user = $1", idUser); err != nil {
+		m.log.Error("Failed to delete the user mfa: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *mfaRepository) CreateChallenge(tokenHash, idUser string, expiresAt time.Time) error {
+	if _, err := m.db.Exec("INSERT INTO mfa_challenge (token_hash, id_user, expires_at) VALUES ($1, $2, $3)", tokenHash, idUser, expiresAt); err != nil {
+		m.log.Error("Failed to create the mfa challenge: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// ChallengeUser counts an attempt on a challenge and returns its user. It returns sql.ErrNoRows when the
+// challenge is unknown, expired or out of attempts.
+func (m *mfaRepository) ChallengeUser(tokenHash string, maxAttempts int) (string, error) {
+	var idUser string
+
+	err := m.db.QueryRow(`
+		UPDATE mfa_challenge SET attempts = attempts + 1
+		WHERE token_hash = $1 AND expires_at > NOW() AND attempts < $2
+		RETURNING id_user`,
+		tokenHash, maxAttempts,
+	).Scan(&idUser)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		m.log.Error("Failed to get the mfa challenge: ", err)
+	}
+
+	return idUser, err
+}
+
+func (m *mfaRepository) DeleteChallenge(tokenHash string) error {
+	if _, err := m.db.Exec("DELETE FROM mfa_challenge WHERE token_hash = $1 OR expires_at < NOW()", tokenHash); err != nil {
+		m.log.Error("Failed to delete the mfa challenge: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func NewMFARepository(db *sql.DB, log *logger.Logger) MFARepository {
+	return &mfaRepository{db: db, log: log}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+)
+
+type OperatorPrefixRepository interface {
+	List() ([]entity.OperatorPrefix, error)
+	Save(payload entity.OperatorPrefix) (entity.OperatorPrefix, error)
+	Delete(prefix string) error
+	FindByNumber(number string) (entity.OperatorPrefix, error)
+}
+
+type operatorPrefixRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+func (o *operatorPrefixRepository) List() ([]entity.OperatorPrefix, error) {
+	o.log.Info("Starting to retrive all operator prefixes in the repository layer", nil)
+
+	rows, err := o.db.Query("SELECT prefix, operator, updated_at FROM mst_operator_prefix ORDER BY prefix")
+	if err != nil {
+		o.log.Error("Failed to retrive the operator prefixes: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prefixes []entity.OperatorPrefix
+	for rows.Next() {
+		var prefix entity.OperatorPrefix
+		if err := rows.Scan(&prefix.Prefix, &prefix.Operator, &prefix.UpdatedAt); err != nil {
+			o.log.Error("Failed to scan the operator prefix: ", err)
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	if err := rows.Err(); err != nil {
+		o.log.Error("Failed to read the operator prefixes: ", err)
+		return nil, err
+	}
+
+	return prefixes, nil
+}
+
+// Save creates the prefix or moves an existing prefix to another operator.
+func (o *operatorPrefixRepository) Save(payload entity.OperatorPrefix) (entity.OperatorPrefix, error) {
+	o.log.Info("Starting to save an operator prefix in the repository layer", payload)
+
+	err := o.db.QueryRow(`
+		INSERT INTO mst_operator_prefix (prefix, operator) VALUES ($1, $2)
+		ON CONFLICT (prefix) DO UPDATE SET operator = EXCLUDED.operator, updated_at = NOW()
+		RETURNING updated_at`,
+		payload.Prefix, payload.Operator,
+	).Scan(&payload.UpdatedAt)
+	if err != nil {
+		o.log.Error("Failed to save the operator prefix: ", err)
+		return entity.OperatorPrefix{}, err
+	}
+
+	return payload, nil
+}
+
+func (o *operatorPrefixRepository) Delete(prefix string) error {
+	o.log.Info("Starting to delete an operator prefix in the repository layer", prefix)
+
+	res, err := o.db.Exec("DELETE FROM mst_operator_prefix WHERE prefix = $1", prefix)
+	if err != nil {
+		o.log.Error("Failed to delete the operator prefix: ", err)
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		o.log.Error("Failed to read affected rows: ", err)
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// FindByNumber returns the longest prefix matching the number, or sql.ErrNoRows when none does.
+func (o *operatorPrefixRepository) FindByNumber(number string) (entity.OperatorPrefix, error) {
+	var prefix entity.OperatorPrefix
+
+	err := o.db.QueryRow(`
+		SELECT prefix, operator, updated_at FROM mst_operator_prefix
+		WHERE $1 LIKE prefix || '%'
+		ORDER BY LENGTH(prefix) DESC
+		LIMIT 1`,
+		number,
+	).Scan(&prefix.Prefix, &prefix.Operator, &prefix.UpdatedAt)
+	if err != nil {
+		o.log.Error("Failed to find the operator of the number: ", err)
+		return entity.OperatorPrefix{}, err
+	}
+
+	return prefix, nil
+}
+
+func NewOperatorPrefixRepository(db *sql.DB, log *logger.Logger) OperatorPrefixRepository {
+	return &operatorPrefixRepository{db: db, log: log}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type operatorPrefixRepositoryTestSuite struct {
+	suite.Suite
+	mockDb       *sql.DB
+	mockSql      sqlmock.Sqlmock
+	log          logger.Logger
+	operatorRepo OperatorPrefixRepository
+}
+
+func TestOperatorPrefixRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(operatorPrefixRepositoryTestSuite))
+}
+
+func (s *operatorPrefixRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.log = logger.NewLogger()
+	s.operatorRepo = NewOperatorPrefixRepository(mockDb, &s.log)
+}
+
+func (s *operatorPrefixRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+var (
+	testPrefixUpdatedAt = time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	operatorPrefixRows  = []string{"prefix", "operator", "updated_at"}
+)
+
+func (s *operatorPrefixRepositoryTestSuite) TestFindByNumber_LongestPrefix() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT prefix, operator, updated_at FROM mst_operator_prefix
+		WHERE $1 LIKE prefix || '%'
+		ORDER BY LENGTH(prefix) DESC
+		LIMIT 1`)).
+		WithArgs("081234567890").
+		WillReturnRows(sqlmock.NewRows(operatorPrefixRows).AddRow("0812", "Telkomsel", testPrefixUpdatedAt))
+
+	prefix, err := s.operatorRepo.FindByNumber("081234567890")
+
+	s.NoError(err)
+	s.Equal(entity.OperatorPrefix{Prefix: "0812", Operator: "Telkomsel", UpdatedAt: testPrefixUpdatedAt}, prefix)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *operatorPrefixRepositoryTestSuite) TestFindByNumber_UnknownPrefix() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`FROM mst_operator_prefix WHERE $1 LIKE prefix || '%'`)).
+		WithArgs("099912345678").
+		WillReturnRows(sqlmock.NewRows(operatorPrefixRows))
+
+	_, err := s.operatorRepo.FindByNumber("099912345678")
+
+	s.ErrorIs(err, sql.ErrNoRows)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *operatorPrefixRepositoryTestSuite) TestList() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT prefix, operator, updated_at FROM mst_operator_prefix ORDER BY prefix`)).
+		WillReturnRows(sqlmock.NewRows(operatorPrefixRows).
+			AddRow("0812", "Telkomsel", testPrefixUpdatedAt).
+			AddRow("0817", "XL", testPrefixUpdatedAt))
+
+	prefixes, err := s.operatorRepo.List()
+
+	s.NoError(err)
+	s.Equal([]entity.OperatorPrefix{
+		{Prefix: "0812", Operator: "Telkomsel", UpdatedAt: testPrefixUpdatedAt},
+		{Prefix: "0817", Operator: "XL", UpdatedAt: testPrefixUpdatedAt},
+	}, prefixes)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *operatorPrefixRepositoryTestSuite) TestSave_MovesPrefix() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO mst_operator_prefix (prefix, operator) VALUES ($1, $2)
+		ON CONFLICT (prefix) DO UPDATE SET operator = EXCLUDED.operator, updated_at = NOW()
+		RETURNING updated_at`)).
+		WithArgs("0812", "Indosat").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(testPrefixUpdatedAt))
+
+	saved, err := s.operatorRepo.Save(entity.OperatorPrefix{Prefix: "0812", Operator: "Indosat"})
+
+	s.NoError(err)
+	s.Equal(entity.OperatorPrefix{Prefix: "0812", Operator: "Indosat", UpdatedAt: testPrefixUpdatedAt}, saved)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *operatorPrefixRepositoryTestSuite) TestDelete_NotFound() {
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`DELETE FROM mst_operator_prefix WHERE prefix = $1`)).
+		WithArgs("0899").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := s.operatorRepo.Delete("0899")
+
+	s.ErrorIs(err, sql.ErrNoRows)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"time"
 
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/shared/custom"
@@ -9,6 +10,7 @@ import (
 
 type ReportRepository interface {
 	List(userId, startDate, endDate string) ([]custom.ReportResp, error)
+	Profit(startDate, endDate time.Time, merchantId string) ([]custom.ProfitReport, error)
 }
 
 type reportRepository struct {
@@ -68,6 +70,54 @@ func (r *reportRepository) List(userId, startDate, endDate string) ([]custom.Rep
 	return reportSlice, nil
 }
 
+// Profit aggregates the cost, selling price and margin captured on successful transaction details.
+// Failed and refunded details are left out since their cost went back to the merchant.
+func (r *reportRepository) Profit(startDate, endDate time.Time, merchantId string) ([]custom.ProfitReport, error) {
+	selectQuery := `
+		SELECT
+			m.id_merchant, m.name_merchant, p.name_provider, TO_CHAR(t.transaction_date, 'DD-MM-YYYY'),
+			COUNT(td.transaction_detail_id), SUM(td.price), SUM(td.cost), SUM(td.margin)
+		FROM transaction_detail td
+		JOIN transactions t ON td.transaction_id = t.transaction_id
+		JOIN mst_merchant m ON t.id_merchant = m.id_merchant
+		JOIN mst_product p ON td.id_product = p.id_product
+		WHERE td.status = 'success'
+		AND t.transaction_date >= $1
+		AND t.transaction_date <= $2
+		AND ($3 = '' OR m.id_merchant::text = $3)
+		GROUP BY m.id_merchant, m.name_merchant, p.name_provider, t.transaction_date
+		ORDER BY t.transaction_date, m.name_merchant, p.name_provider`
+
+	r.log.Info("Starting to retrive profit report in the repository layer", nil)
+
+	rows, err := r.db.Query(selectQuery, startDate, endDate, merchantId)
+	if err != nil {
+		r.log.Error("Failed to retrieve the profit report", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []custom.ProfitReport
+	for rows.Next() {
+		var report custom.ProfitReport
+		if err := rows.Scan(
+			&report.MerchantId, &report.MerchantName, &report.Provider, &report.Date,
+			&report.Count, &report.GrossSales, &report.Cost, &report.Profit,
+		); err != nil {
+			r.log.Error("Failed to scan profit report", err)
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.log.Error("Failed to scan profit report", err)
+		return nil, err
+	}
+
+	return reports, nil
+}
+
 func NewReportRepository(db *sql.DB, log *logger.Logger) ReportRepository {
 	return &reportRepository{db: db, log: log}
 }
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/shared/custom"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type reportRepositoryTestSuite struct {
+	suite.Suite
+	mockDb     *sql.DB
+	mockSql    sqlmock.Sqlmock
+	log        logger.Logger
+	reportRepo ReportRepository
+}
+
+func TestReportRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(reportRepositoryTestSuite))
+}
+
+func (s *reportRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.log = logger.NewLogger()
+	s.reportRepo = NewReportRepository(mockDb, &s.log)
+}
+
+func (s *reportRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+var (
+	testProfitStart = time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	testProfitEnd   = time.Date(2024, 11, 30, 0, 0, 0, 0, time.UTC)
+	profitRows      = []string{"id_merchant", "name_merchant", "name_provider", "to_char", "count", "sum_price", "sum_cost", "sum_margin"}
+)
+
+func (s *reportRepositoryTestSuite) TestProfit_GroupsSuccessfulDetails() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`WHERE td.status = 'success'
+		AND t.transaction_date >= $1
+		AND t.transaction_date <= $2
+		AND ($3 = '' OR m.id_merchant::text = $3)
+		GROUP BY m.id_merchant, m.name_merchant, p.name_provider, t.transaction_date
+		ORDER BY t.transaction_date, m.name_merchant, p.name_provider`)).
+		WithArgs(testProfitStart, testProfitEnd, "").
+		WillReturnRows(sqlmock.NewRows(profitRows).
+			AddRow("merchant-uuid", "Toko A", "Telkomsel", "01-11-2024", 3, 165000, 150000, 15000).
+			AddRow("merchant-uuid", "Toko A", "XL", "01-11-2024", 1, 26000, 25000, 1000))
+
+	reports, err := s.reportRepo.Profit(testProfitStart, testProfitEnd, "")
+
+	s.NoError(err)
+	s.Equal([]custom.ProfitReport{
+		{MerchantId: "merchant-uuid", MerchantName: "Toko A", Provider: "Telkomsel", Date: "01-11-2024", Count: 3, GrossSales: 165000, Cost: 150000, Profit: 15000},
+		{MerchantId: "merchant-uuid", MerchantName: "Toko A", Provider: "XL", Date: "01-11-2024", Count: 1, GrossSales: 26000, Cost: 25000, Profit: 1000},
+	}, reports)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *reportRepositoryTestSuite) TestProfit_FiltersMerchant() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`GROUP BY m.id_merchant, m.name_merchant, p.name_provider, t.transaction_date`)).
+		WithArgs(testProfitStart, testProfitEnd, "merchant-uuid").
+		WillReturnRows(sqlmock.NewRows(profitRows))
+
+	reports, err := s.reportRepo.Profit(testProfitStart, testProfitEnd, "merchant-uuid")
+
+	s.NoError(err)
+	s.Empty(reports)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *reportRepositoryTestSuite) TestProfit_ScanError() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`FROM transaction_detail td`)).
+		WithArgs(testProfitStart, testProfitEnd, "").
+		WillReturnRows(sqlmock.NewRows(profitRows).
+			AddRow("merchant-uuid", "Toko A", "Telkomsel", "01-11-2024", "three", 165000, 150000, 15000))
+
+	_, err := s.reportRepo.Profit(testProfitStart, testProfitEnd, "")
+
+	s.Error(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *reportRepositoryTestSuite) TestList_CountsPerProvider() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`GROUP BY p.name_provider ORDER BY 2 DESC`)).
+		WithArgs("user-uuid", "2024-11-01", "2024-11-30").
+		WillReturnRows(sqlmock.NewRows([]string{"name_provider", "count"}).AddRow("Telkomsel", 3))
+
+	reports, err := s.reportRepo.List("user-uuid", "2024-11-01", "2024-11-30")
+
+	s.NoError(err)
+	s.Equal([]custom.ReportResp{{ProviderName: "Telkomsel", Count: "3"}}, reports)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
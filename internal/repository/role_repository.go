@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRoleExists = errors.New("role already exists")
+	ErrRoleInUse  = errors.New("role is still assigned to users")
+)
+
+type RoleRepository interface {
+	List() ([]entity.Role, error)
+	Get(name string) (entity.Role, error)
+	Create(payload entity.Role) (entity.Role, error)
+	Update(payload entity.Role) (entity.Role, error)
+	Delete(name string) error
+	Permissions(role string) ([]string, error)
+	SetRequireMFA(name string, required bool) error
+}
+
+type roleRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+const roleQuery = `
+	SELECT r.name, r.description, r.built_in, r.require_mfa, r.created_at,
+		COALESCE(array_agg(p.permission ORDER BY p.permission) FILTER (WHERE p.permission IS NOT NULL), '{}')
+	FROM mst_role r LEFT JOIN role_permission p ON p.role = r.name`
+
+func scanRole(row interface{ Scan(...any) error }) (entity.Role, error) {
+	var role entity.Role
+	err := row.Scan(&role.Name, &role.Description, &role.BuiltIn, &role.RequireMFA, &role.CreatedAt, pq.Array(&role.Permissions))
+	return role, err
+}
+
+func (r *roleRepository) List() ([]entity.Role, error) {
+	r.log.Info("Starting to retrive all roles in the repository layer", nil)
+
+	rows, err := r.db.Query(roleQuery + " GROUP BY r.name ORDER BY r.name")
+	if err != nil {
+		r.log.Error("Failed to retrive the roles: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []entity.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			r.log.Error("Failed to scan the role: ", err)
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// Get returns a role with its permissions, or sql.ErrNoRows.
+func (r *roleRepository) Get(name string) (entity.Role, error) {
+	return scanRole(r.db.QueryRow(roleQuery+" WHERE r.name = $1 GROUP BY r.name", name))
+}
+
+func (r *roleRepository) Create(payload entity.Role) (entity.Role, error) {
+	r.log.Info("Starting to create a role in the repository layer", payload)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entity.Role{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow("INSERT INTO mst_role (name, description) VALUES ($1, $2) RETURNING created_at", payload.Name, payload.Description).
+		Scan(&payload.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return entity.Role{}, ErrRoleExists
+	} else if err != nil {
+		r.log.Error("Failed to create the role: ", err)
+		return entity.Role{}, err
+	}
+
+	if err := r.grant(tx, payload.Name, payload.Permissions); err != nil {
+		return entity.Role{}, err
+	}
+
+	return payload, tx.Commit()
+}
+
+// Update replaces the description and permissions of a role and returns sql.ErrNoRows when it doesn't exist.
+func (r *roleRepository) Update(payload entity.Role) (entity.Role, error) {
+	r.log.Info("Starting to update a role in the repository layer", payload)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return entity.Role{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow("UPDATE mst_role SET description = $2 WHERE name = $1 RETURNING built_in, require_mfa, created_at", payload.Name, payload.Description).
+		Scan(&payload.BuiltIn, &payload.RequireMFA, &payload.CreatedAt)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_permission WHERE role = $1", payload.Name); err != nil {
+		r.log.Error("Failed to clear the role permissions: ", err)
+		return entity.Role{}, err
+	}
+
+	if err := r.grant(tx, payload.Name, payload.Permissions); err != nil {
+		return entity.Role{}, err
+	}
+
+	return payload, tx.Commit()
+}
+
+// Delete removes a role. It returns sql.ErrNoRows when the role doesn't exist and ErrRoleInUse while users
+// still have it.
+func (r *roleRepository) Delete(name string) error {
+	r.log.Info("Starting to delete a role in the repository layer", name)
+
+	res, err := r.db.Exec("DELETE FROM mst_role WHERE name = $1", name)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrRoleInUse
+	} else if err != nil {
+		r.log.Error("Failed to delete the role: ", err)
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Permissions returns the permissions granted to a role, empty when the role doesn't exist.
+func (r *roleRepository) Permissions(role string) ([]string, error) {
+	var permissions []string
+
+	err := r.db.QueryRow("SELECT COALESCE(array_agg(permission), '{}') FROM role_permission WHERE role = $1", role).
+		Scan(pq.Array(&permissions))
+	if err != nil {
+		r.log.Error("Failed to get the role permissions: ", err)
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// SetRequireMFA makes two factor authentication mandatory for the users of a role and returns sql.ErrNoRows when
+// the role doesn't exist.
+func (r *roleRepository) SetRequireMFA(name string, required bool) error {
+	r.log.Info("Starting to set the mfa requirement of a role in the repository layer", name)
+
+	res, err := r.db.Exec("UPDATE mst_role SET require_mfa = $2 WHERE name = $1", name, required)
+	if err != nil {
+		r.log.Error("Failed to set the mfa requirement: ", err)
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *roleRepository) grant(tx *sql.Tx, role string, permissions []string) error {
+	if _, err := tx.Exec("INSERT INTO role_permission (role, permission) SELECT $1, unnest($2::text[])", role, pq.Array(permissions)); err != nil {
+		r.log.Error("Failed to grant the role permissions: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func NewRoleRepository(db *sql.DB, log *logger.Logger) RoleRepository {
+	return &roleRepository{db: db, log: log}
+}
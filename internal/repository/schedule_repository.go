@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+)
+
+type ScheduleRepository interface {
+	Create(payload entity.TransactionSchedule) (entity.TransactionSchedule, error)
+	List(userId string) ([]entity.TransactionSchedule, error)
+	Get(id string) (entity.TransactionSchedule, error)
+	Deactivate(id string) error
+	ListRuns(id string) ([]entity.TransactionScheduleRun, error)
+	ClaimDue(now time.Time, lease time.Duration, limit int) ([]entity.TransactionSchedule, error)
+	Finish(schedule entity.TransactionSchedule, run entity.TransactionScheduleRun) error
+}
+
+type scheduleRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+const scheduleColumns = `id, id_merchant, id_user, customer_name, destination_number, id_product, recurrence,
+	occurrence_at, next_run_at, retry_count, active, created_at`
+
+func scanSchedule(row interface{ Scan(...any) error }) (entity.TransactionSchedule, error) {
+	var schedule entity.TransactionSchedule
+	err := row.Scan(
+		&schedule.Id, &schedule.MerchantId, &schedule.UserId, &schedule.CustomerName, &schedule.DestinationNumber,
+		&schedule.ProductId, &schedule.Recurrence, &schedule.OccurrenceAt, &schedule.NextRunAt,
+		&schedule.RetryCount, &schedule.Active, &schedule.CreatedAt,
+	)
+	return schedule, err
+}
+
+func (s *scheduleRepository) Create(payload entity.TransactionSchedule) (entity.TransactionSchedule, error) {
+	s.log.Info("Starting to create a new transaction schedule in the repository layer", nil)
+
+	schedule, err := scanSchedule(s.db.QueryRow(`
+		INSERT INTO transaction_schedule
+			(id_merchant, id_user, customer_name, destination_number, id_product, recurrence, occurrence_at, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING `+scheduleColumns,
+		payload.MerchantId, payload.UserId, payload.CustomerName, payload.DestinationNumber, payload.ProductId,
+		payload.Recurrence, payload.OccurrenceAt,
+	))
+	if err != nil {
+		s.log.Error("Failed to create the transaction schedule: ", err)
+		return entity.TransactionSchedule{}, err
+	}
+
+	return schedule, nil
+}
+
+func (s *scheduleRepository) List(userId string) ([]entity.TransactionSchedule, error) {
+	s.log.Info("Starting to retrive transaction schedules in the repository layer", nil)
+
+	rows, err := s.db.Query("SELECT "+scheduleColumns+" FROM transaction_schedule WHERE id_user = $1 ORDER BY created_at DESC", userId)
+	if err != nil {
+		s.log.Error("Failed to retrive the transaction schedules: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []entity.TransactionSchedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			s.log.Error("Failed to scan the transaction schedule: ", err)
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.log.Error("Failed to read the transaction schedules: ", err)
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (s *scheduleRepository) Get(id string) (entity.TransactionSchedule, error) {
+	schedule, err := scanSchedule(s.db.QueryRow("SELECT "+scheduleColumns+" FROM transaction_schedule WHERE id = $1", id))
+	if err != nil {
+		s.log.Error("Failed to retrive the transaction schedule: ", err)
+		return entity.TransactionSchedule{}, err
+	}
+	return schedule, nil
+}
+
+func (s *scheduleRepository) Deactivate(id string) error {
+	s.log.Info("Starting to deactivate a transaction schedule in the repository layer", id)
+
+	if _, err := s.db.Exec("UPDATE transaction_schedule SET active = FALSE, updated_at = NOW() WHERE id = $1", id); err != nil {
+		s.log.Error("Failed to deactivate the transaction schedule: ", err)
+		return err
+	}
+	return nil
+}
+
+func (s *scheduleRepository) ListRuns(id string) ([]entity.TransactionScheduleRun, error) {
+	rows, err := s.db.Query(`
+		SELECT id, schedule_id, scheduled_for, status, COALESCE(transaction_id::text, ''), COALESCE(message, ''), created_at
+		FROM transaction_schedule_run
+		WHERE schedule_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 100`, id)
+	if err != nil {
+		s.log.Error("Failed to retrive the transaction schedule runs: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []entity.TransactionScheduleRun
+	for rows.Next() {
+		var run entity.TransactionScheduleRun
+		if err := rows.Scan(&run.Id, &run.ScheduleId, &run.ScheduledFor, &run.Status, &run.TransactionId, &run.Message, &run.CreatedAt); err != nil {
+			s.log.Error("Failed to scan the transaction schedule run: ", err)
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.log.Error("Failed to read the transaction schedule runs: ", err)
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// ClaimDue leases up to limit active schedules whose next run is due. A leased schedule is skipped
+// by other workers until Finish releases it or the lease expires.
+func (s *scheduleRepository) ClaimDue(now time.Time, lease time.Duration, limit int) ([]entity.TransactionSchedule, error) {
+	rows, err := s.db.Query(`
+		UPDATE transaction_schedule
+		SET locked_until = $2, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM transaction_schedule
+			WHERE active AND next_run_at <= $1 AND (locked_until IS NULL OR locked_until < $1)
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+scheduleColumns,
+		now, now.Add(lease), limit,
+	)
+	if err != nil {
+		s.log.Error("Failed to claim due transaction schedules", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []entity.TransactionSchedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			s.log.Error("Failed to scan claimed transaction schedule", err)
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		s.log.Error("Failed to read claimed transaction schedules", err)
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// Finish records the outcome of a run and stores the next occurrence of the schedule, releasing its lease.
+func (s *scheduleRepository) Finish(schedule entity.TransactionSchedule, run entity.TransactionScheduleRun) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.log.Error("Failed start db transaction", err)
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO transaction_schedule_run (schedule_id, scheduled_for, status, transaction_id, message)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, NULLIF($5, ''))`,
+		run.ScheduleId, run.ScheduledFor, run.Status, run.TransactionId, run.Message,
+	); err != nil {
+		tx.Rollback()
+		s.log.Error("Failed to insert into transaction schedule run table", err)
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE transaction_schedule
+		SET occurrence_at = $2, next_run_at = $3, retry_count = $4, active = active AND $5, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1`,
+		schedule.Id, schedule.OccurrenceAt, schedule.NextRunAt, schedule.RetryCount, schedule.Active,
+	); err != nil {
+		tx.Rollback()
+		s.log.Error("Failed to update the transaction schedule", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.log.Error("Failed to commit transaction", err)
+		return err
+	}
+	return nil
+}
+
+func NewScheduleRepository(db *sql.DB, log *logger.Logger) ScheduleRepository {
+	return &scheduleRepository{db: db, log: log}
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type scheduleRepositoryTestSuite struct {
+	suite.Suite
+	mockDb       *sql.DB
+	mockSql      sqlmock.Sqlmock
+	log          logger.Logger
+	scheduleRepo ScheduleRepository
+}
+
+func TestScheduleRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(scheduleRepositoryTestSuite))
+}
+
+func (s *scheduleRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.log = logger.NewLogger()
+	s.scheduleRepo = NewScheduleRepository(mockDb, &s.log)
+}
+
+func (s *scheduleRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+var (
+	testScheduleNow = time.Date(2024, 11, 1, 7, 0, 0, 0, time.UTC)
+	scheduleRows    = []string{
+		"id", "id_merchant", "id_user", "customer_name", "destination_number", "id_product", "recurrence",
+		"occurrence_at", "next_run_at", "retry_count", "active", "created_at",
+	}
+	expectedSchedule = entity.TransactionSchedule{
+		Id:                "schedule-uuid",
+		MerchantId:        "merchant-uuid",
+		UserId:            "user-uuid",
+		CustomerName:      "Budi",
+		DestinationNumber: "081234567890",
+		ProductId:         "product-uuid",
+		Recurrence:        "0 7 1 * *",
+		OccurrenceAt:      testScheduleNow,
+		NextRunAt:         testScheduleNow,
+		Active:            true,
+		CreatedAt:         testScheduleNow.AddDate(0, -1, 0),
+	}
+)
+
+func (s *scheduleRepositoryTestSuite) TestClaimDue_LeasesDueSchedules() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE transaction_schedule
+		SET locked_until = $2, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM transaction_schedule
+			WHERE active AND next_run_at <= $1 AND (locked_until IS NULL OR locked_until < $1)
+			ORDER BY next_run_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)`)).
+		WithArgs(testScheduleNow, testScheduleNow.Add(5*time.Minute), 20).
+		WillReturnRows(sqlmock.NewRows(scheduleRows).AddRow(
+			expectedSchedule.Id, expectedSchedule.MerchantId, expectedSchedule.UserId, expectedSchedule.CustomerName,
+			expectedSchedule.DestinationNumber, expectedSchedule.ProductId, expectedSchedule.Recurrence,
+			expectedSchedule.OccurrenceAt, expectedSchedule.NextRunAt, expectedSchedule.RetryCount,
+			expectedSchedule.Active, expectedSchedule.CreatedAt,
+		))
+
+	schedules, err := s.scheduleRepo.ClaimDue(testScheduleNow, 5*time.Minute, 20)
+
+	s.NoError(err)
+	s.Equal([]entity.TransactionSchedule{expectedSchedule}, schedules)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *scheduleRepositoryTestSuite) TestClaimDue_NothingDue() {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE transaction_schedule SET locked_until = $2`)).
+		WithArgs(testScheduleNow, testScheduleNow.Add(5*time.Minute), 20).
+		WillReturnRows(sqlmock.NewRows(scheduleRows))
+
+	schedules, err := s.scheduleRepo.ClaimDue(testScheduleNow, 5*time.Minute, 20)
+
+	s.NoError(err)
+	s.Empty(schedules)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *scheduleRepositoryTestSuite) TestFinish_AdvancesAndReleases() {
+	next := expectedSchedule
+	next.OccurrenceAt = testScheduleNow.AddDate(0, 1, 0)
+	next.NextRunAt = next.OccurrenceAt
+	run := entity.TransactionScheduleRun{ScheduleId: next.Id, ScheduledFor: testScheduleNow, Status: entity.ScheduleRunSuccess, TransactionId: "tx-uuid"}
+
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO transaction_schedule_run (schedule_id, scheduled_for, status, transaction_id, message)`)).
+		WithArgs(next.Id, testScheduleNow, entity.ScheduleRunSuccess, "tx-uuid", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transaction_schedule
+		SET occurrence_at = $2, next_run_at = $3, retry_count = $4, active = active AND $5, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1`)).
+		WithArgs(next.Id, next.OccurrenceAt, next.NextRunAt, 0, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectCommit()
+
+	err := s.scheduleRepo.Finish(next, run)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *scheduleRepositoryTestSuite) TestFinish_RollsBackWhenRunFails() {
+	run := entity.TransactionScheduleRun{ScheduleId: expectedSchedule.Id, ScheduledFor: testScheduleNow, Status: entity.ScheduleRunFailed, Message: "product not found"}
+
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO transaction_schedule_run`)).
+		WithArgs(expectedSchedule.Id, testScheduleNow, entity.ScheduleRunFailed, "", "product not found").
+		WillReturnError(errors.New("insert failed"))
+	s.mockSql.ExpectRollback()
+
+	err := s.scheduleRepo.Finish(expectedSchedule, run)
+
+	s.Error(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
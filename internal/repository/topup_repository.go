@@ -2,47 +2,243 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"server-pulsa-app/internal/entity"
 	"time"
 )
 
+var (
+	// ErrIllegalTopupTransition is returned when a topup status change is not allowed from its current status.
+	ErrIllegalTopupTransition = errors.New("illegal topup status transition")
+	// ErrNotManualTopup is returned when a review targets a topup paid through the payment gateway.
+	ErrNotManualTopup = errors.New("topup is not a manual topup")
+	// ErrTopupLimitExceeded is returned when a new topup would take the merchant over one of its limits.
+	ErrTopupLimitExceeded = errors.New("topup limit exceeded")
+)
+
 type topupRepository struct {
 	db *sql.DB
 }
 
+const topupColumns = `id, id_merchant, id_supliyer, item_name, amount, payment_method, status, method,
+	COALESCE(rejection_reason, ''), COALESCE(reviewed_by::text, ''), fee, fee_charged_to, created_at`
+
+func scanTopup(row interface{ Scan(...any) error }) (entity.TopupRequest, error) {
+	var payload entity.TopupRequest
+	err := row.Scan(
+		&payload.Id, &payload.IdMerchant, &payload.IdSupliyer, &payload.Item_name, &payload.Amount, &payload.PaymentMethod,
+		&payload.Status, &payload.Method, &payload.RejectionReason, &payload.ReviewedBy, &payload.Fee, &payload.FeeChargedTo,
+		&payload.CreatedAt,
+	)
+	return payload, err
+}
+
+func scanTopups(rows *sql.Rows) ([]entity.TopupRequest, error) {
+	var topups []entity.TopupRequest
+	for rows.Next() {
+		item, err := scanTopup(rows)
+		if err != nil {
+			return nil, err
+		}
+		topups = append(topups, item)
+	}
+
+	return topups, rows.Err()
+}
+
 type TopupRepository interface {
-	CreateTopup(payload entity.TopupRequest) (string, error)
+	CreateTopup(payload entity.TopupRequest, limits []entity.TopupLimit) (string, error)
+	CreateTopupIdempotent(payload entity.TopupRequest, key entity.IdempotencyKey, lease time.Duration, limits []entity.TopupLimit) (string, entity.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(key entity.IdempotencyKey) error
+	ReleaseIdempotencyKey(key entity.IdempotencyKey, idTopup string) error
+	CancelTopup(idTopup string) error
 	GetTopupById(tx *sql.Tx, id string) (entity.TopupRequest, error)
+	FindTopup(id string) (entity.TopupRequest, error)
 	GetTopupByMerchantId(idMerchant string) ([]entity.TopupRequestDetail, error)
+	GetTopupByUser(idUser string) ([]entity.TopupRequestDetail, error)
+	MerchantIdsByUser(idUser string) ([]string, error)
 	UpdateStatus(tx *sql.Tx, status, idTopup string) error
 	UpdatePaymentMethod(tx *sql.Tx, paymentMethod, idTopup string) error
-	UpdateBalanceMerchant(tx *sql.Tx, balance int, idMerchant string) error
+	UpdateBalanceMerchant(tx *sql.Tx, balance int, idMerchant, idTopup string) error
 	UpdateBalanceSupliyer(tx *sql.Tx, balance int, idSupliyer string) error
-	TxTopupUpdateAfterPayment(payload entity.TopupRequest) error
+	TxTopupUpdateAfterPayment(payload entity.TopupRequest, audit entity.AuditLog) error
+	ClaimPending(createdBefore, checkedBefore time.Time, limit int) ([]entity.TopupRequest, error)
+	CreateManualTopup(payload entity.TopupRequest, proof entity.TopupProof, limits []entity.TopupLimit) (string, error)
+	ListPendingManual() ([]entity.TopupRequest, error)
+	GetTopupProof(idTopup string) (entity.TopupProof, error)
+	ReviewManualTopup(payload entity.TopupRequest, audit entity.AuditLog) error
 }
 
-func (t *topupRepository) CreateTopup(payload entity.TopupRequest) (string, error) {
+// CreateTopup checks the limits of the merchant and stores the topup in one transaction.
+func (t *topupRepository) CreateTopup(payload entity.TopupRequest, limits []entity.TopupLimit) (string, error) {
 	payload.CreatedAt = time.Now()
 
-	query := "INSERT INTO tx_topup (id_merchant, id_supliyer, item_name, amount, payment_method, status, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	tx, err := t.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction")
+	}
+
+	if err := checkTopupLimits(tx, payload, limits); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	query := "INSERT INTO tx_topup (id_merchant, id_supliyer, item_name, amount, payment_method, status, fee, fee_charged_to, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id"
+
+	if err := tx.QueryRow(query, payload.IdMerchant, payload.IdSupliyer, payload.Item_name, payload.Amount, payload.PaymentMethod, payload.Status, payload.Fee, feeChargedTo(payload.FeeChargedTo), payload.CreatedAt).Scan(&payload.Id); err != nil {
+		tx.Rollback()
+		return "", err
+	}
 
-	if err := t.db.QueryRow(query, payload.IdMerchant, payload.IdSupliyer, payload.Item_name, payload.Amount, payload.PaymentMethod, payload.Status, payload.CreatedAt).Scan(&payload.Id); err != nil {
+	if err := tx.Commit(); err != nil {
 		return "", err
 	}
 
 	return payload.Id, nil
 }
 
-func (t *topupRepository) GetTopupById(tx *sql.Tx, id string) (entity.TopupRequest, error) {
-	var payload entity.TopupRequest
+// checkTopupLimits locks the merchant and adds up its pending and paid topups of each limit, so concurrent topups
+// of one merchant are counted one after another and can't pass a limit together.
+func checkTopupLimits(tx *sql.Tx, payload entity.TopupRequest, limits []entity.TopupLimit) error {
+	if len(limits) == 0 {
+		return nil
+	}
 
-	query := "SELECT * FROM tx_topup WHERE id = $1"
+	if _, err := tx.Exec("SELECT id_merchant FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE", payload.IdMerchant); err != nil {
+		return fmt.Errorf("failed to lock merchant: %w", err)
+	}
 
-	err := tx.QueryRow(query, id).Scan(&payload.Id, &payload.IdMerchant, &payload.IdSupliyer, &payload.Item_name, &payload.Amount, &payload.PaymentMethod, &payload.Status, &payload.CreatedAt)
+	for _, l := range limits {
+		var total float64
+		err := tx.QueryRow(
+			"SELECT COALESCE(SUM(amount), 0) FROM tx_topup WHERE id_merchant = $1 AND created_at >= $2 AND status IN ('pending', 'paid')",
+			payload.IdMerchant, l.Since,
+		).Scan(&total)
+		if err != nil {
+			return fmt.Errorf("failed to sum merchant topups: %w", err)
+		}
+		if int(total)+payload.Amount > l.Limit {
+			return fmt.Errorf("%w: the %s limit of %d leaves %d", ErrTopupLimitExceeded, l.Name, l.Limit, max(l.Limit-int(total), 0))
+		}
+	}
+
+	return nil
+}
+
+// CreateTopupIdempotent reserves the key and stores the topup in one transaction. A key left in progress for longer
+// than lease by a request that died before storing its response is taken over instead of being reported as used.
+// The limits are only checked for a new or taken over key, so a retry still replays its stored response.
+func (t *topupRepository) CreateTopupIdempotent(payload entity.TopupRequest, key entity.IdempotencyKey, lease time.Duration, limits []entity.TopupLimit) (string, entity.IdempotencyKey, bool, error) {
+	payload.CreatedAt = time.Now()
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return "", entity.IdempotencyKey{}, false, fmt.Errorf("failed to begin transaction")
+	}
+
+	stored, exists, err := reserveIdempotencyKey(tx, key)
+	if err != nil {
+		tx.Rollback()
+		return "", entity.IdempotencyKey{}, false, err
+	}
+	if exists && stored.Response == nil {
+		taken, err := takeOverIdempotencyKey(tx, key, lease)
+		if err != nil {
+			tx.Rollback()
+			return "", entity.IdempotencyKey{}, false, err
+		}
+		exists = !taken
+	}
+	if exists {
+		tx.Rollback()
+		return "", stored, true, nil
+	}
+
+	if err := checkTopupLimits(tx, payload, limits); err != nil {
+		tx.Rollback()
+		return "", entity.IdempotencyKey{}, false, err
+	}
+
+	query := "INSERT INTO tx_topup (id_merchant, id_supliyer, item_name, amount, payment_method, status, fee, fee_charged_to, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id"
+
+	if err := tx.QueryRow(query, payload.IdMerchant, payload.IdSupliyer, payload.Item_name, payload.Amount, payload.PaymentMethod, payload.Status, payload.Fee, feeChargedTo(payload.FeeChargedTo), payload.CreatedAt).Scan(&payload.Id); err != nil {
+		tx.Rollback()
+		return "", entity.IdempotencyKey{}, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", entity.IdempotencyKey{}, false, err
+	}
+
+	return payload.Id, key, false, nil
+}
+
+func (t *topupRepository) CompleteIdempotencyKey(key entity.IdempotencyKey) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction")
+	}
+
+	if err := completeIdempotencyKey(tx, key); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed before a response was stored, so the client can retry it,
+// and cancels the topup that request left behind so it no longer counts against the limits.
+func (t *topupRepository) ReleaseIdempotencyKey(key entity.IdempotencyKey, idTopup string) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction")
+	}
+
+	query := "DELETE FROM idempotency_key WHERE scope = $1 AND idempotency_key = $2 AND response IS NULL"
+	if _, err := tx.Exec(query, key.Scope, key.Key); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to release idempotency key")
+	}
+
+	if err := cancelTopup(tx, idTopup); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CancelTopup cancels a pending topup that was never charged.
+func (t *topupRepository) CancelTopup(idTopup string) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction")
+	}
+
+	if err := cancelTopup(tx, idTopup); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func cancelTopup(tx *sql.Tx, idTopup string) error {
+	if _, err := tx.Exec("UPDATE tx_topup SET status = $1 WHERE id = $2 AND status = $3", entity.TopupCancelled, idTopup, entity.TopupPending); err != nil {
+		return fmt.Errorf("failed to cancel topup")
+	}
+	return nil
+}
+
+func (t *topupRepository) GetTopupById(tx *sql.Tx, id string) (entity.TopupRequest, error) {
+	query := "SELECT " + topupColumns + " FROM tx_topup WHERE id = $1 FOR UPDATE"
+
+	payload, err := scanTopup(tx.QueryRow(query, id))
 
 	if err == sql.ErrNoRows {
-		return entity.TopupRequest{}, fmt.Errorf("topup not found")
+		return entity.TopupRequest{}, fmt.Errorf("topup not found: %w", err)
 	} else if err != nil {
 		return entity.TopupRequest{}, err
 	}
@@ -50,12 +246,33 @@ func (t *topupRepository) GetTopupById(tx *sql.Tx, id string) (entity.TopupReque
 	return payload, nil
 }
 
+// FindTopup reads a topup outside a transaction and returns sql.ErrNoRows when it does not exist.
+func (t *topupRepository) FindTopup(id string) (entity.TopupRequest, error) {
+	query := "SELECT " + topupColumns + " FROM tx_topup WHERE id = $1"
+
+	payload, err := scanTopup(t.db.QueryRow(query, id))
+	if err != nil {
+		return entity.TopupRequest{}, err
+	}
+
+	return payload, nil
+}
+
 func (t *topupRepository) GetTopupByMerchantId(idMerchant string) ([]entity.TopupRequestDetail, error) {
+	return t.listTopupDetails("t.id_merchant = $1", idMerchant)
+}
+
+// GetTopupByUser returns the topups of every merchant owned by the user, newest first.
+func (t *topupRepository) GetTopupByUser(idUser string) ([]entity.TopupRequestDetail, error) {
+	return t.listTopupDetails("t.id_merchant IN (SELECT id_merchant FROM mst_merchant WHERE id_user = $1)", idUser)
+}
+
+func (t *topupRepository) listTopupDetails(condition string, arg string) ([]entity.TopupRequestDetail, error) {
 	var payload []entity.TopupRequestDetail
 
-	query := "SELECT t.id, t.id_merchant, t.id_supliyer, s.name_supliyer, t.item_name, t.amount, t.payment_method, t.status, t.created_at FROM tx_topup t JOIN mst_supliyer s ON t.id_supliyer = s.id_supliyer WHERE t.id_merchant = $1"
+	query := "SELECT t.id, t.id_merchant, t.id_supliyer, s.name_supliyer, t.item_name, t.amount, t.payment_method, t.status, t.method, COALESCE(t.rejection_reason, ''), t.fee, t.fee_charged_to, t.created_at FROM tx_topup t JOIN mst_supliyer s ON t.id_supliyer = s.id_supliyer WHERE " + condition + " ORDER BY t.created_at DESC"
 
-	rows, err := t.db.Query(query, idMerchant)
+	rows, err := t.db.Query(query, arg)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +281,7 @@ func (t *topupRepository) GetTopupByMerchantId(idMerchant string) ([]entity.Topu
 	for rows.Next() {
 		var item entity.TopupRequestDetail
 		var supliyer entity.Supliyer
-		err := rows.Scan(&item.Id, &item.IdMerchant, &supliyer.IdSupliyer, &supliyer.NameSupliyer, &item.Item_name, &item.Amount, &item.PaymentMethod, &item.Status, &item.CreatedAt)
+		err := rows.Scan(&item.Id, &item.IdMerchant, &supliyer.IdSupliyer, &supliyer.NameSupliyer, &item.Item_name, &item.Amount, &item.PaymentMethod, &item.Status, &item.Method, &item.RejectionReason, &item.Fee, &item.FeeChargedTo, &item.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -76,6 +293,26 @@ func (t *topupRepository) GetTopupByMerchantId(idMerchant string) ([]entity.Topu
 	return payload, nil
 }
 
+// MerchantIdsByUser returns the merchants owned by the user.
+func (t *topupRepository) MerchantIdsByUser(idUser string) ([]string, error) {
+	rows, err := t.db.Query("SELECT id_merchant FROM mst_merchant WHERE id_user = $1 ORDER BY name_merchant", idUser)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
 func (t *topupRepository) UpdateStatus(tx *sql.Tx, status, idTopup string) error {
 	query := "UPDATE tx_topup SET status = $1 WHERE id = $2"
 
@@ -96,10 +333,17 @@ func (t *topupRepository) UpdatePaymentMethod(tx *sql.Tx, paymentMethod, idTopup
 	return nil
 }
 
-func (t *topupRepository) UpdateBalanceMerchant(tx *sql.Tx, balance int, idMerchant string) error {
-	query := "UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2"
+func (t *topupRepository) UpdateBalanceMerchant(tx *sql.Tx, balance int, idMerchant, idTopup string) error {
+	entry := entity.LedgerEntry{
+		IdMerchant:    idMerchant,
+		EntryType:     entity.LedgerTopup,
+		Amount:        float64(balance),
+		ReferenceType: "tx_topup",
+		ReferenceId:   idTopup,
+		Description:   "topup",
+	}
 
-	if _, err := tx.Exec(query, balance, idMerchant); err != nil {
+	if _, err := postLedgerEntry(tx, entry); err != nil {
 		return fmt.Errorf("failed to update balance")
 	}
 
@@ -107,7 +351,7 @@ func (t *topupRepository) UpdateBalanceMerchant(tx *sql.Tx, balance int, idMerch
 }
 
 func (t *topupRepository) UpdateBalanceSupliyer(tx *sql.Tx, balance int, idSupliyer string) error {
-	query := "UPDATE mst_supplier SET balance = balance - $1 WHERE supplier_id = $2"
+	query := "UPDATE mst_supliyer SET balance = balance - $1 WHERE id_supliyer = $2"
 
 	if _, err := tx.Exec(query, balance, idSupliyer); err != nil {
 		return fmt.Errorf("failed to update balance")
@@ -116,7 +360,10 @@ func (t *topupRepository) UpdateBalanceSupliyer(tx *sql.Tx, balance int, idSupli
 	return nil
 }
 
-func (t *topupRepository) TxTopupUpdateAfterPayment(payload entity.TopupRequest) error {
+// TxTopupUpdateAfterPayment moves the topup to payload.Status under a row lock and records audit with the topup
+// before and after it in the same db transaction. Repeated notifications for the same status are a no-op, so a
+// topup is never credited twice.
+func (t *topupRepository) TxTopupUpdateAfterPayment(payload entity.TopupRequest, audit entity.AuditLog) error {
 	tx, err := t.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction")
@@ -132,38 +379,224 @@ func (t *topupRepository) TxTopupUpdateAfterPayment(payload entity.TopupRequest)
 		return err
 	}
 
-	status := "pending"
-
-	if payload.Status == "settlement" {
-		status = "paid"
-	} else if payload.Status == "cancelled" {
-		status = "cancelled"
+	if data.Status == payload.Status {
+		err = tx.Rollback()
+		return err
 	}
 
-	err = t.UpdateStatus(tx, status, data.Id)
+	err = t.transition(tx, data, payload)
 	if err != nil {
 		return err
 	}
 
-	err = t.UpdatePaymentMethod(tx, payload.PaymentMethod, data.Id)
-	if err != nil {
+	if err = recordAudit(tx, audit, data, payload); err != nil {
 		return err
 	}
 
-	err = t.UpdateBalanceMerchant(tx, data.Amount, data.IdMerchant)
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReviewManualTopup applies an admin decision to a manual topup through the same transition as a paid gateway
+// topup and records who reviewed it and why it was rejected, auditing the topup as it was before the review.
+func (t *topupRepository) ReviewManualTopup(payload entity.TopupRequest, audit entity.AuditLog) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction")
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	data, err := t.GetTopupById(tx, payload.Id)
 	if err != nil {
 		return err
 	}
 
-	err = t.UpdateBalanceSupliyer(tx, data.Amount, data.IdSupliyer)
+	if data.Method != entity.TopupMethodManual {
+		err = ErrNotManualTopup
+		return err
+	}
+
+	err = t.transition(tx, data, payload)
 	if err != nil {
 		return err
 	}
 
-	if err := tx.Commit(); err != nil {
+	query := "UPDATE tx_topup SET reviewed_by = $1, reviewed_at = NOW(), rejection_reason = NULLIF($2, '') WHERE id = $3"
+	if _, err = tx.Exec(query, payload.ReviewedBy, payload.RejectionReason, data.Id); err != nil {
+		return fmt.Errorf("failed to record topup review")
+	}
+
+	if err = recordAudit(tx, audit, data, payload); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// transition moves the locked topup data to payload.Status. The merchant is credited only on the transition to
+// paid, less payload.Fee when the fee is charged to the merchant, and debited back the same credit on the
+// transition from paid to refunded.
+func (t *topupRepository) transition(tx *sql.Tx, data, payload entity.TopupRequest) error {
+	if !entity.CanTransitionTopup(data.Status, payload.Status) {
+		return fmt.Errorf("%w: %s to %s", ErrIllegalTopupTransition, data.Status, payload.Status)
+	}
+
+	if err := t.UpdateStatus(tx, payload.Status, data.Id); err != nil {
 		return err
 	}
-	fmt.Println("Topup transaction committed")
+
+	if payload.PaymentMethod != "" {
+		if err := t.UpdatePaymentMethod(tx, payload.PaymentMethod, data.Id); err != nil {
+			return err
+		}
+	}
+
+	switch payload.Status {
+	case entity.TopupPaid:
+		payload.FeeChargedTo = feeChargedTo(payload.FeeChargedTo)
+		if _, err := tx.Exec("UPDATE tx_topup SET fee = $1, fee_charged_to = $2 WHERE id = $3", payload.Fee, payload.FeeChargedTo, data.Id); err != nil {
+			return fmt.Errorf("failed to update fee")
+		}
+		if err := t.UpdateBalanceMerchant(tx, creditedAmount(data.Amount, payload.Fee, payload.FeeChargedTo), data.IdMerchant, data.Id); err != nil {
+			return err
+		}
+		return t.UpdateBalanceSupliyer(tx, data.Amount, data.IdSupliyer)
+	case entity.TopupRefunded:
+		if err := t.reverseBalanceMerchant(tx, creditedAmount(data.Amount, data.Fee, data.FeeChargedTo), data.IdMerchant, data.Id); err != nil {
+			return err
+		}
+		return t.UpdateBalanceSupliyer(tx, -data.Amount, data.IdSupliyer)
+	}
+
+	return nil
+}
+
+// feeChargedTo defaults an unpriced topup to a fee paid by the platform.
+func feeChargedTo(chargedTo string) string {
+	if chargedTo == "" {
+		return entity.TopupFeePlatform
+	}
+	return chargedTo
+}
+
+// creditedAmount is the part of a topup that reaches the merchant balance.
+func creditedAmount(amount, fee int, chargedTo string) int {
+	if chargedTo == entity.TopupFeeMerchant {
+		return amount - fee
+	}
+	return amount
+}
+
+// CreateManualTopup checks the limits of the merchant and stores a pending manual topup together with its transfer
+// receipt.
+func (t *topupRepository) CreateManualTopup(payload entity.TopupRequest, proof entity.TopupProof, limits []entity.TopupLimit) (string, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction")
+	}
+
+	if err := checkTopupLimits(tx, payload, limits); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	query := "INSERT INTO tx_topup (id_merchant, id_supliyer, item_name, amount, payment_method, status, method, fee, fee_charged_to, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id"
+
+	if err := tx.QueryRow(query, payload.IdMerchant, payload.IdSupliyer, payload.Item_name, payload.Amount, payload.PaymentMethod, entity.TopupPending, entity.TopupMethodManual, payload.Fee, feeChargedTo(payload.FeeChargedTo), time.Now()).Scan(&payload.Id); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO topup_proof (id_topup, file_name, content_type, content) VALUES ($1, $2, $3, $4)",
+		payload.Id, proof.FileName, proof.ContentType, proof.Content,
+	); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return payload.Id, nil
+}
+
+// ListPendingManual returns the manual topups waiting for review, oldest first.
+func (t *topupRepository) ListPendingManual() ([]entity.TopupRequest, error) {
+	rows, err := t.db.Query("SELECT " + topupColumns + " FROM tx_topup WHERE method = 'manual' AND status = 'pending' ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTopups(rows)
+}
+
+// GetTopupProof returns the receipt of a manual topup, or sql.ErrNoRows when it has none.
+func (t *topupRepository) GetTopupProof(idTopup string) (entity.TopupProof, error) {
+	var proof entity.TopupProof
+
+	query := "SELECT id_topup, file_name, content_type, content, uploaded_at FROM topup_proof WHERE id_topup = $1"
+
+	err := t.db.QueryRow(query, idTopup).Scan(&proof.IdTopup, &proof.FileName, &proof.ContentType, &proof.Content, &proof.UploadedAt)
+	if err != nil {
+		return entity.TopupProof{}, err
+	}
+
+	return proof, nil
+}
+
+// ClaimPending marks up to limit pending topups created before createdBefore as checked and returns them. A topup
+// checked after checkedBefore is skipped, so concurrent workers and consecutive runs do not query it twice.
+func (t *topupRepository) ClaimPending(createdBefore, checkedBefore time.Time, limit int) ([]entity.TopupRequest, error) {
+	rows, err := t.db.Query(`
+		UPDATE tx_topup
+		SET checked_at = NOW()
+		WHERE id IN (
+			SELECT id FROM tx_topup
+			WHERE status = 'pending' AND method = 'gateway' AND created_at <= $1 AND (checked_at IS NULL OR checked_at <= $2)
+			ORDER BY checked_at NULLS FIRST, created_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+topupColumns,
+		createdBefore, checkedBefore, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTopups(rows)
+}
+
+// reverseBalanceMerchant debits a refunded or charged back topup. The payment has already been returned to the
+// payer, so the debit is recorded even when it takes the merchant balance below zero.
+func (t *topupRepository) reverseBalanceMerchant(tx *sql.Tx, balance int, idMerchant, idTopup string) error {
+	entry := entity.LedgerEntry{
+		IdMerchant:    idMerchant,
+		EntryType:     entity.LedgerTopupRefund,
+		Amount:        -float64(balance),
+		ReferenceType: "tx_topup",
+		ReferenceId:   idTopup,
+		Description:   "topup refund",
+	}
+
+	if _, err := postLedgerReversal(tx, entry); err != nil {
+		return fmt.Errorf("failed to update balance")
+	}
 
 	return nil
 }
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/suite"
+)
+
+type topupRepositoryTestSuite struct {
+	suite.Suite
+	mockDb    *sql.DB
+	mockSql   sqlmock.Sqlmock
+	topupRepo TopupRepository
+}
+
+func TestTopupRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(topupRepositoryTestSuite))
+}
+
+func (s *topupRepositoryTestSuite) SetupTest() {
+	mockDb, mockSql, err := sqlmock.New()
+	s.NoError(err)
+
+	s.mockDb = mockDb
+	s.mockSql = mockSql
+	s.topupRepo = NewTopupRepository(mockDb)
+}
+
+func (s *topupRepositoryTestSuite) TearDownTest() {
+	s.mockDb.Close()
+}
+
+var topupRows = []string{"id", "id_merchant", "id_supliyer", "item_name", "amount", "payment_method", "status", "method", "rejection_reason", "reviewed_by", "fee", "fee_charged_to", "created_at"}
+
+func (s *topupRepositoryTestSuite) expectTopup(status string) {
+	s.expectTopupRow(status, entity.TopupMethodGateway, 0, entity.TopupFeePlatform)
+}
+
+func (s *topupRepositoryTestSuite) expectTopupWithMethod(status, method string) {
+	s.expectTopupRow(status, method, 0, entity.TopupFeePlatform)
+}
+
+func (s *topupRepositoryTestSuite) expectTopupRow(status, method string, fee int, feeChargedTo string) {
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`FROM tx_topup WHERE id = $1 FOR UPDATE`)).
+		WithArgs("topup-uuid").
+		WillReturnRows(sqlmock.NewRows(topupRows).
+			AddRow("topup-uuid", "merchant-uuid", "supliyer-uuid", "saldo", 50000, "", status, method, "", "", fee, feeChargedTo, time.Now()))
+}
+
+var testTopupAudit = entity.AuditLog{System: "payment callback", Action: entity.AuditPayment, EntityType: entity.AuditTopup, EntityId: "topup-uuid"}
+
+func (s *topupRepositoryTestSuite) TestUpdateAfterPayment_CreditsOnPaid() {
+	s.mockSql.ExpectBegin()
+	s.expectTopup(entity.TopupPending)
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET status = $1 WHERE id = $2`)).
+		WithArgs(entity.TopupPaid, "topup-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET payment_method = $1 WHERE id = $2`)).
+		WithArgs("bca", "topup-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET fee = $1, fee_charged_to = $2 WHERE id = $3`)).
+		WithArgs(750, entity.TopupFeeMerchant, "topup-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance`)).
+		WithArgs(float64(49250), "merchant-uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(49250))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO merchant_ledger`)).
+		WithArgs("merchant-uuid", entity.LedgerTopup, float64(49250), float64(49250), "tx_topup", "topup-uuid", "topup", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE mst_supliyer SET balance = balance - $1 WHERE id_supliyer = $2`)).
+		WithArgs(50000, "supliyer-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditLog(s.mockSql, entity.AuditPayment, entity.AuditTopup, "topup-uuid", jsonContaining(`"status":"pending"`), jsonContaining(`"status":"paid"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockSql.ExpectCommit()
+
+	err := s.topupRepo.TxTopupUpdateAfterPayment(entity.TopupRequest{Id: "topup-uuid", Status: entity.TopupPaid, PaymentMethod: "bca", Fee: 750, FeeChargedTo: entity.TopupFeeMerchant}, testTopupAudit)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestUpdateAfterPayment_RepeatedPaidIsNoop() {
+	s.mockSql.ExpectBegin()
+	s.expectTopup(entity.TopupPaid)
+	s.mockSql.ExpectRollback()
+
+	err := s.topupRepo.TxTopupUpdateAfterPayment(entity.TopupRequest{Id: "topup-uuid", Status: entity.TopupPaid, PaymentMethod: "bca"}, testTopupAudit)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestUpdateAfterPayment_DebitsOnRefund() {
+	s.mockSql.ExpectBegin()
+	s.expectTopupRow(entity.TopupPaid, entity.TopupMethodGateway, 750, entity.TopupFeeMerchant)
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET status = $1 WHERE id = $2`)).
+		WithArgs(entity.TopupRefunded, "topup-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance`)).
+		WithArgs(float64(-49250), "merchant-uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(-20000))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO merchant_ledger`)).
+		WithArgs("merchant-uuid", entity.LedgerTopupRefund, float64(-49250), float64(-20000), "tx_topup", "topup-uuid", "topup refund", "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, time.Now()))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE mst_supliyer SET balance = balance - $1 WHERE id_supliyer = $2`)).
+		WithArgs(-50000, "supliyer-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditLog(s.mockSql, entity.AuditPayment, entity.AuditTopup, "topup-uuid", jsonContaining(`"status":"paid"`), jsonContaining(`"status":"refunded"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockSql.ExpectCommit()
+
+	err := s.topupRepo.TxTopupUpdateAfterPayment(entity.TopupRequest{Id: "topup-uuid", Status: entity.TopupRefunded}, testTopupAudit)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestUpdateAfterPayment_AuditFailureRollsBack() {
+	s.mockSql.ExpectBegin()
+	s.expectTopup(entity.TopupPending)
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET status = $1 WHERE id = $2`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET fee = $1, fee_charged_to = $2 WHERE id = $3`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance`)).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(50000))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO merchant_ledger`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE mst_supliyer SET balance = balance - $1 WHERE id_supliyer = $2`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditLog(s.mockSql, entity.AuditPayment, entity.AuditTopup, "topup-uuid", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnError(sql.ErrConnDone)
+	s.mockSql.ExpectRollback()
+
+	err := s.topupRepo.TxTopupUpdateAfterPayment(entity.TopupRequest{Id: "topup-uuid", Status: entity.TopupPaid}, testTopupAudit)
+
+	s.ErrorIs(err, sql.ErrConnDone)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestUpdateAfterPayment_RejectsIllegalTransition() {
+	s.mockSql.ExpectBegin()
+	s.expectTopup(entity.TopupExpired)
+	s.mockSql.ExpectRollback()
+
+	err := s.topupRepo.TxTopupUpdateAfterPayment(entity.TopupRequest{Id: "topup-uuid", Status: entity.TopupPaid}, testTopupAudit)
+
+	s.ErrorIs(err, ErrIllegalTopupTransition)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestClaimPending() {
+	createdBefore := time.Date(2024, 11, 1, 11, 55, 0, 0, time.UTC)
+	checkedBefore := time.Date(2024, 11, 1, 11, 59, 0, 0, time.UTC)
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE tx_topup
+		SET checked_at = NOW()`)).
+		WithArgs(createdBefore, checkedBefore, 20).
+		WillReturnRows(sqlmock.NewRows(topupRows).
+			AddRow("topup-uuid", "merchant-uuid", "supliyer-uuid", "saldo", 50000, "", entity.TopupPending, entity.TopupMethodGateway, "", "", 0, entity.TopupFeePlatform, createdBefore))
+
+	topups, err := s.topupRepo.ClaimPending(createdBefore, checkedBefore, 20)
+
+	s.NoError(err)
+	s.Len(topups, 1)
+	s.Equal("topup-uuid", topups[0].Id)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestReviewManualTopup_Rejects() {
+	s.mockSql.ExpectBegin()
+	s.expectTopupWithMethod(entity.TopupPending, entity.TopupMethodManual)
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET status = $1 WHERE id = $2`)).
+		WithArgs(entity.TopupRejected, "topup-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET reviewed_by = $1, reviewed_at = NOW(), rejection_reason = NULLIF($2, '') WHERE id = $3`)).
+		WithArgs("admin-uuid", "receipt is blurry", "topup-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditLog(s.mockSql, entity.AuditReview, entity.AuditTopup, "topup-uuid", jsonContaining(`"status":"pending"`), jsonContaining(`"status":"rejected"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockSql.ExpectCommit()
+
+	audit := entity.AuditLog{IdUser: "admin-uuid", Action: entity.AuditReview, EntityType: entity.AuditTopup, EntityId: "topup-uuid"}
+	err := s.topupRepo.ReviewManualTopup(entity.TopupRequest{Id: "topup-uuid", Status: entity.TopupRejected, ReviewedBy: "admin-uuid", RejectionReason: "receipt is blurry"}, audit)
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestReviewManualTopup_RejectsGatewayTopup() {
+	s.mockSql.ExpectBegin()
+	s.expectTopup(entity.TopupPending)
+	s.mockSql.ExpectRollback()
+
+	err := s.topupRepo.ReviewManualTopup(entity.TopupRequest{Id: "topup-uuid", Status: entity.TopupPaid, ReviewedBy: "admin-uuid"}, entity.AuditLog{Action: entity.AuditReview})
+
+	s.ErrorIs(err, ErrNotManualTopup)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+var testTopupKey = entity.IdempotencyKey{Scope: "/topup:user-uuid", Key: "key-1", RequestHash: "hash-1"}
+
+// expectUsedKey makes key-1 already reserved by a request that hasn't stored a response yet.
+func (s *topupRepositoryTestSuite) expectUsedKey() {
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO idempotency_key`)).
+		WithArgs(testTopupKey.Scope, testTopupKey.Key, testTopupKey.RequestHash).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT request_hash, response, created_at FROM idempotency_key`)).
+		WithArgs(testTopupKey.Scope, testTopupKey.Key).
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "response", "created_at"}).AddRow("hash-1", nil, time.Now()))
+}
+
+func (s *topupRepositoryTestSuite) TestCreateTopupIdempotent_TakesOverStaleKey() {
+	s.expectUsedKey()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE idempotency_key SET created_at = NOW()`)).
+		WithArgs(testTopupKey.Scope, testTopupKey.Key, testTopupKey.RequestHash, float64(300)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO tx_topup`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("topup-uuid"))
+	s.mockSql.ExpectCommit()
+
+	id, _, exists, err := s.topupRepo.CreateTopupIdempotent(entity.TopupRequest{IdMerchant: "merchant-uuid", Amount: 50000}, testTopupKey, 5*time.Minute, nil)
+
+	s.NoError(err)
+	s.False(exists)
+	s.Equal("topup-uuid", id)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+var testTopupLimits = []entity.TopupLimit{{Name: "daily", Limit: 100000, Since: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)}}
+
+func (s *topupRepositoryTestSuite) TestCreateTopupIdempotent_ChecksLimitsForNewKey() {
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO idempotency_key`)).
+		WithArgs(testTopupKey.Scope, testTopupKey.Key, testTopupKey.RequestHash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`SELECT id_merchant FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE`)).
+		WithArgs("merchant-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount), 0) FROM tx_topup WHERE id_merchant = $1 AND created_at >= $2 AND status IN ('pending', 'paid')`)).
+		WithArgs("merchant-uuid", testTopupLimits[0].Since).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(60000))
+	s.mockSql.ExpectRollback()
+
+	_, _, _, err := s.topupRepo.CreateTopupIdempotent(entity.TopupRequest{IdMerchant: "merchant-uuid", Amount: 50000}, testTopupKey, 5*time.Minute, testTopupLimits)
+
+	s.ErrorIs(err, ErrTopupLimitExceeded)
+	s.EqualError(err, "topup limit exceeded: the daily limit of 100000 leaves 40000")
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestCreateTopupIdempotent_ReplaysWithoutCheckingLimits() {
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`INSERT INTO idempotency_key`)).
+		WithArgs(testTopupKey.Scope, testTopupKey.Key, testTopupKey.RequestHash).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT request_hash, response, created_at FROM idempotency_key`)).
+		WithArgs(testTopupKey.Scope, testTopupKey.Key).
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "response", "created_at"}).AddRow("hash-1", []byte(`{"token":"t"}`), time.Now()))
+	s.mockSql.ExpectRollback()
+
+	_, stored, exists, err := s.topupRepo.CreateTopupIdempotent(entity.TopupRequest{IdMerchant: "merchant-uuid", Amount: 50000}, testTopupKey, 5*time.Minute, testTopupLimits)
+
+	s.NoError(err)
+	s.True(exists)
+	s.JSONEq(`{"token":"t"}`, string(stored.Response))
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestCreateTopup_WithinLimits() {
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`SELECT id_merchant FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE`)).
+		WithArgs("merchant-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(SUM(amount), 0) FROM tx_topup`)).
+		WithArgs("merchant-uuid", testTopupLimits[0].Since).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(50000))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO tx_topup`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("topup-uuid"))
+	s.mockSql.ExpectCommit()
+
+	id, err := s.topupRepo.CreateTopup(entity.TopupRequest{IdMerchant: "merchant-uuid", Amount: 50000}, testTopupLimits)
+
+	s.NoError(err)
+	s.Equal("topup-uuid", id)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestCreateTopupIdempotent_KeepsLeasedKey() {
+	s.expectUsedKey()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE idempotency_key SET created_at = NOW()`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mockSql.ExpectRollback()
+
+	_, stored, exists, err := s.topupRepo.CreateTopupIdempotent(entity.TopupRequest{IdMerchant: "merchant-uuid", Amount: 50000}, testTopupKey, 5*time.Minute, nil)
+
+	s.NoError(err)
+	s.True(exists)
+	s.Nil(stored.Response)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
+
+func (s *topupRepositoryTestSuite) TestReleaseIdempotencyKey_CancelsTopup() {
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`DELETE FROM idempotency_key WHERE scope = $1 AND idempotency_key = $2 AND response IS NULL`)).
+		WithArgs(testTopupKey.Scope, testTopupKey.Key).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE tx_topup SET status = $1 WHERE id = $2 AND status = $3`)).
+		WithArgs(entity.TopupCancelled, "topup-uuid", entity.TopupPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	s.mockSql.ExpectCommit()
+
+	err := s.topupRepo.ReleaseIdempotencyKey(testTopupKey, "topup-uuid")
+
+	s.NoError(err)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+)
+
+type TopupSettingRepository interface {
+	Get() (entity.TopupSetting, error)
+	Save(payload entity.TopupSetting) (entity.TopupSetting, error)
+	ListFees() ([]entity.TopupFee, error)
+	SaveFee(payload entity.TopupFee) (entity.TopupFee, error)
+	DeleteFee(paymentMethod string) error
+	FindFee(paymentMethod string) (entity.TopupFee, error)
+}
+
+type topupSettingRepository struct {
+	db  *sql.DB
+	log *logger.Logger
+}
+
+func (t *topupSettingRepository) Get() (entity.TopupSetting, error) {
+	var setting entity.TopupSetting
+
+	err := t.db.QueryRow(`
+		SELECT min_amount, max_amount, daily_limit, monthly_limit, COALESCE(updated_by::text, ''), updated_at
+		FROM topup_setting WHERE id = 1`,
+	).Scan(&setting.MinAmount, &setting.MaxAmount, &setting.DailyLimit, &setting.MonthlyLimit, &setting.UpdatedBy, &setting.UpdatedAt)
+	if err != nil {
+		t.log.Error("Failed to get the topup settings: ", err)
+		return entity.TopupSetting{}, err
+	}
+
+	return setting, nil
+}
+
+func (t *topupSettingRepository) Save(payload entity.TopupSetting) (entity.TopupSetting, error) {
+	t.log.Info("Starting to save the topup settings in the repository layer", payload)
+
+	err := t.db.QueryRow(`
+		INSERT INTO topup_setting (id, min_amount, max_amount, daily_limit, monthly_limit, updated_by)
+		VALUES (1, $1, $2, $3, $4, NULLIF($5, '')::uuid)
+		ON CONFLICT (id) DO UPDATE SET
+			min_amount = EXCLUDED.min_amount, max_amount = EXCLUDED.max_amount,
+			daily_limit = EXCLUDED.daily_limit, monthly_limit = EXCLUDED.monthly_limit,
+			updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING updated_at`,
+		payload.MinAmount, payload.MaxAmount, payload.DailyLimit, payload.MonthlyLimit, payload.UpdatedBy,
+	).Scan(&payload.UpdatedAt)
+	if err != nil {
+		t.log.Error("Failed to save the topup settings: ", err)
+		return entity.TopupSetting{}, err
+	}
+
+	return payload, nil
+}
+
+func (t *topupSettingRepository) ListFees() ([]entity.TopupFee, error) {
+	rows, err := t.db.Query("SELECT payment_method, fee_type, fee_value, charged_to, updated_at FROM topup_fee ORDER BY payment_method")
+	if err != nil {
+		t.log.Error("Failed to retrive the topup fees: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fees []entity.TopupFee
+	for rows.Next() {
+		var fee entity.TopupFee
+		if err := rows.Scan(&fee.PaymentMethod, &fee.FeeType, &fee.FeeValue, &fee.ChargedTo, &fee.UpdatedAt); err != nil {
+			t.log.Error("Failed to scan the topup fee: ", err)
+			return nil, err
+		}
+		fees = append(fees, fee)
+	}
+
+	return fees, rows.Err()
+}
+
+// SaveFee creates or replaces the fee rule of a payment method.
+func (t *topupSettingRepository) SaveFee(payload entity.TopupFee) (entity.TopupFee, error) {
+	t.log.Info("Starting to save a topup fee in the repository layer", payload)
+
+	err := t.db.QueryRow(`
+		INSERT INTO topup_fee (payment_method, fee_type, fee_value, charged_to) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (payment_method) DO UPDATE SET
+			fee_type = EXCLUDED.fee_type, fee_value = EXCLUDED.fee_value, charged_to = EXCLUDED.charged_to, updated_at = NOW()
+		RETURNING updated_at`,
+		payload.PaymentMethod, payload.FeeType, payload.FeeValue, payload.ChargedTo,
+	).Scan(&payload.UpdatedAt)
+	if err != nil {
+		t.log.Error("Failed to save the topup fee: ", err)
+		return entity.TopupFee{}, err
+	}
+
+	return payload, nil
+}
+
+// DeleteFee removes the fee rule of a payment method and returns sql.ErrNoRows when it had none.
+func (t *topupSettingRepository) DeleteFee(paymentMethod string) error {
+	res, err := t.db.Exec("DELETE FROM topup_fee WHERE payment_method = $1", paymentMethod)
+	if err != nil {
+		t.log.Error("Failed to delete the topup fee: ", err)
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// FindFee returns the fee rule of a payment method, falling back to the default rule. It returns
+// sql.ErrNoRows when neither exists.
+func (t *topupSettingRepository) FindFee(paymentMethod string) (entity.TopupFee, error) {
+	var fee entity.TopupFee
+
+	err := t.db.QueryRow(`
+		SELECT payment_method, fee_type, fee_value, charged_to, updated_at FROM topup_fee
+		WHERE payment_method IN ($1, $2)
+		ORDER BY payment_method = $2
+		LIMIT 1`,
+		paymentMethod, entity.TopupFeeDefault,
+	).Scan(&fee.PaymentMethod, &fee.FeeType, &fee.FeeValue, &fee.ChargedTo, &fee.UpdatedAt)
+	if err != nil {
+		return entity.TopupFee{}, err
+	}
+
+	return fee, nil
+}
+
+func NewTopupSettingRepository(db *sql.DB, log *logger.Logger) TopupSettingRepository {
+	return &topupSettingRepository{db: db, log: log}
+}
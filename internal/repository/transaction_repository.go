@@ -2,11 +2,15 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/shared/custom"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type transactionRepository struct {
@@ -16,8 +20,10 @@ type transactionRepository struct {
 
 type TransactionRepository interface {
 	Create(payload entity.Transactions) (entity.Transactions, error)
-	GetAll(userId string) ([]custom.TransactionsReq, error)
+	CreateIdempotent(payload entity.Transactions, key entity.IdempotencyKey) (entity.Transactions, entity.IdempotencyKey, bool, error)
+	GetAll(filter entity.TransactionFilter) ([]custom.TransactionsReq, int, error)
 	GetById(id string) (custom.TransactionsReq, error)
+	Refund(payload entity.TransactionRefund, audit entity.AuditLog) (entity.TransactionRefund, error)
 	// Update(payload entity.Transactions) (entity.Transactions, error)
 	// Delete(id string) error
 }
@@ -41,37 +47,107 @@ func (r *transactionRepository) Create(payload entity.Transactions) (entity.Tran
 		return entity.Transactions{}, err
 	}
 
+	payload, err = r.insert(tx, payload, parsedDate)
+	if err != nil {
+		tx.Rollback()
+		return entity.Transactions{}, err
+	}
+
+	// commit transaction
+	if err := tx.Commit(); err != nil {
+		r.log.Error("Failed to commit transaction", err)
+		return entity.Transactions{}, err
+	}
+
+	return payload, nil
+}
+
+// CreateIdempotent creates the transaction and stores its result under key in the same db transaction.
+// When key was already used nothing is created and the stored key is returned with true instead.
+func (r *transactionRepository) CreateIdempotent(payload entity.Transactions, key entity.IdempotencyKey) (entity.Transactions, entity.IdempotencyKey, bool, error) {
+	r.log.Info("Starting to create a new idempotent transaction in the repository layer", key.Key)
+	parsedDate, err := time.Parse("02-01-2006", payload.TransactionDate)
+	if err != nil {
+		r.log.Error("invalid date format", err)
+		return entity.Transactions{}, entity.IdempotencyKey{}, false, fmt.Errorf("invalid date format. Please use dd-mm-yyyy format: %v", err)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error("Failed start db transaction", err)
+		return entity.Transactions{}, entity.IdempotencyKey{}, false, err
+	}
+
+	stored, exists, err := reserveIdempotencyKey(tx, key)
+	if err != nil {
+		tx.Rollback()
+		r.log.Error("Failed to reserve idempotency key", err)
+		return entity.Transactions{}, entity.IdempotencyKey{}, false, err
+	}
+	if exists {
+		tx.Rollback()
+		r.log.Info("Idempotency key was already used", key.Key)
+		return entity.Transactions{}, stored, true, nil
+	}
+
+	payload, err = r.insert(tx, payload, parsedDate)
+	if err != nil {
+		tx.Rollback()
+		return entity.Transactions{}, entity.IdempotencyKey{}, false, err
+	}
+
+	key.Response, err = json.Marshal(payload)
+	if err != nil {
+		tx.Rollback()
+		r.log.Error("Failed to encode transaction response", err)
+		return entity.Transactions{}, entity.IdempotencyKey{}, false, err
+	}
+
+	if err := completeIdempotencyKey(tx, key); err != nil {
+		tx.Rollback()
+		r.log.Error("Failed to store idempotency key response", err)
+		return entity.Transactions{}, entity.IdempotencyKey{}, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error("Failed to commit transaction", err)
+		return entity.Transactions{}, entity.IdempotencyKey{}, false, err
+	}
+
+	return payload, key, false, nil
+}
+
+// insert debits the merchant and writes the transaction with its details inside tx.
+// The caller owns tx and must roll it back when an error is returned.
+func (r *transactionRepository) insert(tx *sql.Tx, payload entity.Transactions, parsedDate time.Time) (entity.Transactions, error) {
 	// Check merchant's current balance before processing
 	var currentBalance float64
 	if err := tx.QueryRow(
 		"SELECT balance FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE",
 		payload.MerchantId,
 	).Scan(&currentBalance); err != nil {
-		tx.Rollback()
 		r.log.Error("Failed to fetch merchant balance", err)
 		return entity.Transactions{}, err
 	}
 
-	// Calculate total nominal needed for the transaction
+	// Calculate total nominal needed for the transaction, capturing cost and selling price at the time of sale
 	var totalNominal float64
-	for _, detail := range payload.TransactionDetail {
-		var nominal float64
+	for i, detail := range payload.TransactionDetail {
 		if err := tx.QueryRow(
-			"SELECT nominal FROM mst_product WHERE id_product = $1",
+			"SELECT nominal, price FROM mst_product WHERE id_product = $1",
 			detail.ProductId,
-		).Scan(&nominal); err != nil {
-			tx.Rollback()
+		).Scan(&payload.TransactionDetail[i].Cost, &payload.TransactionDetail[i].Price); err != nil {
 			r.log.Error("Failed to fetch product nominal", err)
 			return entity.Transactions{}, err
 		}
-		totalNominal += nominal
+		payload.TransactionDetail[i].Margin = payload.TransactionDetail[i].Price - payload.TransactionDetail[i].Cost
+		totalNominal += payload.TransactionDetail[i].Cost
 	}
 
 	// Check if merchant has sufficient balance
 	if currentBalance < totalNominal {
-		tx.Rollback()
 		r.log.Error("Insufficient merchant balance", fmt.Errorf("required balance: %v, current balance: %v", totalNominal, currentBalance))
-		return entity.Transactions{}, fmt.Errorf("insufficient merchant balance: required %v, current balance %v", totalNominal, currentBalance)
+		return entity.Transactions{}, fmt.Errorf("%w: required %v, current balance %v", ErrInsufficientBalance, totalNominal, currentBalance)
 	}
 
 	//insert into transactions table
@@ -79,7 +155,6 @@ func (r *transactionRepository) Create(payload entity.Transactions) (entity.Tran
 	insertTransaction := "INSERT INTO transactions (id_merchant, id_user, customer_name, destination_number, transaction_date) VALUES ($1, $2, $3, $4, $5) RETURNING transaction_id"
 
 	if err := tx.QueryRow(insertTransaction, payload.MerchantId, payload.UserId, payload.CustomerName, payload.DestinationNumber, parsedDate).Scan(&transactionId); err != nil {
-		tx.Rollback()
 		r.log.Error("Failed to insert into transactions table", err)
 		return entity.Transactions{}, err
 	}
@@ -87,58 +162,37 @@ func (r *transactionRepository) Create(payload entity.Transactions) (entity.Tran
 	payload.TransactionsId = transactionId
 
 	//insert into transaction detail table
-	insertTransactionDetail := "INSERT INTO transaction_detail (transaction_id, id_product, price) VALUES ($1, $2, $3) RETURNING transaction_detail_id"
+	insertTransactionDetail := "INSERT INTO transaction_detail (transaction_id, id_product, cost, price, margin) VALUES ($1, $2, $3, $4, $5) RETURNING transaction_detail_id"
 
 	for i := range payload.TransactionDetail {
-		var transactionDetailId string
+		detail := &payload.TransactionDetail[i]
 
-		if err := tx.QueryRow(insertTransactionDetail, transactionId, payload.TransactionDetail[i].ProductId, payload.TransactionDetail[i].Price).Scan(&transactionDetailId); err != nil {
-			tx.Rollback()
+		if err := tx.QueryRow(insertTransactionDetail, transactionId, detail.ProductId, detail.Cost, detail.Price, detail.Margin).Scan(&detail.TransactionDetailId); err != nil {
 			r.log.Error("Failed to insert into transaction detail table", err)
 			return entity.Transactions{}, err
 		}
-		payload.TransactionDetail[i].TransactionDetailId = transactionDetailId
-		payload.TransactionDetail[i].TransactionsId = transactionId
-
-		// Fetch product price from product table
-		var productPrice float64
-		if err := tx.QueryRow(
-			"SELECT price FROM mst_product WHERE id_product = $1",
-			payload.TransactionDetail[i].ProductId,
-		).Scan(&productPrice); err != nil {
-			tx.Rollback()
-			r.log.Error("Failed to fetch product price", err)
-			return entity.Transactions{}, err
-		}
-
-		payload.TransactionDetail[i].Price = productPrice
+		detail.TransactionsId = transactionId
+		detail.Status = entity.FulfillmentPending
 	}
 
 	// Update merchant balance - only subtract the nominal amount
-	updateMerchantBalance := `
-		UPDATE mst_merchant 
-		SET balance = balance - $1
-		WHERE id_merchant = $2
-		RETURNING balance`
-
-	var newBalance float64
-	if err := tx.QueryRow(
-		updateMerchantBalance,
-		totalNominal, // amount to subtract (nominal/cost)
-		payload.MerchantId,
-	).Scan(&newBalance); err != nil {
-		tx.Rollback()
+	entry, err := postLedgerEntry(tx, entity.LedgerEntry{
+		IdMerchant:    payload.MerchantId,
+		EntryType:     entity.LedgerSale,
+		Amount:        -totalNominal, // amount to subtract (nominal/cost)
+		ReferenceType: "transaction",
+		ReferenceId:   transactionId,
+		Description:   "sale to " + payload.DestinationNumber,
+		CreatedBy:     payload.UserId,
+	})
+	if err != nil {
 		r.log.Error("Failed to update merchant balance", err)
 		return entity.Transactions{}, err
 	}
-
-	// commit transaction
-	if err := tx.Commit(); err != nil {
-		r.log.Error("Failed to commit transaction", err)
-		return entity.Transactions{}, err
-	}
+	newBalance := entry.BalanceAfter
 
 	payload.TransactionDate = parsedDate.Format("02-01-2006")
+	payload.Status = entity.FulfillmentPending
 	r.log.Info("Transaction created successfully with updated merchant balance", map[string]interface{}{
 		"payload":    payload,
 		"newBalance": newBalance,
@@ -146,37 +200,152 @@ func (r *transactionRepository) Create(payload entity.Transactions) (entity.Tran
 	return payload, nil
 }
 
-func (r *transactionRepository) GetAll(userId string) ([]custom.TransactionsReq, error) {
+// transactionSortColumns maps the sort fields accepted by GetAll to their column and the type the
+// cursor value is cast to. The transaction id is always appended as a tie breaker.
+var transactionSortColumns = map[string]struct{ column, cast string }{
+	"date":               {"t.transaction_date", "date"},
+	"customer_name":      {"t.customer_name", "text"},
+	"destination_number": {"t.destination_number", "text"},
+	"status":             {"t.status", "text"},
+}
+
+// GetAll returns one page of the transactions of the merchant owned by filter.UserId together with
+// the number of transactions matching the filter. The page is selected on the transactions table
+// first so details never split a transaction across pages, then the details are loaded for it.
+func (r *transactionRepository) GetAll(filter entity.TransactionFilter) ([]custom.TransactionsReq, int, error) {
+	r.log.Info("Starting to retrive all transactions in the repository layer", nil)
+
+	field, descending := strings.TrimPrefix(filter.Sort, "-"), strings.HasPrefix(filter.Sort, "-")
+	sortColumn, ok := transactionSortColumns[field]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort field %q", field)
+	}
+
+	conditions := []string{"m.id_user = $1"}
+	args := []interface{}{filter.UserId}
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if !filter.StartDate.IsZero() {
+		addCondition("t.transaction_date >= $%d", filter.StartDate)
+	}
+	if !filter.EndDate.IsZero() {
+		addCondition("t.transaction_date <= $%d", filter.EndDate)
+	}
+	if filter.Status != "" {
+		addCondition("t.status = $%d", filter.Status)
+	}
+	if filter.DestinationNumber != "" {
+		addCondition("t.destination_number LIKE $%d || '%%'", filter.DestinationNumber)
+	}
+	if filter.CustomerName != "" {
+		addCondition("t.customer_name ILIKE '%%' || $%d || '%%'", filter.CustomerName)
+	}
+	if filter.Provider != "" {
+		addCondition(`EXISTS (
+			SELECT 1 FROM transaction_detail td
+			JOIN mst_product p ON td.id_product = p.id_product
+			WHERE td.transaction_id = t.transaction_id AND p.name_provider ILIKE $%d)`, filter.Provider)
+	}
+
+	fromQuery := `
+		FROM transactions t
+		JOIN mst_merchant m ON t.id_merchant = m.id_merchant
+		WHERE ` + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*)"+fromQuery, args...).Scan(&total); err != nil {
+		r.log.Error("Failed to count the transactions", err)
+		return nil, 0, err
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	pageQuery := "SELECT t.transaction_id" + fromQuery
+	if filter.Cursor != "" {
+		args = append(args, filter.AfterValue, filter.AfterId)
+		pageQuery += fmt.Sprintf(" AND (%s, t.transaction_id) %s ($%d::%s, $%d::uuid)",
+			sortColumn.column, comparison, len(args)-1, sortColumn.cast, len(args))
+	}
+	pageQuery += fmt.Sprintf(" ORDER BY %s %s, t.transaction_id %s", sortColumn.column, direction, direction)
+	args = append(args, filter.Limit)
+	pageQuery += fmt.Sprintf(" LIMIT $%d", len(args))
+	if filter.Cursor == "" {
+		args = append(args, (filter.Page-1)*filter.Limit)
+		pageQuery += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := r.db.Query(pageQuery, args...)
+	if err != nil {
+		r.log.Error("Failed to retrieve the transactions", err)
+		return nil, 0, err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			r.log.Error("Failed to scan transactions", err)
+			return nil, 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		r.log.Error("Rows not found", err)
+		return nil, 0, err
+	}
+
+	transactions, err := r.loadTransactions(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	r.log.Info("Successfully Get the transactions list", len(transactions))
+	return transactions, total, nil
+}
+
+// loadTransactions loads the given transactions with their details, keeping the order of ids.
+func (r *transactionRepository) loadTransactions(ids []string) ([]custom.TransactionsReq, error) {
+	transactions := make([]custom.TransactionsReq, 0, len(ids))
+	if len(ids) == 0 {
+		return transactions, nil
+	}
+
 	selectQuery := `
 		SELECT
-			t.transaction_id, t.customer_name, t.destination_number, t.transaction_date,
+			t.transaction_id, t.customer_name, t.destination_number, t.transaction_date, t.status,
 			u.id_user, u.username, u.role,
 			m.id_merchant, m.name_merchant, m.address,
-			td.transaction_detail_id, td.transaction_id, p.id_product, p.name_provider, p.nominal, p.price
-			
+			td.transaction_detail_id, td.transaction_id, td.status, COALESCE(td.serial_number, ''),
+			td.cost, td.price, td.margin,
+			p.id_product, p.name_provider, p.nominal, p.price
 		FROM transactions t
 		JOIN mst_user u ON t.id_user = u.id_user
 		JOIN mst_merchant m ON t.id_merchant = m.id_merchant
 		JOIN transaction_detail td ON t.transaction_id = td.transaction_id
 		JOIN mst_product p ON td.id_product = p.id_product
-		WHERE m.id_merchant = (
-			SELECT
-				m.id_merchant
-			FROM mst_merchant m
-			WHERE m.id_user = $1
-		)
-		ORDER BY t.transaction_date DESC`
-
-	r.log.Info("Starting to retrive all transactions in the repository layer", nil)
+		WHERE t.transaction_id = ANY($1::uuid[])
+		ORDER BY td.transaction_detail_id`
 
-	rows, err := r.db.Query(selectQuery, userId)
+	rows, err := r.db.Query(selectQuery, pq.Array(ids))
 	if err != nil {
-		r.log.Error("Failed to retrieve the transactions", err)
+		r.log.Error("Failed to retrieve the transaction details", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	transactionMap := make(map[string]*custom.TransactionsReq)
+	position := make(map[string]int, len(ids))
+	for _, id := range ids {
+		position[id] = -1
+	}
 
 	for rows.Next() {
 		var (
@@ -188,10 +357,11 @@ func (r *transactionRepository) GetAll(userId string) ([]custom.TransactionsReq,
 		)
 
 		if err := rows.Scan(
-			&transaction.TransactionsId, &transaction.CustomerName, &transaction.DestinationNumber, &transaction.TransactionDate,
+			&transaction.TransactionsId, &transaction.CustomerName, &transaction.DestinationNumber, &transaction.TransactionDate, &transaction.Status,
 			&user.Id_user, &user.Username, &user.Role,
 			&merchant.IdMerchant, &merchant.NameMerchant, &merchant.Address,
-			&transactionDetail.TransactionDetailId, &transactionDetail.TransactionsId,
+			&transactionDetail.TransactionDetailId, &transactionDetail.TransactionsId, &transactionDetail.Status, &transactionDetail.SerialNumber,
+			&transactionDetail.Cost, &transactionDetail.Price, &transactionDetail.Margin,
 			&product.IdProduct, &product.NameProvider, &product.Nominal, &product.Price,
 		); err != nil {
 			r.log.Error("Failed to scan transactions", err)
@@ -200,14 +370,16 @@ func (r *transactionRepository) GetAll(userId string) ([]custom.TransactionsReq,
 
 		transactionDetail.Product = product
 
-		if existingTransaction, ok := transactionMap[transaction.TransactionsId]; ok {
-			existingTransaction.TransactionDetail = append(existingTransaction.TransactionDetail, transactionDetail)
-		} else {
-			transaction.User = user
-			transaction.Merchant = merchant
-			transaction.TransactionDetail = []custom.TransactionDetailReq{transactionDetail}
-			transactionMap[transaction.TransactionsId] = &transaction
+		if index := position[transaction.TransactionsId]; index >= 0 {
+			transactions[index].TransactionDetail = append(transactions[index].TransactionDetail, transactionDetail)
+			continue
 		}
+
+		transaction.User = user
+		transaction.Merchant = merchant
+		transaction.TransactionDetail = []custom.TransactionDetailReq{transactionDetail}
+		position[transaction.TransactionsId] = len(transactions)
+		transactions = append(transactions, transaction)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -215,22 +387,25 @@ func (r *transactionRepository) GetAll(userId string) ([]custom.TransactionsReq,
 		return nil, err
 	}
 
-	transactions := make([]custom.TransactionsReq, 0, len(transactionMap))
-	for _, transaction := range transactionMap {
-		transactions = append(transactions, *transaction)
+	// the details query is ordered by detail id, put the transactions back in page order
+	ordered := make([]custom.TransactionsReq, 0, len(transactions))
+	for _, id := range ids {
+		if index := position[id]; index >= 0 {
+			ordered = append(ordered, transactions[index])
+		}
 	}
-
-	r.log.Info("Successfully Get the transactions list", transactions)
-	return transactions, nil
+	return ordered, nil
 }
 
 func (r *transactionRepository) GetById(id string) (custom.TransactionsReq, error) {
 	selectQuery := `
 	SELECT
-		t.transaction_id, t.customer_name, t.destination_number, t.transaction_date,
+		t.transaction_id, t.customer_name, t.destination_number, t.transaction_date, t.status,
 		u.id_user, u.username, u.role,
 		m.id_merchant, m.name_merchant, m.address,
-		td.transaction_detail_id, p.id_product, p.name_provider, p.nominal, p.price
+		td.transaction_detail_id, td.status, COALESCE(td.serial_number, ''),
+		td.cost, td.price, td.margin,
+		p.id_product, p.name_provider, p.nominal, p.price
 		
 	FROM transactions t
 	JOIN mst_user u ON t.id_user = u.id_user
@@ -250,7 +425,6 @@ func (r *transactionRepository) GetById(id string) (custom.TransactionsReq, erro
 	var transaction custom.TransactionsReq
 	transactionDetailMap := make(map[string]custom.TransactionDetailReq)
 
-	//loop and process the first row after checking
 	for rows.Next() {
 		var (
 			user              custom.UserRes
@@ -259,10 +433,11 @@ func (r *transactionRepository) GetById(id string) (custom.TransactionsReq, erro
 			product           custom.ProductRes
 		)
 		if err := rows.Scan(
-			&transaction.TransactionsId, &transaction.CustomerName, &transaction.DestinationNumber, &transaction.TransactionDate,
+			&transaction.TransactionsId, &transaction.CustomerName, &transaction.DestinationNumber, &transaction.TransactionDate, &transaction.Status,
 			&user.Id_user, &user.Username, &user.Role,
 			&merchant.IdMerchant, &merchant.NameMerchant, &merchant.Address,
-			&transactionDetail.TransactionDetailId,
+			&transactionDetail.TransactionDetailId, &transactionDetail.Status, &transactionDetail.SerialNumber,
+			&transactionDetail.Cost, &transactionDetail.Price, &transactionDetail.Margin,
 			&product.IdProduct, &product.NameProvider, &product.Nominal, &product.Price); err != nil {
 			r.log.Error("Failed to scan transaction", err)
 			return custom.TransactionsReq{}, err
@@ -273,12 +448,13 @@ func (r *transactionRepository) GetById(id string) (custom.TransactionsReq, erro
 
 		//store transaction detail in the map
 		transactionDetailMap[transactionDetail.TransactionDetailId] = transactionDetail
+	}
 
-		//continue iterating if more row are present
-		if !rows.Next() {
-			break
-		}
+	if err := rows.Err(); err != nil {
+		r.log.Error("Rows not found", err)
+		return custom.TransactionsReq{}, err
 	}
+
 	for _, detail := range transactionDetailMap {
 		transaction.TransactionDetail = append(transaction.TransactionDetail, detail)
 	}
@@ -286,6 +462,95 @@ func (r *transactionRepository) GetById(id string) (custom.TransactionsReq, erro
 	return transaction, nil
 }
 
+// Refund marks the given details as refunded and credits the cost debited at the time of sale back to the merchant.
+// Only pending and successful details can be refunded; any other status aborts the whole refund. audit is
+// recorded with the refund in the same db transaction.
+func (r *transactionRepository) Refund(payload entity.TransactionRefund, audit entity.AuditLog) (entity.TransactionRefund, error) {
+	r.log.Info("Starting to refund a transaction in the repository layer", payload)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		r.log.Error("Failed start db transaction", err)
+		return entity.TransactionRefund{}, err
+	}
+
+	var merchantId string
+	if err := tx.QueryRow(
+		"SELECT id_merchant FROM transactions WHERE transaction_id = $1 FOR UPDATE",
+		payload.TransactionsId,
+	).Scan(&merchantId); err != nil {
+		tx.Rollback()
+		r.log.Error("Failed to lock the transaction", err)
+		return entity.TransactionRefund{}, err
+	}
+
+	markRefunded := `
+		UPDATE transaction_detail
+		SET status = 'refunded', updated_at = NOW()
+		WHERE transaction_detail_id = $1
+		AND transaction_id = $2
+		AND status IN ('pending', 'success')
+		RETURNING cost`
+
+	insertRefund := "INSERT INTO transaction_refund (transaction_id, transaction_detail_id, amount, reason, refunded_by) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
+
+	payload.Amount = 0
+	for _, detailId := range payload.TransactionDetailIds {
+		var nominal float64
+		if err := tx.QueryRow(markRefunded, detailId, payload.TransactionsId).Scan(&nominal); err != nil {
+			tx.Rollback()
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("transaction detail %s can no longer be refunded", detailId)
+			}
+			r.log.Error("Failed to mark transaction detail as refunded", err)
+			return entity.TransactionRefund{}, err
+		}
+
+		var refundId string
+		if err := tx.QueryRow(insertRefund, payload.TransactionsId, detailId, nominal, payload.Reason, payload.RefundedBy).Scan(&refundId, &payload.RefundedAt); err != nil {
+			tx.Rollback()
+			r.log.Error("Failed to insert into transaction refund table", err)
+			return entity.TransactionRefund{}, err
+		}
+
+		// Credit back the cost that was subtracted when the transaction was created
+		if _, err := postLedgerEntry(tx, entity.LedgerEntry{
+			IdMerchant:    merchantId,
+			EntryType:     entity.LedgerRefund,
+			Amount:        nominal,
+			ReferenceType: "transaction_refund",
+			ReferenceId:   refundId,
+			Description:   payload.Reason,
+			CreatedBy:     payload.RefundedBy,
+		}); err != nil {
+			tx.Rollback()
+			r.log.Error("Failed to restore merchant balance", err)
+			return entity.TransactionRefund{}, err
+		}
+		payload.Amount += nominal
+	}
+
+	if _, err := tx.Exec(refreshTransactionStatus, payload.TransactionsId); err != nil {
+		tx.Rollback()
+		r.log.Error("Failed to refresh transaction status", err)
+		return entity.TransactionRefund{}, err
+	}
+
+	if err := recordAudit(tx, audit, nil, payload); err != nil {
+		tx.Rollback()
+		r.log.Error("Failed to audit the refund", err)
+		return entity.TransactionRefund{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.log.Error("Failed to commit transaction", err)
+		return entity.TransactionRefund{}, err
+	}
+
+	r.log.Info("Transaction refunded successfully", payload)
+	return payload, nil
+}
+
 // func (r *transactionRepository) Update(payload entity.Transactions) (entity.Transactions, error) {
 // 	tx, err := r.db.Begin()
 // 	if err != nil {
@@ -79,6 +79,7 @@ func (s *transactionRepositoryTestSuite) SetupTest() {
 
 	s.mockDb = mockDb
 	s.mockSql = mockSql
+	s.log = logger.NewLogger()
 	s.transactionRepo = NewTransactionRepository(mockDb, &s.log)
 }
 
@@ -87,18 +88,17 @@ func (s *transactionRepositoryTestSuite) TearDownTest() {
 }
 
 func (s *transactionRepositoryTestSuite) TestCreate_Success() {
-	// Mock merchant existence check
-	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM mst_merchant WHERE id_merchant = $1)`)).
-		WithArgs(expectedTransaction.MerchantId).
-		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	s.mockSql.ExpectBegin()
 
-	// Mock user existence check
-	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM mst_user WHERE id_user = $1)`)).
-		WithArgs(expectedTransaction.UserId).
-		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	// Mock merchant balance lock
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT balance FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE`)).
+		WithArgs(expectedTransaction.MerchantId).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100000))
 
-	// Mock transaction begin
-	s.mockSql.ExpectBegin()
+	// Mock product cost and price query
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT nominal, price FROM mst_product WHERE id_product = $1`)).
+		WithArgs(expectedTransaction.TransactionDetail[0].ProductId).
+		WillReturnRows(sqlmock.NewRows([]string{"nominal", "price"}).AddRow(48000, 50000))
 
 	// Mock transaction insert
 	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO transactions`)).
@@ -111,24 +111,26 @@ func (s *transactionRepositoryTestSuite) TestCreate_Success() {
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"transaction_id"}).AddRow(expectedTransaction.TransactionsId))
 
-	// Mock product price query
-	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM mst_product WHERE id_product = $1)`)).
-		WithArgs(expectedTransaction.TransactionDetail[0].ProductId).
-		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
-
-	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT price FROM mst_product WHERE id_product = $1`)).
-		WithArgs(expectedTransaction.TransactionDetail[0].ProductId).
-		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(50000))
-
 	// Mock transaction detail insert
 	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO transaction_detail`)).
 		WithArgs(
 			expectedTransaction.TransactionsId,
 			expectedTransaction.TransactionDetail[0].ProductId,
-			50000,
+			float64(48000),
+			float64(50000),
+			float64(2000),
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"transaction_detail_id"}).AddRow("detail-uuid"))
 
+	// Mock merchant debit through the ledger
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance`)).
+		WithArgs(float64(-48000), expectedTransaction.MerchantId).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(52000))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO merchant_ledger`)).
+		WithArgs(expectedTransaction.MerchantId, entity.LedgerSale, float64(-48000), float64(52000),
+			"transaction", expectedTransaction.TransactionsId, "sale to "+expectedTransaction.DestinationNumber, expectedTransaction.UserId).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+
 	// Mock commit
 	s.mockSql.ExpectCommit()
 
@@ -137,6 +139,7 @@ func (s *transactionRepositoryTestSuite) TestCreate_Success() {
 	s.NoError(err)
 	s.Equal(expectedTransaction.TransactionsId, result.TransactionsId)
 	s.Equal(expectedTransaction.CustomerName, result.CustomerName)
+	s.NoError(s.mockSql.ExpectationsWereMet())
 }
 
 func (s *transactionRepositoryTestSuite) TestCreate_InvalidDate() {
@@ -151,30 +154,43 @@ func (s *transactionRepositoryTestSuite) TestCreate_InvalidDate() {
 }
 
 func (s *transactionRepositoryTestSuite) TestCreate_MerchantNotFound() {
-	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM mst_merchant WHERE id_merchant = $1)`)).
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT balance FROM mst_merchant WHERE id_merchant = $1 FOR UPDATE`)).
 		WithArgs(expectedTransaction.MerchantId).
-		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		WillReturnError(sql.ErrNoRows)
+	s.mockSql.ExpectRollback()
 
 	result, err := s.transactionRepo.Create(expectedTransaction)
 
-	s.Error(err)
-	s.Equal("merchant not found", err.Error())
+	s.ErrorIs(err, sql.ErrNoRows)
 	s.Equal(entity.Transactions{}, result)
+	s.NoError(s.mockSql.ExpectationsWereMet())
 }
 
 // GetAll Tests
 func (s *transactionRepositoryTestSuite) TestGetAll_Success() {
-	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT`)).
+	filter := entity.TransactionFilter{UserId: "user-uuid", Provider: "Test Provider", Sort: "-date", Page: 2, Limit: 10}
+
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).
+		WithArgs(filter.UserId, filter.Provider).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`ORDER BY t.transaction_date DESC, t.transaction_id DESC LIMIT $3 OFFSET $4`)).
+		WithArgs(filter.UserId, filter.Provider, 10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"transaction_id"}).AddRow(expectedTransactionReq.TransactionsId))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`WHERE t.transaction_id = ANY($1::uuid[])`)).
 		WillReturnRows(sqlmock.NewRows([]string{
-			"transaction_id", "customer_name", "destination_number", "transaction_date",
+			"transaction_id", "customer_name", "destination_number", "transaction_date", "status",
 			"id_user", "username", "role",
 			"id_merchant", "name_merchant", "address",
-			"transaction_detail_id", "transaction_id", "id_product", "name_provider", "nominal", "price",
+			"transaction_detail_id", "transaction_id", "status", "serial_number",
+			"cost", "price", "margin",
+			"id_product", "name_provider", "nominal", "price",
 		}).AddRow(
 			expectedTransactionReq.TransactionsId,
 			expectedTransactionReq.CustomerName,
 			expectedTransactionReq.DestinationNumber,
 			expectedTransactionReq.TransactionDate,
+			"success",
 			expectedTransactionReq.User.Id_user,
 			expectedTransactionReq.User.Username,
 			expectedTransactionReq.User.Role,
@@ -183,31 +199,57 @@ func (s *transactionRepositoryTestSuite) TestGetAll_Success() {
 			expectedTransactionReq.Merchant.Address,
 			expectedTransactionReq.TransactionDetail[0].TransactionDetailId,
 			expectedTransactionReq.TransactionsId,
+			"success",
+			"",
+			9500.0,
+			10500.0,
+			1000.0,
 			expectedTransactionReq.TransactionDetail[0].Product.IdProduct,
 			expectedTransactionReq.TransactionDetail[0].Product.NameProvider,
 			expectedTransactionReq.TransactionDetail[0].Product.Nominal,
 			expectedTransactionReq.TransactionDetail[0].Product.Price,
 		))
 
-	result, err := s.transactionRepo.GetAll("")
+	result, total, err := s.transactionRepo.GetAll(filter)
 
 	s.NoError(err)
+	s.Equal(11, total)
 	s.Len(result, 1)
 	s.Equal(expectedTransactionReq.TransactionsId, result[0].TransactionsId)
 }
 
 func (s *transactionRepositoryTestSuite) TestGetAll_EmptyResult() {
-	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT`)).
-		WillReturnRows(sqlmock.NewRows([]string{
-			"transaction_id", "customer_name", "destination_number", "transaction_date",
-			"id_user", "username", "role",
-			"id_merchant", "name_merchant", "address",
-			"transaction_detail_id", "transaction_id", "id_product", "name_provider", "nominal", "price",
-		}))
+	filter := entity.TransactionFilter{UserId: "user-uuid", Sort: "-date", Page: 1, Limit: 10}
 
-	result, err := s.transactionRepo.GetAll("")
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).
+		WithArgs(filter.UserId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`ORDER BY t.transaction_date DESC, t.transaction_id DESC LIMIT $2 OFFSET $3`)).
+		WithArgs(filter.UserId, 10, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"transaction_id"}))
+
+	result, total, err := s.transactionRepo.GetAll(filter)
+
+	s.NoError(err)
+	s.Equal(0, total)
+	s.Empty(result)
+}
+
+func (s *transactionRepositoryTestSuite) TestGetAll_Cursor() {
+	filter := entity.TransactionFilter{UserId: "user-uuid", Sort: "customer_name", Page: 1, Limit: 10,
+		Cursor: "cursor", AfterValue: "John", AfterId: "test-uuid"}
+
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).
+		WithArgs(filter.UserId).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`AND (t.customer_name, t.transaction_id) > ($2::text, $3::uuid) ORDER BY t.customer_name ASC, t.transaction_id ASC LIMIT $4`)).
+		WithArgs(filter.UserId, filter.AfterValue, filter.AfterId, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"transaction_id"}))
+
+	result, total, err := s.transactionRepo.GetAll(filter)
 
 	s.NoError(err)
+	s.Equal(1, total)
 	s.Empty(result)
 }
 
@@ -216,15 +258,17 @@ func (s *transactionRepositoryTestSuite) TestGetById_Success() {
 	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT`)).
 		WithArgs(expectedTransactionReq.TransactionsId).
 		WillReturnRows(sqlmock.NewRows([]string{
-			"transaction_id", "customer_name", "destination_number", "transaction_date",
+			"transaction_id", "customer_name", "destination_number", "transaction_date", "status",
 			"id_user", "username", "role",
 			"id_merchant", "name_merchant", "address",
-			"transaction_detail_id", "id_product", "name_provider", "nominal", "price",
+			"transaction_detail_id", "status", "serial_number", "cost", "price", "margin",
+			"id_product", "name_provider", "nominal", "price",
 		}).AddRow(
 			expectedTransactionReq.TransactionsId,
 			expectedTransactionReq.CustomerName,
 			expectedTransactionReq.DestinationNumber,
 			expectedTransactionReq.TransactionDate,
+			"success",
 			expectedTransactionReq.User.Id_user,
 			expectedTransactionReq.User.Username,
 			expectedTransactionReq.User.Role,
@@ -232,6 +276,11 @@ func (s *transactionRepositoryTestSuite) TestGetById_Success() {
 			expectedTransactionReq.Merchant.NameMerchant,
 			expectedTransactionReq.Merchant.Address,
 			expectedTransactionReq.TransactionDetail[0].TransactionDetailId,
+			"success",
+			"",
+			48000,
+			50000,
+			2000,
 			expectedTransactionReq.TransactionDetail[0].Product.IdProduct,
 			expectedTransactionReq.TransactionDetail[0].Product.NameProvider,
 			expectedTransactionReq.TransactionDetail[0].Product.Nominal,
@@ -248,15 +297,48 @@ func (s *transactionRepositoryTestSuite) TestGetById_NotFound() {
 	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT`)).
 		WithArgs("non-existent-id").
 		WillReturnRows(sqlmock.NewRows([]string{
-			"transaction_id", "customer_name", "destination_number", "transaction_date",
+			"transaction_id", "customer_name", "destination_number", "transaction_date", "status",
 			"id_user", "username", "role",
 			"id_merchant", "name_merchant", "address",
-			"transaction_detail_id", "id_product", "name_provider", "nominal", "price",
+			"transaction_detail_id", "status", "serial_number", "cost", "price", "margin",
+			"id_product", "name_provider", "nominal", "price",
 		}))
 
 	result, err := s.transactionRepo.GetById("non-existent-id")
 
-	s.Error(err)
-	s.Equal("transaction not found", err.Error())
+	// An unknown id yields an empty transaction, the usecase turns it into ErrTransactionNotFound
+	s.NoError(err)
 	s.Equal(custom.TransactionsReq{}, result)
 }
+
+func (s *transactionRepositoryTestSuite) TestRefund_AuditsInTransaction() {
+	payload := entity.TransactionRefund{TransactionsId: "test-uuid", TransactionDetailIds: []string{"detail-uuid"}, Reason: "wrong number", RefundedBy: "admin-uuid"}
+	audit := entity.AuditLog{IdUser: "admin-uuid", Action: entity.AuditRefund, EntityType: entity.AuditTransaction, EntityId: "test-uuid", Before: []byte(`{"status":"success"}`)}
+
+	s.mockSql.ExpectBegin()
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`SELECT id_merchant FROM transactions WHERE transaction_id = $1 FOR UPDATE`)).
+		WithArgs("test-uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"id_merchant"}).AddRow("merchant-uuid"))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE transaction_detail`)).
+		WithArgs("detail-uuid", "test-uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"cost"}).AddRow(48000))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO transaction_refund`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("refund-uuid", time.Now()))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`UPDATE mst_merchant SET balance = balance + $1 WHERE id_merchant = $2 RETURNING balance`)).
+		WithArgs(float64(48000), "merchant-uuid").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100000))
+	s.mockSql.ExpectQuery(regexp.QuoteMeta(`INSERT INTO merchant_ledger`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	s.mockSql.ExpectExec(regexp.QuoteMeta(`UPDATE transactions t`)).
+		WithArgs("test-uuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAuditLog(s.mockSql, entity.AuditRefund, entity.AuditTransaction, "test-uuid", `{"status":"success"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockSql.ExpectCommit()
+
+	refund, err := s.transactionRepo.Refund(payload, audit)
+
+	s.NoError(err)
+	s.Equal(float64(48000), refund.Amount)
+	s.NoError(s.mockSql.ExpectationsWereMet())
+}
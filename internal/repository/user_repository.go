@@ -2,17 +2,29 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUsernameTaken    = errors.New("username already exist")
+	ErrUnknownUserRole  = errors.New("role doesn't exist")
+	ErrMerchantNotFound = errors.New("merchant not found")
 )
 
 type UserRepository interface {
 	CreateUser(user entity.User) (entity.User, error)
+	CreateUserWithMerchant(user entity.User, idMerchant string) (entity.User, error)
+	CreateFirstUser(user entity.User) (entity.User, bool, error)
 	ListUser() ([]entity.User, error)
 	GetUserByID(id string) (entity.User, error)
 	GetUserByUsername(username string) (entity.User, error)
 	UpdateUser(user, payload entity.User) (entity.User, error)
+	UpdatePassword(id, passwordHash string) error
 	DeleteUser(id string) error
 }
 
@@ -35,6 +47,64 @@ func (u *userRepository) CreateUser(user entity.User) (entity.User, error) {
 	return user, nil
 }
 
+// CreateUserWithMerchant creates a user and, when idMerchant is set, makes the user the owner of that merchant.
+func (u *userRepository) CreateUserWithMerchant(user entity.User, idMerchant string) (entity.User, error) {
+	u.log.Info("Starting to provision a user in the repository layer", nil)
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		return entity.User{}, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO mst_user (username, password, role) VALUES ($1, $2, $3) RETURNING id_user`, user.Username, user.Password, user.Role).Scan(&user.Id_user)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return entity.User{}, ErrUsernameTaken
+	} else if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return entity.User{}, ErrUnknownUserRole
+	} else if err != nil {
+		u.log.Error("Failed to create the user: ", err)
+		return entity.User{}, err
+	}
+
+	if idMerchant != "" {
+		res, err := tx.Exec(`UPDATE mst_merchant SET id_user = $1 WHERE id_merchant = $2`, user.Id_user, idMerchant)
+		if err != nil {
+			u.log.Error("Failed to assign the merchant: ", err)
+			return entity.User{}, err
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return entity.User{}, ErrMerchantNotFound
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.User{}, err
+	}
+
+	u.log.Info("User has been provisioned successfully", user.Id_user)
+	return user, nil
+}
+
+// CreateFirstUser creates the user only while mst_user is empty. It returns false when a user already exists.
+func (u *userRepository) CreateFirstUser(user entity.User) (entity.User, bool, error) {
+	err := u.db.QueryRow(`
+		INSERT INTO mst_user (username, password, role)
+		SELECT $1, $2, $3 WHERE NOT EXISTS (SELECT 1 FROM mst_user)
+		RETURNING id_user`,
+		user.Username, user.Password, user.Role,
+	).Scan(&user.Id_user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.User{}, false, nil
+	} else if err != nil {
+		u.log.Error("Failed to create the first user: ", err)
+		return entity.User{}, false, err
+	}
+
+	return user, true, nil
+}
+
 func (u *userRepository) ListUser() ([]entity.User, error) {
 	var users []entity.User
 
@@ -101,8 +171,12 @@ func (u *userRepository) UpdateUser(user, payload entity.User) (entity.User, err
 	}
 
 	_, err := u.db.Exec(`UPDATE mst_user SET username = $2, password = $3, role = $4 WHERE id_user = $1`, user.Id_user, user.Username, user.Password, user.Role)
-
-	if err != nil {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return entity.User{}, ErrUsernameTaken
+	} else if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return entity.User{}, ErrUnknownUserRole
+	} else if err != nil {
 		u.log.Error("Failed to update the user: ", err)
 		return entity.User{}, err
 	}
@@ -110,6 +184,24 @@ func (u *userRepository) UpdateUser(user, payload entity.User) (entity.User, err
 	u.log.Info("User has been updated successfully", user)
 	return user, nil
 }
+
+// UpdatePassword replaces the password hash of a user and returns sql.ErrNoRows when the user doesn't exist.
+func (u *userRepository) UpdatePassword(id, passwordHash string) error {
+	u.log.Info("Starting to update a password in the repository layer", nil)
+
+	res, err := u.db.Exec(`UPDATE mst_user SET password = $2 WHERE id_user = $1`, id, passwordHash)
+	if err != nil {
+		u.log.Error("Failed to update the password: ", err)
+		return err
+	}
+
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 func (u *userRepository) DeleteUser(id string) error {
 	u.log.Info("Starting to delete user in the repository layer", nil)
 
@@ -1,9 +1,11 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"server-pulsa-app/config"
+	"server-pulsa-app/internal/gateway"
 	"server-pulsa-app/internal/handler"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/middleware"
@@ -34,6 +36,8 @@ import (
 // @schemes http https
 type Server struct {
 	jwtService    service.JwtService
+	sessionRepo   repository.AuthSessionRepository
+	roleRepo      repository.RoleRepository
 	authUc        usecase.AuthUseCase
 	productUc     usecase.ProductUseCase
 	merchantUc    usecase.MerchantUseCase
@@ -41,6 +45,14 @@ type Server struct {
 	userUc        usecase.UserUsecase
 	reportUc      usecase.ReportUseCase
 	topupUc       usecase.TopupUseCase
+	topupSetUc    usecase.TopupSettingUseCase
+	fulfillmentUc usecase.FulfillmentUseCase
+	operatorUc    usecase.OperatorPrefixUseCase
+	scheduleUc    usecase.ScheduleUseCase
+	roleUc        usecase.RoleUseCase
+	mfaUc         usecase.MFAUseCase
+	apiKeyUc      usecase.APIKeyUseCase
+	auditUc       usecase.AuditUseCase
 
 	engine *gin.Engine
 	host   string
@@ -50,26 +62,73 @@ var log = logger.NewLogger()
 
 func (s *Server) initRoute() {
 	rg := s.engine.Group(config.ApiGroup)
-	authMiddleware := middleware.NewAuthMiddleware(s.jwtService)
+	authMiddleware := middleware.NewAuthMiddleware(s.jwtService, s.sessionRepo, s.roleRepo, s.apiKeyUc)
 
 	handler.NewMerchantHandler(s.merchantUc, authMiddleware, rg, &log).Route()
-	handler.NewAuthController(s.authUc, rg, &log).Route()
+	handler.NewAuthController(s.authUc, authMiddleware, rg, &log).Route()
 	handler.NewProductController(s.productUc, rg, authMiddleware, &log).Route()
 	handler.NewTransactionHandler(s.transactionUc, authMiddleware, rg, &log).Route()
 	handler.NewUserHandler(s.userUc, authMiddleware, rg, &log).Route()
 	handler.NewReportHandler(s.reportUc, authMiddleware, rg, &log).Route()
 	handler.NewTopupHandler(s.topupUc, authMiddleware, rg, &log).Route()
+	handler.NewTopupSettingHandler(s.topupSetUc, authMiddleware, rg, &log).Route()
+	handler.NewOperatorPrefixHandler(s.operatorUc, authMiddleware, rg, &log).Route()
+	handler.NewScheduleHandler(s.scheduleUc, authMiddleware, rg, &log).Route()
+	handler.NewRoleHandler(s.roleUc, authMiddleware, rg, &log).Route()
+	handler.NewMFAHandler(s.mfaUc, authMiddleware, rg, &log).Route()
+	handler.NewAPIKeyHandler(s.apiKeyUc, authMiddleware, rg, &log).Route()
+	handler.NewAuditHandler(s.auditUc, authMiddleware, rg, &log).Route()
+	// the key set is public and lives outside the api group, where verifiers look for it
+	handler.NewJwksHandler(s.jwtService, s.engine.Group("")).Route()
 
 	s.engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
 
 func (s *Server) Run() {
 	s.initRoute()
+	go s.fulfillmentUc.Run(context.Background())
+	go s.scheduleUc.Run(context.Background())
+	go s.topupUc.Run(context.Background())
 	if err := s.engine.Run(s.host); err != nil {
 		panic(fmt.Errorf("server not running on host %s, becauce error %v", s.host, err.Error()))
 	}
 }
 
+// newPaymentGateway picks the topup payment gateway named by PAYMENT_GATEWAY.
+func newPaymentGateway(cfg *config.Config) usecase.PaymentGateway {
+	switch cfg.PaymentConfig.Gateway {
+	case "xendit":
+		return gateway.NewXenditPaymentGateway(cfg.XenditConfig)
+	case "fake":
+		return gateway.NewFakePaymentGateway(cfg.PaymentConfig.FakeToken)
+	default:
+		return gateway.NewMidtransPaymentGateway(cfg.MidtransConfig)
+	}
+}
+
+// newSupplierGateway picks the supplier gateway named by SUPPLIER_GATEWAY. The fake supplier reports every order
+// delivered, so it has to be asked for explicitly; without a supplier it returns nil and orders stay pending.
+func newSupplierGateway(cfg config.FulfillmentConfig) (usecase.SupplierGateway, error) {
+	switch cfg.Supplier {
+	case "":
+		return nil, nil
+	case "fake":
+		return gateway.NewFakeSupplierGateway(), nil
+	default:
+		return nil, fmt.Errorf("unknown SUPPLIER_GATEWAY %q", cfg.Supplier)
+	}
+}
+
+// newEngine builds the gin engine, trusting X-Forwarded-For only from the configured proxies so a client can't pick
+// the IP that API key allow lists and login lockouts see.
+func newEngine(cfg config.ApiConfig) (*gin.Engine, error) {
+	engine := gin.Default()
+	if err := engine.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	return engine, nil
+}
+
 func NewServer() *Server {
 	cfg, _ := config.NewConfig()
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -82,26 +141,63 @@ func NewServer() *Server {
 
 	//inject dependencies repo layer
 	userRepo := repository.NewUserRepository(db, &log)
+	sessionRepo := repository.NewAuthSessionRepository(db, &log)
+	loginRepo := repository.NewLoginAttemptRepository(db, &log)
+	mfaRepo := repository.NewMFARepository(db, &log)
+	roleRepo := repository.NewRoleRepository(db, &log)
 	productRepo := repository.NewProductRepository(db, &log)
 	merchantRepo := repository.NewMerchantRepository(db, &log)
+	apiKeyRepo := repository.NewAPIKeyRepository(db, &log)
 	transactionRepo := repository.NewTransactionRepository(db, &log)
 	reportRepo := repository.NewReportRepository(db, &log)
 	topupRepo := repository.NewTopupRepository(db)
+	topupSettingRepo := repository.NewTopupSettingRepository(db, &log)
+	fulfillmentRepo := repository.NewFulfillmentRepository(db, &log)
+	operatorRepo := repository.NewOperatorPrefixRepository(db, &log)
+	scheduleRepo := repository.NewScheduleRepository(db, &log)
+	auditRepo := repository.NewAuditRepository(db, &log)
 
 	//inject dependencies usecase layer
-	jwtService := service.NewJwtService(cfg.TokenConfig)
-	userUc := usecase.NewUserUsecase(userRepo, &log)
-	authUc := usecase.NewAuthUseCase(userUc, jwtService, &log)
-	productUc := usecase.NewProductUseCase(productRepo, &log)
-	merchantUc := usecase.NewMerchantUseCase(merchantRepo, &log)
-	transactionUc := usecase.NewTransactionUseCase(transactionRepo, &log)
+	jwtService, err := service.NewJwtService(cfg.TokenConfig)
+	if err != nil {
+		panic(err)
+	}
+	auditUc := usecase.NewAuditUseCase(auditRepo, &log)
+	userUc := usecase.NewUserUsecase(userRepo, sessionRepo, roleRepo, auditUc, cfg.PasswordConfig, &log)
+	if cfg.BootstrapAdminUsername != "" {
+		if created, err := userUc.BootstrapAdmin(cfg.BootstrapAdminUsername, cfg.BootstrapAdminPassword); err != nil {
+			log.Error("Failed to bootstrap the first admin", err)
+		} else if created {
+			log.Info("Created the first admin", cfg.BootstrapAdminUsername)
+		}
+	}
+	mfaUc := usecase.NewMFAUseCase(mfaRepo, userRepo, auditUc, cfg.AuthConfig, &log)
+	authUc := usecase.NewAuthUseCase(userUc, mfaUc, jwtService, sessionRepo, loginRepo, auditUc, cfg.TokenConfig, cfg.AuthConfig, &log)
+	productUc := usecase.NewProductUseCase(productRepo, auditUc, &log)
+	merchantUc := usecase.NewMerchantUseCase(merchantRepo, auditUc, &log)
+	apiKeyUc := usecase.NewAPIKeyUseCase(apiKeyRepo, auditUc, cfg.APIKeyConfig, &log)
+	topupUc := usecase.NewTopupUsecase(topupRepo, topupSettingRepo, newPaymentGateway(cfg), auditUc, cfg.TopupConfig, &log)
+	transactionUc := usecase.NewTransactionUseCase(transactionRepo, productRepo, operatorRepo, topupUc, auditUc, &log)
 	reportUc := usecase.NewReportUseCase(reportRepo, &log)
-	topupUc := usecase.NewTopupUsecase(topupRepo)
+	topupSetUc := usecase.NewTopupSettingUseCase(topupSettingRepo, auditUc, &log)
+	supplier, err := newSupplierGateway(cfg.FulfillmentConfig)
+	if err != nil {
+		panic(err)
+	}
+	fulfillmentUc := usecase.NewFulfillmentUseCase(fulfillmentRepo, supplier, cfg.FulfillmentConfig, &log)
+	operatorUc := usecase.NewOperatorPrefixUseCase(operatorRepo, &log)
+	roleUc := usecase.NewRoleUseCase(roleRepo, auditUc, &log)
+	scheduleUc := usecase.NewScheduleUseCase(scheduleRepo, transactionUc, topupUc, gateway.NewLogScheduleNotifier(&log), cfg.ScheduleConfig, &log)
 
-	engine := gin.Default()
+	engine, err := newEngine(cfg.ApiConfig)
+	if err != nil {
+		panic(err)
+	}
 	host := fmt.Sprintf(":%s", cfg.ApiPort)
 	return &Server{
 		jwtService:    jwtService,
+		sessionRepo:   sessionRepo,
+		roleRepo:      roleRepo,
 		authUc:        authUc,
 		productUc:     productUc,
 		merchantUc:    merchantUc,
@@ -109,6 +205,14 @@ func NewServer() *Server {
 		userUc:        userUc,
 		reportUc:      reportUc,
 		topupUc:       topupUc,
+		topupSetUc:    topupSetUc,
+		fulfillmentUc: fulfillmentUc,
+		operatorUc:    operatorUc,
+		scheduleUc:    scheduleUc,
+		roleUc:        roleUc,
+		mfaUc:         mfaUc,
+		apiKeyUc:      apiKeyUc,
+		auditUc:       auditUc,
 
 		engine: engine,
 		host:   host,
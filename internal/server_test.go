@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server-pulsa-app/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func clientIPSeenBy(t *testing.T, cfg config.ApiConfig) string {
+	engine, err := newEngine(cfg)
+	assert.NoError(t, err)
+
+	var clientIP string
+	engine.GET("/ip", func(c *gin.Context) { clientIP = c.ClientIP() })
+
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.RemoteAddr = "10.0.0.5:41000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	return clientIP
+}
+
+func TestNewEngine_IgnoresSpoofedForwardedFor(t *testing.T) {
+	assert.Equal(t, "10.0.0.5", clientIPSeenBy(t, config.ApiConfig{}))
+}
+
+func TestNewEngine_TrustsConfiguredProxy(t *testing.T) {
+	assert.Equal(t, "203.0.113.7", clientIPSeenBy(t, config.ApiConfig{TrustedProxies: []string{"10.0.0.0/8"}}))
+}
+
+func TestNewEngine_InvalidProxy(t *testing.T) {
+	_, err := newEngine(config.ApiConfig{TrustedProxies: []string{"not-an-ip"}})
+
+	assert.Error(t, err)
+}
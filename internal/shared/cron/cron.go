@@ -0,0 +1,154 @@
+// Package cron parses the five field cron expressions used by recurring schedules.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed "minute hour day-of-month month day-of-week" expression. Each field keeps a
+// bit per allowed value.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fields = []bounds{{"minute", 0, 59}, {"hour", 0, 23}, {"day of month", 1, 31}, {"month", 1, 12}, {"day of week", 0, 7}}
+
+// Parse parses an expression such as "0 8 1 * *" (08:00 on the 1st of every month). Fields accept
+// "*", single values, ranges "1-5", lists "1,15" and steps "*/15" or "1-31/2"; the @daily style
+// descriptors are accepted as well.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if descriptor, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("cron expression %q must have %d fields", expr, len(fields))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		value, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, err
+		}
+		bits[i] = value
+	}
+
+	// Sunday can be written as 0 or 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return Schedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domAny: parts[2] == "*", dowAny: parts[4] == "*",
+	}, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", b.name, field)
+			}
+			rangePart = item[:i]
+		}
+
+		start, end := b.min, b.max
+		if rangePart != "*" {
+			limits := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(limits[0]); err != nil {
+				return 0, fmt.Errorf("invalid %s field %q", b.name, field)
+			}
+			end = start
+			if len(limits) == 2 {
+				if end, err = strconv.Atoi(limits[1]); err != nil {
+					return 0, fmt.Errorf("invalid %s field %q", b.name, field)
+				}
+			} else if step > 1 {
+				end = b.max
+			}
+		}
+
+		if start < b.min || end > b.max || start > end {
+			return 0, fmt.Errorf("%s field %q is out of range %d-%d", b.name, field, b.min, b.max)
+		}
+
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+// dayMatches follows the cron rule that when both day of month and day of week are restricted,
+// a day matching either of them is enough.
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time strictly after t that matches the schedule, in t's location. It
+// returns the zero time when nothing matches within five years, e.g. for "0 0 30 2 *".
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for day := 0; day < 5*366; day++ {
+		if has(s.month, int(t.Month())) && s.dayMatches(t) {
+			for hour := t.Hour(); hour < 24; hour++ {
+				if !has(s.hour, hour) {
+					continue
+				}
+				minute := 0
+				if hour == t.Hour() {
+					minute = t.Minute()
+				}
+				for ; minute < 60; minute++ {
+					if has(s.minute, minute) {
+						return time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+					}
+				}
+			}
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,43 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2024, 10, 25, 9, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 8 1 * *", time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 10, 25, 9, 45, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2024, 10, 26, 9, 30, 0, 0, time.UTC)},
+		{"0 7 * * 1-5", time.Date(2024, 10, 28, 7, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 10, 27, 0, 0, 0, 0, time.UTC)},
+		{"0 12 15,31 * *", time.Date(2024, 10, 31, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, c := range cases {
+		schedule, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", c.expr, err)
+		}
+		if got := schedule.Next(from); !got.Equal(c.want) {
+			t.Errorf("Next(%q) = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) should fail", expr)
+		}
+	}
+}
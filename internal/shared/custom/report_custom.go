@@ -4,3 +4,25 @@ type ReportResp struct {
 	ProviderName string `json:"providerName"`
 	Count        string `json:"count"`
 }
+
+// ProfitReport aggregates the successful sales of one merchant, provider and day.
+type ProfitReport struct {
+	MerchantId   string  `json:"merchantId"`
+	MerchantName string  `json:"merchantName"`
+	Provider     string  `json:"provider"`
+	Date         string  `json:"date"`
+	Count        int     `json:"count"`
+	GrossSales   float64 `json:"grossSales"`
+	Cost         float64 `json:"cost"`
+	Profit       float64 `json:"profit"`
+}
+
+type ProfitSummary struct {
+	StartDate  string         `json:"startDate"`
+	EndDate    string         `json:"endDate"`
+	Count      int            `json:"count"`
+	GrossSales float64        `json:"grossSales"`
+	Cost       float64        `json:"cost"`
+	Profit     float64        `json:"profit"`
+	Rows       []ProfitReport `json:"rows"`
+}
@@ -10,12 +10,18 @@ type (
 		User              UserRes                `json:"user"`
 		Merchant          MerchantRes            `json:"merchant"`
 		TransactionDate   time.Time              `json:"transactionDate"`
+		Status            string                 `json:"status"`
 		TransactionDetail []TransactionDetailReq `json:"transactionDetail"`
 	}
 
 	TransactionDetailReq struct {
 		TransactionDetailId string     `json:"transactionDetailId"`
 		TransactionsId      string     `json:"transactionId,omitempty"`
+		Status              string     `json:"status"`
+		SerialNumber        string     `json:"serialNumber,omitempty"`
+		Cost                float64    `json:"cost"`
+		Price               float64    `json:"price"`
+		Margin              float64    `json:"margin"`
 		Product             ProductRes `json:"product"`
 	}
 
@@ -6,4 +6,6 @@ type Claim struct {
 	jwt.RegisteredClaims
 	UserId string `json:"userId"`
 	Role   string `json:"role"`
+	// SessionId ties the access token to its login session so RequirePermission can reject it once the session is revoked.
+	SessionId string `json:"sid"`
 }
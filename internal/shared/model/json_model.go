@@ -9,3 +9,11 @@ type SingleResponse struct {
 	Status Status      `json:"status"`
 	Data   interface{} `json:"data"`
 }
+
+type Paging struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	TotalRows  int    `json:"totalRows"`
+	TotalPages int    `json:"totalPages"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"server-pulsa-app/internal/shared/model"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Asymmetric signing algorithms. HS256 keeps using the shared TOKEN_SECRET.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 2048
+	// keyReloadInterval is how often a running server picks up keys written by the rotation command. An unknown
+	// kid reloads sooner, but at most every keyForcedReloadInterval.
+	keyReloadInterval       = time.Minute
+	keyForcedReloadInterval = 10 * time.Second
+	// kidTimeLayout starts every kid with the time the key becomes active.
+	kidTimeLayout = "20060102T150405Z"
+)
+
+var ErrNoSigningKey = errors.New("no token signing key")
+
+// signingKey is one private key of the key directory. The kid is its file name without the .pem extension, keys
+// added by hand with another name count as active from their modification time.
+type signingKey struct {
+	kid      string
+	method   jwt.SigningMethod
+	private  crypto.Signer
+	activeAt time.Time
+}
+
+// keySet holds the keys of a directory, oldest first. The newest active one signs, all of them verify and are
+// published, so tokens signed before a rotation stay valid until they expire and a rotated key is known to every
+// server before it signs.
+type keySet struct {
+	dir      string
+	mu       sync.Mutex
+	keys     []signingKey
+	loadedAt time.Time
+}
+
+func newKeySet(dir, algorithm string) (*keySet, error) {
+	set := &keySet{dir: dir}
+
+	keys, err := loadSigningKeys(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		if _, err := writeSigningKey(dir, algorithm, time.Now()); err != nil {
+			return nil, err
+		}
+		if keys, err = loadSigningKeys(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	set.keys = keys
+	set.loadedAt = time.Now()
+	return set, nil
+}
+
+// current returns the loaded keys, reloading the directory once they are old. force reloads sooner, for a kid
+// that isn't known yet. A failed reload keeps the keys already loaded.
+func (k *keySet) current(force bool) []signingKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	age := time.Since(k.loadedAt)
+	if age > keyReloadInterval || (force && age > keyForcedReloadInterval) {
+		if keys, err := loadSigningKeys(k.dir); err == nil && len(keys) > 0 {
+			k.keys = keys
+		}
+		k.loadedAt = time.Now()
+	}
+
+	return k.keys
+}
+
+func (k *keySet) active() (signingKey, error) {
+	return activeKey(k.current(false), time.Now())
+}
+
+// activeKey returns the newest of keys that is active at now. When none is active yet the oldest key signs.
+func activeKey(keys []signingKey, now time.Time) (signingKey, error) {
+	if len(keys) == 0 {
+		return signingKey{}, ErrNoSigningKey
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].activeAt.After(now) {
+			return keys[i], nil
+		}
+	}
+	return keys[0], nil
+}
+
+func (k *keySet) find(kid string) (signingKey, bool) {
+	for _, force := range []bool{false, true} {
+		for _, key := range k.current(force) {
+			if key.kid == kid {
+				return key, true
+			}
+		}
+	}
+	return signingKey{}, false
+}
+
+func (k *keySet) jwks() model.JWKSet {
+	set := model.JWKSet{Keys: []model.JWK{}}
+	for _, key := range k.current(false) {
+		set.Keys = append(set.Keys, publicJWK(key))
+	}
+	return set
+}
+
+// RotateSigningKey writes a new signing key of the algorithm to the directory and deletes keys retired for longer
+// than retain. The new key becomes active one reload interval later, so every running server publishes it before
+// the first token it signs turns up. A key is retired when the next one becomes active, tokens it signed stay
+// valid for at most the access token lifetime after that.
+func RotateSigningKey(dir, algorithm string, retain time.Duration) (string, []string, error) {
+	keys, err := loadSigningKeys(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// kids only hold seconds, a second rotation within the same second must still activate last
+	activeAt := time.Now().Add(keyReloadInterval)
+	if len(keys) > 0 && !activeAt.Truncate(time.Second).After(keys[len(keys)-1].activeAt) {
+		activeAt = keys[len(keys)-1].activeAt.Add(time.Second)
+	}
+
+	kid, err := writeSigningKey(dir, algorithm, activeAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if keys, err = loadSigningKeys(dir); err != nil {
+		return kid, nil, err
+	}
+
+	var removed []string
+	for i := 0; i < len(keys)-1; i++ {
+		if time.Since(keys[i+1].activeAt) <= retain {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, keys[i].kid+".pem")); err != nil {
+			return kid, removed, fmt.Errorf("failed to remove signing key %s: %w", keys[i].kid, err)
+		}
+		removed = append(removed, keys[i].kid)
+	}
+
+	return kid, removed, nil
+}
+
+// writeSigningKey generates a key active from activeAt and stores it as a PKCS#8 PEM file named after its kid.
+func writeSigningKey(dir, algorithm string, activeAt time.Time) (string, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("unsupported token signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate key id: %w", err)
+	}
+	kid := activeAt.UTC().Format(kidTimeLayout) + "-" + hex.EncodeToString(suffix)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	// the key is written under a temporary name first so a server reloading meanwhile never reads half of it
+	path := filepath.Join(dir, kid+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write signing key: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return "", fmt.Errorf("failed to write signing key: %w", err)
+	}
+
+	return kid, nil
+}
+
+// loadSigningKeys reads every key of the directory, ordered by the time they become active.
+func loadSigningKeys(dir string) ([]signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]signingKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := readSigningKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	// file names only sort by time for generated kids, keys added by hand go by their modification time
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].activeAt.Equal(keys[j].activeAt) {
+			return keys[i].kid < keys[j].kid
+		}
+		return keys[i].activeAt.Before(keys[j].activeAt)
+	})
+
+	return keys, nil
+}
+
+func readSigningKey(path string) (signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return signingKey{}, fmt.Errorf("signing key %s is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("failed to parse signing key %s: %w", path, err)
+	}
+
+	key := signingKey{kid: strings.TrimSuffix(filepath.Base(path), ".pem"), activeAt: info.ModTime()}
+	if activeAt, err := time.Parse(kidTimeLayout, strings.SplitN(key.kid, "-", 2)[0]); err == nil {
+		key.activeAt = activeAt
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private = jwt.SigningMethodRS256, private
+	case ed25519.PrivateKey:
+		key.method, key.private = jwt.SigningMethodEdDSA, private
+	default:
+		return signingKey{}, fmt.Errorf("signing key %s is neither RSA nor Ed25519", path)
+	}
+
+	return key, nil
+}
+
+func publicJWK(key signingKey) model.JWK {
+	jwk := model.JWK{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+
+	switch public := key.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
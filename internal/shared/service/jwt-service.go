@@ -12,26 +12,44 @@ import (
 )
 
 type JwtService interface {
-	CreateToken(user entity.User) (dto.AuthResponseDto, error)
+	CreateToken(user entity.User, sessionId string) (dto.AuthResponseDto, error)
 	ValidateToken(tokenString string) (*model.Claim, error)
+	// JWKS returns the public keys tokens can be verified with. It is empty with HS256, whose secret can't be
+	// published.
+	JWKS() model.JWKSet
 }
+
+// jwtService signs with TOKEN_SECRET under HS256. With RS256 or EdDSA it signs with the newest key of the key
+// directory and names it in the kid header, older keys still verify.
 type jwtService struct {
 	cfgToken config.TokenConfig
+	keys     *keySet
 }
 
-func (j *jwtService) CreateToken(user entity.User) (dto.AuthResponseDto, error) {
+func (j *jwtService) CreateToken(user entity.User, sessionId string) (dto.AuthResponseDto, error) {
 	claims := model.Claim{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.cfgToken.IssuerName,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.cfgToken.JwtExpiresTime)),
 		},
-		UserId: user.Id_user,
-		Role:   user.Role,
+		UserId:    user.Id_user,
+		Role:      user.Role,
+		SessionId: sessionId,
 	}
 
-	token := jwt.NewWithClaims(j.cfgToken.JwtSigningMethod, claims)
-	ss, err := token.SignedString(j.cfgToken.JwtSignatureKy)
+	var ss string
+	var err error
+	if j.keys == nil {
+		ss, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.cfgToken.JwtSignatureKy)
+	} else {
+		var key signingKey
+		if key, err = j.keys.active(); err == nil {
+			token := jwt.NewWithClaims(key.method, claims)
+			token.Header["kid"] = key.kid
+			ss, err = token.SignedString(key.private)
+		}
+	}
 	if err != nil {
 		return dto.AuthResponseDto{}, fmt.Errorf("failed to create token: %v", err)
 	}
@@ -40,9 +58,7 @@ func (j *jwtService) CreateToken(user entity.User) (dto.AuthResponseDto, error)
 }
 
 func (j *jwtService) ValidateToken(tokenString string) (*model.Claim, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &model.Claim{}, func(token *jwt.Token) (interface{}, error) {
-		return j.cfgToken.JwtSignatureKy, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &model.Claim{}, j.verificationKey, jwt.WithValidMethods(j.validMethods()))
 
 	if err != nil {
 		return nil, fmt.Errorf("unauthorized : %v", err)
@@ -56,6 +72,50 @@ func (j *jwtService) ValidateToken(tokenString string) (*model.Claim, error) {
 	return claim, nil
 }
 
-func NewJwtService(cfgToken config.TokenConfig) JwtService {
-	return &jwtService{cfgToken: cfgToken}
+func (j *jwtService) JWKS() model.JWKSet {
+	if j.keys == nil {
+		return model.JWKSet{Keys: []model.JWK{}}
+	}
+	return j.keys.jwks()
+}
+
+// verificationKey picks the key named by the kid header. The algorithm of the token must be the one of that key,
+// so a token can't pick a weaker check than its key was made for.
+func (j *jwtService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if j.keys == nil {
+		return j.cfgToken.JwtSignatureKy, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := j.keys.find(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("signing key %q doesn't use %s", kid, token.Method.Alg())
+	}
+
+	return key.private.Public(), nil
+}
+
+func (j *jwtService) validMethods() []string {
+	if j.keys == nil {
+		return []string{AlgorithmHS256}
+	}
+	return []string{AlgorithmRS256, AlgorithmEdDSA}
+}
+
+// NewJwtService loads the signing keys of RS256 and EdDSA. When the key directory holds no key yet, the first one
+// is created.
+func NewJwtService(cfgToken config.TokenConfig) (JwtService, error) {
+	if cfgToken.JwtAlgorithm == AlgorithmHS256 {
+		return &jwtService{cfgToken: cfgToken}, nil
+	}
+
+	keys, err := newKeySet(cfgToken.JwtKeysDir, cfgToken.JwtAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token signing keys: %w", err)
+	}
+
+	return &jwtService{cfgToken: cfgToken, keys: keys}, nil
 }
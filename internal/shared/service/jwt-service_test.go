@@ -0,0 +1,202 @@
+package service
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testUser = entity.User{Id_user: "user-1", Role: entity.RoleAdmin}
+
+func newTestJwtService(t *testing.T, algorithm, dir string) *jwtService {
+	t.Helper()
+	service, err := NewJwtService(config.TokenConfig{
+		IssuerName:     "test",
+		JwtSignatureKy: []byte("secret"),
+		JwtAlgorithm:   algorithm,
+		JwtKeysDir:     dir,
+		JwtExpiresTime: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.(*jwtService)
+}
+
+func createTestToken(t *testing.T, service *jwtService) string {
+	t.Helper()
+	res, err := service.CreateToken(testUser, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.Token
+}
+
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestJwtService_HS256(t *testing.T) {
+	service := newTestJwtService(t, AlgorithmHS256, "")
+
+	claims, err := service.ValidateToken(createTestToken(t, service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserId != "user-1" || claims.SessionId != "session-1" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if keys := service.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("HS256 published %d keys", len(keys))
+	}
+}
+
+func TestJwtService_RS256CreatesFirstKey(t *testing.T) {
+	service := newTestJwtService(t, AlgorithmRS256, t.TempDir())
+
+	token := createTestToken(t, service)
+	if _, err := service.ValidateToken(token); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := service.JWKS().Keys
+	if len(keys) != 1 {
+		t.Fatalf("published %d keys", len(keys))
+	}
+	if keys[0].Kty != "RSA" || keys[0].Alg != AlgorithmRS256 || keys[0].Kid != tokenKid(t, token) || keys[0].N == "" {
+		t.Errorf("unexpected jwk %+v", keys[0])
+	}
+}
+
+func TestJwtService_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := writeSigningKey(dir, AlgorithmRS256, time.Now().Add(-3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	service := newTestJwtService(t, AlgorithmRS256, dir)
+	removedToken := createTestToken(t, service)
+
+	// retired two hours ago by this key, longer than it is kept
+	if _, err := writeSigningKey(dir, AlgorithmRS256, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	service.keys.loadedAt = time.Time{}
+	retiredToken := createTestToken(t, service)
+
+	kid, removed, err := RotateSigningKey(dir, AlgorithmEdDSA, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != tokenKid(t, removedToken) {
+		t.Fatalf("removed %v", removed)
+	}
+	service.keys.loadedAt = time.Time{}
+
+	// the new key is published right away but only signs once every server had a chance to load it
+	newToken := createTestToken(t, service)
+	if tokenKid(t, newToken) != tokenKid(t, retiredToken) {
+		t.Fatal("rotated key signed before it was published")
+	}
+	active, err := activeKey(service.keys.current(false), time.Now().Add(keyReloadInterval+time.Second))
+	if err != nil || active.kid != kid {
+		t.Fatalf("rotated key isn't active after the reload interval: %v %v", active.kid, err)
+	}
+	if _, err := service.ValidateToken(newToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ValidateToken(retiredToken); err != nil {
+		t.Errorf("token of the retired key was rejected: %v", err)
+	}
+	if _, err := service.ValidateToken(removedToken); err == nil {
+		t.Error("token of a removed key was accepted")
+	}
+	if keys := service.JWKS().Keys; len(keys) != 2 || keys[1].Kty != "OKP" || keys[1].Crv != "Ed25519" {
+		t.Errorf("unexpected key set %+v", keys)
+	}
+}
+
+func TestRotateSigningKey_SameSecond(t *testing.T) {
+	dir := t.TempDir()
+	first, _, err := RotateSigningKey(dir, AlgorithmEdDSA, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := RotateSigningKey(dir, AlgorithmEdDSA, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := loadSigningKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].kid != first || keys[1].kid != second {
+		t.Errorf("keys out of order: %v then %v", first, second)
+	}
+}
+
+func TestLoadSigningKeys_OrdersByActivation(t *testing.T) {
+	dir := t.TempDir()
+	generated, err := writeSigningKey(dir, AlgorithmEdDSA, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a key added by hand sorts after the generated one by name, but is older
+	old, err := writeSigningKey(dir, AlgorithmEdDSA, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manual := filepath.Join(dir, "manual.pem")
+	if err := os.Rename(filepath.Join(dir, old+".pem"), manual); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(manual, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := loadSigningKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].kid != "manual" || keys[1].kid != generated {
+		t.Errorf("keys out of order: %v then %v", keys[0].kid, keys[1].kid)
+	}
+}
+
+func TestJwtService_RejectsAlgorithmConfusion(t *testing.T) {
+	service := newTestJwtService(t, AlgorithmRS256, t.TempDir())
+	key, err := service.keys.active()
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userId": "user-1", "role": entity.RoleAdmin})
+	forged.Header["kid"] = key.kid
+	token, err := forged.SignedString(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.ValidateToken(token); err == nil {
+		t.Error("HS256 token signed with the public key was accepted")
+	}
+}
@@ -0,0 +1,20 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SignRequest returns the hex HMAC-SHA256 signature of a request made with an api key. The signed string is the
+// method, the request uri with its query, the unix timestamp, the nonce and the hex SHA-256 of the body, joined
+// by newlines.
+func SignRequest(secret, method, uri, timestamp, nonce string, body []byte) string {
+	bodySum := sha256.Sum256(body)
+	canonical := strings.Join([]string{strings.ToUpper(method), uri, timestamp, nonce, hex.EncodeToString(bodySum[:])}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// The secret and codes are the SHA1 test vectors of RFC 6238, truncated to 6 digits.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, c := range cases {
+		step, ok := ValidateTOTP(rfcSecret, c.code, time.Unix(c.unix, 0))
+		if !ok {
+			t.Errorf("code %s at %d was rejected", c.code, c.unix)
+		}
+		if step != c.unix/totpPeriod {
+			t.Errorf("code %s at %d matched step %d", c.code, c.unix, step)
+		}
+	}
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	if _, ok := ValidateTOTP(rfcSecret, "287082", time.Unix(59+totpPeriod, 0)); !ok {
+		t.Error("code of the previous step was rejected")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, "287082", time.Unix(59+3*totpPeriod, 0)); ok {
+		t.Error("code of an old step was accepted")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, "28708", time.Unix(59, 0)); ok {
+		t.Error("short code was accepted")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret %q is not 160 bits", secret)
+	}
+}
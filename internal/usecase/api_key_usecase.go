@@ -0,0 +1,224 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/shared/service"
+)
+
+var (
+	ErrAPIKeysDisabled    = errors.New("api keys are not configured")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrInvalidAllowedIP   = errors.New("invalid allowed ip")
+	ErrInvalidAPIKey      = errors.New("invalid or revoked api key")
+	ErrInvalidSignature   = errors.New("invalid request signature")
+	ErrAPIKeyIPNotAllowed = errors.New("api key is not allowed from this ip")
+)
+
+const (
+	apiKeyPrefix    = "pk_"
+	apiSecretPrefix = "sk_"
+	maxNonceLength  = 64
+)
+
+type APIKeyUseCase interface {
+	Create(idMerchant string, payload entity.APIKeyRequest, actor entity.AuditActor) (entity.APIKeyCreated, error)
+	List(idMerchant string) ([]entity.APIKey, error)
+	Revoke(idMerchant, id string, actor entity.AuditActor) error
+	Authenticate(req entity.SignedRequest) (entity.APIKey, error)
+}
+
+type apiKeyUseCase struct {
+	repo  repository.APIKeyRepository
+	audit AuditUseCase
+	cfg   config.APIKeyConfig
+	log   *logger.Logger
+}
+
+// Create issues a key for a merchant, owned by the user of actor. The signing secret is derived from the server
+// secret and only its hash is stored, so this is the one time it can be shown.
+func (a *apiKeyUseCase) Create(idMerchant string, payload entity.APIKeyRequest, actor entity.AuditActor) (entity.APIKeyCreated, error) {
+	a.log.Info("Starting to create an api key in the usecase layer", idMerchant)
+
+	if len(a.cfg.APIKeySecret) == 0 {
+		return entity.APIKeyCreated{}, ErrAPIKeysDisabled
+	}
+
+	allowed := make([]string, 0, len(payload.AllowedIPs))
+	for _, ip := range payload.AllowedIPs {
+		ip = strings.TrimSpace(ip)
+		if !validAllowedIP(ip) {
+			return entity.APIKeyCreated{}, fmt.Errorf("%w: %q", ErrInvalidAllowedIP, ip)
+		}
+		allowed = append(allowed, ip)
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return entity.APIKeyCreated{}, fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	id := apiKeyPrefix + hex.EncodeToString(buf)
+	secret := a.signingSecret(id)
+
+	key, err := a.repo.Create(entity.APIKey{
+		Id:         id,
+		IdMerchant: idMerchant,
+		IdUser:     actor.IdUser,
+		Name:       strings.TrimSpace(payload.Name),
+		SecretHash: hashToken(secret),
+		AllowedIPs: allowed,
+	})
+	if err != nil {
+		return entity.APIKeyCreated{}, err
+	}
+
+	a.log.Info("Api key created", key.Id)
+	a.audit.Record(actor, entity.AuditCreate, entity.AuditAPIKey, key.Id, nil, key)
+	return entity.APIKeyCreated{APIKey: key, Secret: secret}, nil
+}
+
+func (a *apiKeyUseCase) List(idMerchant string) ([]entity.APIKey, error) {
+	return a.repo.List(idMerchant)
+}
+
+func (a *apiKeyUseCase) Revoke(idMerchant, id string, actor entity.AuditActor) error {
+	a.log.Info("Starting to revoke an api key in the usecase layer", id)
+
+	key, err := a.repo.Get(id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && key.IdMerchant != idMerchant) {
+		return ErrAPIKeyNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	err = a.repo.Revoke(idMerchant, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	} else if err != nil {
+		return err
+	}
+
+	revoked := key
+	revokedAt := time.Now()
+	revoked.RevokedAt = &revokedAt
+	a.audit.Record(actor, entity.AuditRevoke, entity.AuditAPIKey, id, key, revoked)
+	return nil
+}
+
+// Authenticate checks a signed request: an active key, a signature over the request made with its secret, a
+// timestamp within the allowed clock skew, an allowed client ip and a nonce the key didn't use yet.
+func (a *apiKeyUseCase) Authenticate(req entity.SignedRequest) (entity.APIKey, error) {
+	if len(a.cfg.APIKeySecret) == 0 {
+		return entity.APIKey{}, ErrAPIKeysDisabled
+	}
+
+	key, err := a.repo.Get(req.KeyId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.APIKey{}, ErrInvalidAPIKey
+	} else if err != nil {
+		return entity.APIKey{}, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if key.Revoked() {
+		return entity.APIKey{}, ErrInvalidAPIKey
+	}
+
+	secret := a.signingSecret(key.Id)
+	if !hmac.Equal([]byte(hashToken(secret)), []byte(key.SecretHash)) {
+		a.log.Error("Api key doesn't match the configured secret", key.Id)
+		return entity.APIKey{}, ErrInvalidAPIKey
+	}
+
+	timestamp, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return entity.APIKey{}, fmt.Errorf("%w: invalid timestamp", ErrInvalidSignature)
+	}
+	signedAt := time.Unix(timestamp, 0)
+	if skew := time.Since(signedAt); skew > a.cfg.MaxClockSkew || skew < -a.cfg.MaxClockSkew {
+		return entity.APIKey{}, fmt.Errorf("%w: timestamp outside the allowed window", ErrInvalidSignature)
+	}
+	if req.Nonce == "" || len(req.Nonce) > maxNonceLength {
+		return entity.APIKey{}, fmt.Errorf("%w: invalid nonce", ErrInvalidSignature)
+	}
+
+	expected := service.SignRequest(secret, req.Method, req.URI, req.Timestamp, req.Nonce, req.Body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(req.Signature))) {
+		return entity.APIKey{}, ErrInvalidSignature
+	}
+
+	if !ipAllowed(key.AllowedIPs, req.ClientIP) {
+		a.log.Error("Security event: api key used from a disallowed ip", map[string]interface{}{"id_key": key.Id, "ip": req.ClientIP})
+		return entity.APIKey{}, ErrAPIKeyIPNotAllowed
+	}
+
+	fresh, err := a.repo.UseNonce(key.Id, req.Nonce, signedAt.Add(a.cfg.MaxClockSkew))
+	if err != nil {
+		return entity.APIKey{}, fmt.Errorf("failed to record nonce: %w", err)
+	}
+	if !fresh {
+		a.log.Error("Security event: signed api request replayed", map[string]interface{}{"id_key": key.Id, "ip": req.ClientIP})
+		return entity.APIKey{}, fmt.Errorf("%w: nonce already used", ErrInvalidSignature)
+	}
+
+	if err := a.repo.Touch(key.Id); err != nil {
+		a.log.Error("Failed to record the api key use: ", err)
+	}
+
+	return key, nil
+}
+
+// signingSecret derives the secret of a key from the server secret, so the database never holds it.
+func (a *apiKeyUseCase) signingSecret(id string) string {
+	mac := hmac.New(sha256.New, a.cfg.APIKeySecret)
+	mac.Write([]byte(id))
+	return apiSecretPrefix + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func validAllowedIP(value string) bool {
+	if net.ParseIP(value) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(value)
+	return err == nil
+}
+
+// ipAllowed accepts any client ip when the list is empty.
+func ipAllowed(allowed []string, clientIP string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	for _, value := range allowed {
+		if allowedIP := net.ParseIP(value); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(value); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func NewAPIKeyUseCase(repo repository.APIKeyRepository, audit AuditUseCase, cfg config.APIKeyConfig, log *logger.Logger) APIKeyUseCase {
+	return &apiKeyUseCase{repo: repo, audit: audit, cfg: cfg, log: log}
+}
@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"database/sql"
+	"strconv"
+	"testing"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/shared/service"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type apiKeyUsecaseSuite struct {
+	suite.Suite
+	repo          *repositorymock.MockAPIKeyRepository
+	audit         *usecase_mock.AuditUseCaseMock
+	apiKeyUsecase APIKeyUseCase
+	log           logger.Logger
+}
+
+func (a *apiKeyUsecaseSuite) SetupTest() {
+	a.repo = new(repositorymock.MockAPIKeyRepository)
+	a.audit = new(usecase_mock.AuditUseCaseMock)
+	a.audit.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	a.log = logger.NewLogger()
+	a.apiKeyUsecase = NewAPIKeyUseCase(a.repo, a.audit, config.APIKeyConfig{APIKeySecret: []byte("server-secret"), MaxClockSkew: 5 * time.Minute}, &a.log)
+}
+
+func TestAPIKeyUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(apiKeyUsecaseSuite))
+}
+
+// createKey issues a key through the usecase and returns it as stored, together with its secret.
+func (a *apiKeyUsecaseSuite) createKey(allowedIPs ...string) (entity.APIKey, string) {
+	var stored entity.APIKey
+	a.repo.On("Create", mock.AnythingOfType("entity.APIKey")).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(entity.APIKey)
+	}).Return(entity.APIKey{}, nil).Once()
+
+	created, err := a.apiKeyUsecase.Create("merchant-1", entity.APIKeyRequest{Name: "partner", AllowedIPs: allowedIPs}, entity.AuditActor{IdUser: "user-1"})
+	a.Require().NoError(err)
+	return stored, created.Secret
+}
+
+func (a *apiKeyUsecaseSuite) signedRequest(key entity.APIKey, secret string, at time.Time, nonce string) entity.SignedRequest {
+	body := []byte(`{"customerName":"budi"}`)
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return entity.SignedRequest{
+		KeyId:     key.Id,
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		Signature: service.SignRequest(secret, "POST", "/api/v1/transaction", timestamp, nonce, body),
+		Method:    "POST",
+		URI:       "/api/v1/transaction",
+		Body:      body,
+		ClientIP:  "10.0.0.7",
+	}
+}
+
+func (a *apiKeyUsecaseSuite) TestCreate_StoresOnlyTheSecretHash() {
+	stored, secret := a.createKey("10.0.0.0/24")
+
+	a.Regexp(`^pk_[0-9a-f]{24}$`, stored.Id)
+	a.Regexp(`^sk_`, secret)
+	a.Equal(hashToken(secret), stored.SecretHash)
+	a.NotContains(stored.SecretHash, secret)
+	a.Equal([]string{"10.0.0.0/24"}, stored.AllowedIPs)
+	a.Equal("user-1", stored.IdUser)
+	a.audit.AssertCalled(a.T(), "Record", entity.AuditActor{IdUser: "user-1"}, entity.AuditCreate, entity.AuditAPIKey, mock.Anything, nil, mock.Anything)
+}
+
+func (a *apiKeyUsecaseSuite) TestCreate_InvalidAllowedIP() {
+	_, err := a.apiKeyUsecase.Create("merchant-1", entity.APIKeyRequest{Name: "partner", AllowedIPs: []string{"not-an-ip"}}, entity.AuditActor{IdUser: "user-1"})
+
+	a.ErrorIs(err, ErrInvalidAllowedIP)
+	a.repo.AssertNotCalled(a.T(), "Create", mock.Anything)
+}
+
+func (a *apiKeyUsecaseSuite) TestCreate_DisabledWithoutServerSecret() {
+	a.apiKeyUsecase = NewAPIKeyUseCase(a.repo, a.audit, config.APIKeyConfig{MaxClockSkew: 5 * time.Minute}, &a.log)
+
+	_, err := a.apiKeyUsecase.Create("merchant-1", entity.APIKeyRequest{Name: "partner"}, entity.AuditActor{IdUser: "user-1"})
+
+	a.ErrorIs(err, ErrAPIKeysDisabled)
+}
+
+func (a *apiKeyUsecaseSuite) TestAuthenticate_Success() {
+	key, secret := a.createKey("10.0.0.0/24")
+	a.repo.On("Get", key.Id).Return(key, nil)
+	a.repo.On("UseNonce", key.Id, "nonce-1", mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+	a.repo.On("Touch", key.Id).Return(nil).Once()
+
+	authenticated, err := a.apiKeyUsecase.Authenticate(a.signedRequest(key, secret, time.Now(), "nonce-1"))
+
+	a.NoError(err)
+	a.Equal("merchant-1", authenticated.IdMerchant)
+	a.Equal("user-1", authenticated.IdUser)
+	a.repo.AssertExpectations(a.T())
+}
+
+func (a *apiKeyUsecaseSuite) TestAuthenticate_TamperedBody() {
+	key, secret := a.createKey()
+	a.repo.On("Get", key.Id).Return(key, nil)
+
+	req := a.signedRequest(key, secret, time.Now(), "nonce-1")
+	req.Body = []byte(`{"customerName":"mallory"}`)
+	_, err := a.apiKeyUsecase.Authenticate(req)
+
+	a.ErrorIs(err, ErrInvalidSignature)
+	a.repo.AssertNotCalled(a.T(), "UseNonce", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (a *apiKeyUsecaseSuite) TestAuthenticate_ExpiredTimestamp() {
+	key, secret := a.createKey()
+	a.repo.On("Get", key.Id).Return(key, nil)
+
+	_, err := a.apiKeyUsecase.Authenticate(a.signedRequest(key, secret, time.Now().Add(-10*time.Minute), "nonce-1"))
+
+	a.ErrorIs(err, ErrInvalidSignature)
+}
+
+func (a *apiKeyUsecaseSuite) TestAuthenticate_ReplayedNonce() {
+	key, secret := a.createKey()
+	a.repo.On("Get", key.Id).Return(key, nil)
+	a.repo.On("UseNonce", key.Id, "nonce-1", mock.AnythingOfType("time.Time")).Return(false, nil).Once()
+
+	_, err := a.apiKeyUsecase.Authenticate(a.signedRequest(key, secret, time.Now(), "nonce-1"))
+
+	a.ErrorIs(err, ErrInvalidSignature)
+	a.repo.AssertNotCalled(a.T(), "Touch", mock.Anything)
+}
+
+func (a *apiKeyUsecaseSuite) TestAuthenticate_IPNotAllowed() {
+	key, secret := a.createKey("192.168.1.10")
+	a.repo.On("Get", key.Id).Return(key, nil)
+
+	_, err := a.apiKeyUsecase.Authenticate(a.signedRequest(key, secret, time.Now(), "nonce-1"))
+
+	a.ErrorIs(err, ErrAPIKeyIPNotAllowed)
+}
+
+func (a *apiKeyUsecaseSuite) TestAuthenticate_RevokedKey() {
+	key, secret := a.createKey()
+	revokedAt := time.Now()
+	key.RevokedAt = &revokedAt
+	a.repo.On("Get", key.Id).Return(key, nil)
+
+	_, err := a.apiKeyUsecase.Authenticate(a.signedRequest(key, secret, time.Now(), "nonce-1"))
+
+	a.ErrorIs(err, ErrInvalidAPIKey)
+}
+
+func (a *apiKeyUsecaseSuite) TestRevoke_NotFound() {
+	a.repo.On("Get", "pk_unknown").Return(entity.APIKey{}, sql.ErrNoRows)
+
+	err := a.apiKeyUsecase.Revoke("merchant-1", "pk_unknown", entity.AuditActor{})
+
+	a.ErrorIs(err, ErrAPIKeyNotFound)
+	a.repo.AssertNotCalled(a.T(), "Revoke", mock.Anything, mock.Anything)
+}
+
+func (a *apiKeyUsecaseSuite) TestRevoke_OtherMerchantsKey() {
+	a.repo.On("Get", "pk_other").Return(entity.APIKey{Id: "pk_other", IdMerchant: "merchant-2"}, nil)
+
+	err := a.apiKeyUsecase.Revoke("merchant-1", "pk_other", entity.AuditActor{})
+
+	a.ErrorIs(err, ErrAPIKeyNotFound)
+	a.repo.AssertNotCalled(a.T(), "Revoke", mock.Anything, mock.Anything)
+}
+
+func (a *apiKeyUsecaseSuite) TestRevoke_AuditsKey() {
+	key := entity.APIKey{Id: "pk_partner", IdMerchant: "merchant-1", Name: "partner"}
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin}
+	a.repo.On("Get", "pk_partner").Return(key, nil)
+	a.repo.On("Revoke", "merchant-1", "pk_partner").Return(nil)
+
+	err := a.apiKeyUsecase.Revoke("merchant-1", "pk_partner", actor)
+
+	a.NoError(err)
+	a.audit.AssertCalled(a.T(), "Record", actor, entity.AuditRevoke, entity.AuditAPIKey, "pk_partner", key, mock.MatchedBy(func(after entity.APIKey) bool {
+		return after.Id == key.Id && after.RevokedAt != nil
+	}))
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/shared/model"
+)
+
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+type AuditUseCase interface {
+	Record(actor entity.AuditActor, action, entityType, entityId string, before, after any)
+	List(filter entity.AuditFilter) ([]entity.AuditLog, model.Paging, error)
+}
+
+type auditUseCase struct {
+	repo repository.AuditRepository
+	log  *logger.Logger
+}
+
+// Record writes a change to the audit log, before and after are stored as JSON and nil leaves them out. The
+// change already happened, so a failure doesn't undo it; the entry goes to the application log instead.
+func (a *auditUseCase) Record(actor entity.AuditActor, action, entityType, entityId string, before, after any) {
+	entry := auditEntry(actor, action, entityType, entityId)
+
+	var err error
+	if entry.Before, err = auditValue(before); err == nil {
+		entry.After, err = auditValue(after)
+	}
+	if err == nil {
+		err = a.repo.Create(entry)
+	}
+	if err != nil {
+		a.log.Error("Failed to record the audit log: ", map[string]interface{}{"error": err.Error(), "entry": entry})
+	}
+}
+
+func (a *auditUseCase) List(filter entity.AuditFilter) ([]entity.AuditLog, model.Paging, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, model.Paging{}, fmt.Errorf("%w: to is before from", ErrInvalidAuditFilter)
+	}
+
+	filter.Page, filter.Limit = normalizePaging(filter.Page, filter.Limit)
+	entries, total, err := a.repo.List(filter)
+	if err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	return entries, newPaging(filter.Page, filter.Limit, total), nil
+}
+
+// auditEntry starts the audit entry of a change. Changes that move money hand it to their repository, which
+// records it with the state before and after in the same db transaction as the change itself.
+func auditEntry(actor entity.AuditActor, action, entityType, entityId string) entity.AuditLog {
+	return entity.AuditLog{
+		IdUser:     actor.IdUser,
+		Role:       actor.Role,
+		ApiKey:     actor.ApiKey,
+		System:     actor.System,
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		ClientIP:   actor.ClientIP,
+	}
+}
+
+func auditValue(value any) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit value: %w", err)
+	}
+	return raw, nil
+}
+
+func NewAuditUseCase(repo repository.AuditRepository, log *logger.Logger) AuditUseCase {
+	return &auditUseCase{repo: repo, log: log}
+}
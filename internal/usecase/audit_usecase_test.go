@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type auditUsecaseSuite struct {
+	suite.Suite
+	repo         *repositorymock.MockAuditRepository
+	auditUsecase AuditUseCase
+	log          logger.Logger
+}
+
+func (a *auditUsecaseSuite) SetupTest() {
+	a.repo = new(repositorymock.MockAuditRepository)
+	a.log = logger.NewLogger()
+	a.auditUsecase = NewAuditUseCase(a.repo, &a.log)
+}
+
+func TestAuditUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(auditUsecaseSuite))
+}
+
+func (a *auditUsecaseSuite) TestRecord_StoresValuesAsJSON() {
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin, ClientIP: "10.0.0.1"}
+	a.repo.On("Create", entity.AuditLog{
+		IdUser:     "admin-1",
+		Role:       entity.RoleAdmin,
+		Action:     entity.AuditUpdate,
+		EntityType: entity.AuditProduct,
+		EntityId:   "product-1",
+		Before:     []byte(`{"price":1000}`),
+		After:      []byte(`{"price":1500}`),
+		ClientIP:   "10.0.0.1",
+	}).Return(nil).Once()
+
+	a.auditUsecase.Record(actor, entity.AuditUpdate, entity.AuditProduct, "product-1",
+		map[string]int{"price": 1000}, map[string]int{"price": 1500})
+
+	a.repo.AssertExpectations(a.T())
+}
+
+func (a *auditUsecaseSuite) TestRecord_LeavesOutNilValues() {
+	a.repo.On("Create", mock.MatchedBy(func(entry entity.AuditLog) bool {
+		return entry.System == "bootstrap" && entry.Before == nil && string(entry.After) == `{"id":"user-1"}`
+	})).Return(nil).Once()
+
+	a.auditUsecase.Record(entity.SystemActor("bootstrap"), entity.AuditCreate, entity.AuditUser, "user-1", nil, map[string]string{"id": "user-1"})
+
+	a.repo.AssertExpectations(a.T())
+}
+
+func (a *auditUsecaseSuite) TestRecord_FailureDoesNotPanic() {
+	a.repo.On("Create", mock.Anything).Return(errors.New("database down")).Once()
+
+	a.NotPanics(func() {
+		a.auditUsecase.Record(entity.AuditActor{}, entity.AuditDelete, entity.AuditMerchant, "merchant-1", nil, nil)
+	})
+}
+
+func (a *auditUsecaseSuite) TestList_Pages() {
+	from := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	entries := []entity.AuditLog{{Id: 1, Action: entity.AuditCreate, EntityType: entity.AuditTopup, EntityId: "topup-1"}}
+	a.repo.On("List", entity.AuditFilter{EntityType: entity.AuditTopup, From: from, Page: 1, Limit: 10}).Return(entries, 12, nil).Once()
+
+	result, paging, err := a.auditUsecase.List(entity.AuditFilter{EntityType: entity.AuditTopup, From: from})
+
+	a.NoError(err)
+	a.Equal(entries, result)
+	a.Equal(2, paging.TotalPages)
+	a.Equal(12, paging.TotalRows)
+}
+
+func (a *auditUsecaseSuite) TestList_ToBeforeFrom() {
+	from := time.Date(2024, 11, 2, 0, 0, 0, 0, time.UTC)
+
+	_, _, err := a.auditUsecase.List(entity.AuditFilter{From: from, To: from.Add(-time.Hour)})
+
+	a.ErrorIs(err, ErrInvalidAuditFilter)
+	a.repo.AssertNotCalled(a.T(), "List", mock.Anything)
+}
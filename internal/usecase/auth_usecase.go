@@ -1,52 +1,335 @@
 package usecase
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/entity/dto"
 	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
 	"server-pulsa-app/internal/shared/service"
+	"strings"
+	"time"
 )
 
+var (
+	ErrInvalidRefreshToken  = errors.New("invalid refresh token")
+	ErrRegistrationDisabled = errors.New("public registration is disabled")
+	ErrTooManyLoginAttempts = errors.New("too many failed logins, try again later")
+)
+
+// LoginBlockedError is returned while a username or a client ip has to wait before trying to login again.
+type LoginBlockedError struct {
+	Until time.Time
+}
+
+func (e *LoginBlockedError) Error() string { return ErrTooManyLoginAttempts.Error() }
+
+func (e *LoginBlockedError) Unwrap() error { return ErrTooManyLoginAttempts }
+
 type AuthUseCase interface {
-	Login(payload dto.AuthRequestDto) (dto.AuthResponseDto, error)
-	Register(payload dto.AuthRequestDto) (entity.User, error)
+	Login(payload dto.AuthRequestDto, clientIP string) (dto.AuthResponseDto, error)
+	Register(payload dto.AuthRequestDto, clientIP string) (entity.User, error)
+	Refresh(refreshToken string) (dto.AuthResponseDto, error)
+	Logout(refreshToken string) error
+	UnlockUser(idUser string, actor entity.AuditActor) error
+	VerifyMFA(payload dto.MFAVerifyRequestDto, clientIP string) (dto.AuthResponseDto, error)
+	EnrollMFA(challengeToken string) (entity.MFAEnrollment, error)
 }
 
 type authUseCase struct {
-	useCase    UserUsecase
-	jwtService service.JwtService
-	log        *logger.Logger
+	useCase     UserUsecase
+	mfaUseCase  MFAUseCase
+	jwtService  service.JwtService
+	sessionRepo repository.AuthSessionRepository
+	loginRepo   repository.LoginAttemptRepository
+	audit       AuditUseCase
+	cfg         config.TokenConfig
+	authCfg     config.AuthConfig
+	log         *logger.Logger
 }
 
-func (a *authUseCase) Login(payload dto.AuthRequestDto) (dto.AuthResponseDto, error) {
+// Login authenticates a user. Failed logins are counted per username and per client ip: every failure of a
+// username doubles the wait before its next attempt, and reaching the maximum locks the username or the ip out.
+func (a *authUseCase) Login(payload dto.AuthRequestDto, clientIP string) (dto.AuthResponseDto, error) {
 	a.log.Info("Starting to authenticate user in the use case layer", nil)
 
-	user, err := a.useCase.FindUserByUsernamePassword(payload.Username, payload.Password)
+	username := loginKey(payload.Username)
+	until, err := a.loginRepo.BlockedUntil(username, clientIP)
 	if err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to check failed logins: %w", err)
+	}
+	if time.Now().Before(until) {
+		return dto.AuthResponseDto{}, &LoginBlockedError{Until: until}
+	}
+
+	user, err := a.useCase.FindUserByUsernamePassword(payload.Username, payload.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		a.log.Error("Failed to authenticate user: ", err)
+		if err := a.recordFailedLogin(username, clientIP); err != nil {
+			return dto.AuthResponseDto{}, err
+		}
+		return dto.AuthResponseDto{}, ErrInvalidCredentials
+	} else if err != nil {
 		a.log.Error("Failed to authenticate user: ", err)
 		return dto.AuthResponseDto{}, err
 	}
 
-	a.log.Info("User has been authenticated successfully", nil)
-	token, err := a.jwtService.CreateToken(user)
+	mfa, err := a.mfaUseCase.Status(user.Id_user)
+	if err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to get mfa status: %w", err)
+	}
+
+	// With a second factor the password only earns a challenge. The failed logins are kept until the second
+	// factor passes too, so wrong codes still lead to a lockout.
+	if mfa.Enabled() || mfa.Required {
+		challenge, err := a.mfaUseCase.NewChallenge(user.Id_user)
+		if err != nil {
+			return dto.AuthResponseDto{}, err
+		}
+
+		a.log.Info("User ID %s has to pass the second factor", user.Id_user)
+		return dto.AuthResponseDto{MFARequired: mfa.Enabled(), MFAEnrollmentRequired: !mfa.Enabled(), ChallengeToken: challenge}, nil
+	}
+
+	return a.completeLogin(user)
+}
+
+// VerifyMFA exchanges a login challenge and a second factor code for the tokens. When the role of the user
+// requires a second factor the user never enrolled, the code confirms the enrollment started with EnrollMFA and
+// the response carries the recovery codes.
+func (a *authUseCase) VerifyMFA(payload dto.MFAVerifyRequestDto, clientIP string) (dto.AuthResponseDto, error) {
+	a.log.Info("Starting to verify a second factor in the use case layer", nil)
+
+	idUser, err := a.mfaUseCase.ChallengeUser(payload.ChallengeToken)
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	user, err := a.useCase.GetUserByID(idUser)
+	if err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	username := loginKey(user.Username)
+	until, err := a.loginRepo.BlockedUntil(username, clientIP)
+	if err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to check failed logins: %w", err)
+	}
+	if time.Now().Before(until) {
+		return dto.AuthResponseDto{}, &LoginBlockedError{Until: until}
+	}
+
+	mfa, err := a.mfaUseCase.Status(idUser)
+	if err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to get mfa status: %w", err)
+	}
+
+	var recoveryCodes []string
+	if mfa.Enabled() {
+		err = a.mfaUseCase.Verify(idUser, payload.Code)
+	} else {
+		recoveryCodes, err = a.mfaUseCase.Confirm(idUser, payload.Code)
+	}
+	if errors.Is(err, ErrInvalidMFACode) {
+		a.log.Error("Failed second factor: ", map[string]interface{}{"id_user": idUser})
+		if err := a.recordFailedLogin(username, clientIP); err != nil {
+			return dto.AuthResponseDto{}, err
+		}
+		return dto.AuthResponseDto{}, ErrInvalidMFACode
+	} else if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	if err := a.mfaUseCase.CloseChallenge(payload.ChallengeToken); err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to close mfa challenge: %w", err)
+	}
+
+	response, err := a.completeLogin(user)
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+// EnrollMFA starts the enrollment of a user who got a login challenge because the role requires a second factor.
+func (a *authUseCase) EnrollMFA(challengeToken string) (entity.MFAEnrollment, error) {
+	a.log.Info("Starting to enroll a second factor during login in the use case layer", nil)
+
+	idUser, err := a.mfaUseCase.ChallengeUser(challengeToken)
+	if err != nil {
+		return entity.MFAEnrollment{}, err
+	}
+
+	return a.mfaUseCase.Enroll(idUser)
+}
+
+// completeLogin starts a session for an authenticated user and issues its tokens.
+func (a *authUseCase) completeLogin(user entity.User) (dto.AuthResponseDto, error) {
+	if err := a.loginRepo.Reset(repository.LoginScopeUsername, loginKey(user.Username)); err != nil {
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+
+	refreshToken, refreshHash, err := newToken()
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	idSession, err := a.sessionRepo.CreateSession(user.Id_user, refreshHash, time.Now().Add(a.cfg.RefreshExpiresTime))
+	if err != nil {
+		a.log.Error("Failed to create session: ", err)
+		return dto.AuthResponseDto{}, err
+	}
+
+	token, err := a.jwtService.CreateToken(user, idSession)
 	if err != nil {
 		a.log.Error("Failed to create token: ", err)
 		return dto.AuthResponseDto{}, err
 	}
 
 	response := dto.AuthResponseDto{
-		Token: token.Token,
+		Token:        token.Token,
+		RefreshToken: refreshToken,
 	}
 
 	a.log.Info("User ID %s has been authenticated successfully", user.Id_user)
 	return response, nil
 }
 
-func (a *authUseCase) Register(payload dto.AuthRequestDto) (entity.User, error) {
+// Refresh exchanges a refresh token for a new access token and a new refresh token. Each refresh token works
+// once; reusing one revokes its session, logging out whoever else holds a token of it.
+func (a *authUseCase) Refresh(refreshToken string) (dto.AuthResponseDto, error) {
+	a.log.Info("Starting to refresh a token in the use case layer", nil)
+
+	newToken, newHash, err := newToken()
+	if err != nil {
+		return dto.AuthResponseDto{}, err
+	}
+
+	session, err := a.sessionRepo.RotateRefreshToken(hashToken(refreshToken), newHash, time.Now().Add(a.cfg.RefreshExpiresTime))
+	switch {
+	case errors.Is(err, repository.ErrRefreshTokenReused):
+		a.log.Error("Security event: refresh token reused, session revoked", map[string]interface{}{"id_session": session.Id, "id_user": session.IdUser})
+		return dto.AuthResponseDto{}, ErrInvalidRefreshToken
+	case errors.Is(err, repository.ErrRefreshTokenNotFound), errors.Is(err, repository.ErrRefreshTokenExpired), errors.Is(err, repository.ErrSessionRevoked):
+		a.log.Error("Refresh token rejected: ", err)
+		return dto.AuthResponseDto{}, ErrInvalidRefreshToken
+	case err != nil:
+		return dto.AuthResponseDto{}, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	user, err := a.useCase.GetUserByID(session.IdUser)
+	if err != nil {
+		a.log.Error("Failed to get the user of the session: ", err)
+		return dto.AuthResponseDto{}, ErrInvalidRefreshToken
+	}
+
+	token, err := a.jwtService.CreateToken(user, session.Id)
+	if err != nil {
+		a.log.Error("Failed to create token: ", err)
+		return dto.AuthResponseDto{}, err
+	}
+
+	return dto.AuthResponseDto{Token: token.Token, RefreshToken: newToken}, nil
+}
+
+// Logout revokes the session of a refresh token, which also invalidates the access tokens issued for it.
+func (a *authUseCase) Logout(refreshToken string) error {
+	a.log.Info("Starting to logout in the use case layer", nil)
+	return a.sessionRepo.RevokeByRefreshToken(hashToken(refreshToken), repository.SessionRevokedLogout)
+}
+
+// UnlockUser lifts the lockout of a user before it expires.
+func (a *authUseCase) UnlockUser(idUser string, actor entity.AuditActor) error {
+	a.log.Info("Starting to unlock a user in the use case layer", idUser)
+
+	user, err := a.useCase.GetUserByID(idUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := a.loginRepo.Reset(repository.LoginScopeUsername, loginKey(user.Username)); err != nil {
+		return err
+	}
+
+	a.audit.Record(actor, entity.AuditUnlock, entity.AuditUser, idUser, nil, map[string]string{"username": user.Username})
+	return nil
+}
+
+func (a *authUseCase) recordFailedLogin(username, clientIP string) error {
+	failures, err := a.loginRepo.RecordFailure(repository.LoginScopeUsername, username, a.authCfg.LockoutDuration)
+	if err != nil {
+		return fmt.Errorf("failed to record failed login: %w", err)
+	}
+	if err := a.loginRepo.Block(repository.LoginScopeUsername, username, time.Now().Add(a.loginDelay(failures))); err != nil {
+		return fmt.Errorf("failed to block login: %w", err)
+	}
+	if failures >= a.authCfg.MaxLoginAttempts {
+		a.log.Error("Security event: username locked out after failed logins", map[string]interface{}{"username": username, "failures": failures})
+	}
+
+	failures, err = a.loginRepo.RecordFailure(repository.LoginScopeIP, clientIP, a.authCfg.LockoutDuration)
+	if err != nil {
+		return fmt.Errorf("failed to record failed login: %w", err)
+	}
+	if failures >= a.authCfg.MaxIPLoginAttempts {
+		a.log.Error("Security event: client ip locked out after failed logins", map[string]interface{}{"ip": clientIP, "failures": failures})
+		if err := a.loginRepo.Block(repository.LoginScopeIP, clientIP, time.Now().Add(a.authCfg.LockoutDuration)); err != nil {
+			return fmt.Errorf("failed to block login: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// loginDelay is how long a username waits after its nth failed login: LoginDelay doubled on every failure, and
+// the whole lockout once the maximum is reached.
+func (a *authUseCase) loginDelay(failures int) time.Duration {
+	if failures >= a.authCfg.MaxLoginAttempts || failures > 30 {
+		return a.authCfg.LockoutDuration
+	}
+	return min(a.authCfg.LoginDelay<<(failures-1), a.authCfg.LockoutDuration)
+}
+
+// loginKey is the username failed logins are counted under, so changing its case doesn't get more attempts.
+func loginKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func (a *authUseCase) Register(payload dto.AuthRequestDto, clientIP string) (entity.User, error) {
 	a.log.Info("Starting to register a new user in the use case layer", nil)
-	return a.useCase.RegisterUser(entity.User{Username: payload.Username, Password: payload.Password})
+	if !a.authCfg.AllowRegistration {
+		return entity.User{}, ErrRegistrationDisabled
+	}
+	return a.useCase.RegisterUser(entity.User{Username: payload.Username, Password: payload.Password}, entity.AuditActor{ClientIP: clientIP})
+}
+
+// newToken returns a random opaque token, for refresh tokens and mfa challenges, and the hash it is stored under.
+func newToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func NewAuthUseCase(uc UserUsecase, jwtService service.JwtService, log *logger.Logger) AuthUseCase {
-	return &authUseCase{useCase: uc, jwtService: jwtService, log: log}
+func NewAuthUseCase(uc UserUsecase, mfaUc MFAUseCase, jwtService service.JwtService, sessionRepo repository.AuthSessionRepository, loginRepo repository.LoginAttemptRepository, audit AuditUseCase, cfg config.TokenConfig, authCfg config.AuthConfig, log *logger.Logger) AuthUseCase {
+	return &authUseCase{useCase: uc, mfaUseCase: mfaUc, jwtService: jwtService, sessionRepo: sessionRepo, loginRepo: loginRepo, audit: audit, cfg: cfg, authCfg: authCfg, log: log}
 }
@@ -2,14 +2,19 @@ package usecase
 
 import (
 	"testing"
+	"time"
 
+	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/entity/dto"
 	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
 	"server-pulsa-app/internal/mock/service_mock"
 	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/repository"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -17,36 +22,179 @@ type AuthUseCaseTestSuite struct {
 	suite.Suite
 	authUC          AuthUseCase
 	mockUserUsecase *usecase_mock.UserUseCaseMock
+	mockMFAUsecase  *usecase_mock.MFAUseCaseMock
 	mockJwtService  *service_mock.JwtServiceMock
+	mockSessionRepo *repositorymock.MockAuthSessionRepository
+	mockLoginRepo   *repositorymock.MockLoginAttemptRepository
+	mockAudit       *usecase_mock.AuditUseCaseMock
 	log             logger.Logger
 }
 
 func (suite *AuthUseCaseTestSuite) SetupTest() {
 	suite.mockUserUsecase = new(usecase_mock.UserUseCaseMock)
+	suite.mockMFAUsecase = new(usecase_mock.MFAUseCaseMock)
 	suite.mockJwtService = new(service_mock.JwtServiceMock)
+	suite.mockSessionRepo = new(repositorymock.MockAuthSessionRepository)
+	suite.mockLoginRepo = new(repositorymock.MockLoginAttemptRepository)
+	suite.mockAudit = new(usecase_mock.AuditUseCaseMock)
 	suite.log = logger.NewLogger()
-	suite.authUC = NewAuthUseCase(suite.mockUserUsecase, suite.mockJwtService, &suite.log)
+	authCfg := config.AuthConfig{
+		AllowRegistration:  true,
+		MaxLoginAttempts:   3,
+		MaxIPLoginAttempts: 10,
+		LoginDelay:         time.Second,
+		LockoutDuration:    15 * time.Minute,
+	}
+	suite.authUC = NewAuthUseCase(suite.mockUserUsecase, suite.mockMFAUsecase, suite.mockJwtService, suite.mockSessionRepo, suite.mockLoginRepo, suite.mockAudit, config.TokenConfig{RefreshExpiresTime: time.Hour}, authCfg, &suite.log)
 }
 
 func (suite *AuthUseCaseTestSuite) TestLogin() {
-	user := entity.User{Username: "testuser", Password: "password"}
+	user := entity.User{Id_user: "user-1", Username: "testuser", Password: "password"}
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(time.Time{}, nil)
 	suite.mockUserUsecase.On("FindUserByUsernamePassword", "testuser", "password").Return(user, nil)
-	suite.mockJwtService.On("CreateToken", user).Return(dto.AuthResponseDto{Token: "mockToken"}, nil)
+	suite.mockMFAUsecase.On("Status", "user-1").Return(entity.UserMFA{IdUser: "user-1"}, nil)
+	suite.mockLoginRepo.On("Reset", repository.LoginScopeUsername, "testuser").Return(nil).Once()
+	suite.mockSessionRepo.On("CreateSession", "user-1", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return("session-1", nil)
+	suite.mockJwtService.On("CreateToken", user, "session-1").Return(dto.AuthResponseDto{Token: "mockToken"}, nil)
 
-	response, err := suite.authUC.Login(dto.AuthRequestDto{Username: "testuser", Password: "password"})
+	response, err := suite.authUC.Login(dto.AuthRequestDto{Username: "testuser", Password: "password"}, "10.0.0.1")
 
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), "mockToken", response.Token)
+	assert.NotEmpty(suite.T(), response.RefreshToken)
+	suite.mockSessionRepo.AssertCalled(suite.T(), "CreateSession", "user-1", hashToken(response.RefreshToken), mock.Anything)
 
 	suite.mockUserUsecase.AssertExpectations(suite.T())
 	suite.mockJwtService.AssertExpectations(suite.T())
 }
 
+func (suite *AuthUseCaseTestSuite) TestLogin_Blocked() {
+	until := time.Now().Add(time.Minute)
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(until, nil)
+
+	_, err := suite.authUC.Login(dto.AuthRequestDto{Username: " TestUser", Password: "password"}, "10.0.0.1")
+
+	var blocked *LoginBlockedError
+	suite.ErrorAs(err, &blocked)
+	suite.ErrorIs(err, ErrTooManyLoginAttempts)
+	suite.Equal(until, blocked.Until)
+	suite.mockUserUsecase.AssertNotCalled(suite.T(), "FindUserByUsernamePassword", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthUseCaseTestSuite) TestLogin_FailureDelaysNextAttempt() {
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(time.Time{}, nil)
+	suite.mockUserUsecase.On("FindUserByUsernamePassword", "testuser", "wrong").Return(entity.User{}, ErrInvalidCredentials)
+	suite.mockLoginRepo.On("RecordFailure", repository.LoginScopeUsername, "testuser", 15*time.Minute).Return(2, nil)
+	suite.mockLoginRepo.On("Block", repository.LoginScopeUsername, "testuser", mock.MatchedBy(func(until time.Time) bool {
+		return time.Until(until) > time.Second && time.Until(until) <= 2*time.Second
+	})).Return(nil).Once()
+	suite.mockLoginRepo.On("RecordFailure", repository.LoginScopeIP, "10.0.0.1", 15*time.Minute).Return(2, nil)
+
+	_, err := suite.authUC.Login(dto.AuthRequestDto{Username: "testuser", Password: "wrong"}, "10.0.0.1")
+
+	suite.ErrorIs(err, ErrInvalidCredentials)
+	suite.mockLoginRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AuthUseCaseTestSuite) TestLogin_LocksOutAtMaxAttempts() {
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(time.Time{}, nil)
+	suite.mockUserUsecase.On("FindUserByUsernamePassword", "testuser", "wrong").Return(entity.User{}, ErrInvalidCredentials)
+	suite.mockLoginRepo.On("RecordFailure", repository.LoginScopeUsername, "testuser", 15*time.Minute).Return(3, nil)
+	suite.mockLoginRepo.On("Block", repository.LoginScopeUsername, "testuser", mock.MatchedBy(func(until time.Time) bool {
+		return time.Until(until) > 14*time.Minute
+	})).Return(nil).Once()
+	suite.mockLoginRepo.On("RecordFailure", repository.LoginScopeIP, "10.0.0.1", 15*time.Minute).Return(10, nil)
+	suite.mockLoginRepo.On("Block", repository.LoginScopeIP, "10.0.0.1", mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+	_, err := suite.authUC.Login(dto.AuthRequestDto{Username: "testuser", Password: "wrong"}, "10.0.0.1")
+
+	suite.ErrorIs(err, ErrInvalidCredentials)
+	suite.mockLoginRepo.AssertExpectations(suite.T())
+}
+
+func (suite *AuthUseCaseTestSuite) TestLogin_MFAIssuesChallenge() {
+	enabledAt := time.Now()
+	user := entity.User{Id_user: "user-1", Username: "testuser"}
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(time.Time{}, nil)
+	suite.mockUserUsecase.On("FindUserByUsernamePassword", "testuser", "password").Return(user, nil)
+	suite.mockMFAUsecase.On("Status", "user-1").Return(entity.UserMFA{IdUser: "user-1", EnabledAt: &enabledAt}, nil)
+	suite.mockMFAUsecase.On("NewChallenge", "user-1").Return("challenge", nil)
+
+	response, err := suite.authUC.Login(dto.AuthRequestDto{Username: "testuser", Password: "password"}, "10.0.0.1")
+
+	suite.NoError(err)
+	suite.Equal(dto.AuthResponseDto{MFARequired: true, ChallengeToken: "challenge"}, response)
+	suite.mockSessionRepo.AssertNotCalled(suite.T(), "CreateSession", mock.Anything, mock.Anything, mock.Anything)
+	suite.mockLoginRepo.AssertNotCalled(suite.T(), "Reset", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthUseCaseTestSuite) TestLogin_RequiredMFAAsksEnrollment() {
+	user := entity.User{Id_user: "user-1", Username: "testuser"}
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(time.Time{}, nil)
+	suite.mockUserUsecase.On("FindUserByUsernamePassword", "testuser", "password").Return(user, nil)
+	suite.mockMFAUsecase.On("Status", "user-1").Return(entity.UserMFA{IdUser: "user-1", Required: true}, nil)
+	suite.mockMFAUsecase.On("NewChallenge", "user-1").Return("challenge", nil)
+
+	response, err := suite.authUC.Login(dto.AuthRequestDto{Username: "testuser", Password: "password"}, "10.0.0.1")
+
+	suite.NoError(err)
+	suite.Equal(dto.AuthResponseDto{MFAEnrollmentRequired: true, ChallengeToken: "challenge"}, response)
+}
+
+func (suite *AuthUseCaseTestSuite) TestVerifyMFA_Success() {
+	enabledAt := time.Now()
+	user := entity.User{Id_user: "user-1", Username: "testuser"}
+	suite.mockMFAUsecase.On("ChallengeUser", "challenge").Return("user-1", nil)
+	suite.mockUserUsecase.On("GetUserByID", "user-1").Return(user, nil)
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(time.Time{}, nil)
+	suite.mockMFAUsecase.On("Status", "user-1").Return(entity.UserMFA{IdUser: "user-1", EnabledAt: &enabledAt}, nil)
+	suite.mockMFAUsecase.On("Verify", "user-1", "123456").Return(nil)
+	suite.mockMFAUsecase.On("CloseChallenge", "challenge").Return(nil).Once()
+	suite.mockLoginRepo.On("Reset", repository.LoginScopeUsername, "testuser").Return(nil).Once()
+	suite.mockSessionRepo.On("CreateSession", "user-1", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return("session-1", nil)
+	suite.mockJwtService.On("CreateToken", user, "session-1").Return(dto.AuthResponseDto{Token: "mockToken"}, nil)
+
+	response, err := suite.authUC.VerifyMFA(dto.MFAVerifyRequestDto{ChallengeToken: "challenge", Code: "123456"}, "10.0.0.1")
+
+	suite.NoError(err)
+	suite.Equal("mockToken", response.Token)
+	suite.mockMFAUsecase.AssertExpectations(suite.T())
+}
+
+func (suite *AuthUseCaseTestSuite) TestVerifyMFA_WrongCodeCountsAsFailedLogin() {
+	enabledAt := time.Now()
+	suite.mockMFAUsecase.On("ChallengeUser", "challenge").Return("user-1", nil)
+	suite.mockUserUsecase.On("GetUserByID", "user-1").Return(entity.User{Id_user: "user-1", Username: "testuser"}, nil)
+	suite.mockLoginRepo.On("BlockedUntil", "testuser", "10.0.0.1").Return(time.Time{}, nil)
+	suite.mockMFAUsecase.On("Status", "user-1").Return(entity.UserMFA{IdUser: "user-1", EnabledAt: &enabledAt}, nil)
+	suite.mockMFAUsecase.On("Verify", "user-1", "000000").Return(ErrInvalidMFACode)
+	suite.mockLoginRepo.On("RecordFailure", repository.LoginScopeUsername, "testuser", 15*time.Minute).Return(1, nil).Once()
+	suite.mockLoginRepo.On("Block", repository.LoginScopeUsername, "testuser", mock.AnythingOfType("time.Time")).Return(nil).Once()
+	suite.mockLoginRepo.On("RecordFailure", repository.LoginScopeIP, "10.0.0.1", 15*time.Minute).Return(1, nil).Once()
+
+	_, err := suite.authUC.VerifyMFA(dto.MFAVerifyRequestDto{ChallengeToken: "challenge", Code: "000000"}, "10.0.0.1")
+
+	suite.ErrorIs(err, ErrInvalidMFACode)
+	suite.mockLoginRepo.AssertExpectations(suite.T())
+	suite.mockMFAUsecase.AssertNotCalled(suite.T(), "CloseChallenge", mock.Anything)
+}
+
+func (suite *AuthUseCaseTestSuite) TestUnlockUser() {
+	suite.mockUserUsecase.On("GetUserByID", "user-1").Return(entity.User{Id_user: "user-1", Username: "TestUser"}, nil)
+	suite.mockLoginRepo.On("Reset", repository.LoginScopeUsername, "testuser").Return(nil).Once()
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin}
+	suite.mockAudit.On("Record", actor, entity.AuditUnlock, entity.AuditUser, "user-1", nil, map[string]string{"username": "TestUser"}).Once()
+
+	suite.NoError(suite.authUC.UnlockUser("user-1", actor))
+	suite.mockLoginRepo.AssertExpectations(suite.T())
+	suite.mockAudit.AssertExpectations(suite.T())
+}
+
 func (suite *AuthUseCaseTestSuite) TestRegister() {
 	user := entity.User{Username: "testuser", Password: "password"}
-	suite.mockUserUsecase.On("RegisterUser", user).Return(user, nil)
+	suite.mockUserUsecase.On("RegisterUser", user, entity.AuditActor{ClientIP: "10.0.0.1"}).Return(user, nil)
 
-	createdUser, err := suite.authUC.Register(dto.AuthRequestDto{Username: "testuser", Password: "password"})
+	createdUser, err := suite.authUC.Register(dto.AuthRequestDto{Username: "testuser", Password: "password"}, "10.0.0.1")
 
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), user.Username, createdUser.Username)
@@ -54,6 +202,49 @@ func (suite *AuthUseCaseTestSuite) TestRegister() {
 	suite.mockUserUsecase.AssertExpectations(suite.T())
 }
 
+func (suite *AuthUseCaseTestSuite) TestRegister_Disabled() {
+	authUC := NewAuthUseCase(suite.mockUserUsecase, suite.mockMFAUsecase, suite.mockJwtService, suite.mockSessionRepo, suite.mockLoginRepo, suite.mockAudit, config.TokenConfig{}, config.AuthConfig{}, &suite.log)
+
+	_, err := authUC.Register(dto.AuthRequestDto{Username: "testuser", Password: "password"}, "10.0.0.1")
+
+	assert.ErrorIs(suite.T(), err, ErrRegistrationDisabled)
+	suite.mockUserUsecase.AssertNotCalled(suite.T(), "RegisterUser", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthUseCaseTestSuite) TestRefresh_RotatesToken() {
+	user := entity.User{Id_user: "user-1", Role: "employee"}
+	suite.mockSessionRepo.On("RotateRefreshToken", hashToken("old-token"), mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+		Return(entity.AuthSession{Id: "session-1", IdUser: "user-1"}, nil)
+	suite.mockUserUsecase.On("GetUserByID", "user-1").Return(user, nil)
+	suite.mockJwtService.On("CreateToken", user, "session-1").Return(dto.AuthResponseDto{Token: "newToken"}, nil)
+
+	response, err := suite.authUC.Refresh("old-token")
+
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "newToken", response.Token)
+	assert.NotEqual(suite.T(), "old-token", response.RefreshToken)
+	suite.mockSessionRepo.AssertCalled(suite.T(), "RotateRefreshToken", hashToken("old-token"), hashToken(response.RefreshToken), mock.Anything)
+}
+
+func (suite *AuthUseCaseTestSuite) TestRefresh_ReusedToken() {
+	suite.mockSessionRepo.On("RotateRefreshToken", hashToken("spent-token"), mock.Anything, mock.Anything).
+		Return(entity.AuthSession{Id: "session-1", IdUser: "user-1"}, repository.ErrRefreshTokenReused)
+
+	_, err := suite.authUC.Refresh("spent-token")
+
+	assert.ErrorIs(suite.T(), err, ErrInvalidRefreshToken)
+	suite.mockJwtService.AssertNotCalled(suite.T(), "CreateToken", mock.Anything, mock.Anything)
+}
+
+func (suite *AuthUseCaseTestSuite) TestLogout_RevokesSession() {
+	suite.mockSessionRepo.On("RevokeByRefreshToken", hashToken("token"), repository.SessionRevokedLogout).Return(nil)
+
+	err := suite.authUC.Logout("token")
+
+	assert.NoError(suite.T(), err)
+	suite.mockSessionRepo.AssertExpectations(suite.T())
+}
+
 func TestAuthUseCaseTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthUseCaseTestSuite))
 }
@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"server-pulsa-app/internal/entity"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const maxBulkTransactionRows = 1000
+
+var ErrInvalidBulkFile = errors.New("invalid bulk transaction file")
+
+var bulkTransactionHeader = []string{"customer_name", "destination_number", "product_id", "status", "transaction_id", "error"}
+
+// BulkCreate runs every row of a CSV or XLSX sheet through CreateIdempotent. The sheet starts with a
+// header row followed by customer name, destination number and product id columns; template supplies
+// the merchant, user and date shared by all rows, and the merchant must be one the user owns. Every row
+// is keyed by the file hash, the date and its line, so uploading the same sheet again for the same date
+// replays the rows that were already sold. A failing row never stops the rows after it.
+func (u *transactionUseCase) BulkCreate(template entity.Transactions, filename string, file io.Reader, actor entity.AuditActor) (entity.BulkTransactionResult, error) {
+	u.log.Info("Starting to create bulk transactions in the usecase layer", filename)
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return entity.BulkTransactionResult{}, fmt.Errorf("%w: %v", ErrInvalidBulkFile, err)
+	}
+	sum := sha256.Sum256(content)
+	fileHash := hex.EncodeToString(sum[:])
+
+	format := strings.ToLower(filepath.Ext(filename))
+	records, err := readBulkTransactionFile(format, bytes.NewReader(content))
+	if err != nil {
+		return entity.BulkTransactionResult{}, err
+	}
+
+	if template.MerchantId, err = u.merchants.MerchantForUser(template.UserId, template.MerchantId); err != nil {
+		return entity.BulkTransactionResult{}, err
+	}
+
+	result := entity.BulkTransactionResult{Rows: make([]entity.BulkTransactionRow, 0, len(records))}
+	for i, record := range records {
+		row := entity.BulkTransactionRow{
+			Line:              i + 2,
+			CustomerName:      column(record, 0),
+			DestinationNumber: column(record, 1),
+			ProductId:         column(record, 2),
+		}
+
+		if row.CustomerName == "" && row.DestinationNumber == "" && row.ProductId == "" {
+			continue
+		}
+
+		transaction, err := u.createBulkRow(template, fileHash, row, actor)
+		if err != nil {
+			row.Status, row.Error = entity.BulkRowFailed, err.Error()
+			result.Failed++
+		} else {
+			row.Status, row.TransactionId = entity.BulkRowSuccess, transaction.TransactionsId
+			result.Succeeded++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	result.FileName = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + "_result" + format
+	result.File, result.ContentType, err = writeBulkTransactionResult(format, result.Rows)
+	if err != nil {
+		u.log.Error("Failed to write bulk transaction result", err)
+		return entity.BulkTransactionResult{}, err
+	}
+
+	u.log.Info("Bulk transactions processed", map[string]int{"succeeded": result.Succeeded, "failed": result.Failed})
+	return result, nil
+}
+
+func (u *transactionUseCase) createBulkRow(template entity.Transactions, fileHash string, row entity.BulkTransactionRow, actor entity.AuditActor) (entity.Transactions, error) {
+	switch {
+	case row.CustomerName == "":
+		return entity.Transactions{}, fmt.Errorf("customer name is required")
+	case row.DestinationNumber == "":
+		return entity.Transactions{}, fmt.Errorf("destination number is required")
+	case row.ProductId == "":
+		return entity.Transactions{}, fmt.Errorf("product id is required")
+	}
+
+	payload := template
+	payload.CustomerName = row.CustomerName
+	payload.DestinationNumber = row.DestinationNumber
+	payload.TransactionDetail = []entity.TransactionDetail{{ProductId: row.ProductId}}
+
+	key := entity.IdempotencyKey{
+		Key:   fmt.Sprintf("%s:%s:%d", fileHash, template.TransactionDate, row.Line),
+		Scope: "bulk:" + template.MerchantId,
+	}
+	transaction, _, err := u.CreateIdempotent(payload, key, actor)
+	return transaction, err
+}
+
+func column(record []string, index int) string {
+	if index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+// readBulkTransactionFile returns the data rows of the sheet, without its header row.
+func readBulkTransactionFile(format string, file io.Reader) ([][]string, error) {
+	var (
+		records [][]string
+		err     error
+	)
+
+	switch format {
+	case ".csv":
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err = reader.ReadAll()
+	case ".xlsx":
+		var f *excelize.File
+		if f, err = excelize.OpenReader(file); err == nil {
+			defer f.Close()
+			records, err = f.GetRows(f.GetSheetName(0))
+		}
+	default:
+		return nil, fmt.Errorf("%w: only .csv and .xlsx files are supported", ErrInvalidBulkFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBulkFile, err)
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%w: the file has no rows below the header", ErrInvalidBulkFile)
+	}
+	if len(records)-1 > maxBulkTransactionRows {
+		return nil, fmt.Errorf("%w: at most %d rows can be uploaded at once", ErrInvalidBulkFile, maxBulkTransactionRows)
+	}
+
+	return records[1:], nil
+}
+
+func bulkResultRecord(row entity.BulkTransactionRow) []string {
+	return []string{row.CustomerName, row.DestinationNumber, row.ProductId, row.Status, row.TransactionId, row.Error}
+}
+
+func writeBulkTransactionResult(format string, rows []entity.BulkTransactionRow) ([]byte, string, error) {
+	var buf bytes.Buffer
+
+	if format == ".csv" {
+		writer := csv.NewWriter(&buf)
+		writer.Write(bulkTransactionHeader)
+		for _, row := range rows {
+			writer.Write(bulkResultRecord(row))
+		}
+		writer.Flush()
+		return buf.Bytes(), "text/csv", writer.Error()
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := f.GetSheetName(0)
+	if err := f.SetSheetRow(sheet, "A1", &bulkTransactionHeader); err != nil {
+		return nil, "", err
+	}
+	for i, row := range rows {
+		record := bulkResultRecord(row)
+		if err := f.SetSheetRow(sheet, "A"+strconv.Itoa(i+2), &record); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if _, err := f.WriteTo(&buf); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", nil
+}
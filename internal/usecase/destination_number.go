@@ -0,0 +1,136 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"server-pulsa-app/internal/entity"
+)
+
+var (
+	ErrInvalidDestinationNumber = errors.New("invalid destination number")
+	ErrUnknownOperator          = errors.New("operator of the destination number is unknown")
+	ErrOperatorMismatch         = errors.New("product provider does not match the destination operator")
+	ErrInvalidOperator          = errors.New("unknown operator name")
+)
+
+// operatorAliases lists, per canonical operator name stored with the prefixes, the names the operator and its
+// prepaid brands go by, lowercased and without spaces or punctuation.
+var operatorAliases = map[string][]string{
+	"Telkomsel": {"telkomsel", "tsel", "simpati", "kartuas", "loop", "byu"},
+	"Indosat":   {"indosat", "indosatooredoo", "ooredoo", "im3", "im3ooredoo", "mentari", "isat"},
+	"XL":        {"xl", "xlaxiata", "axiata"},
+	"Axis":      {"axis"},
+	"Tri":       {"tri", "three", "3"},
+	"Smartfren": {"smartfren", "sf"},
+}
+
+// canonicalOperator returns the canonical name of an operator, e.g. "Indosat Ooredoo" becomes "Indosat" and
+// "Three" becomes "Tri". It reports false for names that aren't a known operator.
+func canonicalOperator(name string) (string, bool) {
+	key := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+
+	for operator, aliases := range operatorAliases {
+		if slices.Contains(aliases, key) {
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+// canonicalNumber strips separators and rewrites the +62 and 62 country code forms into the
+// local 08 form, e.g. "+62 812-3456-7890" becomes "081234567890".
+func canonicalNumber(number string) string {
+	number = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+
+	switch {
+	case strings.HasPrefix(number, "+62"):
+		number = "0" + number[3:]
+	case strings.HasPrefix(number, "62"):
+		number = "0" + number[2:]
+	}
+	return number
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// normalizeDestinationNumber returns the number in canonical 08 form, rejecting anything that is
+// not an Indonesian mobile number of 10 to 13 digits.
+func normalizeDestinationNumber(number string) (string, error) {
+	canonical := canonicalNumber(number)
+	if !isDigits(canonical) || !strings.HasPrefix(canonical, "08") || len(canonical) < 10 || len(canonical) > 13 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDestinationNumber, number)
+	}
+	return canonical, nil
+}
+
+// normalizeOperatorPrefix returns the prefix in the same 08 form as normalized destination numbers.
+func normalizeOperatorPrefix(prefix string) (string, error) {
+	canonical := canonicalNumber(prefix)
+	if !isDigits(canonical) || !strings.HasPrefix(canonical, "08") || len(canonical) < 3 || len(canonical) > 6 {
+		return "", fmt.Errorf("%w: prefix %q", ErrInvalidDestinationNumber, prefix)
+	}
+	return canonical, nil
+}
+
+// validateDestination normalizes the destination number of the transaction and makes sure every
+// product is sold by the operator owning the number. Provider and operator names are compared by
+// their canonical operator, so brand names and spelling variants match.
+func (u *transactionUseCase) validateDestination(payload entity.Transactions) (entity.Transactions, error) {
+	number, err := normalizeDestinationNumber(payload.DestinationNumber)
+	if err != nil {
+		return entity.Transactions{}, err
+	}
+
+	prefix, err := u.operatorRepo.FindByNumber(number)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.Transactions{}, fmt.Errorf("%w: %s", ErrUnknownOperator, number)
+		}
+		return entity.Transactions{}, err
+	}
+
+	operator, ok := canonicalOperator(prefix.Operator)
+	if !ok {
+		return entity.Transactions{}, fmt.Errorf("%w: %s is mapped to %q", ErrUnknownOperator, number, prefix.Operator)
+	}
+
+	for _, detail := range payload.TransactionDetail {
+		product, err := u.productRepo.Get(detail.ProductId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return entity.Transactions{}, fmt.Errorf("product %s not found", detail.ProductId)
+			}
+			return entity.Transactions{}, err
+		}
+
+		if provider, ok := canonicalOperator(product.NameProvider); !ok || provider != operator {
+			return entity.Transactions{}, fmt.Errorf("%w: %s product cannot be sold to %s number %s",
+				ErrOperatorMismatch, product.NameProvider, operator, number)
+		}
+	}
+
+	payload.DestinationNumber = number
+	return payload, nil
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+)
+
+type FulfillmentUseCase interface {
+	ProcessPending() (int, error)
+	Run(ctx context.Context)
+}
+
+type fulfillmentUseCase struct {
+	repo     repository.FulfillmentRepository
+	supplier SupplierGateway
+	cfg      config.FulfillmentConfig
+	log      *logger.Logger
+}
+
+// ProcessPending claims a batch of pending transaction details and dispatches each of them
+// to its supplier. Details left processing for longer than the claim timeout are claimed again.
+// It returns the number of details that reached a final status. Without a supplier nothing is
+// claimed, so the details wait until one is configured.
+func (f *fulfillmentUseCase) ProcessPending() (int, error) {
+	if f.supplier == nil {
+		return 0, nil
+	}
+
+	orders, err := f.repo.ClaimPending(time.Now().Add(-f.cfg.ClaimTimeout), f.cfg.BatchSize)
+	if err != nil {
+		f.log.Error("Failed to claim pending transaction details", err)
+		return 0, err
+	}
+
+	finished := 0
+	for _, order := range orders {
+		result, err := f.supplier.Purchase(order)
+		if err != nil {
+			f.log.Error("Supplier unreachable, order will be retried", err)
+			if err := f.repo.Release(order); err != nil {
+				f.log.Error("Failed to release transaction detail", err)
+			}
+			continue
+		}
+
+		switch result.Status {
+		case entity.FulfillmentSuccess:
+			err = f.repo.MarkSuccess(order, result)
+		case entity.FulfillmentFailed:
+			err = f.repo.MarkFailed(order, result, auditEntry(entity.SystemActor("fulfillment"), entity.AuditRefund, entity.AuditTransaction, order.TransactionsId))
+		default:
+			f.log.Info("Supplier has not finished the order yet, it will be retried", result)
+			if err := f.repo.Release(order); err != nil {
+				f.log.Error("Failed to release transaction detail", err)
+			}
+			continue
+		}
+
+		if err != nil {
+			f.log.Error("Failed to store supplier result", err)
+			continue
+		}
+		finished++
+	}
+
+	return finished, nil
+}
+
+// Run processes pending transaction details every configured interval until ctx is cancelled.
+func (f *fulfillmentUseCase) Run(ctx context.Context) {
+	if f.supplier == nil {
+		f.log.Error("No supplier gateway configured, transaction details stay pending", nil)
+		return
+	}
+
+	f.log.Info("Starting the fulfillment worker", f.cfg)
+
+	ticker := time.NewTicker(f.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.log.Info("Fulfillment worker stopped", nil)
+			return
+		case <-ticker.C:
+			if _, err := f.ProcessPending(); err != nil {
+				f.log.Error("Fulfillment run failed", err)
+			}
+		}
+	}
+}
+
+func NewFulfillmentUseCase(repo repository.FulfillmentRepository, supplier SupplierGateway, cfg config.FulfillmentConfig, log *logger.Logger) FulfillmentUseCase {
+	return &fulfillmentUseCase{repo: repo, supplier: supplier, cfg: cfg, log: log}
+}
@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/gateway"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type unreachableSupplier struct{}
+
+func (unreachableSupplier) Purchase(order entity.SupplierOrder) (entity.SupplierResult, error) {
+	return entity.SupplierResult{}, errors.New("connection refused")
+}
+
+type fulfillmentUsecaseTestSuite struct {
+	suite.Suite
+	mockRepo *repositorymock.MockFulfillmentRepository
+	supplier *gateway.FakeSupplierGateway
+	cfg      config.FulfillmentConfig
+	useCase  FulfillmentUseCase
+	log      logger.Logger
+}
+
+func (f *fulfillmentUsecaseTestSuite) SetupTest() {
+	f.mockRepo = new(repositorymock.MockFulfillmentRepository)
+	f.supplier = gateway.NewFakeSupplierGateway()
+	f.cfg = config.FulfillmentConfig{Interval: time.Second, BatchSize: 10, ClaimTimeout: 10 * time.Minute}
+	f.log = logger.NewLogger()
+	f.useCase = NewFulfillmentUseCase(f.mockRepo, f.supplier, f.cfg, &f.log)
+}
+
+// claimedBefore matches the cutoff for stale claims, allowing for the time the test takes.
+func claimedBefore(timeout time.Duration) any {
+	return mock.MatchedBy(func(cutoff time.Time) bool {
+		return time.Since(cutoff.Add(timeout)).Abs() < time.Minute
+	})
+}
+
+func (f *fulfillmentUsecaseTestSuite) order(detailId, number string) entity.SupplierOrder {
+	return entity.SupplierOrder{
+		TransactionDetailId: detailId,
+		TransactionsId:      "tx-uuid",
+		MerchantId:          "merchant-uuid",
+		SupplierId:          "supplier-uuid",
+		ProductId:           "product-uuid",
+		NameProvider:        "Telkomsel",
+		Nominal:             10000,
+		DestinationNumber:   number,
+	}
+}
+
+func (f *fulfillmentUsecaseTestSuite) TestProcessPending_Success() {
+	order := f.order("detail-uuid", "081234567890")
+	f.mockRepo.On("ClaimPending", claimedBefore(f.cfg.ClaimTimeout), 10).Return([]entity.SupplierOrder{order}, nil).Once()
+	f.mockRepo.On("MarkSuccess", order, mock.MatchedBy(func(result entity.SupplierResult) bool {
+		return result.Status == entity.FulfillmentSuccess && result.SerialNumber != ""
+	})).Return(nil).Once()
+
+	finished, err := f.useCase.ProcessPending()
+
+	f.NoError(err)
+	f.Equal(1, finished)
+	f.Len(f.supplier.Orders(), 1)
+	f.mockRepo.AssertExpectations(f.T())
+}
+
+func (f *fulfillmentUsecaseTestSuite) TestProcessPending_SupplierFailureRefunds() {
+	order := f.order("detail-uuid", "081200000000")
+	f.supplier.FailDestination(order.DestinationNumber, "number inactive")
+	result := entity.SupplierResult{Status: entity.FulfillmentFailed, Message: "number inactive"}
+
+	f.mockRepo.On("ClaimPending", claimedBefore(f.cfg.ClaimTimeout), 10).Return([]entity.SupplierOrder{order}, nil).Once()
+	f.mockRepo.On("MarkFailed", order, result, auditEntry(entity.SystemActor("fulfillment"), entity.AuditRefund, entity.AuditTransaction, order.TransactionsId)).Return(nil).Once()
+
+	finished, err := f.useCase.ProcessPending()
+
+	f.NoError(err)
+	f.Equal(1, finished)
+	f.mockRepo.AssertNotCalled(f.T(), "MarkSuccess", mock.Anything, mock.Anything)
+	f.mockRepo.AssertExpectations(f.T())
+}
+
+func (f *fulfillmentUsecaseTestSuite) TestProcessPending_SupplierUnreachableReleases() {
+	f.useCase = NewFulfillmentUseCase(f.mockRepo, unreachableSupplier{}, f.cfg, &f.log)
+	order := f.order("detail-uuid", "081234567890")
+
+	f.mockRepo.On("ClaimPending", claimedBefore(f.cfg.ClaimTimeout), 10).Return([]entity.SupplierOrder{order}, nil).Once()
+	f.mockRepo.On("Release", order).Return(nil).Once()
+
+	finished, err := f.useCase.ProcessPending()
+
+	f.NoError(err)
+	f.Equal(0, finished)
+	f.mockRepo.AssertExpectations(f.T())
+}
+
+func (f *fulfillmentUsecaseTestSuite) TestProcessPending_ReclaimedOrderIsNotBoughtTwice() {
+	order := f.order("detail-uuid", "081234567890")
+	var results []entity.SupplierResult
+	f.mockRepo.On("ClaimPending", claimedBefore(f.cfg.ClaimTimeout), 10).Return([]entity.SupplierOrder{order}, nil).Twice()
+	f.mockRepo.On("MarkSuccess", order, mock.Anything).Run(func(args mock.Arguments) {
+		results = append(results, args.Get(1).(entity.SupplierResult))
+	}).Return(nil).Twice()
+
+	_, err := f.useCase.ProcessPending()
+	f.NoError(err)
+	// The number is blocked after the first purchase, a repeated order must still report the first result
+	f.supplier.FailDestination(order.DestinationNumber, "number inactive")
+	_, err = f.useCase.ProcessPending()
+	f.NoError(err)
+
+	f.Len(results, 2)
+	f.Equal(results[0], results[1])
+	f.mockRepo.AssertNotCalled(f.T(), "MarkFailed", mock.Anything, mock.Anything, mock.Anything)
+	f.mockRepo.AssertExpectations(f.T())
+}
+
+func (f *fulfillmentUsecaseTestSuite) TestProcessPending_ClaimError() {
+	f.mockRepo.On("ClaimPending", claimedBefore(f.cfg.ClaimTimeout), 10).Return([]entity.SupplierOrder(nil), errors.New("db down")).Once()
+
+	finished, err := f.useCase.ProcessPending()
+
+	f.Error(err)
+	f.Equal(0, finished)
+}
+
+func TestFulfillmentUsecaseTestSuite(t *testing.T) {
+	suite.Run(t, new(fulfillmentUsecaseTestSuite))
+}
+
+func (f *fulfillmentUsecaseTestSuite) TestProcessPending_WithoutSupplierLeavesDetailsPending() {
+	f.useCase = NewFulfillmentUseCase(f.mockRepo, nil, f.cfg, &f.log)
+
+	finished, err := f.useCase.ProcessPending()
+
+	f.NoError(err)
+	f.Equal(0, finished)
+	f.mockRepo.AssertNotCalled(f.T(), "ClaimPending", mock.Anything, mock.Anything)
+}
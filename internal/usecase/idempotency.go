@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"server-pulsa-app/internal/entity"
+)
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	ErrIdempotencyKeyInvalid    = fmt.Errorf("idempotency key must be between 1 and %d characters", maxIdempotencyKeyLength)
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// prepareIdempotencyKey validates the client key and fills in the hash of the request it guards.
+func prepareIdempotencyKey(key entity.IdempotencyKey, request any) (entity.IdempotencyKey, error) {
+	key.Key = strings.TrimSpace(key.Key)
+	if key.Key == "" || len(key.Key) > maxIdempotencyKeyLength {
+		return entity.IdempotencyKey{}, ErrIdempotencyKeyInvalid
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return entity.IdempotencyKey{}, fmt.Errorf("failed to hash request: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	key.RequestHash = hex.EncodeToString(sum[:])
+
+	return key, nil
+}
+
+// replayIdempotencyKey decodes the response stored for a key that was already used by the same request.
+func replayIdempotencyKey(key, stored entity.IdempotencyKey, response any) error {
+	if stored.RequestHash != key.RequestHash {
+		return ErrIdempotencyKeyMismatch
+	}
+	if len(stored.Response) == 0 {
+		return ErrIdempotencyKeyInProgress
+	}
+
+	return json.Unmarshal(stored.Response, response)
+}
@@ -5,24 +5,36 @@ import (
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/shared/model"
 )
 
 type MerchantUseCase interface {
-	RegisterNewMerchant(payload entity.Merchant) (entity.Merchant, error)
+	RegisterNewMerchant(payload entity.Merchant, actor entity.AuditActor) (entity.Merchant, error)
 	FindAllMerchant() ([]entity.Merchant, error)
 	FindMerchantByID(id string) (entity.Merchant, error)
-	UpdateMerchant(payload entity.Merchant) (entity.Merchant, error)
-	DeleteMerchant(id string) error
+	UpdateMerchant(payload entity.Merchant, actor entity.AuditActor) (entity.Merchant, error)
+	DeleteMerchant(id string, actor entity.AuditActor) error
+	FindMutations(id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error)
+	FindOwnMutations(idUser, id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error)
+	AdjustBalance(payload entity.LedgerEntry, actor entity.AuditActor) (entity.LedgerEntry, error)
 }
 
 type merchantUseCase struct {
-	repo repository.MerchantRepository
-	log  *logger.Logger
+	repo  repository.MerchantRepository
+	audit AuditUseCase
+	log   *logger.Logger
 }
 
-func (m *merchantUseCase) RegisterNewMerchant(payload entity.Merchant) (entity.Merchant, error) {
+func (m *merchantUseCase) RegisterNewMerchant(payload entity.Merchant, actor entity.AuditActor) (entity.Merchant, error) {
 	m.log.Info("Starting to create a new merchant in the usecase layer", nil)
-	return m.repo.Create(payload)
+
+	merchant, err := m.repo.Create(payload)
+	if err != nil {
+		return entity.Merchant{}, err
+	}
+
+	m.audit.Record(actor, entity.AuditCreate, entity.AuditMerchant, merchant.IdMerchant, nil, merchant)
+	return merchant, nil
 }
 
 func (m *merchantUseCase) FindAllMerchant() ([]entity.Merchant, error) {
@@ -35,7 +47,7 @@ func (m *merchantUseCase) FindMerchantByID(id string) (entity.Merchant, error) {
 	return m.repo.Get(id)
 }
 
-func (m *merchantUseCase) UpdateMerchant(payload entity.Merchant) (entity.Merchant, error) {
+func (m *merchantUseCase) UpdateMerchant(payload entity.Merchant, actor entity.AuditActor) (entity.Merchant, error) {
 	m.log.Info("Starting to retrive a merchant by id in the usecase layer", nil)
 
 	merchant, err := m.repo.Get(payload.IdMerchant)
@@ -52,22 +64,82 @@ func (m *merchantUseCase) UpdateMerchant(payload entity.Merchant) (entity.Mercha
 	}
 
 	m.log.Info("Merchant ID %s has been updated successfully: ", payload.IdMerchant)
-	return m.repo.Get(payload.IdMerchant)
+	updated, err := m.repo.Get(payload.IdMerchant)
+	if err != nil {
+		return entity.Merchant{}, err
+	}
+
+	m.audit.Record(actor, entity.AuditUpdate, entity.AuditMerchant, updated.IdMerchant, merchant, updated)
+	return updated, nil
 }
 
-func (m *merchantUseCase) DeleteMerchant(id string) error {
+func (m *merchantUseCase) DeleteMerchant(id string, actor entity.AuditActor) error {
 	m.log.Info("Starting to retrive a merchant by id in the usecase layer", nil)
 
-	_, err := m.repo.Get(id)
+	merchant, err := m.repo.Get(id)
 	if err != nil {
 		m.log.Error("Merchant ID %s not found: %v", id)
 		return fmt.Errorf("merchant ID of \\%s\\ not found", id)
 	}
 
+	if err := m.repo.Delete(id); err != nil {
+		return err
+	}
+
 	m.log.Info("Merchant has been deleted successfully: ", id)
-	return m.repo.Delete(id)
+	m.audit.Record(actor, entity.AuditDelete, entity.AuditMerchant, id, merchant, nil)
+	return nil
+}
+
+func (m *merchantUseCase) FindMutations(id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error) {
+	m.log.Info("Starting to retrive merchant mutations in the usecase layer", nil)
+
+	if _, err := m.repo.Get(id); err != nil {
+		m.log.Error("Merchant ID %s not found: ", id)
+		return nil, model.Paging{}, fmt.Errorf("merchant ID of \\%s\\ not found", id)
+	}
+
+	return m.listMutations(id, page, limit)
+}
+
+// FindOwnMutations is FindMutations for a merchant of idUser. Another user's merchant is reported as not found.
+func (m *merchantUseCase) FindOwnMutations(idUser, id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error) {
+	m.log.Info("Starting to retrive own merchant mutations in the usecase layer", nil)
+
+	merchant, err := m.repo.Get(id)
+	if err != nil || merchant.IdUser != idUser {
+		m.log.Error("Merchant ID %s not found for the user: ", id)
+		return nil, model.Paging{}, fmt.Errorf("merchant ID of \\%s\\ not found", id)
+	}
+
+	return m.listMutations(id, page, limit)
+}
+
+func (m *merchantUseCase) listMutations(id string, page, limit int) ([]entity.LedgerEntry, model.Paging, error) {
+	page, limit = normalizePaging(page, limit)
+	mutations, total, err := m.repo.ListMutations(id, page, limit)
+	if err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	return mutations, newPaging(page, limit, total), nil
+}
+
+func (m *merchantUseCase) AdjustBalance(payload entity.LedgerEntry, actor entity.AuditActor) (entity.LedgerEntry, error) {
+	m.log.Info("Starting to adjust merchant balance in the usecase layer", nil)
+
+	if payload.Amount == 0 {
+		return entity.LedgerEntry{}, fmt.Errorf("adjustment amount can't be zero")
+	}
+
+	if _, err := m.repo.Get(payload.IdMerchant); err != nil {
+		m.log.Error("Merchant ID %s not found: ", payload.IdMerchant)
+		return entity.LedgerEntry{}, fmt.Errorf("merchant ID of \\%s\\ not found", payload.IdMerchant)
+	}
+
+	return m.repo.AdjustBalance(payload, auditEntry(actor, entity.AuditAdjustBalance, entity.AuditMerchant, payload.IdMerchant))
 }
 
-func NewMerchantUseCase(repo repository.MerchantRepository, log *logger.Logger) MerchantUseCase {
-	return &merchantUseCase{repo: repo, log: log}
+func NewMerchantUseCase(repo repository.MerchantRepository, audit AuditUseCase, log *logger.Logger) MerchantUseCase {
+	return &merchantUseCase{repo: repo, audit: audit, log: log}
 }
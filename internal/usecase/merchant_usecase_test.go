@@ -7,13 +7,16 @@ import (
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/mock/repo_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
 type merchantUsecaseSuite struct {
 	suite.Suite
 	merchantRepo    *repo_mock.MerchantRepoMock
+	audit           *usecase_mock.AuditUseCaseMock
 	merchantUsecase MerchantUseCase
 	log             logger.Logger
 }
@@ -24,8 +27,9 @@ func TestMerchantUsecaseSuite(t *testing.T) {
 
 func (m *merchantUsecaseSuite) SetupTest() {
 	m.merchantRepo = new(repo_mock.MerchantRepoMock)
+	m.audit = new(usecase_mock.AuditUseCaseMock)
 	m.log = logger.NewLogger()
-	m.merchantUsecase = NewMerchantUseCase(m.merchantRepo, &m.log)
+	m.merchantUsecase = NewMerchantUseCase(m.merchantRepo, m.audit, &m.log)
 }
 
 func (m *merchantUsecaseSuite) TestCreateMerchant_success() {
@@ -38,11 +42,14 @@ func (m *merchantUsecaseSuite) TestCreateMerchant_success() {
 		Balance:      10000,
 	}
 
+	actor := entity.AuditActor{IdUser: "uuid-admin-test", Role: entity.RoleAdmin, ClientIP: "10.0.0.1"}
 	m.merchantRepo.On("Create", merchant).Return(merchant, nil)
+	m.audit.On("Record", actor, entity.AuditCreate, entity.AuditMerchant, merchant.IdMerchant, nil, merchant).Once()
 
-	result, err := m.merchantUsecase.RegisterNewMerchant(merchant)
+	result, err := m.merchantUsecase.RegisterNewMerchant(merchant, actor)
 	m.NoError(err)
 	m.Equal(merchant.IdMerchant, result.IdMerchant)
+	m.audit.AssertExpectations(m.T())
 }
 
 func (m *merchantUsecaseSuite) TestGetAllMerchant_success() {
@@ -101,8 +108,9 @@ func (m *merchantUsecaseSuite) TestUpdateMerchant_success() {
 
 	m.merchantRepo.On("Get", merchant.IdMerchant).Return(merchant, nil)
 	m.merchantRepo.On("Update", merchant, merchant).Return(merchant, nil)
+	m.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditMerchant, merchant.IdMerchant, merchant, merchant).Once()
 
-	result, err := m.merchantUsecase.UpdateMerchant(merchant)
+	result, err := m.merchantUsecase.UpdateMerchant(merchant, entity.AuditActor{})
 	m.NoError(err)
 	m.Equal(merchant.IdMerchant, result.IdMerchant)
 }
@@ -119,7 +127,7 @@ func (m *merchantUsecaseSuite) TestUpdateMerchant_failed() {
 
 	m.merchantRepo.On("Get", merchant.IdMerchant).Return(entity.Merchant{}, errors.New("merchant ID of \\uuid-merchant-test\\ not found"))
 
-	result, err := m.merchantUsecase.UpdateMerchant(merchant)
+	result, err := m.merchantUsecase.UpdateMerchant(merchant, entity.AuditActor{})
 	m.Error(err)
 	m.EqualError(err, "merchant ID of \\uuid-merchant-test\\ not found")
 	m.Equal(entity.Merchant{}, result)
@@ -137,9 +145,11 @@ func (m *merchantUsecaseSuite) TestDeleteMerchant_success() {
 
 	m.merchantRepo.On("Get", merchant.IdMerchant).Return(merchant, nil)
 	m.merchantRepo.On("Delete", merchant.IdMerchant).Return(nil)
+	m.audit.On("Record", entity.AuditActor{}, entity.AuditDelete, entity.AuditMerchant, merchant.IdMerchant, merchant, nil).Once()
 
-	err := m.merchantUsecase.DeleteMerchant(merchant.IdMerchant)
+	err := m.merchantUsecase.DeleteMerchant(merchant.IdMerchant, entity.AuditActor{})
 	m.NoError(err)
+	m.audit.AssertExpectations(m.T())
 }
 
 func (m *merchantUsecaseSuite) TestDeleteMerchant_failed() {
@@ -149,7 +159,65 @@ func (m *merchantUsecaseSuite) TestDeleteMerchant_failed() {
 
 	m.merchantRepo.On("Get", merchant.IdMerchant).Return(entity.Merchant{}, errors.New("merchant not found"))
 
-	err := m.merchantUsecase.DeleteMerchant(merchant.IdMerchant)
+	err := m.merchantUsecase.DeleteMerchant(merchant.IdMerchant, entity.AuditActor{})
 	m.Error(err)
 	m.EqualError(err, "merchant ID of \\uuid-merchant-test\\ not found")
 }
+
+func (m *merchantUsecaseSuite) TestFindMutations_success() {
+	mutations := []entity.LedgerEntry{
+		{Id: 2, IdMerchant: "uuid-merchant-test", EntryType: entity.LedgerSale, Amount: -10000, BalanceAfter: 40000},
+		{Id: 1, IdMerchant: "uuid-merchant-test", EntryType: entity.LedgerTopup, Amount: 50000, BalanceAfter: 50000},
+	}
+
+	m.merchantRepo.On("Get", "uuid-merchant-test").Return(entity.Merchant{IdMerchant: "uuid-merchant-test"}, nil)
+	m.merchantRepo.On("ListMutations", "uuid-merchant-test", 1, 10).Return(mutations, 12, nil)
+
+	result, paging, err := m.merchantUsecase.FindMutations("uuid-merchant-test", 0, 0)
+	m.NoError(err)
+	m.Equal(mutations, result)
+	m.Equal(2, paging.TotalPages)
+	m.Equal(12, paging.TotalRows)
+}
+
+func (m *merchantUsecaseSuite) TestFindOwnMutations_ownMerchant() {
+	mutations := []entity.LedgerEntry{{Id: 1, IdMerchant: "uuid-merchant-test", EntryType: entity.LedgerOpening, Amount: 50000, BalanceAfter: 50000}}
+
+	m.merchantRepo.On("Get", "uuid-merchant-test").Return(entity.Merchant{IdMerchant: "uuid-merchant-test", IdUser: "uuid-user-test"}, nil)
+	m.merchantRepo.On("ListMutations", "uuid-merchant-test", 1, 10).Return(mutations, 1, nil)
+
+	result, _, err := m.merchantUsecase.FindOwnMutations("uuid-user-test", "uuid-merchant-test", 0, 0)
+	m.NoError(err)
+	m.Equal(mutations, result)
+}
+
+func (m *merchantUsecaseSuite) TestFindOwnMutations_otherUsersMerchant() {
+	m.merchantRepo.On("Get", "uuid-merchant-test").Return(entity.Merchant{IdMerchant: "uuid-merchant-test", IdUser: "uuid-owner"}, nil)
+
+	_, _, err := m.merchantUsecase.FindOwnMutations("uuid-user-test", "uuid-merchant-test", 0, 0)
+	m.EqualError(err, "merchant ID of \\uuid-merchant-test\\ not found")
+	m.merchantRepo.AssertNotCalled(m.T(), "ListMutations", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (m *merchantUsecaseSuite) TestAdjustBalance_zeroAmount() {
+	_, err := m.merchantUsecase.AdjustBalance(entity.LedgerEntry{IdMerchant: "uuid-merchant-test"}, entity.AuditActor{})
+	m.EqualError(err, "adjustment amount can't be zero")
+	m.merchantRepo.AssertNotCalled(m.T(), "AdjustBalance", mock.Anything, mock.Anything)
+}
+
+func (m *merchantUsecaseSuite) TestAdjustBalance_success() {
+	entry := entity.LedgerEntry{IdMerchant: "uuid-merchant-test", Amount: -5000, Description: "correction"}
+	posted := entry
+	posted.Id = 3
+	posted.EntryType = entity.LedgerAdjustment
+	posted.BalanceAfter = 45000
+
+	m.merchantRepo.On("Get", entry.IdMerchant).Return(entity.Merchant{IdMerchant: entry.IdMerchant, Balance: 50000}, nil)
+	actor := entity.AuditActor{IdUser: "uuid-admin"}
+	m.merchantRepo.On("AdjustBalance", entry, auditEntry(actor, entity.AuditAdjustBalance, entity.AuditMerchant, entry.IdMerchant)).Return(posted, nil)
+
+	result, err := m.merchantUsecase.AdjustBalance(entry, actor)
+	m.NoError(err)
+	m.Equal(posted, result)
+	m.merchantRepo.AssertExpectations(m.T())
+}
@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/shared/service"
+)
+
+var (
+	ErrMFANotEnabled       = errors.New("two factor authentication is not enabled")
+	ErrMFAAlreadyEnabled   = errors.New("two factor authentication is already enabled")
+	ErrMFARequired         = errors.New("two factor authentication is required for this role")
+	ErrInvalidMFACode      = errors.New("invalid two factor code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa challenge")
+)
+
+const (
+	recoveryCodeCount       = 10
+	maxMFAChallengeAttempts = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAUseCase interface {
+	Status(idUser string) (entity.UserMFA, error)
+	Enroll(idUser string) (entity.MFAEnrollment, error)
+	Confirm(idUser, code string) ([]string, error)
+	Verify(idUser, code string) error
+	Disable(idUser, code string) error
+	Reset(idUser string, actor entity.AuditActor) error
+	NewChallenge(idUser string) (string, error)
+	ChallengeUser(token string) (string, error)
+	CloseChallenge(token string) error
+}
+
+type mfaUseCase struct {
+	repo     repository.MFARepository
+	userRepo repository.UserRepository
+	audit    AuditUseCase
+	cfg      config.AuthConfig
+	log      *logger.Logger
+}
+
+func (m *mfaUseCase) Status(idUser string) (entity.UserMFA, error) {
+	mfa, err := m.repo.Get(idUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.UserMFA{}, ErrUserNotFound
+	}
+	return mfa, err
+}
+
+// Enroll starts a totp enrollment with a new secret. The secret only protects logins once Confirm accepted a
+// first code from it, so an abandoned enrollment never locks the user out.
+func (m *mfaUseCase) Enroll(idUser string) (entity.MFAEnrollment, error) {
+	m.log.Info("Starting to enroll a second factor in the usecase layer", idUser)
+
+	user, err := m.userRepo.GetUserByID(idUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.MFAEnrollment{}, ErrUserNotFound
+	} else if err != nil {
+		return entity.MFAEnrollment{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	secret, err := service.GenerateTOTPSecret()
+	if err != nil {
+		return entity.MFAEnrollment{}, err
+	}
+
+	err = m.repo.SavePending(idUser, secret)
+	if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+		return entity.MFAEnrollment{}, ErrMFAAlreadyEnabled
+	} else if err != nil {
+		return entity.MFAEnrollment{}, fmt.Errorf("failed to save mfa secret: %w", err)
+	}
+
+	return entity.MFAEnrollment{Secret: secret, ProvisioningURI: service.TOTPProvisioningURI(m.cfg.MFAIssuer, user.Username, secret)}, nil
+}
+
+// Confirm enables the pending secret of a user with a first code and returns fresh recovery codes. They are
+// only stored hashed, so this is the one time they can be shown.
+func (m *mfaUseCase) Confirm(idUser, code string) ([]string, error) {
+	m.log.Info("Starting to confirm a second factor in the usecase layer", idUser)
+
+	mfa, err := m.Status(idUser)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if mfa.Secret == "" {
+		return nil, fmt.Errorf("%w: enroll first", ErrMFANotEnabled)
+	}
+
+	step, ok := service.ValidateTOTP(mfa.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.repo.Enable(idUser, step, hashes)
+	if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+		return nil, ErrMFAAlreadyEnabled
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to enable mfa: %w", err)
+	}
+
+	return codes, nil
+}
+
+// Verify checks a totp code, or spends a recovery code, of a user with an enabled second factor. A totp code
+// works once.
+func (m *mfaUseCase) Verify(idUser, code string) error {
+	mfa, err := m.Status(idUser)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled() {
+		return ErrMFANotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := service.ValidateTOTP(mfa.Secret, code, time.Now()); ok {
+		used, err := m.repo.UseStep(idUser, step)
+		if err != nil {
+			return fmt.Errorf("failed to record mfa code: %w", err)
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := m.repo.UseRecoveryCode(idUser, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	m.log.Info("Recovery code used", idUser)
+	return nil
+}
+
+// Disable turns off the second factor of a user after checking a code. Users whose role requires it can't.
+func (m *mfaUseCase) Disable(idUser, code string) error {
+	m.log.Info("Starting to disable a second factor in the usecase layer", idUser)
+
+	mfa, err := m.Status(idUser)
+	if err != nil {
+		return err
+	}
+	if mfa.Required {
+		return ErrMFARequired
+	}
+
+	if err := m.Verify(idUser, code); err != nil {
+		return err
+	}
+
+	return m.repo.Delete(idUser)
+}
+
+// Reset removes the second factor of a user who lost it. When the role requires one, the next login asks the
+// user to enroll again.
+func (m *mfaUseCase) Reset(idUser string, actor entity.AuditActor) error {
+	m.log.Info("Starting to reset a second factor in the usecase layer", idUser)
+
+	mfa, err := m.Status(idUser)
+	if err != nil {
+		return err
+	}
+
+	if err := m.repo.Delete(idUser); err != nil {
+		return err
+	}
+
+	m.audit.Record(actor, entity.AuditResetMFA, entity.AuditUser, idUser, mfa, nil)
+	return nil
+}
+
+// NewChallenge returns a short lived token a login with the right password exchanges, together with a second
+// factor code, for the real tokens.
+func (m *mfaUseCase) NewChallenge(idUser string) (string, error) {
+	token, hash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.repo.CreateChallenge(hash, idUser, time.Now().Add(m.cfg.MFAChallengeLifetime)); err != nil {
+		return "", fmt.Errorf("failed to create mfa challenge: %w", err)
+	}
+
+	return token, nil
+}
+
+// ChallengeUser returns the user of a challenge. Every call counts as an attempt, a challenge allows only a few.
+func (m *mfaUseCase) ChallengeUser(token string) (string, error) {
+	idUser, err := m.repo.ChallengeUser(hashToken(token), maxMFAChallengeAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidMFAChallenge
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get mfa challenge: %w", err)
+	}
+
+	return idUser, nil
+}
+
+func (m *mfaUseCase) CloseChallenge(token string) error {
+	return m.repo.DeleteChallenge(hashToken(token))
+}
+
+// newRecoveryCodes returns recovery codes formatted as xxxx-xxxx and the hashes they are stored under.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = hashToken(code)
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func NewMFAUseCase(repo repository.MFARepository, userRepo repository.UserRepository, audit AuditUseCase, cfg config.AuthConfig, log *logger.Logger) MFAUseCase {
+	return &mfaUseCase{repo: repo, userRepo: userRepo, audit: audit, cfg: cfg, log: log}
+}
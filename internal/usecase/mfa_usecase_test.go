@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/mock/repo_mock"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/shared/service"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+type mfaUsecaseSuite struct {
+	suite.Suite
+	repo       *repositorymock.MockMFARepository
+	userRepo   *repo_mock.UserRepoMock
+	audit      *usecase_mock.AuditUseCaseMock
+	mfaUsecase MFAUseCase
+	log        logger.Logger
+}
+
+func (m *mfaUsecaseSuite) SetupTest() {
+	m.repo = new(repositorymock.MockMFARepository)
+	m.userRepo = new(repo_mock.UserRepoMock)
+	m.audit = new(usecase_mock.AuditUseCaseMock)
+	m.log = logger.NewLogger()
+	m.mfaUsecase = NewMFAUseCase(m.repo, m.userRepo, m.audit, config.AuthConfig{MFAIssuer: "Server Pulsa", MFAChallengeLifetime: 5 * time.Minute}, &m.log)
+}
+
+func TestMFAUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(mfaUsecaseSuite))
+}
+
+func (m *mfaUsecaseSuite) currentCode() string {
+	code, err := service.GenerateTOTPCode(testTOTPSecret, time.Now())
+	m.Require().NoError(err)
+	return code
+}
+
+func (m *mfaUsecaseSuite) TestEnroll_ReturnsProvisioningURI() {
+	m.userRepo.On("GetUserByID", "user-1").Return(entity.User{Id_user: "user-1", Username: "admin"}, nil)
+	m.repo.On("SavePending", "user-1", mock.AnythingOfType("string")).Return(nil).Once()
+
+	enrollment, err := m.mfaUsecase.Enroll("user-1")
+
+	m.NoError(err)
+	m.Len(enrollment.Secret, 32)
+	m.Contains(enrollment.ProvisioningURI, "otpauth://totp/Server%20Pulsa:admin?")
+	m.Contains(enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+}
+
+func (m *mfaUsecaseSuite) TestConfirm_EnablesWithRecoveryCodes() {
+	m.repo.On("Get", "user-1").Return(entity.UserMFA{IdUser: "user-1", Secret: testTOTPSecret}, nil)
+	m.repo.On("Enable", "user-1", mock.AnythingOfType("int64"), mock.MatchedBy(func(hashes []string) bool { return len(hashes) == recoveryCodeCount })).
+		Return(nil).Once()
+
+	codes, err := m.mfaUsecase.Confirm("user-1", m.currentCode())
+
+	m.NoError(err)
+	m.Len(codes, recoveryCodeCount)
+	m.Regexp(`^[a-z2-7]{4}-[a-z2-7]{4}$`, codes[0])
+	m.repo.AssertExpectations(m.T())
+}
+
+func (m *mfaUsecaseSuite) TestConfirm_WrongCode() {
+	m.repo.On("Get", "user-1").Return(entity.UserMFA{IdUser: "user-1", Secret: testTOTPSecret}, nil)
+
+	_, err := m.mfaUsecase.Confirm("user-1", "not-a-code")
+
+	m.ErrorIs(err, ErrInvalidMFACode)
+	m.repo.AssertNotCalled(m.T(), "Enable", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (m *mfaUsecaseSuite) TestVerify_RejectsReplayedCode() {
+	enabledAt := time.Now()
+	m.repo.On("Get", "user-1").Return(entity.UserMFA{IdUser: "user-1", Secret: testTOTPSecret, EnabledAt: &enabledAt}, nil)
+	m.repo.On("UseStep", "user-1", mock.AnythingOfType("int64")).Return(false, nil).Once()
+
+	err := m.mfaUsecase.Verify("user-1", m.currentCode())
+
+	m.ErrorIs(err, ErrInvalidMFACode)
+}
+
+func (m *mfaUsecaseSuite) TestVerify_RecoveryCode() {
+	enabledAt := time.Now()
+	m.repo.On("Get", "user-1").Return(entity.UserMFA{IdUser: "user-1", Secret: testTOTPSecret, EnabledAt: &enabledAt}, nil)
+	m.repo.On("UseRecoveryCode", "user-1", hashToken("abcd2345")).Return(true, nil).Once()
+
+	err := m.mfaUsecase.Verify("user-1", " ABCD-2345 ")
+
+	m.NoError(err)
+	m.repo.AssertExpectations(m.T())
+}
+
+func (m *mfaUsecaseSuite) TestDisable_RequiredByRole() {
+	enabledAt := time.Now()
+	m.repo.On("Get", "user-1").Return(entity.UserMFA{IdUser: "user-1", Secret: testTOTPSecret, EnabledAt: &enabledAt, Required: true}, nil)
+
+	err := m.mfaUsecase.Disable("user-1", m.currentCode())
+
+	m.ErrorIs(err, ErrMFARequired)
+	m.repo.AssertNotCalled(m.T(), "Delete", mock.Anything)
+}
+
+func (m *mfaUsecaseSuite) TestReset_Audits() {
+	enabledAt := time.Now()
+	mfa := entity.UserMFA{IdUser: "user-1", Secret: testTOTPSecret, EnabledAt: &enabledAt, Required: true}
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin}
+	m.repo.On("Get", "user-1").Return(mfa, nil)
+	m.repo.On("Delete", "user-1").Return(nil).Once()
+	m.audit.On("Record", actor, entity.AuditResetMFA, entity.AuditUser, "user-1", mfa, nil).Once()
+
+	err := m.mfaUsecase.Reset("user-1", actor)
+
+	m.NoError(err)
+	m.repo.AssertExpectations(m.T())
+	m.audit.AssertExpectations(m.T())
+}
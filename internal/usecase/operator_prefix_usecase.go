@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+)
+
+var ErrOperatorPrefixNotFound = errors.New("operator prefix not found")
+
+type OperatorPrefixUseCase interface {
+	FindAll() ([]entity.OperatorPrefix, error)
+	Save(prefix, operator string) (entity.OperatorPrefix, error)
+	Delete(prefix string) error
+}
+
+type operatorPrefixUseCase struct {
+	repo repository.OperatorPrefixRepository
+	log  *logger.Logger
+}
+
+func (o *operatorPrefixUseCase) FindAll() ([]entity.OperatorPrefix, error) {
+	o.log.Info("Starting to retrive all operator prefixes in the usecase layer", nil)
+	return o.repo.List()
+}
+
+// Save maps a prefix to an operator. The operator is stored under its canonical name, so every known spelling of it
+// matches the products of that operator.
+func (o *operatorPrefixUseCase) Save(prefix, operator string) (entity.OperatorPrefix, error) {
+	o.log.Info("Starting to save an operator prefix in the usecase layer", prefix)
+
+	prefix, err := normalizeOperatorPrefix(prefix)
+	if err != nil {
+		return entity.OperatorPrefix{}, err
+	}
+
+	canonical, ok := canonicalOperator(operator)
+	if !ok {
+		return entity.OperatorPrefix{}, fmt.Errorf("%w: %q", ErrInvalidOperator, strings.TrimSpace(operator))
+	}
+
+	return o.repo.Save(entity.OperatorPrefix{Prefix: prefix, Operator: canonical})
+}
+
+func (o *operatorPrefixUseCase) Delete(prefix string) error {
+	o.log.Info("Starting to delete an operator prefix in the usecase layer", prefix)
+
+	prefix, err := normalizeOperatorPrefix(prefix)
+	if err != nil {
+		return err
+	}
+
+	if err := o.repo.Delete(prefix); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOperatorPrefixNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func NewOperatorPrefixUseCase(repo repository.OperatorPrefixRepository, log *logger.Logger) OperatorPrefixUseCase {
+	return &operatorPrefixUseCase{repo: repo, log: log}
+}
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"database/sql"
+	"testing"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type operatorPrefixUsecaseSuite struct {
+	suite.Suite
+	operatorRepo    *repositorymock.MockOperatorPrefixRepository
+	operatorUsecase OperatorPrefixUseCase
+	log             logger.Logger
+}
+
+func (o *operatorPrefixUsecaseSuite) SetupTest() {
+	o.operatorRepo = new(repositorymock.MockOperatorPrefixRepository)
+	o.log = logger.NewLogger()
+	o.operatorUsecase = NewOperatorPrefixUseCase(o.operatorRepo, &o.log)
+}
+
+func TestOperatorPrefixUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(operatorPrefixUsecaseSuite))
+}
+
+func (o *operatorPrefixUsecaseSuite) TestSave_NormalizesPrefix() {
+	saved := entity.OperatorPrefix{Prefix: "0812", Operator: "Telkomsel"}
+	o.operatorRepo.On("Save", saved).Return(saved, nil).Once()
+
+	result, err := o.operatorUsecase.Save("+62812", " Telkomsel ")
+
+	o.NoError(err)
+	o.Equal(saved, result)
+}
+
+func (o *operatorPrefixUsecaseSuite) TestSave_InvalidPrefix() {
+	_, err := o.operatorUsecase.Save("0212", "Telkomsel")
+
+	o.ErrorIs(err, ErrInvalidDestinationNumber)
+	o.operatorRepo.AssertNotCalled(o.T(), "Save", mock.Anything)
+}
+
+func (o *operatorPrefixUsecaseSuite) TestSave_CanonicalOperator() {
+	saved := entity.OperatorPrefix{Prefix: "0896", Operator: "Tri"}
+	o.operatorRepo.On("Save", saved).Return(saved, nil).Once()
+
+	result, err := o.operatorUsecase.Save("0896", "Three")
+
+	o.NoError(err)
+	o.Equal(saved, result)
+}
+
+func (o *operatorPrefixUsecaseSuite) TestSave_UnknownOperator() {
+	_, err := o.operatorUsecase.Save("0812", "Telkomsell")
+
+	o.ErrorIs(err, ErrInvalidOperator)
+	o.operatorRepo.AssertNotCalled(o.T(), "Save", mock.Anything)
+}
+
+func (o *operatorPrefixUsecaseSuite) TestDelete_NotFound() {
+	o.operatorRepo.On("Delete", "0899").Return(sql.ErrNoRows).Once()
+
+	err := o.operatorUsecase.Delete("62899")
+
+	o.ErrorIs(err, ErrOperatorPrefixNotFound)
+}
@@ -0,0 +1,30 @@
+package usecase
+
+import "server-pulsa-app/internal/shared/model"
+
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 100
+)
+
+func normalizePaging(page, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return page, limit
+}
+
+func newPaging(page, limit, total int) model.Paging {
+	return model.Paging{
+		Page:       page,
+		Limit:      limit,
+		TotalRows:  total,
+		TotalPages: (total + limit - 1) / limit,
+	}
+}
@@ -0,0 +1,20 @@
+package usecase
+
+import (
+	"net/http"
+
+	"server-pulsa-app/internal/entity"
+)
+
+// PaymentGateway collects topup payments from merchants. Implementations translate the gateway's own
+// statuses into topup statuses, so the usecase never depends on a particular provider.
+type PaymentGateway interface {
+	// CreateCharge opens a payment for the topup, using topup.Id as the gateway order id.
+	CreateCharge(topup entity.TopupRequest) (entity.PaymentSession, error)
+	// GetStatus asks the gateway for the current state of an order.
+	GetStatus(orderId string) (entity.PaymentNotification, error)
+	Cancel(orderId string) error
+	Refund(orderId string, amount int, reason string) error
+	// ParseNotification authenticates a webhook request and returns an error when it was not sent by the gateway.
+	ParseNotification(header http.Header, body []byte) (entity.PaymentNotification, error)
+}
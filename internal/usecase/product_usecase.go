@@ -10,21 +10,29 @@ import (
 // var logProduct = logger.GetLogger()
 
 type ProductUseCase interface {
-	CreateNewProduct(Product entity.Product) (entity.Product, error)
+	CreateNewProduct(Product entity.Product, actor entity.AuditActor) (entity.Product, error)
 	FindAllProduct() ([]entity.Product, error)
 	FindProductById(id string) (entity.Product, error)
-	UpdateProduct(Product entity.Product) (entity.Product, error)
-	DeleteProduct(id string) error
+	UpdateProduct(Product entity.Product, actor entity.AuditActor) (entity.Product, error)
+	DeleteProduct(id string, actor entity.AuditActor) error
 }
 
 type productUseCase struct {
-	repo repository.ProductRepository
-	log  *logger.Logger
+	repo  repository.ProductRepository
+	audit AuditUseCase
+	log   *logger.Logger
 }
 
-func (p *productUseCase) CreateNewProduct(Product entity.Product) (entity.Product, error) {
+func (p *productUseCase) CreateNewProduct(Product entity.Product, actor entity.AuditActor) (entity.Product, error) {
 	p.log.Info("Starting to create a new product in the usecase layer", nil)
-	return p.repo.Create(Product)
+
+	product, err := p.repo.Create(Product)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
+	p.audit.Record(actor, entity.AuditCreate, entity.AuditProduct, product.IdProduct, nil, product)
+	return product, nil
 }
 
 func (p *productUseCase) FindAllProduct() ([]entity.Product, error) {
@@ -37,30 +45,41 @@ func (p *productUseCase) FindProductById(id string) (entity.Product, error) {
 	return p.repo.Get(id)
 }
 
-func (p *productUseCase) UpdateProduct(product entity.Product) (entity.Product, error) {
+func (p *productUseCase) UpdateProduct(product entity.Product, actor entity.AuditActor) (entity.Product, error) {
 	p.log.Info("Starting to retrive a product by id in the usecase layer", nil)
 
-	_, err := p.repo.Get(product.IdProduct)
+	before, err := p.repo.Get(product.IdProduct)
 	if err != nil {
 		return entity.Product{}, fmt.Errorf("product with ID %s not found", product.IdProduct)
 	}
 
+	updated, err := p.repo.Update(product)
+	if err != nil {
+		return entity.Product{}, err
+	}
+
 	p.log.Info("Product ID %s has been updated successfully: ", product.IdProduct)
-	return p.repo.Update(product)
+	p.audit.Record(actor, entity.AuditUpdate, entity.AuditProduct, product.IdProduct, before, updated)
+	return updated, nil
 }
 
-func (p *productUseCase) DeleteProduct(id string) error {
+func (p *productUseCase) DeleteProduct(id string, actor entity.AuditActor) error {
 	p.log.Info("Starting to retrive a product by id in the usecase layer", nil)
 
-	_, err := p.repo.Get(id)
+	product, err := p.repo.Get(id)
 	if err != nil {
 		return fmt.Errorf("product with ID %s not found", id)
 	}
 
+	if err := p.repo.Delete(id); err != nil {
+		return err
+	}
+
 	p.log.Info("Product has been deleted successfully: ", id)
-	return p.repo.Delete(id)
+	p.audit.Record(actor, entity.AuditDelete, entity.AuditProduct, id, product, nil)
+	return nil
 }
 
-func NewProductUseCase(repo repository.ProductRepository, log *logger.Logger) ProductUseCase {
-	return &productUseCase{repo: repo, log: log}
+func NewProductUseCase(repo repository.ProductRepository, audit AuditUseCase, log *logger.Logger) ProductUseCase {
+	return &productUseCase{repo: repo, audit: audit, log: log}
 }
@@ -4,6 +4,7 @@ import (
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -12,14 +13,16 @@ import (
 type productUsecaseTestSuite struct {
 	suite.Suite
 	mockProductRepository *repositorymock.MockProductRepository
+	audit                 *usecase_mock.AuditUseCaseMock
 	ProductUseCase        ProductUseCase
 	log                   logger.Logger
 }
 
 func (p *productUsecaseTestSuite) SetupTest() {
 	p.mockProductRepository = new(repositorymock.MockProductRepository)
+	p.audit = new(usecase_mock.AuditUseCaseMock)
 	p.log = logger.NewLogger()
-	p.ProductUseCase = NewProductUseCase(p.mockProductRepository, &p.log)
+	p.ProductUseCase = NewProductUseCase(p.mockProductRepository, p.audit, &p.log)
 }
 
 func (p *productUsecaseTestSuite) TestCreateNewProduct_Success() {
@@ -38,12 +41,15 @@ func (p *productUsecaseTestSuite) TestCreateNewProduct_Success() {
 		IdSupliyer:   "1",
 	}
 
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin}
 	p.mockProductRepository.On("Create", newProduct).Return(CreatedProduct, nil).Once()
+	p.audit.On("Record", actor, entity.AuditCreate, entity.AuditProduct, "1", nil, CreatedProduct).Once()
 
-	product, err := p.ProductUseCase.CreateNewProduct(newProduct)
+	product, err := p.ProductUseCase.CreateNewProduct(newProduct, actor)
 
 	p.Nil(err)
 	p.Equal(CreatedProduct, product)
+	p.audit.AssertExpectations(p.T())
 }
 
 func (p *productUsecaseTestSuite) TestListAllProducts_Success() {
@@ -104,8 +110,9 @@ func (p *productUsecaseTestSuite) TestUpdateProduct_Success() {
 
 	p.mockProductRepository.On("Get", id).Return(updatedProduct, nil).Once()
 	p.mockProductRepository.On("Update", updatedProduct).Return(updatedProduct, nil).Once()
+	p.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditProduct, id, updatedProduct, updatedProduct).Once()
 
-	productUpdated, err := p.ProductUseCase.UpdateProduct(updatedProduct)
+	productUpdated, err := p.ProductUseCase.UpdateProduct(updatedProduct, entity.AuditActor{})
 
 	p.Nil(err)
 	p.Equal(updatedProduct, productUpdated)
@@ -116,10 +123,12 @@ func (p *productUsecaseTestSuite) TestDeleteProduct_Success() {
 
 	p.mockProductRepository.On("Get", id).Return(entity.Product{}, nil).Once()
 	p.mockProductRepository.On("Delete", id).Return(nil).Once()
+	p.audit.On("Record", entity.AuditActor{}, entity.AuditDelete, entity.AuditProduct, id, entity.Product{}, nil).Once()
 
-	err := p.ProductUseCase.DeleteProduct(id)
+	err := p.ProductUseCase.DeleteProduct(id, entity.AuditActor{})
 
 	p.Nil(err)
+	p.audit.AssertExpectations(p.T())
 }
 
 func TestProductUsecaseTestSuite(t *testing.T) {
@@ -1,17 +1,22 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/repository"
 	"server-pulsa-app/internal/shared/custom"
+	"time"
 
 	"github.com/xuri/excelize/v2"
 )
 
+var ErrInvalidReportPeriod = errors.New("invalid report period")
+
 type ReportUseCase interface {
 	FindAllTransactions(userId, startDate, endDate string) error
+	Profit(startDate, endDate, merchantId string) (custom.ProfitSummary, error)
 }
 
 type reportUseCase struct {
@@ -112,6 +117,42 @@ func (r *reportUseCase) FindAllTransactions(userId, startDate, endDate string) e
 	return nil
 }
 
+// Profit returns the gross sales, cost and profit per merchant, provider and day between startDate and
+// endDate (dd-mm-yyyy, inclusive) with their totals. An empty merchantId reports every merchant.
+func (r *reportUseCase) Profit(startDate, endDate, merchantId string) (custom.ProfitSummary, error) {
+	r.log.Info("Starting to retrive profit report in the usecase layer", nil)
+
+	start, err := time.Parse("02-01-2006", startDate)
+	if err != nil {
+		return custom.ProfitSummary{}, fmt.Errorf("%w: startDate must be formatted as dd-mm-yyyy", ErrInvalidReportPeriod)
+	}
+	end, err := time.Parse("02-01-2006", endDate)
+	if err != nil {
+		return custom.ProfitSummary{}, fmt.Errorf("%w: endDate must be formatted as dd-mm-yyyy", ErrInvalidReportPeriod)
+	}
+	if end.Before(start) {
+		return custom.ProfitSummary{}, fmt.Errorf("%w: endDate is before startDate", ErrInvalidReportPeriod)
+	}
+
+	rows, err := r.repo.Profit(start, end, merchantId)
+	if err != nil {
+		return custom.ProfitSummary{}, err
+	}
+
+	summary := custom.ProfitSummary{StartDate: startDate, EndDate: endDate, Rows: rows}
+	for _, row := range rows {
+		summary.Count += row.Count
+		summary.GrossSales += row.GrossSales
+		summary.Cost += row.Cost
+		summary.Profit += row.Profit
+	}
+	if summary.Rows == nil {
+		summary.Rows = []custom.ProfitReport{}
+	}
+
+	return summary, nil
+}
+
 func NewReportUseCase(repo repository.ReportRepository, log *logger.Logger) ReportUseCase {
 	return &reportUseCase{repo: repo, log: log}
 }
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/shared/custom"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type reportUsecaseSuite struct {
+	suite.Suite
+	reportRepo    *repositorymock.MockReportRepository
+	reportUsecase ReportUseCase
+	log           logger.Logger
+}
+
+func (r *reportUsecaseSuite) SetupTest() {
+	r.reportRepo = new(repositorymock.MockReportRepository)
+	r.log = logger.NewLogger()
+	r.reportUsecase = NewReportUseCase(r.reportRepo, &r.log)
+}
+
+func TestReportUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(reportUsecaseSuite))
+}
+
+func (r *reportUsecaseSuite) TestProfit_Totals() {
+	rows := []custom.ProfitReport{
+		{MerchantId: "merchant-a", Provider: "Telkomsel", Date: "01-11-2024", Count: 2, GrossSales: 21000, Cost: 19000, Profit: 2000},
+		{MerchantId: "merchant-a", Provider: "XL", Date: "02-11-2024", Count: 1, GrossSales: 26000, Cost: 25000, Profit: 1000},
+	}
+	start := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 11, 30, 0, 0, 0, 0, time.UTC)
+	r.reportRepo.On("Profit", start, end, "merchant-a").Return(rows, nil).Once()
+
+	summary, err := r.reportUsecase.Profit("01-11-2024", "30-11-2024", "merchant-a")
+
+	r.NoError(err)
+	r.Equal(3, summary.Count)
+	r.Equal(float64(47000), summary.GrossSales)
+	r.Equal(float64(44000), summary.Cost)
+	r.Equal(float64(3000), summary.Profit)
+	r.Equal(rows, summary.Rows)
+}
+
+func (r *reportUsecaseSuite) TestProfit_InvalidPeriod() {
+	for _, period := range [][2]string{{"", "30-11-2024"}, {"01-11-2024", "2024-11-30"}, {"30-11-2024", "01-11-2024"}} {
+		_, err := r.reportUsecase.Profit(period[0], period[1], "")
+		r.ErrorIs(err, ErrInvalidReportPeriod)
+	}
+	r.reportRepo.AssertNotCalled(r.T(), "Profit", mock.Anything, mock.Anything, mock.Anything)
+}
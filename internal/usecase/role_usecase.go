@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+)
+
+var (
+	ErrInvalidRole  = errors.New("invalid role")
+	ErrRoleNotFound = errors.New("role not found")
+	ErrBuiltInRole  = errors.New("built in roles can't be changed")
+	ErrRoleConflict = errors.New("role conflict")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+type RoleUseCase interface {
+	ListPermissions() []string
+	ListRoles() ([]entity.Role, error)
+	CreateRole(payload entity.RoleRequest, actor entity.AuditActor) (entity.Role, error)
+	UpdateRole(name string, payload entity.RoleRequest, actor entity.AuditActor) (entity.Role, error)
+	DeleteRole(name string, actor entity.AuditActor) error
+	SetRoleMFA(name string, required bool, actor entity.AuditActor) (entity.Role, error)
+}
+
+type roleUseCase struct {
+	repo  repository.RoleRepository
+	audit AuditUseCase
+	log   *logger.Logger
+}
+
+func (r *roleUseCase) ListPermissions() []string {
+	return entity.Permissions
+}
+
+func (r *roleUseCase) ListRoles() ([]entity.Role, error) {
+	r.log.Info("Starting to retrive all roles in the usecase layer", nil)
+	return r.repo.List()
+}
+
+func (r *roleUseCase) CreateRole(payload entity.RoleRequest, actor entity.AuditActor) (entity.Role, error) {
+	r.log.Info("Starting to create a role in the usecase layer", payload)
+
+	role, err := newRole(payload.Name, payload)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	role, err = r.repo.Create(role)
+	if errors.Is(err, repository.ErrRoleExists) {
+		return entity.Role{}, fmt.Errorf("%w: %v", ErrRoleConflict, err)
+	} else if err != nil {
+		return entity.Role{}, err
+	}
+
+	r.audit.Record(actor, entity.AuditCreate, entity.AuditRole, role.Name, nil, role)
+	return role, nil
+}
+
+// UpdateRole replaces the description and permissions of a custom role. Built in roles are read only so the
+// admin role can never lose the permission to manage roles.
+func (r *roleUseCase) UpdateRole(name string, payload entity.RoleRequest, actor entity.AuditActor) (entity.Role, error) {
+	r.log.Info("Starting to update a role in the usecase layer", name)
+
+	role, err := newRole(name, payload)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	before, err := r.checkCustom(role.Name)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	updated, err := r.repo.Update(role)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	r.audit.Record(actor, entity.AuditUpdate, entity.AuditRole, updated.Name, before, updated)
+	return updated, nil
+}
+
+func (r *roleUseCase) DeleteRole(name string, actor entity.AuditActor) error {
+	r.log.Info("Starting to delete a role in the usecase layer", name)
+
+	role, err := r.checkCustom(name)
+	if err != nil {
+		return err
+	}
+
+	err = r.repo.Delete(role.Name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrRoleNotFound
+	case errors.Is(err, repository.ErrRoleInUse):
+		return fmt.Errorf("%w: %v", ErrRoleConflict, err)
+	case err != nil:
+		return err
+	}
+
+	r.audit.Record(actor, entity.AuditDelete, entity.AuditRole, role.Name, role, nil)
+	return nil
+}
+
+// SetRoleMFA makes two factor authentication mandatory, or optional again, for the users of a role. Unlike the
+// permissions it can be changed on built in roles too.
+func (r *roleUseCase) SetRoleMFA(name string, required bool, actor entity.AuditActor) (entity.Role, error) {
+	r.log.Info("Starting to set the mfa requirement of a role in the usecase layer", name)
+
+	before, err := r.getRole(name)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	err = r.repo.SetRequireMFA(name, required)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.Role{}, ErrRoleNotFound
+	} else if err != nil {
+		return entity.Role{}, fmt.Errorf("failed to set mfa requirement: %w", err)
+	}
+
+	role, err := r.repo.Get(name)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	r.audit.Record(actor, entity.AuditUpdate, entity.AuditRole, role.Name, before, role)
+	return role, nil
+}
+
+func (r *roleUseCase) getRole(name string) (entity.Role, error) {
+	role, err := r.repo.Get(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.Role{}, ErrRoleNotFound
+	} else if err != nil {
+		return entity.Role{}, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+// checkCustom returns the role called name, unless it is missing or built in.
+func (r *roleUseCase) checkCustom(name string) (entity.Role, error) {
+	existing, err := r.getRole(name)
+	if err != nil {
+		return entity.Role{}, err
+	}
+
+	if existing.BuiltIn {
+		return entity.Role{}, ErrBuiltInRole
+	}
+	return existing, nil
+}
+
+// newRole validates a role request: a lowercase name and only known permissions, without duplicates.
+func newRole(name string, payload entity.RoleRequest) (entity.Role, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !roleNamePattern.MatchString(name) {
+		return entity.Role{}, fmt.Errorf("%w: name must be 2 to 50 lowercase letters, digits, _ or -", ErrInvalidRole)
+	}
+
+	permissions := make([]string, 0, len(payload.Permissions))
+	for _, permission := range payload.Permissions {
+		if !slices.Contains(entity.Permissions, permission) {
+			return entity.Role{}, fmt.Errorf("%w: unknown permission %q", ErrInvalidRole, permission)
+		}
+		if !slices.Contains(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+
+	return entity.Role{Name: name, Description: strings.TrimSpace(payload.Description), Permissions: permissions}, nil
+}
+
+func NewRoleUseCase(repo repository.RoleRepository, audit AuditUseCase, log *logger.Logger) RoleUseCase {
+	return &roleUseCase{repo: repo, audit: audit, log: log}
+}
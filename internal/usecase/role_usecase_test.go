@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"testing"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/repository"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type roleUsecaseSuite struct {
+	suite.Suite
+	roleRepo    *repositorymock.MockRoleRepository
+	audit       *usecase_mock.AuditUseCaseMock
+	roleUsecase RoleUseCase
+	log         logger.Logger
+}
+
+func (r *roleUsecaseSuite) SetupTest() {
+	r.roleRepo = new(repositorymock.MockRoleRepository)
+	r.audit = new(usecase_mock.AuditUseCaseMock)
+	r.log = logger.NewLogger()
+	r.roleUsecase = NewRoleUseCase(r.roleRepo, r.audit, &r.log)
+}
+
+func TestRoleUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(roleUsecaseSuite))
+}
+
+func (r *roleUsecaseSuite) TestCreateRole_NormalizesRole() {
+	role := entity.Role{Name: "finance", Description: "Reads reports", Permissions: []string{entity.PermReportRead, entity.PermReportProfit}}
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin}
+	r.roleRepo.On("Create", role).Return(role, nil).Once()
+	r.audit.On("Record", actor, entity.AuditCreate, entity.AuditRole, "finance", nil, role).Once()
+
+	result, err := r.roleUsecase.CreateRole(entity.RoleRequest{
+		Name:        " Finance ",
+		Description: "Reads reports ",
+		Permissions: []string{entity.PermReportRead, entity.PermReportProfit, entity.PermReportRead},
+	}, actor)
+
+	r.NoError(err)
+	r.Equal(role, result)
+	r.audit.AssertExpectations(r.T())
+}
+
+func (r *roleUsecaseSuite) TestCreateRole_UnknownPermission() {
+	_, err := r.roleUsecase.CreateRole(entity.RoleRequest{Name: "supervisor", Permissions: []string{"everything:*"}}, entity.AuditActor{})
+
+	r.ErrorIs(err, ErrInvalidRole)
+	r.roleRepo.AssertNotCalled(r.T(), "Create", mock.Anything)
+}
+
+func (r *roleUsecaseSuite) TestUpdateRole_BuiltInIsReadOnly() {
+	r.roleRepo.On("Get", entity.RoleAdmin).Return(entity.Role{Name: entity.RoleAdmin, BuiltIn: true}, nil).Once()
+
+	_, err := r.roleUsecase.UpdateRole(entity.RoleAdmin, entity.RoleRequest{Permissions: []string{entity.PermReportRead}}, entity.AuditActor{})
+
+	r.ErrorIs(err, ErrBuiltInRole)
+	r.roleRepo.AssertNotCalled(r.T(), "Update", mock.Anything)
+}
+
+func (r *roleUsecaseSuite) TestUpdateRole_AuditsChange() {
+	before := entity.Role{Name: "supervisor", Permissions: []string{entity.PermReportRead}}
+	role := entity.Role{Name: "supervisor", Permissions: []string{entity.PermReportRead, entity.PermReportProfit}}
+	r.roleRepo.On("Get", "supervisor").Return(before, nil).Once()
+	r.roleRepo.On("Update", role).Return(role, nil).Once()
+	r.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditRole, "supervisor", before, role).Once()
+
+	_, err := r.roleUsecase.UpdateRole("supervisor", entity.RoleRequest{Permissions: role.Permissions}, entity.AuditActor{})
+
+	r.NoError(err)
+	r.audit.AssertExpectations(r.T())
+}
+
+func (r *roleUsecaseSuite) TestDeleteRole_InUse() {
+	r.roleRepo.On("Get", "supervisor").Return(entity.Role{Name: "supervisor"}, nil).Once()
+	r.roleRepo.On("Delete", "supervisor").Return(repository.ErrRoleInUse).Once()
+
+	err := r.roleUsecase.DeleteRole("supervisor", entity.AuditActor{})
+
+	r.ErrorIs(err, ErrRoleConflict)
+	r.audit.AssertNotCalled(r.T(), "Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (r *roleUsecaseSuite) TestSetRoleMFA_BuiltInAllowed() {
+	before := entity.Role{Name: entity.RoleAdmin, BuiltIn: true}
+	after := entity.Role{Name: entity.RoleAdmin, BuiltIn: true, RequireMFA: true}
+	r.roleRepo.On("Get", entity.RoleAdmin).Return(before, nil).Once()
+	r.roleRepo.On("SetRequireMFA", entity.RoleAdmin, true).Return(nil).Once()
+	r.roleRepo.On("Get", entity.RoleAdmin).Return(after, nil).Once()
+	r.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditRole, entity.RoleAdmin, before, after).Once()
+
+	role, err := r.roleUsecase.SetRoleMFA(entity.RoleAdmin, true, entity.AuditActor{})
+
+	r.NoError(err)
+	r.True(role.RequireMFA)
+	r.audit.AssertExpectations(r.T())
+}
@@ -0,0 +1,9 @@
+package usecase
+
+import "server-pulsa-app/internal/entity"
+
+// ScheduleNotifier tells the merchant about scheduled runs that did not create a transaction,
+// e.g. because the merchant balance was insufficient.
+type ScheduleNotifier interface {
+	Notify(schedule entity.TransactionSchedule, run entity.TransactionScheduleRun)
+}
@@ -0,0 +1,251 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+	"server-pulsa-app/internal/shared/cron"
+)
+
+// scheduleLease is how long a claimed schedule stays hidden from other workers.
+const scheduleLease = 5 * time.Minute
+
+var (
+	ErrScheduleNotFound  = errors.New("transaction schedule not found")
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+)
+
+type ScheduleUseCase interface {
+	Create(payload entity.TransactionSchedule) (entity.TransactionSchedule, error)
+	FindAll(userId string) ([]entity.TransactionSchedule, error)
+	FindRuns(id, userId string) ([]entity.TransactionScheduleRun, error)
+	Deactivate(id, userId string) error
+	ProcessDue() (int, error)
+	Run(ctx context.Context)
+}
+
+type scheduleUseCase struct {
+	repo          repository.ScheduleRepository
+	transactionUc TransactionUseCase
+	merchants     MerchantOwner
+	notifier      ScheduleNotifier
+	cfg           config.ScheduleConfig
+	log           *logger.Logger
+	now           func() time.Time
+}
+
+func (s *scheduleUseCase) Create(payload entity.TransactionSchedule) (entity.TransactionSchedule, error) {
+	s.log.Info("Starting to create a new transaction schedule in the usecase layer", nil)
+
+	recurrence, err := cron.Parse(payload.Recurrence)
+	if err != nil {
+		return entity.TransactionSchedule{}, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+
+	payload.OccurrenceAt = recurrence.Next(s.now())
+	if payload.OccurrenceAt.IsZero() {
+		return entity.TransactionSchedule{}, fmt.Errorf("%w: %q never occurs", ErrInvalidRecurrence, payload.Recurrence)
+	}
+
+	if payload.DestinationNumber, err = normalizeDestinationNumber(payload.DestinationNumber); err != nil {
+		return entity.TransactionSchedule{}, err
+	}
+
+	if payload.MerchantId, err = s.merchants.MerchantForUser(payload.UserId, payload.MerchantId); err != nil {
+		return entity.TransactionSchedule{}, err
+	}
+
+	return s.repo.Create(payload)
+}
+
+// FindAll returns the schedules of the user, leaving out those of merchants the user no longer owns.
+func (s *scheduleUseCase) FindAll(userId string) ([]entity.TransactionSchedule, error) {
+	s.log.Info("Starting to retrive transaction schedules in the usecase layer", nil)
+
+	schedules, err := s.repo.List(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make(map[string]bool)
+	result := make([]entity.TransactionSchedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		ok, checked := owned[schedule.MerchantId]
+		if !checked {
+			if ok, err = s.ownsMerchant(userId, schedule.MerchantId); err != nil {
+				return nil, err
+			}
+			owned[schedule.MerchantId] = ok
+		}
+		if ok {
+			result = append(result, schedule)
+		}
+	}
+	return result, nil
+}
+
+func (s *scheduleUseCase) ownsMerchant(userId, merchantId string) (bool, error) {
+	_, err := s.merchants.MerchantForUser(userId, merchantId)
+	if errors.Is(err, ErrTopupMerchantForbidden) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *scheduleUseCase) get(id, userId string) (entity.TransactionSchedule, error) {
+	schedule, err := s.repo.Get(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entity.TransactionSchedule{}, ErrScheduleNotFound
+		}
+		return entity.TransactionSchedule{}, err
+	}
+	if schedule.UserId != userId {
+		return entity.TransactionSchedule{}, ErrScheduleNotFound
+	}
+	if owned, err := s.ownsMerchant(userId, schedule.MerchantId); err != nil {
+		return entity.TransactionSchedule{}, err
+	} else if !owned {
+		return entity.TransactionSchedule{}, ErrScheduleNotFound
+	}
+	return schedule, nil
+}
+
+func (s *scheduleUseCase) FindRuns(id, userId string) ([]entity.TransactionScheduleRun, error) {
+	s.log.Info("Starting to retrive transaction schedule runs in the usecase layer", id)
+
+	if _, err := s.get(id, userId); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRuns(id)
+}
+
+func (s *scheduleUseCase) Deactivate(id, userId string) error {
+	s.log.Info("Starting to deactivate a transaction schedule in the usecase layer", id)
+
+	if _, err := s.get(id, userId); err != nil {
+		return err
+	}
+	return s.repo.Deactivate(id)
+}
+
+// ProcessDue creates the transactions of every due schedule and returns how many were created.
+func (s *scheduleUseCase) ProcessDue() (int, error) {
+	schedules, err := s.repo.ClaimDue(s.now(), scheduleLease, s.cfg.BatchSize)
+	if err != nil {
+		s.log.Error("Failed to claim due transaction schedules", err)
+		return 0, err
+	}
+
+	created := 0
+	for _, schedule := range schedules {
+		schedule, run := s.runSchedule(schedule)
+		if run.Status == entity.ScheduleRunSuccess {
+			created++
+		} else {
+			s.notifier.Notify(schedule, run)
+		}
+
+		if err := s.repo.Finish(schedule, run); err != nil {
+			s.log.Error("Failed to store transaction schedule run", err)
+		}
+	}
+
+	return created, nil
+}
+
+// runSchedule creates the transaction of the current occurrence. The occurrence is used as the
+// idempotency key so a run that is repeated after a crash never sells twice. An insufficient
+// balance is retried every RetryInterval up to MaxRetries times before the occurrence is skipped;
+// any other error fails the occurrence. Either way the schedule then moves to its next occurrence.
+func (s *scheduleUseCase) runSchedule(schedule entity.TransactionSchedule) (entity.TransactionSchedule, entity.TransactionScheduleRun) {
+	run := entity.TransactionScheduleRun{ScheduleId: schedule.Id, ScheduledFor: schedule.OccurrenceAt}
+
+	payload := entity.Transactions{
+		MerchantId:        schedule.MerchantId,
+		UserId:            schedule.UserId,
+		CustomerName:      schedule.CustomerName,
+		DestinationNumber: schedule.DestinationNumber,
+		TransactionDate:   schedule.OccurrenceAt.Format("02-01-2006"),
+		TransactionDetail: []entity.TransactionDetail{{ProductId: schedule.ProductId}},
+	}
+	key := entity.IdempotencyKey{Key: schedule.OccurrenceAt.UTC().Format(time.RFC3339), Scope: "schedule:" + schedule.Id}
+
+	actor := entity.SystemActor("transaction schedule")
+	actor.IdUser = schedule.UserId
+
+	transaction, _, err := s.transactionUc.CreateIdempotent(payload, key, actor)
+	switch {
+	case err == nil:
+		run.Status, run.TransactionId = entity.ScheduleRunSuccess, transaction.TransactionsId
+	case errors.Is(err, repository.ErrInsufficientBalance) && schedule.RetryCount < s.cfg.MaxRetries:
+		schedule.RetryCount++
+		schedule.NextRunAt = s.now().Add(s.cfg.RetryInterval)
+		run.Status = entity.ScheduleRunRetry
+		run.Message = fmt.Sprintf("%v, retry %d of %d at %s", err, schedule.RetryCount, s.cfg.MaxRetries, schedule.NextRunAt.Format(time.RFC3339))
+		return schedule, run
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		run.Status, run.Message = entity.ScheduleRunSkipped, fmt.Sprintf("%v, skipped after %d retries", err, schedule.RetryCount)
+	default:
+		run.Status, run.Message = entity.ScheduleRunFailed, err.Error()
+	}
+
+	return s.advance(schedule), run
+}
+
+// advance moves the schedule to its first occurrence after both the current occurrence and now, so
+// occurrences missed while the server was down are not replayed in a burst.
+func (s *scheduleUseCase) advance(schedule entity.TransactionSchedule) entity.TransactionSchedule {
+	schedule.RetryCount = 0
+
+	recurrence, err := cron.Parse(schedule.Recurrence)
+	if err != nil {
+		s.log.Error("Deactivating transaction schedule with invalid recurrence", err)
+		schedule.Active = false
+		return schedule
+	}
+
+	from := schedule.OccurrenceAt
+	if now := s.now(); now.After(from) {
+		from = now
+	}
+
+	schedule.OccurrenceAt = recurrence.Next(from)
+	schedule.NextRunAt = schedule.OccurrenceAt
+	if schedule.OccurrenceAt.IsZero() {
+		schedule.Active = false
+		schedule.OccurrenceAt, schedule.NextRunAt = from, from
+	}
+	return schedule
+}
+
+// Run processes due schedules every configured interval until ctx is cancelled.
+func (s *scheduleUseCase) Run(ctx context.Context) {
+	s.log.Info("Starting the transaction scheduler", s.cfg)
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.log.Info("Transaction scheduler stopped", nil)
+			return
+		case <-ticker.C:
+			if _, err := s.ProcessDue(); err != nil {
+				s.log.Error("Transaction scheduler run failed", err)
+			}
+		}
+	}
+}
+
+func NewScheduleUseCase(repo repository.ScheduleRepository, transactionUc TransactionUseCase, merchants MerchantOwner, notifier ScheduleNotifier, cfg config.ScheduleConfig, log *logger.Logger) ScheduleUseCase {
+	return &scheduleUseCase{repo: repo, transactionUc: transactionUc, merchants: merchants, notifier: notifier, cfg: cfg, log: log, now: time.Now}
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/repository"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type scheduleNotifierMock struct {
+	mock.Mock
+}
+
+func (n *scheduleNotifierMock) Notify(schedule entity.TransactionSchedule, run entity.TransactionScheduleRun) {
+	n.Called(schedule, run)
+}
+
+type merchantOwnerMock struct {
+	mock.Mock
+}
+
+func (m *merchantOwnerMock) MerchantForUser(idUser, idMerchant string) (string, error) {
+	args := m.Called(idUser, idMerchant)
+	return args.String(0), args.Error(1)
+}
+
+type scheduleUsecaseSuite struct {
+	suite.Suite
+	scheduleRepo    *repositorymock.MockScheduleRepository
+	transactionUc   *usecase_mock.MockTransactionUseCase
+	merchants       *merchantOwnerMock
+	notifier        *scheduleNotifierMock
+	scheduleUsecase *scheduleUseCase
+	log             logger.Logger
+	now             time.Time
+}
+
+func (s *scheduleUsecaseSuite) SetupTest() {
+	s.scheduleRepo = new(repositorymock.MockScheduleRepository)
+	s.transactionUc = new(usecase_mock.MockTransactionUseCase)
+	s.merchants = new(merchantOwnerMock)
+	s.notifier = new(scheduleNotifierMock)
+	s.log = logger.NewLogger()
+	s.now = time.Date(2024, 11, 1, 8, 0, 30, 0, time.UTC)
+
+	cfg := config.ScheduleConfig{BatchSize: 20, RetryInterval: 30 * time.Minute, MaxRetries: 2}
+	s.scheduleUsecase = NewScheduleUseCase(s.scheduleRepo, s.transactionUc, s.merchants, s.notifier, cfg, &s.log).(*scheduleUseCase)
+	s.scheduleUsecase.now = func() time.Time { return s.now }
+}
+
+func TestScheduleUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(scheduleUsecaseSuite))
+}
+
+func (s *scheduleUsecaseSuite) dueSchedule(retries int) entity.TransactionSchedule {
+	occurrence := time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+	schedule := entity.TransactionSchedule{
+		Id: "schedule-uuid", MerchantId: "merchant-uuid", UserId: "user-uuid", CustomerName: "budi",
+		DestinationNumber: "081234567890", ProductId: "product-uuid", Recurrence: "0 8 1 * *",
+		OccurrenceAt: occurrence, NextRunAt: occurrence, RetryCount: retries, Active: true,
+	}
+	s.scheduleRepo.On("ClaimDue", s.now, scheduleLease, 20).Return([]entity.TransactionSchedule{schedule}, nil).Once()
+	return schedule
+}
+
+func (s *scheduleUsecaseSuite) expectTransaction(schedule entity.TransactionSchedule, transaction entity.Transactions, err error) {
+	payload := entity.Transactions{
+		MerchantId: "merchant-uuid", UserId: "user-uuid", CustomerName: "budi", DestinationNumber: "081234567890",
+		TransactionDate: "01-11-2024", TransactionDetail: []entity.TransactionDetail{{ProductId: "product-uuid"}},
+	}
+	key := entity.IdempotencyKey{Key: "2024-11-01T08:00:00Z", Scope: "schedule:schedule-uuid"}
+	s.transactionUc.On("CreateIdempotent", payload, key, mock.Anything).Return(transaction, false, err).Once()
+}
+
+func (s *scheduleUsecaseSuite) TestProcessDue_Success() {
+	schedule := s.dueSchedule(0)
+	s.expectTransaction(schedule, entity.Transactions{TransactionsId: "tx-uuid"}, nil)
+
+	next := schedule
+	next.OccurrenceAt = time.Date(2024, 12, 1, 8, 0, 0, 0, time.UTC)
+	next.NextRunAt = next.OccurrenceAt
+	s.scheduleRepo.On("Finish", next, entity.TransactionScheduleRun{
+		ScheduleId: "schedule-uuid", ScheduledFor: schedule.OccurrenceAt, Status: entity.ScheduleRunSuccess, TransactionId: "tx-uuid",
+	}).Return(nil).Once()
+
+	created, err := s.scheduleUsecase.ProcessDue()
+
+	s.NoError(err)
+	s.Equal(1, created)
+	s.notifier.AssertNotCalled(s.T(), "Notify", mock.Anything, mock.Anything)
+	s.scheduleRepo.AssertExpectations(s.T())
+}
+
+func (s *scheduleUsecaseSuite) TestProcessDue_InsufficientBalanceRetries() {
+	schedule := s.dueSchedule(1)
+	s.expectTransaction(schedule, entity.Transactions{}, fmt.Errorf("%w: required 10000, current balance 0", repository.ErrInsufficientBalance))
+
+	retry := schedule
+	retry.RetryCount = 2
+	retry.NextRunAt = s.now.Add(30 * time.Minute)
+	s.notifier.On("Notify", retry, mock.MatchedBy(func(run entity.TransactionScheduleRun) bool {
+		return run.Status == entity.ScheduleRunRetry
+	})).Once()
+	s.scheduleRepo.On("Finish", retry, mock.Anything).Return(nil).Once()
+
+	created, err := s.scheduleUsecase.ProcessDue()
+
+	s.NoError(err)
+	s.Equal(0, created)
+	s.notifier.AssertExpectations(s.T())
+	s.scheduleRepo.AssertExpectations(s.T())
+}
+
+func (s *scheduleUsecaseSuite) TestProcessDue_InsufficientBalanceSkipsAfterRetries() {
+	schedule := s.dueSchedule(2)
+	s.expectTransaction(schedule, entity.Transactions{}, fmt.Errorf("%w: required 10000, current balance 0", repository.ErrInsufficientBalance))
+
+	next := schedule
+	next.RetryCount = 0
+	next.OccurrenceAt = time.Date(2024, 12, 1, 8, 0, 0, 0, time.UTC)
+	next.NextRunAt = next.OccurrenceAt
+	skipped := mock.MatchedBy(func(run entity.TransactionScheduleRun) bool {
+		return run.Status == entity.ScheduleRunSkipped && run.ScheduledFor.Equal(schedule.OccurrenceAt)
+	})
+	s.notifier.On("Notify", next, skipped).Once()
+	s.scheduleRepo.On("Finish", next, skipped).Return(nil).Once()
+
+	_, err := s.scheduleUsecase.ProcessDue()
+
+	s.NoError(err)
+	s.notifier.AssertExpectations(s.T())
+	s.scheduleRepo.AssertExpectations(s.T())
+}
+
+func (s *scheduleUsecaseSuite) TestCreate_InvalidRecurrence() {
+	_, err := s.scheduleUsecase.Create(entity.TransactionSchedule{DestinationNumber: "081234567890", Recurrence: "every month"})
+
+	s.ErrorIs(err, ErrInvalidRecurrence)
+	s.scheduleRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *scheduleUsecaseSuite) TestCreate_FirstOccurrence() {
+	payload := entity.TransactionSchedule{MerchantId: "merchant-uuid", UserId: "user-uuid", DestinationNumber: "+62 812 3456 7890", Recurrence: "@monthly"}
+	s.merchants.On("MerchantForUser", "user-uuid", "merchant-uuid").Return("merchant-uuid", nil).Once()
+
+	expected := payload
+	expected.DestinationNumber = "081234567890"
+	expected.OccurrenceAt = time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	s.scheduleRepo.On("Create", expected).Return(expected, nil).Once()
+
+	schedule, err := s.scheduleUsecase.Create(payload)
+
+	s.NoError(err)
+	s.Equal(expected, schedule)
+}
+
+func (s *scheduleUsecaseSuite) TestCreate_ForeignMerchant() {
+	s.merchants.On("MerchantForUser", "user-uuid", "other-merchant").Return("", ErrTopupMerchantForbidden).Once()
+
+	_, err := s.scheduleUsecase.Create(entity.TransactionSchedule{
+		MerchantId: "other-merchant", UserId: "user-uuid", DestinationNumber: "081234567890", Recurrence: "@monthly",
+	})
+
+	s.ErrorIs(err, ErrTopupMerchantForbidden)
+	s.scheduleRepo.AssertNotCalled(s.T(), "Create", mock.Anything)
+}
+
+func (s *scheduleUsecaseSuite) TestFindAll_LeavesOutForeignMerchants() {
+	own := entity.TransactionSchedule{Id: "schedule-1", MerchantId: "merchant-uuid", UserId: "user-uuid"}
+	foreign := entity.TransactionSchedule{Id: "schedule-2", MerchantId: "other-merchant", UserId: "user-uuid"}
+	s.scheduleRepo.On("List", "user-uuid").Return([]entity.TransactionSchedule{own, foreign, own}, nil).Once()
+	s.merchants.On("MerchantForUser", "user-uuid", "merchant-uuid").Return("merchant-uuid", nil).Once()
+	s.merchants.On("MerchantForUser", "user-uuid", "other-merchant").Return("", ErrTopupMerchantForbidden).Once()
+
+	schedules, err := s.scheduleUsecase.FindAll("user-uuid")
+
+	s.NoError(err)
+	s.Equal([]entity.TransactionSchedule{own, own}, schedules)
+	s.merchants.AssertExpectations(s.T())
+}
+
+func (s *scheduleUsecaseSuite) TestDeactivate_ForeignMerchant() {
+	s.scheduleRepo.On("Get", "schedule-uuid").Return(entity.TransactionSchedule{Id: "schedule-uuid", MerchantId: "other-merchant", UserId: "user-uuid"}, nil).Once()
+	s.merchants.On("MerchantForUser", "user-uuid", "other-merchant").Return("", ErrTopupMerchantForbidden).Once()
+
+	err := s.scheduleUsecase.Deactivate("schedule-uuid", "user-uuid")
+
+	s.ErrorIs(err, ErrScheduleNotFound)
+	s.scheduleRepo.AssertNotCalled(s.T(), "Deactivate", mock.Anything)
+}
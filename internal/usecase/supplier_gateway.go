@@ -0,0 +1,12 @@
+package usecase
+
+import "server-pulsa-app/internal/entity"
+
+// SupplierGateway sends a single order to the supplier that owns the product.
+// A returned error means the supplier could not be reached and the order may be retried;
+// a definitive answer is reported through SupplierResult.Status (success or failed).
+// Purchase must be idempotent per TransactionDetailId: an order that is sent again, because it
+// was released or its claim timed out, has to return the outcome of the first purchase.
+type SupplierGateway interface {
+	Purchase(order entity.SupplierOrder) (entity.SupplierResult, error)
+}
@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	"server-pulsa-app/internal/repository"
+)
+
+var (
+	ErrInvalidTopupSetting = errors.New("invalid topup setting")
+	ErrTopupFeeNotFound    = errors.New("topup fee not found")
+)
+
+type TopupSettingUseCase interface {
+	GetSettings() (entity.TopupSetting, error)
+	SaveSettings(payload entity.TopupSetting, actor entity.AuditActor) (entity.TopupSetting, error)
+	ListFees() ([]entity.TopupFee, error)
+	SaveFee(paymentMethod string, payload entity.TopupFee, actor entity.AuditActor) (entity.TopupFee, error)
+	DeleteFee(paymentMethod string, actor entity.AuditActor) error
+}
+
+type topupSettingUseCase struct {
+	repo  repository.TopupSettingRepository
+	audit AuditUseCase
+	log   *logger.Logger
+}
+
+func (t *topupSettingUseCase) GetSettings() (entity.TopupSetting, error) {
+	t.log.Info("Starting to retrive the topup settings in the usecase layer", nil)
+	return t.repo.Get()
+}
+
+// SaveSettings replaces the topup limits. Zero maximum, daily or monthly limits mean unlimited, but a set limit
+// may not be below the minimum topup.
+func (t *topupSettingUseCase) SaveSettings(payload entity.TopupSetting, actor entity.AuditActor) (entity.TopupSetting, error) {
+	t.log.Info("Starting to save the topup settings in the usecase layer", payload)
+
+	if payload.MinAmount <= 0 {
+		return entity.TopupSetting{}, fmt.Errorf("%w: min_amount must be positive", ErrInvalidTopupSetting)
+	}
+
+	limits := map[string]int{"max_amount": payload.MaxAmount, "daily_limit": payload.DailyLimit, "monthly_limit": payload.MonthlyLimit}
+	for name, limit := range limits {
+		if limit < 0 || (limit > 0 && limit < payload.MinAmount) {
+			return entity.TopupSetting{}, fmt.Errorf("%w: %s must be zero or at least min_amount", ErrInvalidTopupSetting, name)
+		}
+	}
+
+	if payload.DailyLimit > 0 && payload.MonthlyLimit > 0 && payload.MonthlyLimit < payload.DailyLimit {
+		return entity.TopupSetting{}, fmt.Errorf("%w: monthly_limit can't be below daily_limit", ErrInvalidTopupSetting)
+	}
+
+	before, err := t.repo.Get()
+	if err != nil {
+		return entity.TopupSetting{}, fmt.Errorf("failed to get topup settings: %w", err)
+	}
+
+	payload.UpdatedBy = actor.IdUser
+	setting, err := t.repo.Save(payload)
+	if err != nil {
+		return entity.TopupSetting{}, err
+	}
+
+	t.audit.Record(actor, entity.AuditUpdate, entity.AuditTopupSetting, "limits", before, setting)
+	return setting, nil
+}
+
+func (t *topupSettingUseCase) ListFees() ([]entity.TopupFee, error) {
+	t.log.Info("Starting to retrive the topup fees in the usecase layer", nil)
+	return t.repo.ListFees()
+}
+
+// SaveFee sets the fee of a payment method. The "default" method prices every method without its own fee. A flat fee
+// charged to the merchant may not exceed the minimum topup, which would leave the merchant nothing to credit.
+func (t *topupSettingUseCase) SaveFee(paymentMethod string, payload entity.TopupFee, actor entity.AuditActor) (entity.TopupFee, error) {
+	t.log.Info("Starting to save a topup fee in the usecase layer", paymentMethod)
+
+	payload.PaymentMethod = strings.ToLower(strings.TrimSpace(paymentMethod))
+	if payload.PaymentMethod == "" {
+		return entity.TopupFee{}, fmt.Errorf("%w: payment method can't be empty", ErrInvalidTopupSetting)
+	}
+
+	switch payload.FeeType {
+	case entity.TopupFeeFlat:
+	case entity.TopupFeePercent:
+		if payload.FeeValue > 100 {
+			return entity.TopupFee{}, fmt.Errorf("%w: a percent fee can't exceed 100", ErrInvalidTopupSetting)
+		}
+	default:
+		return entity.TopupFee{}, fmt.Errorf("%w: fee_type must be flat or percent", ErrInvalidTopupSetting)
+	}
+
+	if payload.FeeValue < 0 {
+		return entity.TopupFee{}, fmt.Errorf("%w: fee_value can't be negative", ErrInvalidTopupSetting)
+	}
+
+	if payload.ChargedTo != entity.TopupFeeMerchant && payload.ChargedTo != entity.TopupFeePlatform {
+		return entity.TopupFee{}, fmt.Errorf("%w: charged_to must be merchant or platform", ErrInvalidTopupSetting)
+	}
+
+	if payload.FeeType == entity.TopupFeeFlat && payload.ChargedTo == entity.TopupFeeMerchant {
+		setting, err := t.repo.Get()
+		if err != nil {
+			return entity.TopupFee{}, fmt.Errorf("failed to get topup settings: %w", err)
+		}
+		if payload.FeeValue > float64(setting.MinAmount) {
+			return entity.TopupFee{}, fmt.Errorf("%w: a flat fee charged to the merchant can't exceed the min_amount of %d", ErrInvalidTopupSetting, setting.MinAmount)
+		}
+	}
+
+	before, err := t.ownFee(payload.PaymentMethod)
+	if err != nil {
+		return entity.TopupFee{}, err
+	}
+
+	fee, err := t.repo.SaveFee(payload)
+	if err != nil {
+		return entity.TopupFee{}, err
+	}
+
+	if before == nil {
+		t.audit.Record(actor, entity.AuditCreate, entity.AuditTopupFee, fee.PaymentMethod, nil, fee)
+	} else {
+		t.audit.Record(actor, entity.AuditUpdate, entity.AuditTopupFee, fee.PaymentMethod, *before, fee)
+	}
+	return fee, nil
+}
+
+func (t *topupSettingUseCase) DeleteFee(paymentMethod string, actor entity.AuditActor) error {
+	t.log.Info("Starting to delete a topup fee in the usecase layer", paymentMethod)
+
+	paymentMethod = strings.ToLower(strings.TrimSpace(paymentMethod))
+	before, err := t.ownFee(paymentMethod)
+	if err != nil {
+		return err
+	}
+
+	if err := t.repo.DeleteFee(paymentMethod); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTopupFeeNotFound
+		}
+		return err
+	}
+
+	if before != nil {
+		t.audit.Record(actor, entity.AuditDelete, entity.AuditTopupFee, paymentMethod, *before, nil)
+	}
+	return nil
+}
+
+// ownFee returns the fee set for paymentMethod itself, or nil when the method falls back to the default fee.
+func (t *topupSettingUseCase) ownFee(paymentMethod string) (*entity.TopupFee, error) {
+	fees, err := t.repo.ListFees()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topup fees: %w", err)
+	}
+
+	for _, fee := range fees {
+		if fee.PaymentMethod == paymentMethod {
+			return &fee, nil
+		}
+	}
+	return nil, nil
+}
+
+func NewTopupSettingUseCase(repo repository.TopupSettingRepository, audit AuditUseCase, log *logger.Logger) TopupSettingUseCase {
+	return &topupSettingUseCase{repo: repo, audit: audit, log: log}
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"database/sql"
+	"testing"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type topupSettingUsecaseSuite struct {
+	suite.Suite
+	settingRepo    *repositorymock.MockTopupSettingRepository
+	audit          *usecase_mock.AuditUseCaseMock
+	settingUsecase TopupSettingUseCase
+	log            logger.Logger
+}
+
+func (t *topupSettingUsecaseSuite) SetupTest() {
+	t.settingRepo = new(repositorymock.MockTopupSettingRepository)
+	t.audit = new(usecase_mock.AuditUseCaseMock)
+	t.log = logger.NewLogger()
+	t.settingUsecase = NewTopupSettingUseCase(t.settingRepo, t.audit, &t.log)
+}
+
+func TestTopupSettingUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(topupSettingUsecaseSuite))
+}
+
+func (t *topupSettingUsecaseSuite) TestSaveSettings_RecordsAdmin() {
+	setting := entity.TopupSetting{MinAmount: 10000, MaxAmount: 5000000, DailyLimit: 10000000}
+	saved := setting
+	saved.UpdatedBy = "admin-1"
+	before := entity.TopupSetting{MinAmount: 10000}
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin}
+	t.settingRepo.On("Get").Return(before, nil).Once()
+	t.settingRepo.On("Save", saved).Return(saved, nil).Once()
+	t.audit.On("Record", actor, entity.AuditUpdate, entity.AuditTopupSetting, "limits", before, saved).Once()
+
+	result, err := t.settingUsecase.SaveSettings(setting, actor)
+
+	t.NoError(err)
+	t.Equal(saved, result)
+	t.audit.AssertExpectations(t.T())
+}
+
+func (t *topupSettingUsecaseSuite) TestSaveSettings_LimitBelowMinimum() {
+	_, err := t.settingUsecase.SaveSettings(entity.TopupSetting{MinAmount: 10000, MaxAmount: 5000}, entity.AuditActor{IdUser: "admin-1"})
+
+	t.ErrorIs(err, ErrInvalidTopupSetting)
+	t.settingRepo.AssertNotCalled(t.T(), "Save", mock.Anything)
+}
+
+func (t *topupSettingUsecaseSuite) TestSaveFee_NormalizesMethod() {
+	fee := entity.TopupFee{PaymentMethod: "bca", FeeType: entity.TopupFeePercent, FeeValue: 1.5, ChargedTo: entity.TopupFeeMerchant}
+	t.settingRepo.On("ListFees").Return([]entity.TopupFee{{PaymentMethod: entity.TopupFeeDefault}}, nil).Once()
+	t.settingRepo.On("SaveFee", fee).Return(fee, nil).Once()
+	t.audit.On("Record", entity.AuditActor{}, entity.AuditCreate, entity.AuditTopupFee, "bca", nil, fee).Once()
+
+	_, err := t.settingUsecase.SaveFee(" BCA ", entity.TopupFee{FeeType: entity.TopupFeePercent, FeeValue: 1.5, ChargedTo: entity.TopupFeeMerchant}, entity.AuditActor{})
+
+	t.NoError(err)
+	t.settingRepo.AssertExpectations(t.T())
+	t.audit.AssertExpectations(t.T())
+}
+
+func (t *topupSettingUsecaseSuite) TestSaveFee_AuditsPreviousFee() {
+	before := entity.TopupFee{PaymentMethod: "bca", FeeType: entity.TopupFeeFlat, FeeValue: 2500, ChargedTo: entity.TopupFeePlatform}
+	fee := entity.TopupFee{PaymentMethod: "bca", FeeType: entity.TopupFeePercent, FeeValue: 1.5, ChargedTo: entity.TopupFeePlatform}
+	t.settingRepo.On("ListFees").Return([]entity.TopupFee{before}, nil).Once()
+	t.settingRepo.On("SaveFee", fee).Return(fee, nil).Once()
+	t.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditTopupFee, "bca", before, fee).Once()
+
+	_, err := t.settingUsecase.SaveFee("bca", entity.TopupFee{FeeType: entity.TopupFeePercent, FeeValue: 1.5, ChargedTo: entity.TopupFeePlatform}, entity.AuditActor{})
+
+	t.NoError(err)
+	t.audit.AssertExpectations(t.T())
+}
+
+func (t *topupSettingUsecaseSuite) TestSaveFee_PercentAboveHundred() {
+	_, err := t.settingUsecase.SaveFee("bca", entity.TopupFee{FeeType: entity.TopupFeePercent, FeeValue: 150, ChargedTo: entity.TopupFeeMerchant}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrInvalidTopupSetting)
+	t.settingRepo.AssertNotCalled(t.T(), "SaveFee", mock.Anything)
+}
+
+func (t *topupSettingUsecaseSuite) TestSaveFee_FlatMerchantFeeAboveMinimum() {
+	t.settingRepo.On("Get").Return(entity.TopupSetting{MinAmount: 10000}, nil).Once()
+
+	_, err := t.settingUsecase.SaveFee("bca", entity.TopupFee{FeeType: entity.TopupFeeFlat, FeeValue: 15000, ChargedTo: entity.TopupFeeMerchant}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrInvalidTopupSetting)
+	t.settingRepo.AssertNotCalled(t.T(), "SaveFee", mock.Anything)
+}
+
+func (t *topupSettingUsecaseSuite) TestDeleteFee_NotFound() {
+	t.settingRepo.On("ListFees").Return([]entity.TopupFee{}, nil).Once()
+	t.settingRepo.On("DeleteFee", "ovo").Return(sql.ErrNoRows).Once()
+
+	err := t.settingUsecase.DeleteFee("OVO", entity.AuditActor{})
+
+	t.ErrorIs(err, ErrTopupFeeNotFound)
+	t.audit.AssertNotCalled(t.T(), "Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -1,37 +1,407 @@
 package usecase
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/repository"
+	"strings"
+	"time"
 )
 
+var (
+	ErrInvalidCallbackSignature = errors.New("invalid callback signature")
+	ErrCallbackAmountMismatch   = errors.New("callback amount does not match the topup")
+	ErrTopupNotFound            = errors.New("topup not found")
+	ErrUnknownTopupStatus       = errors.New("unknown topup payment status")
+	ErrPaymentGateway           = errors.New("payment gateway error")
+	ErrInvalidManualTopup       = errors.New("invalid manual topup")
+	ErrTopupMerchantRequired    = errors.New("id_merchant is required when the user owns several merchants")
+	ErrTopupMerchantForbidden   = errors.New("merchant does not belong to the user")
+	ErrTopupLimitExceeded       = repository.ErrTopupLimitExceeded
+)
+
+// manualTopupProofTypes are the receipt image types accepted for manual topups.
+var manualTopupProofTypes = map[string]bool{"image/jpeg": true, "image/png": true}
+
 type topupUsecase struct {
-	repo repository.TopupRepository
+	repo        repository.TopupRepository
+	settingRepo repository.TopupSettingRepository
+	gateway     PaymentGateway
+	audit       AuditUseCase
+	cfg         config.TopupConfig
+	log         *logger.Logger
+	now         func() time.Time
 }
 
 type TopupUseCase interface {
-	CreateTopup(payload entity.TopupRequest) (string, error)
-	UpdateAfterPayment(payload entity.TopupRequest) (string, error)
+	CreateTopup(payload entity.TopupRequest, actor entity.AuditActor) (entity.PaymentSession, error)
+	CreateTopupIdempotent(payload entity.TopupRequest, key entity.IdempotencyKey, actor entity.AuditActor) (entity.PaymentSession, bool, error)
+	HandlePaymentNotification(header http.Header, body []byte, actor entity.AuditActor) (entity.PaymentNotification, error)
 	GetTopupByMerchantId(idMerchant string) ([]entity.TopupRequestDetail, error)
+	GetTopupByUser(idUser string) ([]entity.TopupRequestDetail, error)
+	MerchantForUser(idUser, idMerchant string) (string, error)
+	CreateManualTopup(payload entity.TopupRequest, proof entity.TopupProof, actor entity.AuditActor) (string, error)
+	ListManualTopups() ([]entity.TopupRequest, error)
+	GetTopupProof(idTopup string) (entity.TopupProof, error)
+	ReviewManualTopup(idTopup string, review entity.ManualTopupReview, actor entity.AuditActor) (string, error)
+	ReconcilePending() (int, error)
+	Run(ctx context.Context)
 }
 
-func (t *topupUsecase) CreateTopup(payload entity.TopupRequest) (string, error) {
-	data, err := t.repo.CreateTopup(payload)
+func (t *topupUsecase) CreateTopup(payload entity.TopupRequest, actor entity.AuditActor) (entity.PaymentSession, error) {
+	limits, err := t.limits(payload)
 	if err != nil {
-		return "", fmt.Errorf("err: %w", err)
+		return entity.PaymentSession{}, err
 	}
 
-	return data, nil
+	if payload.Fee, payload.FeeChargedTo, err = t.fee(payload.PaymentMethod, payload.Amount); err != nil {
+		return entity.PaymentSession{}, err
+	}
+
+	id, err := t.repo.CreateTopup(payload, limits)
+	if errors.Is(err, ErrTopupLimitExceeded) {
+		return entity.PaymentSession{}, err
+	} else if err != nil {
+		return entity.PaymentSession{}, fmt.Errorf("err: %w", err)
+	}
+
+	payload.Id = id
+	t.audit.Record(actor, entity.AuditCreate, entity.AuditTopup, id, nil, payload)
+	session, err := t.charge(payload)
+	if err != nil {
+		if cancelErr := t.repo.CancelTopup(id); cancelErr != nil {
+			t.log.Error("Failed to cancel uncharged topup", cancelErr)
+		}
+		return entity.PaymentSession{}, err
+	}
+
+	return session, nil
+}
+
+// CreateTopupIdempotent creates and charges the topup once per idempotency key. When the key was already used
+// by the same request the stored payment session is returned with true and no new topup is created. A failed
+// charge releases the key and cancels its topup, so the client can retry with the same key. The daily and monthly
+// limits are only checked for a new key, so a retry of a created topup is replayed even once the limits are used up.
+func (t *topupUsecase) CreateTopupIdempotent(payload entity.TopupRequest, key entity.IdempotencyKey, actor entity.AuditActor) (entity.PaymentSession, bool, error) {
+	key, err := prepareIdempotencyKey(key, payload)
+	if err != nil {
+		return entity.PaymentSession{}, false, err
+	}
+
+	limits, err := t.limits(payload)
+	if err != nil {
+		return entity.PaymentSession{}, false, err
+	}
+
+	if payload.Fee, payload.FeeChargedTo, err = t.fee(payload.PaymentMethod, payload.Amount); err != nil {
+		return entity.PaymentSession{}, false, err
+	}
+
+	id, stored, exists, err := t.repo.CreateTopupIdempotent(payload, key, t.cfg.IdempotencyLease, limits)
+	if errors.Is(err, ErrTopupLimitExceeded) {
+		return entity.PaymentSession{}, false, err
+	} else if err != nil {
+		return entity.PaymentSession{}, false, fmt.Errorf("err: %w", err)
+	}
+	if exists {
+		var session entity.PaymentSession
+		if err := replayIdempotencyKey(key, stored, &session); err != nil {
+			return entity.PaymentSession{}, false, err
+		}
+		return session, true, nil
+	}
+
+	payload.Id = id
+	t.audit.Record(actor, entity.AuditCreate, entity.AuditTopup, id, nil, payload)
+	session, err := t.charge(payload)
+	if err != nil {
+		if releaseErr := t.repo.ReleaseIdempotencyKey(key, id); releaseErr != nil {
+			t.log.Error("Failed to release idempotency key", releaseErr)
+		}
+		return entity.PaymentSession{}, false, err
+	}
+
+	body, err := json.Marshal(session)
+	if err == nil {
+		key.Response = body
+		err = t.repo.CompleteIdempotencyKey(key)
+	}
+	if err != nil {
+		t.log.Error("Failed to store topup response for idempotency key", err)
+	}
+
+	return session, false, nil
+}
+
+// limits enforces the admin managed topup settings: the amount must be within the minimum and maximum. It returns
+// the daily and monthly limits, which the repository checks against the merchant's pending and paid topups of today
+// and of this month in the transaction that stores the topup.
+func (t *topupUsecase) limits(payload entity.TopupRequest) ([]entity.TopupLimit, error) {
+	setting, err := t.settingRepo.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topup settings: %w", err)
+	}
+
+	if payload.Amount < setting.MinAmount {
+		return nil, fmt.Errorf("%w: the minimum topup is %d", ErrTopupLimitExceeded, setting.MinAmount)
+	}
+	if setting.MaxAmount > 0 && payload.Amount > setting.MaxAmount {
+		return nil, fmt.Errorf("%w: the maximum topup is %d", ErrTopupLimitExceeded, setting.MaxAmount)
+	}
+
+	now := t.now()
+	var limits []entity.TopupLimit
+	if setting.DailyLimit > 0 {
+		limits = append(limits, entity.TopupLimit{Name: "daily", Limit: setting.DailyLimit, Since: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())})
+	}
+	if setting.MonthlyLimit > 0 {
+		limits = append(limits, entity.TopupLimit{Name: "monthly", Limit: setting.MonthlyLimit, Since: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())})
+	}
+
+	return limits, nil
+}
+
+// fee prices a topup with the fee rule of its payment method, or the default rule. Without any rule the topup is free.
+// The fee never exceeds the amount, so a fee charged to the merchant can't turn the credit negative.
+func (t *topupUsecase) fee(paymentMethod string, amount int) (int, string, error) {
+	rule, err := t.settingRepo.FindFee(strings.ToLower(paymentMethod))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, entity.TopupFeePlatform, nil
+	} else if err != nil {
+		return 0, "", fmt.Errorf("failed to get topup fee: %w", err)
+	}
+
+	fee := int(math.Round(rule.FeeValue))
+	if rule.FeeType == entity.TopupFeePercent {
+		fee = int(math.Round(float64(amount) * rule.FeeValue / 100))
+	}
+	return min(fee, amount), rule.ChargedTo, nil
+}
+
+func (t *topupUsecase) charge(payload entity.TopupRequest) (entity.PaymentSession, error) {
+	t.log.Info("Starting to charge the topup through the payment gateway", payload.Id)
+	session, err := t.gateway.CreateCharge(payload)
+	if err != nil {
+		return entity.PaymentSession{}, fmt.Errorf("%w: %v", ErrPaymentGateway, err)
+	}
+
+	return session, nil
 }
 
-func (t *topupUsecase) UpdateAfterPayment(payload entity.TopupRequest) (string, error) {
-	err := t.repo.TxTopupUpdateAfterPayment(payload)
+// HandlePaymentNotification authenticates a payment gateway webhook, checks its amount against the stored topup
+// and applies the reported status, so a forged or tampered notification can never credit a merchant.
+func (t *topupUsecase) HandlePaymentNotification(header http.Header, body []byte, actor entity.AuditActor) (entity.PaymentNotification, error) {
+	notification, err := t.gateway.ParseNotification(header, body)
 	if err != nil {
-		return "", fmt.Errorf("failed to update payment: %w", err)
+		return entity.PaymentNotification{}, fmt.Errorf("%w: %v", ErrInvalidCallbackSignature, err)
+	}
+
+	topup, err := t.repo.FindTopup(notification.OrderId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && topup.Method == entity.TopupMethodManual) {
+		return notification, ErrTopupNotFound
+	} else if err != nil {
+		return notification, fmt.Errorf("failed to get topup: %w", err)
+	}
+
+	if err := t.applyNotification(topup, notification, actor); err != nil {
+		return notification, err
 	}
 
-	return payload.Id, nil
+	return notification, nil
+}
+
+// applyNotification moves the topup to the status reported by the gateway once the reported amount is confirmed.
+// A partial refund is only acknowledged and flagged, since reversing the whole credit would take more from the
+// merchant than the gateway gave back.
+func (t *topupUsecase) applyNotification(topup entity.TopupRequest, notification entity.PaymentNotification, actor entity.AuditActor) error {
+	if notification.GrossAmount != float64(topup.Amount) {
+		return fmt.Errorf("%w: got %v, expected %d", ErrCallbackAmountMismatch, notification.GrossAmount, topup.Amount)
+	}
+
+	if notification.PartialRefund {
+		t.log.Error("Partial refund of topup needs manual review", notification)
+		t.audit.Record(actor, entity.AuditRefund, entity.AuditTopup, topup.Id, topup, notification)
+		return nil
+	}
+
+	if notification.Status == "" {
+		return fmt.Errorf("%w: %s", ErrUnknownTopupStatus, notification.GatewayStatus)
+	}
+
+	payload := entity.TopupRequest{Id: topup.Id, Status: notification.Status, PaymentMethod: notification.PaymentMethod}
+	if payload.Status == entity.TopupPaid {
+		// the fee priced at creation holds unless the customer paid with another method
+		payload.Fee, payload.FeeChargedTo = topup.Fee, topup.FeeChargedTo
+		if method := notification.PaymentMethod; method != "" && !strings.EqualFold(method, topup.PaymentMethod) {
+			var err error
+			if payload.Fee, payload.FeeChargedTo, err = t.fee(method, topup.Amount); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := t.repo.TxTopupUpdateAfterPayment(payload, auditEntry(actor, entity.AuditPayment, entity.AuditTopup, topup.Id)); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	return nil
+}
+
+// ReconcilePending asks the payment gateway about pending topups whose notification may have been lost and
+// applies the status it reports. Topups older than the configured lifetime are cancelled at the gateway and
+// expired; only a gateway that definitely doesn't know the order lets one expire without being cancelled, any other
+// error leaves it pending for the next run. It returns the number of topups that left pending.
+func (t *topupUsecase) ReconcilePending() (int, error) {
+	now := t.now()
+	topups, err := t.repo.ClaimPending(now.Add(-t.cfg.ReconcileMinAge), now.Add(-t.cfg.ReconcileInterval), t.cfg.ReconcileBatchSize)
+	if err != nil {
+		t.log.Error("Failed to claim pending topups", err)
+		return 0, err
+	}
+
+	resolved := 0
+	for _, topup := range topups {
+		expired := now.Sub(topup.CreatedAt) > t.cfg.Lifetime
+
+		notification, err := t.gateway.GetStatus(topup.Id)
+		switch {
+		case err != nil && (!expired || !errors.Is(err, entity.ErrPaymentOrderNotFound)):
+			t.log.Error("Failed to get topup status from the payment gateway", err)
+			continue
+		case err != nil:
+			// The charge never reached the gateway, so the order can't be paid any more.
+			t.log.Info("Expiring topup unknown to the payment gateway", topup.Id)
+			notification = entity.PaymentNotification{OrderId: topup.Id, Status: entity.TopupExpired, GrossAmount: float64(topup.Amount)}
+		case notification.Status == entity.TopupPending && expired:
+			if err := t.gateway.Cancel(topup.Id); err != nil {
+				t.log.Error("Failed to cancel expired topup at the payment gateway", err)
+				continue
+			}
+			notification.Status = entity.TopupExpired
+		case notification.Status == entity.TopupPending:
+			continue
+		}
+
+		if err := t.applyNotification(topup, notification, entity.SystemActor("topup reconciliation")); err != nil {
+			t.log.Error("Failed to reconcile topup", map[string]interface{}{"id": topup.Id, "error": err.Error()})
+			continue
+		}
+		resolved++
+	}
+
+	return resolved, nil
+}
+
+// Run reconciles pending topups every configured interval until ctx is cancelled.
+func (t *topupUsecase) Run(ctx context.Context) {
+	t.log.Info("Starting the topup reconciliation worker", t.cfg)
+
+	ticker := time.NewTicker(t.cfg.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.log.Info("Topup reconciliation worker stopped", nil)
+			return
+		case <-ticker.C:
+			if _, err := t.ReconcilePending(); err != nil {
+				t.log.Error("Topup reconciliation run failed", err)
+			}
+		}
+	}
+}
+
+// CreateManualTopup records a bank transfer topup with its receipt. It stays pending until an admin reviews it.
+func (t *topupUsecase) CreateManualTopup(payload entity.TopupRequest, proof entity.TopupProof, actor entity.AuditActor) (string, error) {
+	proof.ContentType = http.DetectContentType(proof.Content)
+	if !manualTopupProofTypes[proof.ContentType] {
+		return "", fmt.Errorf("%w: the receipt must be a JPEG or PNG image", ErrInvalidManualTopup)
+	}
+
+	if payload.PaymentMethod == "" {
+		payload.PaymentMethod = "bank_transfer"
+	}
+
+	limits, err := t.limits(payload)
+	if err != nil {
+		return "", err
+	}
+
+	if payload.Fee, payload.FeeChargedTo, err = t.fee(payload.PaymentMethod, payload.Amount); err != nil {
+		return "", err
+	}
+
+	id, err := t.repo.CreateManualTopup(payload, proof, limits)
+	if errors.Is(err, ErrTopupLimitExceeded) {
+		return "", err
+	} else if err != nil {
+		return "", fmt.Errorf("failed to create manual topup: %w", err)
+	}
+
+	payload.Id = id
+	t.audit.Record(actor, entity.AuditCreate, entity.AuditTopup, id, nil, payload)
+	return id, nil
+}
+
+func (t *topupUsecase) ListManualTopups() ([]entity.TopupRequest, error) {
+	topups, err := t.repo.ListPendingManual()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list manual topups: %w", err)
+	}
+
+	return topups, nil
+}
+
+func (t *topupUsecase) GetTopupProof(idTopup string) (entity.TopupProof, error) {
+	proof, err := t.repo.GetTopupProof(idTopup)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.TopupProof{}, ErrTopupNotFound
+	} else if err != nil {
+		return entity.TopupProof{}, fmt.Errorf("failed to get topup proof: %w", err)
+	}
+
+	return proof, nil
+}
+
+// ReviewManualTopup approves a manual topup, crediting the merchant like a paid gateway topup less any fee
+// charged to it, or rejects it with a reason. The actor is recorded as the reviewer. It returns the new topup
+// status.
+func (t *topupUsecase) ReviewManualTopup(idTopup string, review entity.ManualTopupReview, actor entity.AuditActor) (string, error) {
+	payload := entity.TopupRequest{Id: idTopup, Status: entity.TopupPaid, ReviewedBy: actor.IdUser}
+	if !review.Approve {
+		payload.Status = entity.TopupRejected
+		payload.RejectionReason = strings.TrimSpace(review.Reason)
+		if payload.RejectionReason == "" {
+			return "", fmt.Errorf("%w: a rejection reason is required", ErrInvalidManualTopup)
+		}
+	} else {
+		topup, err := t.repo.FindTopup(idTopup)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrTopupNotFound
+		} else if err != nil {
+			return "", fmt.Errorf("failed to get topup: %w", err)
+		}
+
+		payload.Fee, payload.FeeChargedTo = topup.Fee, topup.FeeChargedTo
+	}
+
+	err := t.repo.ReviewManualTopup(payload, auditEntry(actor, entity.AuditReview, entity.AuditTopup, idTopup))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrTopupNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("failed to review manual topup: %w", err)
+	}
+
+	return payload.Status, nil
 }
 
 func (t *topupUsecase) GetTopupByMerchantId(idMerchant string) ([]entity.TopupRequestDetail, error) {
@@ -43,6 +413,47 @@ func (t *topupUsecase) GetTopupByMerchantId(idMerchant string) ([]entity.TopupRe
 	return data, nil
 }
 
-func NewTopupUsecase(repo repository.TopupRepository) TopupUseCase {
-	return &topupUsecase{repo: repo}
+func (t *topupUsecase) GetTopupByUser(idUser string) ([]entity.TopupRequestDetail, error) {
+	data, err := t.repo.GetTopupByUser(idUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topup by user: %w", err)
+	}
+
+	return data, nil
+}
+
+// MerchantOwner resolves the merchant a user acts for, so one user can't act on another user's merchant.
+type MerchantOwner interface {
+	MerchantForUser(idUser, idMerchant string) (string, error)
+}
+
+// MerchantForUser returns the merchant a user tops up. idMerchant may be empty when the user owns exactly one
+// merchant; otherwise it must be one of the user's merchants.
+func (t *topupUsecase) MerchantForUser(idUser, idMerchant string) (string, error) {
+	owned, err := t.repo.MerchantIdsByUser(idUser)
+	if err != nil {
+		return "", fmt.Errorf("failed to get merchants of user: %w", err)
+	}
+
+	if idMerchant == "" {
+		switch len(owned) {
+		case 0:
+			return "", ErrTopupMerchantForbidden
+		case 1:
+			return owned[0], nil
+		default:
+			return "", ErrTopupMerchantRequired
+		}
+	}
+
+	for _, id := range owned {
+		if id == idMerchant {
+			return id, nil
+		}
+	}
+	return "", ErrTopupMerchantForbidden
+}
+
+func NewTopupUsecase(repo repository.TopupRepository, settingRepo repository.TopupSettingRepository, gateway PaymentGateway, audit AuditUseCase, cfg config.TopupConfig, log *logger.Logger) TopupUseCase {
+	return &topupUsecase{repo: repo, settingRepo: settingRepo, gateway: gateway, audit: audit, cfg: cfg, log: log, now: time.Now}
 }
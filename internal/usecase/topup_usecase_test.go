@@ -0,0 +1,484 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"server-pulsa-app/config"
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/gateway"
+	"server-pulsa-app/internal/logger"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/repository"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const testPaymentToken = "fake-token"
+
+var (
+	testTopupNow    = time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	testTopupConfig = config.TopupConfig{
+		ReconcileInterval:  time.Minute,
+		ReconcileBatchSize: 20,
+		ReconcileMinAge:    5 * time.Minute,
+		Lifetime:           24 * time.Hour,
+		IdempotencyLease:   5 * time.Minute,
+	}
+	testCallbackActor = entity.AuditActor{System: "payment callback", ClientIP: "10.0.0.1"}
+)
+
+type topupUsecaseSuite struct {
+	suite.Suite
+	topupRepo    *repositorymock.MockTopupRepository
+	settingRepo  *repositorymock.MockTopupSettingRepository
+	payment      *gateway.FakePaymentGateway
+	audit        *usecase_mock.AuditUseCaseMock
+	topupUsecase TopupUseCase
+	log          logger.Logger
+}
+
+func (t *topupUsecaseSuite) SetupTest() {
+	t.topupRepo = new(repositorymock.MockTopupRepository)
+	t.settingRepo = new(repositorymock.MockTopupSettingRepository)
+	t.payment = gateway.NewFakePaymentGateway(testPaymentToken)
+	t.audit = new(usecase_mock.AuditUseCaseMock)
+	t.audit.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	t.log = logger.NewLogger()
+	uc := NewTopupUsecase(t.topupRepo, t.settingRepo, t.payment, t.audit, testTopupConfig, &t.log)
+	uc.(*topupUsecase).now = func() time.Time { return testTopupNow }
+	t.topupUsecase = uc
+}
+
+func TestTopupUsecaseSuite(t *testing.T) {
+	suite.Run(t, new(topupUsecaseSuite))
+}
+
+func (t *topupUsecaseSuite) notify(notification entity.PaymentNotification, token string) (entity.PaymentNotification, error) {
+	body, err := json.Marshal(notification)
+	t.NoError(err)
+
+	header := http.Header{}
+	header.Set(gateway.FakePaymentTokenHeader, token)
+	return t.topupUsecase.HandlePaymentNotification(header, body, testCallbackActor)
+}
+
+// expectSettings makes the topup settings the default minimum of 10000 without any other limit.
+func (t *topupUsecaseSuite) expectSettings() {
+	t.settingRepo.On("Get").Return(entity.TopupSetting{MinAmount: 10000}, nil).Once()
+}
+
+// expectNoFee makes the payment method free, charging its topups no fee.
+func (t *topupUsecaseSuite) expectNoFee(paymentMethod string) {
+	t.settingRepo.On("FindFee", paymentMethod).Return(entity.TopupFee{}, sql.ErrNoRows).Once()
+}
+
+func (t *topupUsecaseSuite) TestCreateTopup_ChargesGateway() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000, Status: entity.TopupPending}
+	t.expectSettings()
+	t.expectNoFee("")
+	priced := payload
+	priced.FeeChargedTo = entity.TopupFeePlatform
+	t.topupRepo.On("CreateTopup", priced, []entity.TopupLimit(nil)).Return("topup-1", nil).Once()
+
+	session, err := t.topupUsecase.CreateTopup(payload, entity.AuditActor{})
+
+	t.NoError(err)
+	t.Equal("fake-topup-1", session.Token)
+	status, err := t.payment.GetStatus("topup-1")
+	t.NoError(err)
+	t.Equal(entity.TopupPending, status.Status)
+	t.Equal(float64(50000), status.GrossAmount)
+}
+
+func (t *topupUsecaseSuite) TestCreateTopupIdempotent_ReleasesKeyWhenChargeFails() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000, Status: entity.TopupPending}
+	key := entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}
+	t.expectSettings()
+	t.expectNoFee("")
+	t.payment.FailCharges(errors.New("gateway down"))
+	t.topupRepo.On("CreateTopupIdempotent", mock.Anything, mock.Anything, 5*time.Minute, mock.Anything).Return("topup-1", entity.IdempotencyKey{}, false, nil).Once()
+	t.topupRepo.On("ReleaseIdempotencyKey", mock.MatchedBy(func(k entity.IdempotencyKey) bool { return k.Key == "key-1" }), "topup-1").Return(nil).Once()
+
+	_, replayed, err := t.topupUsecase.CreateTopupIdempotent(payload, key, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrPaymentGateway)
+	t.False(replayed)
+	t.topupRepo.AssertExpectations(t.T())
+	t.topupRepo.AssertNotCalled(t.T(), "CompleteIdempotencyKey", mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestCreateTopupIdempotent_StoresSession() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000, Status: entity.TopupPending}
+	t.expectSettings()
+	t.expectNoFee("")
+	t.topupRepo.On("CreateTopupIdempotent", mock.Anything, mock.Anything, 5*time.Minute, mock.Anything).Return("topup-1", entity.IdempotencyKey{}, false, nil).Once()
+	t.topupRepo.On("CompleteIdempotencyKey", mock.MatchedBy(func(k entity.IdempotencyKey) bool {
+		return k.Key == "key-1" && k.RequestHash != "" && strings.Contains(string(k.Response), "fake-topup-1")
+	})).Return(nil).Once()
+
+	session, replayed, err := t.topupUsecase.CreateTopupIdempotent(payload, entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}, entity.AuditActor{})
+
+	t.NoError(err)
+	t.False(replayed)
+	t.Equal("fake-topup-1", session.Token)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestCreateTopupIdempotent_ReplaysStoredSession() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000, Status: entity.TopupPending}
+	stored, err := prepareIdempotencyKey(entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}, payload)
+	t.Require().NoError(err)
+	stored.Response = []byte(`{"token":"fake-topup-1"}`)
+	t.expectSettings()
+	t.expectNoFee("")
+	t.topupRepo.On("CreateTopupIdempotent", mock.Anything, mock.Anything, 5*time.Minute, mock.Anything).Return("", stored, true, nil).Once()
+
+	session, replayed, err := t.topupUsecase.CreateTopupIdempotent(payload, entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}, entity.AuditActor{})
+
+	t.NoError(err)
+	t.True(replayed)
+	t.Equal("fake-topup-1", session.Token)
+	_, err = t.payment.GetStatus("topup-1")
+	t.ErrorIs(err, entity.ErrPaymentOrderNotFound)
+	t.audit.AssertNotCalled(t.T(), "Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestCreateTopupIdempotent_KeyInProgress() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000, Status: entity.TopupPending}
+	stored, err := prepareIdempotencyKey(entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}, payload)
+	t.Require().NoError(err)
+	t.expectSettings()
+	t.expectNoFee("")
+	t.topupRepo.On("CreateTopupIdempotent", mock.Anything, mock.Anything, 5*time.Minute, mock.Anything).Return("", stored, true, nil).Once()
+
+	_, _, err = t.topupUsecase.CreateTopupIdempotent(payload, entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrIdempotencyKeyInProgress)
+}
+
+func (t *topupUsecaseSuite) TestCreateTopupIdempotent_KeyReusedForOtherRequest() {
+	stored, err := prepareIdempotencyKey(entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}, entity.TopupRequest{IdMerchant: "merchant-1", Amount: 20000})
+	t.Require().NoError(err)
+	stored.Response = []byte(`{"token":"fake-topup-1"}`)
+	t.expectSettings()
+	t.expectNoFee("")
+	t.topupRepo.On("CreateTopupIdempotent", mock.Anything, mock.Anything, 5*time.Minute, mock.Anything).Return("", stored, true, nil).Once()
+
+	_, _, err = t.topupUsecase.CreateTopupIdempotent(entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000}, entity.IdempotencyKey{Scope: "/topup", Key: "key-1"}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrIdempotencyKeyMismatch)
+}
+
+func (t *topupUsecaseSuite) TestCreateTopup_CancelsWhenChargeFails() {
+	t.expectSettings()
+	t.expectNoFee("")
+	t.payment.FailCharges(errors.New("gateway down"))
+	t.topupRepo.On("CreateTopup", mock.Anything, mock.Anything).Return("topup-1", nil).Once()
+	t.topupRepo.On("CancelTopup", "topup-1").Return(nil).Once()
+
+	_, err := t.topupUsecase.CreateTopup(entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrPaymentGateway)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestCreateTopup_BelowMinimum() {
+	t.expectSettings()
+
+	_, err := t.topupUsecase.CreateTopup(entity.TopupRequest{IdMerchant: "merchant-1", Amount: 5000}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrTopupLimitExceeded)
+	t.topupRepo.AssertNotCalled(t.T(), "CreateTopup", mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestCreateTopup_DailyLimitExceeded() {
+	t.settingRepo.On("Get").Return(entity.TopupSetting{MinAmount: 10000, DailyLimit: 100000, MonthlyLimit: 1000000}, nil).Once()
+	t.expectNoFee("")
+	limits := []entity.TopupLimit{
+		{Name: "daily", Limit: 100000, Since: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "monthly", Limit: 1000000, Since: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	t.topupRepo.On("CreateTopup", mock.Anything, limits).
+		Return("", fmt.Errorf("%w: the daily limit of 100000 leaves 40000", repository.ErrTopupLimitExceeded)).Once()
+
+	_, err := t.topupUsecase.CreateTopup(entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrTopupLimitExceeded)
+	t.EqualError(err, "topup limit exceeded: the daily limit of 100000 leaves 40000")
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestCreateTopup_WithinMonthlyLimit() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", Amount: 50000, Status: entity.TopupPending}
+	t.settingRepo.On("Get").Return(entity.TopupSetting{MinAmount: 10000, MonthlyLimit: 1000000}, nil).Once()
+	t.expectNoFee("")
+	t.topupRepo.On("CreateTopup", mock.Anything, []entity.TopupLimit{{Name: "monthly", Limit: 1000000, Since: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)}}).
+		Return("topup-1", nil).Once()
+
+	_, err := t.topupUsecase.CreateTopup(payload, entity.AuditActor{})
+
+	t.NoError(err)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_Paid() {
+	t.topupRepo.On("FindTopup", "topup-1").Return(entity.TopupRequest{Id: "topup-1", Amount: 50000}, nil).Once()
+	t.settingRepo.On("FindFee", "bca").Return(entity.TopupFee{PaymentMethod: "bca", FeeType: entity.TopupFeePercent, FeeValue: 1.5, ChargedTo: entity.TopupFeeMerchant}, nil).Once()
+	t.topupRepo.On("TxTopupUpdateAfterPayment", entity.TopupRequest{Id: "topup-1", Status: entity.TopupPaid, PaymentMethod: "BCA", Fee: 750, FeeChargedTo: entity.TopupFeeMerchant},
+		auditEntry(testCallbackActor, entity.AuditPayment, entity.AuditTopup, "topup-1")).Return(nil).Once()
+
+	notification, err := t.notify(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 50000, PaymentMethod: "BCA"}, testPaymentToken)
+
+	t.NoError(err)
+	t.Equal(entity.TopupPaid, notification.Status)
+	t.topupRepo.AssertExpectations(t.T())
+	// the settlement is audited by the repository in its own db transaction
+	t.audit.AssertNotCalled(t.T(), "Record", mock.Anything, entity.AuditPayment, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_KeepsFeeOfCreation() {
+	topup := entity.TopupRequest{Id: "topup-1", Amount: 50000, PaymentMethod: "bca", Fee: 750, FeeChargedTo: entity.TopupFeeMerchant}
+	t.topupRepo.On("FindTopup", "topup-1").Return(topup, nil).Once()
+	t.topupRepo.On("TxTopupUpdateAfterPayment", entity.TopupRequest{Id: "topup-1", Status: entity.TopupPaid, PaymentMethod: "BCA", Fee: 750, FeeChargedTo: entity.TopupFeeMerchant}, mock.Anything).Return(nil).Once()
+
+	_, err := t.notify(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 50000, PaymentMethod: "BCA"}, testPaymentToken)
+
+	t.NoError(err)
+	t.settingRepo.AssertNotCalled(t.T(), "FindFee", mock.Anything)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestCreateTopup_FlatFeeCappedAtAmount() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", Amount: 10000, PaymentMethod: "ovo", Status: entity.TopupPending}
+	t.expectSettings()
+	t.settingRepo.On("FindFee", "ovo").Return(entity.TopupFee{PaymentMethod: "ovo", FeeType: entity.TopupFeeFlat, FeeValue: 15000, ChargedTo: entity.TopupFeeMerchant}, nil).Once()
+	priced := payload
+	priced.Fee, priced.FeeChargedTo = 10000, entity.TopupFeeMerchant
+	t.topupRepo.On("CreateTopup", priced, []entity.TopupLimit(nil)).Return("topup-1", nil).Once()
+
+	_, err := t.topupUsecase.CreateTopup(payload, entity.AuditActor{})
+
+	t.NoError(err)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_Unauthenticated() {
+	_, err := t.notify(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 50000}, "forged")
+
+	t.ErrorIs(err, ErrInvalidCallbackSignature)
+	t.topupRepo.AssertNotCalled(t.T(), "FindTopup", mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_AmountMismatch() {
+	t.topupRepo.On("FindTopup", "topup-1").Return(entity.TopupRequest{Id: "topup-1", Amount: 50000}, nil).Once()
+
+	_, err := t.notify(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 10000}, testPaymentToken)
+
+	t.ErrorIs(err, ErrCallbackAmountMismatch)
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", mock.Anything, mock.Anything)
+	t.audit.AssertNotCalled(t.T(), "Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_UnknownTopup() {
+	t.topupRepo.On("FindTopup", "topup-x").Return(entity.TopupRequest{}, sql.ErrNoRows).Once()
+
+	_, err := t.notify(entity.PaymentNotification{OrderId: "topup-x", Status: entity.TopupPaid, GrossAmount: 50000}, testPaymentToken)
+
+	t.ErrorIs(err, ErrTopupNotFound)
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_UnknownStatus() {
+	t.topupRepo.On("FindTopup", "topup-1").Return(entity.TopupRequest{Id: "topup-1", Amount: 50000}, nil).Once()
+
+	_, err := t.notify(entity.PaymentNotification{OrderId: "topup-1", GatewayStatus: "authorize", GrossAmount: 50000}, testPaymentToken)
+
+	t.ErrorIs(err, ErrUnknownTopupStatus)
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_PartialRefundIsFlagged() {
+	topup := entity.TopupRequest{Id: "topup-1", Amount: 50000, Status: entity.TopupPaid}
+	t.topupRepo.On("FindTopup", "topup-1").Return(topup, nil).Once()
+	notification := entity.PaymentNotification{OrderId: "topup-1", GatewayStatus: "partial_refund", GrossAmount: 50000, PartialRefund: true}
+
+	_, err := t.notify(notification, testPaymentToken)
+
+	t.NoError(err)
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", mock.Anything, mock.Anything)
+	t.audit.AssertCalled(t.T(), "Record", testCallbackActor, entity.AuditRefund, entity.AuditTopup, "topup-1", topup, notification)
+}
+
+func (t *topupUsecaseSuite) expectClaim(topups ...entity.TopupRequest) {
+	t.topupRepo.On("ClaimPending", testTopupNow.Add(-5*time.Minute), testTopupNow.Add(-time.Minute), 20).Return(topups, nil).Once()
+}
+
+func (t *topupUsecaseSuite) charged(id string, age time.Duration) entity.TopupRequest {
+	topup := entity.TopupRequest{Id: id, Amount: 50000, Status: entity.TopupPending, CreatedAt: testTopupNow.Add(-age)}
+	_, err := t.payment.CreateCharge(topup)
+	t.NoError(err)
+	return topup
+}
+
+func (t *topupUsecaseSuite) TestReconcilePending_AppliesGatewayStatus() {
+	paid := t.charged("topup-paid", time.Hour)
+	t.payment.SetStatus(paid.Id, entity.TopupPaid)
+	waiting := t.charged("topup-waiting", time.Hour)
+	t.expectClaim(paid, waiting)
+	t.topupRepo.On("TxTopupUpdateAfterPayment", entity.TopupRequest{Id: paid.Id, Status: entity.TopupPaid}, mock.Anything).Return(nil).Once()
+
+	resolved, err := t.topupUsecase.ReconcilePending()
+
+	t.NoError(err)
+	t.Equal(1, resolved)
+	t.topupRepo.AssertExpectations(t.T())
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", entity.TopupRequest{Id: waiting.Id, Status: entity.TopupPending}, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestReconcilePending_ExpiresAfterLifetime() {
+	stale := t.charged("topup-stale", 25*time.Hour)
+	unknown := entity.TopupRequest{Id: "topup-unknown", Amount: 50000, Status: entity.TopupPending, CreatedAt: testTopupNow.Add(-25 * time.Hour)}
+	t.expectClaim(stale, unknown)
+	t.topupRepo.On("TxTopupUpdateAfterPayment", entity.TopupRequest{Id: stale.Id, Status: entity.TopupExpired}, mock.Anything).Return(nil).Once()
+	t.topupRepo.On("TxTopupUpdateAfterPayment", entity.TopupRequest{Id: unknown.Id, Status: entity.TopupExpired}, mock.Anything).Return(nil).Once()
+
+	resolved, err := t.topupUsecase.ReconcilePending()
+
+	t.NoError(err)
+	t.Equal(2, resolved)
+	status, err := t.payment.GetStatus(stale.Id)
+	t.NoError(err)
+	t.Equal(entity.TopupCancelled, status.Status)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestReconcilePending_KeepsOldTopupWhenGatewayFails() {
+	stale := t.charged("topup-stale", 25*time.Hour)
+	t.expectClaim(stale)
+	t.payment.FailStatus(errors.New("midtrans status request failed with status 503"))
+
+	resolved, err := t.topupUsecase.ReconcilePending()
+
+	t.NoError(err)
+	t.Equal(0, resolved)
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", mock.Anything, mock.Anything)
+	t.payment.FailStatus(nil)
+	status, err := t.payment.GetStatus(stale.Id)
+	t.NoError(err)
+	t.Equal(entity.TopupPending, status.Status)
+}
+
+func (t *topupUsecaseSuite) TestReconcilePending_KeepsYoungUnknownTopup() {
+	t.expectClaim(entity.TopupRequest{Id: "topup-unknown", Amount: 50000, Status: entity.TopupPending, CreatedAt: testTopupNow.Add(-time.Hour)})
+
+	resolved, err := t.topupUsecase.ReconcilePending()
+
+	t.NoError(err)
+	t.Equal(0, resolved)
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", mock.Anything, mock.Anything)
+}
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func (t *topupUsecaseSuite) TestCreateManualTopup() {
+	payload := entity.TopupRequest{IdMerchant: "merchant-1", IdSupliyer: "supliyer-1", Item_name: "saldo", Amount: 50000}
+	expected := payload
+	expected.PaymentMethod = "bank_transfer"
+	expected.Fee, expected.FeeChargedTo = 2500, entity.TopupFeePlatform
+	t.expectSettings()
+	t.settingRepo.On("FindFee", "bank_transfer").Return(entity.TopupFee{PaymentMethod: entity.TopupFeeDefault, FeeType: entity.TopupFeeFlat, FeeValue: 2500, ChargedTo: entity.TopupFeePlatform}, nil).Once()
+	t.topupRepo.On("CreateManualTopup", expected, entity.TopupProof{FileName: "receipt.png", ContentType: "image/png", Content: pngHeader}, []entity.TopupLimit(nil)).Return("topup-1", nil).Once()
+
+	id, err := t.topupUsecase.CreateManualTopup(payload, entity.TopupProof{FileName: "receipt.png", Content: pngHeader}, entity.AuditActor{})
+
+	t.NoError(err)
+	t.Equal("topup-1", id)
+}
+
+func (t *topupUsecaseSuite) TestCreateManualTopup_RejectsNonImageReceipt() {
+	_, err := t.topupUsecase.CreateManualTopup(entity.TopupRequest{Amount: 50000}, entity.TopupProof{FileName: "receipt.png", Content: []byte("%PDF-1.4")}, entity.AuditActor{})
+
+	t.ErrorIs(err, ErrInvalidManualTopup)
+	t.topupRepo.AssertNotCalled(t.T(), "CreateManualTopup", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestReviewManualTopup_Approve() {
+	t.topupRepo.On("FindTopup", "topup-1").Return(entity.TopupRequest{Id: "topup-1", Amount: 50000, PaymentMethod: "bank_transfer", Fee: 2500, FeeChargedTo: entity.TopupFeePlatform}, nil).Once()
+	actor := entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin}
+	t.topupRepo.On("ReviewManualTopup", entity.TopupRequest{Id: "topup-1", Status: entity.TopupPaid, ReviewedBy: "admin-1", Fee: 2500, FeeChargedTo: entity.TopupFeePlatform},
+		auditEntry(actor, entity.AuditReview, entity.AuditTopup, "topup-1")).Return(nil).Once()
+
+	status, err := t.topupUsecase.ReviewManualTopup("topup-1", entity.ManualTopupReview{Approve: true}, actor)
+
+	t.NoError(err)
+	t.Equal(entity.TopupPaid, status)
+	t.topupRepo.AssertExpectations(t.T())
+}
+
+func (t *topupUsecaseSuite) TestReviewManualTopup_RejectNeedsReason() {
+	_, err := t.topupUsecase.ReviewManualTopup("topup-1", entity.ManualTopupReview{Reason: "  "}, entity.AuditActor{IdUser: "admin-1"})
+
+	t.ErrorIs(err, ErrInvalidManualTopup)
+	t.topupRepo.AssertNotCalled(t.T(), "ReviewManualTopup", mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestReviewManualTopup_NotFound() {
+	t.topupRepo.On("ReviewManualTopup", mock.Anything, mock.Anything).Return(fmt.Errorf("topup not found: %w", sql.ErrNoRows)).Once()
+
+	_, err := t.topupUsecase.ReviewManualTopup("topup-x", entity.ManualTopupReview{Reason: "no transfer received"}, entity.AuditActor{IdUser: "admin-1"})
+
+	t.ErrorIs(err, ErrTopupNotFound)
+}
+
+func (t *topupUsecaseSuite) TestHandlePaymentNotification_IgnoresManualTopup() {
+	t.topupRepo.On("FindTopup", "topup-1").Return(entity.TopupRequest{Id: "topup-1", Amount: 50000, Method: entity.TopupMethodManual}, nil).Once()
+
+	_, err := t.notify(entity.PaymentNotification{OrderId: "topup-1", Status: entity.TopupPaid, GrossAmount: 50000}, testPaymentToken)
+
+	t.ErrorIs(err, ErrTopupNotFound)
+	t.topupRepo.AssertNotCalled(t.T(), "TxTopupUpdateAfterPayment", mock.Anything, mock.Anything)
+}
+
+func (t *topupUsecaseSuite) TestMerchantForUser_SingleMerchant() {
+	t.topupRepo.On("MerchantIdsByUser", "user-1").Return([]string{"merchant-1"}, nil).Once()
+
+	id, err := t.topupUsecase.MerchantForUser("user-1", "")
+
+	t.NoError(err)
+	t.Equal("merchant-1", id)
+}
+
+func (t *topupUsecaseSuite) TestMerchantForUser_OtherMerchantForbidden() {
+	t.topupRepo.On("MerchantIdsByUser", "user-1").Return([]string{"merchant-1"}, nil).Once()
+
+	_, err := t.topupUsecase.MerchantForUser("user-1", "merchant-2")
+
+	t.ErrorIs(err, ErrTopupMerchantForbidden)
+}
+
+func (t *topupUsecaseSuite) TestMerchantForUser_SeveralMerchants() {
+	t.topupRepo.On("MerchantIdsByUser", "user-1").Return([]string{"merchant-1", "merchant-2"}, nil).Twice()
+
+	_, err := t.topupUsecase.MerchantForUser("user-1", "")
+	t.ErrorIs(err, ErrTopupMerchantRequired)
+
+	id, err := t.topupUsecase.MerchantForUser("user-1", "merchant-2")
+	t.NoError(err)
+	t.Equal("merchant-2", id)
+}
+
+func (t *topupUsecaseSuite) TestMerchantForUser_NoMerchant() {
+	t.topupRepo.On("MerchantIdsByUser", "user-1").Return([]string{}, nil).Once()
+
+	_, err := t.topupUsecase.MerchantForUser("user-1", "")
+
+	t.ErrorIs(err, ErrTopupMerchantForbidden)
+}
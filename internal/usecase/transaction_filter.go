@@ -0,0 +1,79 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"server-pulsa-app/internal/entity"
+	"server-pulsa-app/internal/shared/custom"
+)
+
+const defaultTransactionSort = "-date"
+
+var ErrInvalidTransactionFilter = errors.New("invalid transaction filter")
+
+// transactionCursor is the position of the last transaction of a page, encoded as opaque base64 JSON
+// so clients can only hand it back unchanged.
+type transactionCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	Id    string `json:"id"`
+}
+
+// transactionSortValue returns the value of the sort field of a transaction as the repository compares it.
+func transactionSortValue(transaction custom.TransactionsReq, field string) (string, bool) {
+	switch field {
+	case "date":
+		return transaction.TransactionDate.Format("2006-01-02"), true
+	case "customer_name":
+		return transaction.CustomerName, true
+	case "destination_number":
+		return transaction.DestinationNumber, true
+	case "status":
+		return transaction.Status, true
+	}
+	return "", false
+}
+
+func prepareTransactionFilter(filter entity.TransactionFilter) (entity.TransactionFilter, error) {
+	if filter.Sort == "" {
+		filter.Sort = defaultTransactionSort
+	}
+	if _, ok := transactionSortValue(custom.TransactionsReq{}, strings.TrimPrefix(filter.Sort, "-")); !ok {
+		return filter, fmt.Errorf("%w: unknown sort field %q", ErrInvalidTransactionFilter, filter.Sort)
+	}
+
+	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() && filter.EndDate.Before(filter.StartDate) {
+		return filter, fmt.Errorf("%w: end date is before start date", ErrInvalidTransactionFilter)
+	}
+
+	filter.Page, filter.Limit = normalizePaging(filter.Page, filter.Limit)
+
+	if filter.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(filter.Cursor)
+		if err != nil {
+			return filter, fmt.Errorf("%w: malformed cursor", ErrInvalidTransactionFilter)
+		}
+
+		var cursor transactionCursor
+		if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Id == "" {
+			return filter, fmt.Errorf("%w: malformed cursor", ErrInvalidTransactionFilter)
+		}
+		if cursor.Sort != filter.Sort {
+			return filter, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidTransactionFilter, cursor.Sort)
+		}
+
+		filter.AfterValue, filter.AfterId = cursor.Value, cursor.Id
+	}
+
+	return filter, nil
+}
+
+func encodeTransactionCursor(transaction custom.TransactionsReq, sort string) string {
+	value, _ := transactionSortValue(transaction, strings.TrimPrefix(sort, "-"))
+	raw, _ := json.Marshal(transactionCursor{Sort: sort, Value: value, Id: transaction.TransactionsId})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -1,38 +1,189 @@
 package usecase
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/repository"
 	"server-pulsa-app/internal/shared/custom"
+	"server-pulsa-app/internal/shared/model"
+)
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrRefundNotAllowed    = errors.New("transaction cannot be refunded")
 )
 
 type transactionUseCase struct {
-	repo repository.TransactionRepository
-	log  *logger.Logger
+	repo         repository.TransactionRepository
+	productRepo  repository.ProductRepository
+	operatorRepo repository.OperatorPrefixRepository
+	merchants    MerchantOwner
+	audit        AuditUseCase
+	log          *logger.Logger
 }
 
 type TransactionUseCase interface {
-	Create(payload entity.Transactions) (entity.Transactions, error)
-	GetAll(userId string) ([]custom.TransactionsReq, error)
+	Create(payload entity.Transactions, actor entity.AuditActor) (entity.Transactions, error)
+	CreateIdempotent(payload entity.Transactions, key entity.IdempotencyKey, actor entity.AuditActor) (entity.Transactions, bool, error)
+	GetAll(filter entity.TransactionFilter) ([]custom.TransactionsReq, model.Paging, error)
 	GetById(id string) (custom.TransactionsReq, error)
+	Refund(payload entity.TransactionRefund, actor entity.AuditActor) (entity.TransactionRefund, error)
+	BulkCreate(template entity.Transactions, filename string, file io.Reader, actor entity.AuditActor) (entity.BulkTransactionResult, error)
+	MerchantForUser(idUser, idMerchant string) (string, error)
 }
 
-func NewTransactionUseCase(repo repository.TransactionRepository, log *logger.Logger) TransactionUseCase {
-	return &transactionUseCase{repo: repo, log: log}
+func NewTransactionUseCase(repo repository.TransactionRepository, productRepo repository.ProductRepository, operatorRepo repository.OperatorPrefixRepository, merchants MerchantOwner, audit AuditUseCase, log *logger.Logger) TransactionUseCase {
+	return &transactionUseCase{repo: repo, productRepo: productRepo, operatorRepo: operatorRepo, merchants: merchants, audit: audit, log: log}
 }
 
-func (u *transactionUseCase) Create(payload entity.Transactions) (entity.Transactions, error) {
+func (u *transactionUseCase) Create(payload entity.Transactions, actor entity.AuditActor) (entity.Transactions, error) {
 	u.log.Info("Starting to create a new transaction in the usecase layer", nil)
-	return u.repo.Create(payload)
+
+	payload, err := u.validateDestination(payload)
+	if err != nil {
+		u.log.Error("Invalid transaction destination", err)
+		return entity.Transactions{}, err
+	}
+
+	transaction, err := u.repo.Create(payload)
+	if err != nil {
+		return entity.Transactions{}, err
+	}
+
+	u.audit.Record(actor, entity.AuditCreate, entity.AuditTransaction, transaction.TransactionsId, nil, transaction)
+	return transaction, nil
 }
 
-func (u *transactionUseCase) GetAll(userId string) ([]custom.TransactionsReq, error) {
+// CreateIdempotent creates the transaction once per idempotency key. Retries with the same key and
+// body return the originally created transaction and true; a different body is rejected.
+func (u *transactionUseCase) CreateIdempotent(payload entity.Transactions, key entity.IdempotencyKey, actor entity.AuditActor) (entity.Transactions, bool, error) {
+	u.log.Info("Starting to create a new idempotent transaction in the usecase layer", key.Key)
+
+	payload, err := u.validateDestination(payload)
+	if err != nil {
+		u.log.Error("Invalid transaction destination", err)
+		return entity.Transactions{}, false, err
+	}
+
+	key, err = prepareIdempotencyKey(key, payload)
+	if err != nil {
+		return entity.Transactions{}, false, err
+	}
+
+	transaction, stored, exists, err := u.repo.CreateIdempotent(payload, key)
+	if err != nil {
+		return entity.Transactions{}, false, err
+	}
+	if !exists {
+		u.audit.Record(actor, entity.AuditCreate, entity.AuditTransaction, transaction.TransactionsId, nil, transaction)
+		return transaction, false, nil
+	}
+
+	if err := replayIdempotencyKey(key, stored, &transaction); err != nil {
+		u.log.Error("Failed to replay idempotency key", err)
+		return entity.Transactions{}, false, err
+	}
+
+	u.log.Info("Replaying transaction for idempotency key", key.Key)
+	return transaction, true, nil
+}
+
+// GetAll returns one page of the transaction history. The returned paging carries a cursor for the
+// next page as long as the current page is full.
+func (u *transactionUseCase) GetAll(filter entity.TransactionFilter) ([]custom.TransactionsReq, model.Paging, error) {
 	u.log.Info("Starting to get all transactions in the usecase layer", nil)
-	return u.repo.GetAll(userId)
+
+	filter, err := prepareTransactionFilter(filter)
+	if err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	transactions, total, err := u.repo.GetAll(filter)
+	if err != nil {
+		return nil, model.Paging{}, err
+	}
+
+	paging := newPaging(filter.Page, filter.Limit, total)
+	hasMore := filter.Page*filter.Limit < total
+	if filter.Cursor != "" {
+		hasMore = len(transactions) == filter.Limit
+	}
+	if hasMore && len(transactions) > 0 {
+		paging.NextCursor = encodeTransactionCursor(transactions[len(transactions)-1], filter.Sort)
+	}
+
+	return transactions, paging, nil
 }
 
 func (u *transactionUseCase) GetById(id string) (custom.TransactionsReq, error) {
 	u.log.Info("Starting to get transaction by id in the usecase layer", nil)
 	return u.repo.GetById(id)
 }
+
+// MerchantForUser returns the merchant a user sells for, so a user can't create or read the transactions of another
+// user's merchant.
+func (u *transactionUseCase) MerchantForUser(idUser, idMerchant string) (string, error) {
+	return u.merchants.MerchantForUser(idUser, idMerchant)
+}
+
+// Refund refunds the requested details of a transaction, or every refundable detail when none are given.
+// Details that already failed or were refunded, or that are still at the supplier, cannot be refunded.
+func (u *transactionUseCase) Refund(payload entity.TransactionRefund, actor entity.AuditActor) (entity.TransactionRefund, error) {
+	u.log.Info("Starting to refund a transaction in the usecase layer", payload)
+
+	transaction, err := u.repo.GetById(payload.TransactionsId)
+	if err != nil {
+		return entity.TransactionRefund{}, err
+	}
+	if transaction.TransactionsId == "" {
+		return entity.TransactionRefund{}, ErrTransactionNotFound
+	}
+
+	statuses := make(map[string]string, len(transaction.TransactionDetail))
+	for _, detail := range transaction.TransactionDetail {
+		statuses[detail.TransactionDetailId] = detail.Status
+	}
+
+	requested := payload.TransactionDetailIds
+	payload.TransactionDetailIds = nil
+	if len(requested) == 0 {
+		for _, detail := range transaction.TransactionDetail {
+			switch detail.Status {
+			case entity.FulfillmentPending, entity.FulfillmentSuccess:
+				payload.TransactionDetailIds = append(payload.TransactionDetailIds, detail.TransactionDetailId)
+			case entity.FulfillmentProcessing:
+				return entity.TransactionRefund{}, fmt.Errorf("%w: detail %s is still being processed by the supplier", ErrRefundNotAllowed, detail.TransactionDetailId)
+			}
+		}
+		if len(payload.TransactionDetailIds) == 0 {
+			return entity.TransactionRefund{}, fmt.Errorf("%w: nothing left to refund", ErrRefundNotAllowed)
+		}
+	} else {
+		seen := make(map[string]bool, len(requested))
+		for _, detailId := range requested {
+			if seen[detailId] {
+				continue
+			}
+			seen[detailId] = true
+
+			status, ok := statuses[detailId]
+			if !ok {
+				return entity.TransactionRefund{}, fmt.Errorf("%w: detail %s does not belong to this transaction", ErrRefundNotAllowed, detailId)
+			}
+			if status != entity.FulfillmentPending && status != entity.FulfillmentSuccess {
+				return entity.TransactionRefund{}, fmt.Errorf("%w: detail %s is %s", ErrRefundNotAllowed, detailId, status)
+			}
+			payload.TransactionDetailIds = append(payload.TransactionDetailIds, detailId)
+		}
+	}
+
+	audit := auditEntry(actor, entity.AuditRefund, entity.AuditTransaction, transaction.TransactionsId)
+	if audit.Before, err = auditValue(transaction); err != nil {
+		return entity.TransactionRefund{}, err
+	}
+
+	return u.repo.Refund(payload, audit)
+}
@@ -1,27 +1,48 @@
 package usecase
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
 	"server-pulsa-app/internal/shared/custom"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"github.com/xuri/excelize/v2"
 )
 
 type transactionUsecaseTestSuite struct {
 	suite.Suite
 	mockTransactionRepo *repositorymock.MockTransactionRepository
+	mockProductRepo     *repositorymock.MockProductRepository
+	mockOperatorRepo    *repositorymock.MockOperatorPrefixRepository
+	merchants           *merchantOwnerMock
+	audit               *usecase_mock.AuditUseCaseMock
 	transactionUseCase  TransactionUseCase
 	log                 logger.Logger
 }
 
 func (tx *transactionUsecaseTestSuite) SetupTest() {
 	tx.mockTransactionRepo = new(repositorymock.MockTransactionRepository)
+	tx.mockProductRepo = new(repositorymock.MockProductRepository)
+	tx.mockOperatorRepo = new(repositorymock.MockOperatorPrefixRepository)
+	tx.merchants = new(merchantOwnerMock)
+	tx.audit = new(usecase_mock.AuditUseCaseMock)
 	tx.log = logger.NewLogger()
-	tx.transactionUseCase = NewTransactionUseCase(tx.mockTransactionRepo, &tx.log)
+	tx.transactionUseCase = NewTransactionUseCase(tx.mockTransactionRepo, tx.mockProductRepo, tx.mockOperatorRepo, tx.merchants, tx.audit, &tx.log)
+
+	tx.mockOperatorRepo.On("FindByNumber", "081234567890").Return(entity.OperatorPrefix{Prefix: "0812", Operator: "Telkomsel"}, nil).Maybe()
+	tx.mockProductRepo.On("Get", "uuid-test").Return(entity.Product{IdProduct: "uuid-test", NameProvider: "Telkomsel"}, nil).Maybe()
 }
 
 func (tx *transactionUsecaseTestSuite) TestCreate_Success() {
@@ -29,7 +50,7 @@ func (tx *transactionUsecaseTestSuite) TestCreate_Success() {
 		MerchantId:        "uuid-test",
 		UserId:            "uuid-test",
 		CustomerName:      "custtest",
-		DestinationNumber: "087654321",
+		DestinationNumber: "081234567890",
 		TransactionDate:   "25-10-2024",
 		TransactionDetail: []entity.TransactionDetail{
 			{
@@ -44,7 +65,7 @@ func (tx *transactionUsecaseTestSuite) TestCreate_Success() {
 		MerchantId:        "uuid-test",
 		UserId:            "uuid-test",
 		CustomerName:      "custtest",
-		DestinationNumber: "087654321",
+		DestinationNumber: "081234567890",
 		TransactionDate:   "25-10-2024",
 		TransactionDetail: []entity.TransactionDetail{
 			{
@@ -56,12 +77,15 @@ func (tx *transactionUsecaseTestSuite) TestCreate_Success() {
 		},
 	}
 
+	actor := entity.AuditActor{IdUser: "uuid-test", ApiKey: "pk_test", ClientIP: "10.0.0.1"}
 	tx.mockTransactionRepo.On("Create", newTx).Return(CreatedTx, nil).Once()
+	tx.audit.On("Record", actor, entity.AuditCreate, entity.AuditTransaction, "uuid-test", nil, CreatedTx).Once()
 
-	transaction, err := tx.transactionUseCase.Create(newTx)
+	transaction, err := tx.transactionUseCase.Create(newTx, actor)
 
 	tx.Nil(err)
 	tx.Equal(CreatedTx, transaction)
+	tx.audit.AssertExpectations(tx.T())
 }
 
 func (tx *transactionUsecaseTestSuite) TestList_Success() {
@@ -127,12 +151,43 @@ func (tx *transactionUsecaseTestSuite) TestList_Success() {
 		},
 	}
 
-	tx.mockTransactionRepo.On("List").Return(transactions, nil).Once()
+	filter := entity.TransactionFilter{UserId: "uuid-test", Sort: "-date", Page: 1, Limit: len(transactions)}
+	tx.mockTransactionRepo.On("GetAll", filter).Return(transactions, 5, nil).Once()
 
-	txList, err := tx.transactionUseCase.GetAll("")
+	txList, paging, err := tx.transactionUseCase.GetAll(entity.TransactionFilter{UserId: "uuid-test", Limit: len(transactions)})
 
 	tx.Nil(err)
 	tx.Equal(transactions, txList)
+	tx.Equal(5, paging.TotalRows)
+	tx.Equal(3, paging.TotalPages)
+	tx.NotEmpty(paging.NextCursor)
+
+	// the cursor resumes after the last transaction of the page
+	last := transactions[len(transactions)-1]
+	next := entity.TransactionFilter{UserId: "uuid-test", Sort: "-date", Page: 1, Limit: len(transactions),
+		Cursor: paging.NextCursor, AfterValue: last.TransactionDate.Format("2006-01-02"), AfterId: last.TransactionsId}
+	tx.mockTransactionRepo.On("GetAll", next).Return(transactions[:1], 5, nil).Once()
+
+	txList, paging, err = tx.transactionUseCase.GetAll(entity.TransactionFilter{UserId: "uuid-test", Limit: len(transactions), Cursor: paging.NextCursor})
+
+	tx.Nil(err)
+	tx.Len(txList, 1)
+	tx.Empty(paging.NextCursor)
+}
+
+func (tx *transactionUsecaseTestSuite) TestList_InvalidFilter() {
+	filters := []entity.TransactionFilter{
+		{UserId: "uuid-test", Sort: "price"},
+		{UserId: "uuid-test", Cursor: "not a cursor"},
+		{UserId: "uuid-test", Sort: "date", Cursor: encodeTransactionCursor(custom.TransactionsReq{TransactionsId: "uuid-test"}, "-date")},
+		{UserId: "uuid-test", StartDate: time.Now(), EndDate: time.Now().AddDate(0, 0, -1)},
+	}
+
+	for _, filter := range filters {
+		_, _, err := tx.transactionUseCase.GetAll(filter)
+		tx.ErrorIs(err, ErrInvalidTransactionFilter)
+	}
+	tx.mockTransactionRepo.AssertNotCalled(tx.T(), "GetAll", mock.Anything)
 }
 
 func (tx *transactionUsecaseTestSuite) TestGetById_Success() {
@@ -170,7 +225,7 @@ func (tx *transactionUsecaseTestSuite) TestGetById_Success() {
 		},
 	}
 
-	tx.mockTransactionRepo.On("Get", id).Return(transaction, nil).Once()
+	tx.mockTransactionRepo.On("GetById", id).Return(transaction, nil).Once()
 
 	txFound, err := tx.transactionUseCase.GetById(id)
 
@@ -178,6 +233,322 @@ func (tx *transactionUsecaseTestSuite) TestGetById_Success() {
 	tx.Equal(transaction, txFound)
 }
 
+func (tx *transactionUsecaseTestSuite) idempotentPayload() (entity.Transactions, entity.Transactions) {
+	newTx := entity.Transactions{
+		MerchantId:        "uuid-test",
+		UserId:            "uuid-test",
+		CustomerName:      "custtest",
+		DestinationNumber: "081234567890",
+		TransactionDate:   "25-10-2024",
+		TransactionDetail: []entity.TransactionDetail{{ProductId: "uuid-test"}},
+	}
+	createdTx := newTx
+	createdTx.TransactionsId = "uuid-test"
+	createdTx.Status = "pending"
+	return newTx, createdTx
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreateIdempotent_FirstRequest() {
+	newTx, createdTx := tx.idempotentPayload()
+	key := entity.IdempotencyKey{Key: "key-1", Scope: "/transaction:uuid-test"}
+
+	tx.mockTransactionRepo.On("CreateIdempotent", newTx, mock.MatchedBy(func(k entity.IdempotencyKey) bool {
+		return k.Key == key.Key && k.Scope == key.Scope && len(k.RequestHash) == 64
+	})).Return(createdTx, entity.IdempotencyKey{}, false, nil).Once()
+	tx.audit.On("Record", entity.AuditActor{}, entity.AuditCreate, entity.AuditTransaction, createdTx.TransactionsId, nil, createdTx).Once()
+
+	transaction, replayed, err := tx.transactionUseCase.CreateIdempotent(newTx, key, entity.AuditActor{})
+
+	tx.Nil(err)
+	tx.False(replayed)
+	tx.Equal(createdTx, transaction)
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreateIdempotent_Replay() {
+	newTx, createdTx := tx.idempotentPayload()
+	key, err := prepareIdempotencyKey(entity.IdempotencyKey{Key: "key-1", Scope: "/transaction:uuid-test"}, newTx)
+	tx.Require().NoError(err)
+
+	stored := key
+	stored.Response, err = json.Marshal(createdTx)
+	tx.Require().NoError(err)
+
+	tx.mockTransactionRepo.On("CreateIdempotent", newTx, key).Return(entity.Transactions{}, stored, true, nil).Once()
+
+	transaction, replayed, err := tx.transactionUseCase.CreateIdempotent(newTx, key, entity.AuditActor{})
+
+	tx.Nil(err)
+	tx.True(replayed)
+	tx.Equal(createdTx, transaction)
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreateIdempotent_DifferentBody() {
+	newTx, _ := tx.idempotentPayload()
+	key, err := prepareIdempotencyKey(entity.IdempotencyKey{Key: "key-1", Scope: "/transaction:uuid-test"}, newTx)
+	tx.Require().NoError(err)
+
+	stored := key
+	stored.RequestHash = "another-request"
+	stored.Response = []byte(`{}`)
+
+	tx.mockTransactionRepo.On("CreateIdempotent", newTx, key).Return(entity.Transactions{}, stored, true, nil).Once()
+
+	_, _, err = tx.transactionUseCase.CreateIdempotent(newTx, key, entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrIdempotencyKeyMismatch)
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreateIdempotent_InvalidKey() {
+	newTx, _ := tx.idempotentPayload()
+
+	_, _, err := tx.transactionUseCase.CreateIdempotent(newTx, entity.IdempotencyKey{Key: "   "}, entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrIdempotencyKeyInvalid)
+	tx.mockTransactionRepo.AssertNotCalled(tx.T(), "CreateIdempotent", mock.Anything, mock.Anything)
+}
+
+func (tx *transactionUsecaseTestSuite) refundableTransaction(statuses ...string) custom.TransactionsReq {
+	transaction := custom.TransactionsReq{TransactionsId: "tx-uuid"}
+	for i, status := range statuses {
+		transaction.TransactionDetail = append(transaction.TransactionDetail, custom.TransactionDetailReq{
+			TransactionDetailId: fmt.Sprintf("detail-%d", i+1),
+			Status:              status,
+		})
+	}
+	return transaction
+}
+
+func (tx *transactionUsecaseTestSuite) TestRefund_FullRefundSkipsFinishedDetails() {
+	tx.mockTransactionRepo.On("GetById", "tx-uuid").
+		Return(tx.refundableTransaction(entity.FulfillmentSuccess, entity.FulfillmentFailed, entity.FulfillmentPending), nil).Once()
+
+	expected := entity.TransactionRefund{
+		TransactionsId:       "tx-uuid",
+		TransactionDetailIds: []string{"detail-1", "detail-3"},
+		Reason:               "wrong number",
+		RefundedBy:           "admin-uuid",
+	}
+	refunded := expected
+	refunded.Amount = 20000
+	tx.mockTransactionRepo.On("Refund", expected, mock.MatchedBy(func(audit entity.AuditLog) bool {
+		return audit.IdUser == "admin-uuid" && audit.Action == entity.AuditRefund && audit.EntityId == "tx-uuid" &&
+			strings.Contains(string(audit.Before), `"detail-3"`)
+	})).Return(refunded, nil).Once()
+
+	refund, err := tx.transactionUseCase.Refund(entity.TransactionRefund{TransactionsId: "tx-uuid", Reason: "wrong number", RefundedBy: "admin-uuid"}, entity.AuditActor{IdUser: "admin-uuid"})
+
+	tx.Nil(err)
+	tx.Equal(refunded, refund)
+	tx.mockTransactionRepo.AssertExpectations(tx.T())
+}
+
+func (tx *transactionUsecaseTestSuite) TestRefund_AlreadyRefunded() {
+	tx.mockTransactionRepo.On("GetById", "tx-uuid").
+		Return(tx.refundableTransaction(entity.FulfillmentRefunded), nil).Once()
+
+	_, err := tx.transactionUseCase.Refund(entity.TransactionRefund{TransactionsId: "tx-uuid", TransactionDetailIds: []string{"detail-1"}, Reason: "twice"}, entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrRefundNotAllowed)
+	tx.mockTransactionRepo.AssertNotCalled(tx.T(), "Refund", mock.Anything, mock.Anything)
+}
+
+func (tx *transactionUsecaseTestSuite) TestRefund_StillProcessing() {
+	tx.mockTransactionRepo.On("GetById", "tx-uuid").
+		Return(tx.refundableTransaction(entity.FulfillmentSuccess, entity.FulfillmentProcessing), nil).Once()
+
+	_, err := tx.transactionUseCase.Refund(entity.TransactionRefund{TransactionsId: "tx-uuid", Reason: "in flight"}, entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrRefundNotAllowed)
+}
+
+func (tx *transactionUsecaseTestSuite) TestRefund_NotFound() {
+	tx.mockTransactionRepo.On("GetById", "missing").Return(custom.TransactionsReq{}, nil).Once()
+
+	_, err := tx.transactionUseCase.Refund(entity.TransactionRefund{TransactionsId: "missing", Reason: "typo"}, entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrTransactionNotFound)
+}
+
 func TestTransactionUsecaseTestSuite(t *testing.T) {
 	suite.Run(t, new(transactionUsecaseTestSuite))
 }
+
+func (tx *transactionUsecaseTestSuite) TestCreate_NormalizesDestinationNumber() {
+	newTx, createdTx := tx.idempotentPayload()
+	newTx.DestinationNumber = "+62 812-3456-7890"
+
+	normalized := newTx
+	normalized.DestinationNumber = "081234567890"
+	tx.mockTransactionRepo.On("Create", normalized).Return(createdTx, nil).Once()
+	tx.audit.On("Record", entity.AuditActor{}, entity.AuditCreate, entity.AuditTransaction, createdTx.TransactionsId, nil, createdTx).Once()
+
+	transaction, err := tx.transactionUseCase.Create(newTx, entity.AuditActor{})
+
+	tx.Nil(err)
+	tx.Equal(createdTx, transaction)
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreate_InvalidDestinationNumber() {
+	for _, number := range []string{"", "0812", "021555123456", "62812abc4567"} {
+		newTx, _ := tx.idempotentPayload()
+		newTx.DestinationNumber = number
+
+		_, err := tx.transactionUseCase.Create(newTx, entity.AuditActor{})
+
+		tx.ErrorIs(err, ErrInvalidDestinationNumber, number)
+	}
+	tx.mockTransactionRepo.AssertNotCalled(tx.T(), "Create", mock.Anything)
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreate_UnknownOperator() {
+	newTx, _ := tx.idempotentPayload()
+	newTx.DestinationNumber = "0800123456"
+	tx.mockOperatorRepo.On("FindByNumber", "0800123456").Return(entity.OperatorPrefix{}, sql.ErrNoRows).Once()
+
+	_, err := tx.transactionUseCase.Create(newTx, entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrUnknownOperator)
+	tx.mockTransactionRepo.AssertNotCalled(tx.T(), "Create", mock.Anything)
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreate_OperatorMismatch() {
+	newTx, _ := tx.idempotentPayload()
+	newTx.DestinationNumber = "081712345678"
+	tx.mockOperatorRepo.On("FindByNumber", "081712345678").Return(entity.OperatorPrefix{Prefix: "0817", Operator: "XL"}, nil).Once()
+
+	_, err := tx.transactionUseCase.Create(newTx, entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrOperatorMismatch)
+	tx.mockTransactionRepo.AssertNotCalled(tx.T(), "Create", mock.Anything)
+}
+
+func (tx *transactionUsecaseTestSuite) TestCreate_MatchesOperatorBrandNames() {
+	providers := map[string]entity.OperatorPrefix{
+		"Indosat Ooredoo": {Prefix: "0856", Operator: "Indosat"},
+		"Three":           {Prefix: "0896", Operator: "Tri"},
+		"XL Axiata":       {Prefix: "0817", Operator: "xl"},
+	}
+	for provider, prefix := range providers {
+		newTx, createdTx := tx.idempotentPayload()
+		newTx.DestinationNumber = prefix.Prefix + "12345678"
+		newTx.TransactionDetail = []entity.TransactionDetail{{ProductId: "uuid-" + provider}}
+		tx.mockOperatorRepo.On("FindByNumber", newTx.DestinationNumber).Return(prefix, nil).Once()
+		tx.mockProductRepo.On("Get", "uuid-"+provider).Return(entity.Product{IdProduct: "uuid-" + provider, NameProvider: provider}, nil).Once()
+		tx.mockTransactionRepo.On("Create", newTx).Return(createdTx, nil).Once()
+		tx.audit.On("Record", entity.AuditActor{}, entity.AuditCreate, entity.AuditTransaction, createdTx.TransactionsId, nil, createdTx).Once()
+
+		_, err := tx.transactionUseCase.Create(newTx, entity.AuditActor{})
+
+		tx.NoError(err, provider)
+	}
+}
+
+// bulkKey is the idempotency key of a row of the sheet uploaded for uuid-test on 25-10-2024.
+func bulkKey(sheet string, line int) entity.IdempotencyKey {
+	sum := sha256.Sum256([]byte(sheet))
+	return entity.IdempotencyKey{Key: fmt.Sprintf("%s:25-10-2024:%d", hex.EncodeToString(sum[:]), line), Scope: "bulk:uuid-test"}
+}
+
+func (tx *transactionUsecaseTestSuite) TestBulkCreate_Csv() {
+	template := entity.Transactions{MerchantId: "uuid-test", UserId: "uuid-test", TransactionDate: "25-10-2024"}
+	sheet := "customer_name,destination_number,product_id\n" +
+		"budi,081234567890,uuid-test\n" +
+		",,\n" +
+		"ani,,uuid-test\n" +
+		"citra,+6281234567890,uuid-test\n"
+	tx.merchants.On("MerchantForUser", "uuid-test", "uuid-test").Return("uuid-test", nil).Once()
+
+	first := template
+	first.CustomerName, first.DestinationNumber = "budi", "081234567890"
+	first.TransactionDetail = []entity.TransactionDetail{{ProductId: "uuid-test"}}
+	created := first
+	created.TransactionsId = "tx-1"
+	tx.mockTransactionRepo.On("CreateIdempotent", first, mock.MatchedBy(func(key entity.IdempotencyKey) bool {
+		return key.Key == bulkKey(sheet, 2).Key && key.Scope == bulkKey(sheet, 2).Scope
+	})).Return(created, entity.IdempotencyKey{}, false, nil).Once()
+	tx.audit.On("Record", entity.AuditActor{}, entity.AuditCreate, entity.AuditTransaction, "tx-1", nil, created).Once()
+
+	third := first
+	third.CustomerName = "citra"
+	tx.mockTransactionRepo.On("CreateIdempotent", third, mock.MatchedBy(func(key entity.IdempotencyKey) bool {
+		return key.Key == bulkKey(sheet, 5).Key
+	})).Return(entity.Transactions{}, entity.IdempotencyKey{}, false, fmt.Errorf("insufficient merchant balance")).Once()
+
+	result, err := tx.transactionUseCase.BulkCreate(template, "office.csv", strings.NewReader(sheet), entity.AuditActor{})
+
+	tx.Require().NoError(err)
+	tx.Equal(1, result.Succeeded)
+	tx.Equal(2, result.Failed)
+	tx.Equal("office_result.csv", result.FileName)
+	tx.Equal([]entity.BulkTransactionRow{
+		{Line: 2, CustomerName: "budi", DestinationNumber: "081234567890", ProductId: "uuid-test", Status: entity.BulkRowSuccess, TransactionId: "tx-1"},
+		{Line: 4, CustomerName: "ani", ProductId: "uuid-test", Status: entity.BulkRowFailed, Error: "destination number is required"},
+		{Line: 5, CustomerName: "citra", DestinationNumber: "+6281234567890", ProductId: "uuid-test", Status: entity.BulkRowFailed, Error: "insufficient merchant balance"},
+	}, result.Rows)
+	tx.Contains(string(result.File), "budi,081234567890,uuid-test,success,tx-1,\n")
+}
+
+func (tx *transactionUsecaseTestSuite) TestBulkCreate_ReuploadReplaysRows() {
+	template := entity.Transactions{MerchantId: "uuid-test", UserId: "uuid-test", TransactionDate: "25-10-2024"}
+	sheet := "customer_name,destination_number,product_id\nbudi,081234567890,uuid-test\n"
+	tx.merchants.On("MerchantForUser", "uuid-test", "uuid-test").Return("uuid-test", nil).Once()
+
+	payload := template
+	payload.CustomerName, payload.DestinationNumber = "budi", "081234567890"
+	payload.TransactionDetail = []entity.TransactionDetail{{ProductId: "uuid-test"}}
+	stored, err := prepareIdempotencyKey(bulkKey(sheet, 2), payload)
+	tx.Require().NoError(err)
+	stored.Response = []byte(`{"transactionId":"tx-1"}`)
+	tx.mockTransactionRepo.On("CreateIdempotent", payload, mock.Anything).Return(entity.Transactions{}, stored, true, nil).Once()
+
+	result, err := tx.transactionUseCase.BulkCreate(template, "office.csv", strings.NewReader(sheet), entity.AuditActor{})
+
+	tx.Require().NoError(err)
+	tx.Equal(1, result.Succeeded)
+	tx.Equal("tx-1", result.Rows[0].TransactionId)
+	tx.audit.AssertNotCalled(tx.T(), "Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (tx *transactionUsecaseTestSuite) TestBulkCreate_ForeignMerchant() {
+	template := entity.Transactions{MerchantId: "other-merchant", UserId: "uuid-test", TransactionDate: "25-10-2024"}
+	tx.merchants.On("MerchantForUser", "uuid-test", "other-merchant").Return("", ErrTopupMerchantForbidden).Once()
+
+	_, err := tx.transactionUseCase.BulkCreate(template, "office.csv", strings.NewReader("customer_name,destination_number,product_id\nbudi,081234567890,uuid-test\n"), entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrTopupMerchantForbidden)
+	tx.mockTransactionRepo.AssertNotCalled(tx.T(), "CreateIdempotent", mock.Anything, mock.Anything)
+}
+
+func (tx *transactionUsecaseTestSuite) TestBulkCreate_UnsupportedFile() {
+	_, err := tx.transactionUseCase.BulkCreate(entity.Transactions{}, "office.pdf", strings.NewReader("%PDF"), entity.AuditActor{})
+
+	tx.ErrorIs(err, ErrInvalidBulkFile)
+}
+
+func (tx *transactionUsecaseTestSuite) TestBulkCreate_Xlsx() {
+	template := entity.Transactions{MerchantId: "uuid-test", UserId: "uuid-test", TransactionDate: "25-10-2024"}
+
+	upload := excelize.NewFile()
+	tx.Require().NoError(upload.SetSheetRow("Sheet1", "A1", &[]string{"customer_name", "destination_number", "product_id"}))
+	tx.Require().NoError(upload.SetSheetRow("Sheet1", "A2", &[]string{"budi", "081234567890", "uuid-test"}))
+	sheet, err := upload.WriteToBuffer()
+	tx.Require().NoError(err)
+
+	payload := template
+	payload.CustomerName, payload.DestinationNumber = "budi", "081234567890"
+	payload.TransactionDetail = []entity.TransactionDetail{{ProductId: "uuid-test"}}
+	created := payload
+	created.TransactionsId = "tx-1"
+	tx.merchants.On("MerchantForUser", "uuid-test", "uuid-test").Return("uuid-test", nil).Once()
+	tx.mockTransactionRepo.On("CreateIdempotent", payload, mock.Anything).Return(created, entity.IdempotencyKey{}, false, nil).Once()
+	tx.audit.On("Record", entity.AuditActor{}, entity.AuditCreate, entity.AuditTransaction, "tx-1", nil, created).Once()
+
+	result, err := tx.transactionUseCase.BulkCreate(template, "office.xlsx", sheet, entity.AuditActor{})
+	tx.Require().NoError(err)
+
+	resultFile, err := excelize.OpenReader(bytes.NewReader(result.File))
+	tx.Require().NoError(err)
+	rows, err := resultFile.GetRows(resultFile.GetSheetName(0))
+	tx.Require().NoError(err)
+	tx.Equal([][]string{bulkTransactionHeader, {"budi", "081234567890", "uuid-test", "success", "tx-1"}}, rows)
+}
@@ -1,32 +1,62 @@
 package usecase
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/repository"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidUser   = errors.New("invalid user")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrWeakPassword  = errors.New("password doesn't meet the password policy")
+	ErrWrongPassword = errors.New("old password doesn't match")
+
+	ErrRoleNotAssignable = errors.New("role grants permissions the caller doesn't have")
+
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// dummyPasswordHash is compared against when the username doesn't exist, so unknown usernames take as long to
+// reject as wrong passwords.
+var dummyPasswordHash = []byte("$2a$10$YMi8BEgGq/QUuL//ZbheC.2XOs.43F9Gdn33GtQj.BMkPIw8VJbNG")
+
 type UserUsecase interface {
-	RegisterUser(user entity.User) (entity.User, error)
+	RegisterUser(user entity.User, actor entity.AuditActor) (entity.User, error)
+	CreateUser(payload entity.UserCreateRequest, actor entity.AuditActor) (entity.User, error)
+	BootstrapAdmin(username, password string) (bool, error)
 	GetUserByID(id string) (entity.User, error)
 	ListUser() ([]entity.User, error)
 	GetUserByUsername(username string) (entity.User, error)
 	FindUserByUsernamePassword(username, password string) (entity.User, error)
-	UpdateUser(payload entity.User) (entity.User, error)
-	DeleteUser(id string) error
+	UpdateUser(payload entity.User, actor entity.AuditActor) (entity.User, error)
+	UpdateProfile(id string, payload entity.ProfileUpdateRequest, actor entity.AuditActor) (entity.User, error)
+	ChangePassword(id, idSession string, payload entity.ChangePasswordRequest, actor entity.AuditActor) error
+	DeleteUser(id string, actor entity.AuditActor) error
 }
 
 type userUsecase struct {
 	UserRepository repository.UserRepository
+	sessionRepo    repository.AuthSessionRepository
+	roleRepo       repository.RoleRepository
+	audit          AuditUseCase
+	policy         config.PasswordConfig
 	log            *logger.Logger
 }
 
-func (u *userUsecase) RegisterUser(user entity.User) (entity.User, error) {
+// RegisterUser creates an employee through the public registration. The new user is the actor of its own creation.
+func (u *userUsecase) RegisterUser(user entity.User, actor entity.AuditActor) (entity.User, error) {
 	u.log.Info("Starting to create a new user in the usecase layer", nil)
 
 	if strings.TrimSpace(user.Username) == "" || strings.TrimSpace(user.Password) == "" {
@@ -42,18 +72,85 @@ func (u *userUsecase) RegisterUser(user entity.User) (entity.User, error) {
 	}
 
 	u.log.Info("Starting to set default role for new user", nil)
-	user.Role = "employee"
+	user.Role = entity.RoleEmployee
 	u.log.Info("Starting to hash the password", nil)
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hash, err := u.hashPassword(user.Username, user.Password)
 	if err != nil {
-		u.log.Error("Failed to hash password: ", err)
 		return entity.User{}, err
 	}
 
-	user.Password = string(hash)
+	user.Password = hash
 
 	u.log.Info("Starting to create a new user in the repository layer", nil)
-	return u.UserRepository.CreateUser(user)
+	created, err := u.UserRepository.CreateUser(user)
+	if err != nil {
+		return entity.User{}, err
+	}
+
+	if actor.IdUser == "" {
+		actor.IdUser = created.Id_user
+	}
+	u.audit.Record(actor, entity.AuditCreate, entity.AuditUser, created.Id_user, nil, created)
+	return created, nil
+}
+
+// CreateUser provisions a user with the chosen role, optionally as the owner of a merchant. Unlike RegisterUser
+// it is reserved to admins, so the role is not forced to employee.
+func (u *userUsecase) CreateUser(payload entity.UserCreateRequest, actor entity.AuditActor) (entity.User, error) {
+	u.log.Info("Starting to provision a user in the usecase layer", nil)
+
+	user := entity.User{
+		Username: strings.TrimSpace(payload.Username),
+		Password: payload.Password,
+		Role:     strings.TrimSpace(payload.Role),
+	}
+	if user.Username == "" || strings.TrimSpace(user.Password) == "" || user.Role == "" {
+		return entity.User{}, fmt.Errorf("%w: username, password and role can't be empty", ErrInvalidUser)
+	}
+
+	if err := u.checkAssignableRoles(actor, user.Role); err != nil {
+		return entity.User{}, err
+	}
+
+	hash, err := u.hashPassword(user.Username, user.Password)
+	if err != nil {
+		return entity.User{}, err
+	}
+	user.Password = hash
+
+	created, err := u.UserRepository.CreateUserWithMerchant(user, strings.TrimSpace(payload.IdMerchant))
+	if err != nil {
+		u.log.Error("Failed to provision user: ", err)
+		return entity.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	u.audit.Record(actor, entity.AuditCreate, entity.AuditUser, created.Id_user, nil,
+		map[string]interface{}{"user": created, "id_merchant": strings.TrimSpace(payload.IdMerchant)})
+	return created, nil
+}
+
+// BootstrapAdmin creates the first admin while the database has no user at all, so a fresh installation can be
+// administered without editing the database. It returns false when users already exist.
+func (u *userUsecase) BootstrapAdmin(username, password string) (bool, error) {
+	if strings.TrimSpace(username) == "" || strings.TrimSpace(password) == "" {
+		return false, fmt.Errorf("%w: username and password can't be empty", ErrInvalidUser)
+	}
+
+	username = strings.TrimSpace(username)
+	hash, err := u.hashPassword(username, password)
+	if err != nil {
+		return false, err
+	}
+
+	admin, created, err := u.UserRepository.CreateFirstUser(entity.User{Username: username, Password: hash, Role: entity.RoleAdmin})
+	if err != nil {
+		return false, fmt.Errorf("failed to create the first admin: %w", err)
+	}
+
+	if created {
+		u.audit.Record(entity.SystemActor("bootstrap"), entity.AuditCreate, entity.AuditUser, admin.Id_user, nil, admin)
+	}
+	return created, nil
 }
 
 func (u *userUsecase) GetUserByUsername(username string) (entity.User, error) {
@@ -75,57 +172,192 @@ func (u *userUsecase) FindUserByUsernamePassword(username, password string) (ent
 	u.log.Info("Starting to authenticate a user in the usecase layer", nil)
 
 	userExist, err := u.UserRepository.GetUserByUsername(username)
-	if err != nil {
-		u.log.Error("User ID %s not found: %v", userExist.Id_user)
-		return entity.User{}, fmt.Errorf("user doesn't exists")
+	if errors.Is(err, sql.ErrNoRows) {
+		u.log.Error("Unknown username", nil)
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return entity.User{}, ErrInvalidCredentials
+	} else if err != nil {
+		return entity.User{}, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	u.log.Info("Starting to validate password", nil)
 	err = bcrypt.CompareHashAndPassword([]byte(userExist.Password), []byte(password))
 	if err != nil {
 		u.log.Error("Password doesn't match", err)
-		return entity.User{}, fmt.Errorf("password doesn't match")
+		return entity.User{}, ErrInvalidCredentials
 	}
 
 	u.log.Info("User ID %s has been authenticated successfully: ", userExist.Id_user)
 	return userExist, nil
 }
 
-func (u *userUsecase) UpdateUser(user entity.User) (entity.User, error) {
+func (u *userUsecase) UpdateUser(user entity.User, actor entity.AuditActor) (entity.User, error) {
 	u.log.Info("Starting to update a user in the usecase layer", nil)
 
 	payload, err := u.UserRepository.GetUserByID(user.Id_user)
 	if err != nil {
 		u.log.Error("User ID %s not found: %v", user.Id_user)
-		return entity.User{}, fmt.Errorf("user ID %s not found", user.Id_user)
+		return entity.User{}, fmt.Errorf("%w: user ID %s", ErrUserNotFound, user.Id_user)
 	}
-	u.log.Info("Starting to hash the password", nil)
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	if err != nil {
-		u.log.Error("Failed to hash password: ", err)
-		return entity.User{}, fmt.Errorf("failed to hash password: %v", err)
+
+	// the caller must outrank the user both as it is and as it will be
+	roles := []string{payload.Role}
+	if user.Role != "" && user.Role != payload.Role {
+		roles = append(roles, user.Role)
+	}
+	if err := u.checkAssignableRoles(actor, roles...); err != nil {
+		return entity.User{}, err
+	}
+
+	// An empty password keeps the current one instead of hashing the empty string.
+	if user.Password != "" {
+		username := payload.Username
+		if strings.TrimSpace(user.Username) != "" {
+			username = user.Username
+		}
+
+		u.log.Info("Starting to hash the password", nil)
+		hash, err := u.hashPassword(username, user.Password)
+		if err != nil {
+			return entity.User{}, err
+		}
+		user.Password = hash
 	}
-	user.Password = string(hash)
 
 	updatedUser, err := u.UserRepository.UpdateUser(payload, user)
 	if err != nil {
 		u.log.Error("Failed to update user: ", err)
-		return entity.User{}, fmt.Errorf("failed to update user: %v", err)
+		return entity.User{}, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	// a new password or role must not leave the user signed in with the old credentials or permissions
+	reason := ""
+	if user.Password != "" {
+		reason = repository.SessionRevokedPasswordChange
+	} else if user.Role != "" && user.Role != payload.Role {
+		reason = repository.SessionRevokedRoleChange
+	}
+	if reason != "" {
+		if err := u.sessionRepo.RevokeUserSessions(user.Id_user, "", reason); err != nil {
+			u.log.Error("Failed to revoke the sessions of the user: ", err)
+			return entity.User{}, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
 	}
 
 	u.log.Info("User ID %s has been updated successfully: ", user.Id_user)
+	u.audit.Record(actor, entity.AuditUpdate, entity.AuditUser, user.Id_user, payload, updatedUser)
+	// the password hash never goes into the audit log, a new one is recorded as its own action
+	if user.Password != "" {
+		u.audit.Record(actor, entity.AuditChangePassword, entity.AuditUser, user.Id_user, nil, nil)
+	}
 	return updatedUser, nil
 }
 
-func (u *userUsecase) DeleteUser(id string) error {
+// checkAssignableRoles lets the actor give users the roles only when the actor holds every permission of them, so
+// user:manage can't be used to hand out admin, not even to oneself. Holders of role:manage may assign any role.
+// The same check guards deleting users, so user:manage can't remove an admin either.
+func (u *userUsecase) checkAssignableRoles(actor entity.AuditActor, roles ...string) error {
+	granted, err := u.roleRepo.Permissions(actor.Role)
+	if err != nil {
+		return fmt.Errorf("failed to get the permissions of the caller: %w", err)
+	}
+	if slices.Contains(granted, entity.PermRoleManage) {
+		return nil
+	}
+
+	for _, role := range roles {
+		required, err := u.roleRepo.Permissions(role)
+		if err != nil {
+			return fmt.Errorf("failed to get the permissions of role %s: %w", role, err)
+		}
+		for _, permission := range required {
+			if !slices.Contains(granted, permission) {
+				return fmt.Errorf("%w: %s needs %s", ErrRoleNotAssignable, role, permission)
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateProfile changes the account of the logged in user. Only the username can be changed here, the role
+// stays in the hands of admins and the password goes through ChangePassword.
+func (u *userUsecase) UpdateProfile(id string, payload entity.ProfileUpdateRequest, actor entity.AuditActor) (entity.User, error) {
+	u.log.Info("Starting to update a profile in the usecase layer", nil)
+
+	username := strings.TrimSpace(payload.Username)
+	if username == "" {
+		return entity.User{}, fmt.Errorf("%w: username can't be empty", ErrInvalidUser)
+	}
+
+	user, err := u.UserRepository.GetUserByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.User{}, ErrUserNotFound
+	} else if err != nil {
+		return entity.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	updated, err := u.UserRepository.UpdateUser(user, entity.User{Username: username})
+	if err != nil {
+		u.log.Error("Failed to update profile: ", err)
+		return entity.User{}, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	u.audit.Record(actor, entity.AuditUpdate, entity.AuditUser, id, user, updated)
+	return updated, nil
+}
+
+// ChangePassword replaces the password of the logged in user after checking the old one. Every other session
+// of the user is revoked so a leaked password stops working everywhere but on the device that changed it.
+func (u *userUsecase) ChangePassword(id, idSession string, payload entity.ChangePasswordRequest, actor entity.AuditActor) error {
+	u.log.Info("Starting to change a password in the usecase layer", nil)
+
+	user, err := u.UserRepository.GetUserByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(payload.OldPassword)); err != nil {
+		return ErrWrongPassword
+	}
+	if payload.OldPassword == payload.NewPassword {
+		return fmt.Errorf("%w: new password must differ from the old one", ErrWeakPassword)
+	}
+
+	hash, err := u.hashPassword(user.Username, payload.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := u.UserRepository.UpdatePassword(id, hash); err != nil {
+		u.log.Error("Failed to update password: ", err)
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := u.sessionRepo.RevokeUserSessions(id, idSession, repository.SessionRevokedPasswordChange); err != nil {
+		u.log.Error("Failed to revoke the other sessions: ", err)
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	u.log.Info("User ID %s has changed the password successfully: ", id)
+	u.audit.Record(actor, entity.AuditChangePassword, entity.AuditUser, id, nil, nil)
+	return nil
+}
+
+func (u *userUsecase) DeleteUser(id string, actor entity.AuditActor) error {
 	u.log.Info("Starting to delete a user in the usecase layer", nil)
 
-	_, err := u.UserRepository.GetUserByID(id)
+	user, err := u.UserRepository.GetUserByID(id)
 	if err != nil {
 		u.log.Error("User ID %s not found: %v", id)
 		return fmt.Errorf("user ID %s not found", id)
 	}
 
+	if err := u.checkAssignableRoles(actor, user.Role); err != nil {
+		return err
+	}
+
 	err = u.UserRepository.DeleteUser(id)
 	if err != nil {
 		u.log.Error("Failed to delete user: ", err)
@@ -133,9 +365,61 @@ func (u *userUsecase) DeleteUser(id string) error {
 	}
 
 	u.log.Info("User ID %s has been deleted successfully: ", id)
+	u.audit.Record(actor, entity.AuditDelete, entity.AuditUser, id, user, nil)
+	return nil
+}
+
+// hashPassword checks a new password against the password policy and returns its bcrypt hash.
+func (u *userUsecase) hashPassword(username, password string) (string, error) {
+	if err := validatePassword(u.policy, username, password); err != nil {
+		return "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		u.log.Error("Failed to hash password: ", err)
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func validatePassword(policy config.PasswordConfig, username, password string) error {
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, policy.MinLength)
+	}
+	if strings.EqualFold(strings.TrimSpace(password), strings.TrimSpace(username)) {
+		return fmt.Errorf("%w: must not be the username", ErrWeakPassword)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	switch {
+	case policy.RequireUpper && !upper:
+		return fmt.Errorf("%w: must contain an uppercase letter", ErrWeakPassword)
+	case policy.RequireLower && !lower:
+		return fmt.Errorf("%w: must contain a lowercase letter", ErrWeakPassword)
+	case policy.RequireDigit && !digit:
+		return fmt.Errorf("%w: must contain a digit", ErrWeakPassword)
+	case policy.RequireSymbol && !symbol:
+		return fmt.Errorf("%w: must contain a symbol", ErrWeakPassword)
+	}
+
 	return nil
 }
 
-func NewUserUsecase(userRepository repository.UserRepository, log *logger.Logger) UserUsecase {
-	return &userUsecase{UserRepository: userRepository, log: log}
+func NewUserUsecase(userRepository repository.UserRepository, sessionRepo repository.AuthSessionRepository, roleRepo repository.RoleRepository, audit AuditUseCase, policy config.PasswordConfig, log *logger.Logger) UserUsecase {
+	return &userUsecase{UserRepository: userRepository, sessionRepo: sessionRepo, roleRepo: roleRepo, audit: audit, policy: policy, log: log}
 }
@@ -1,9 +1,14 @@
 package usecase
 
 import (
+	"errors"
+	"server-pulsa-app/config"
 	"server-pulsa-app/internal/entity"
 	"server-pulsa-app/internal/logger"
 	"server-pulsa-app/internal/mock/repo_mock"
+	repositorymock "server-pulsa-app/internal/mock/repository_mock"
+	"server-pulsa-app/internal/mock/usecase_mock"
+	"server-pulsa-app/internal/repository"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -14,14 +19,20 @@ import (
 type userUsecaseTestSuite struct {
 	suite.Suite
 	mockUserRepository *repo_mock.UserRepoMock
+	mockSessionRepo    *repositorymock.MockAuthSessionRepository
+	mockRoleRepo       *repositorymock.MockRoleRepository
+	audit              *usecase_mock.AuditUseCaseMock
 	UserUseCase        UserUsecase
 	log                logger.Logger
 }
 
 func (u *userUsecaseTestSuite) SetupTest() {
 	u.mockUserRepository = new(repo_mock.UserRepoMock)
+	u.mockSessionRepo = new(repositorymock.MockAuthSessionRepository)
+	u.mockRoleRepo = new(repositorymock.MockRoleRepository)
+	u.audit = new(usecase_mock.AuditUseCaseMock)
 	u.log = logger.NewLogger()
-	u.UserUseCase = NewUserUsecase(u.mockUserRepository, &u.log)
+	u.UserUseCase = NewUserUsecase(u.mockUserRepository, u.mockSessionRepo, u.mockRoleRepo, u.audit, config.PasswordConfig{MinLength: 8, RequireDigit: true}, &u.log)
 }
 
 func (u *userUsecaseTestSuite) TestRegisterUser_Success() {
@@ -29,18 +40,65 @@ func (u *userUsecaseTestSuite) TestRegisterUser_Success() {
 	user := entity.User{
 		Id_user:  "1",
 		Username: username,
-		Password: "Test Password",
+		Password: "Test Password 1",
 		Role:     "Test Role",
 	}
 
 	u.mockUserRepository.On("GetUserByUsername", username).Return(entity.User{}, nil).Once()
 
 	u.mockUserRepository.On("CreateUser", mock.Anything).Return(user, nil).Once()
+	u.audit.On("Record", entity.AuditActor{IdUser: "1", ClientIP: "10.0.0.1"}, entity.AuditCreate, entity.AuditUser, "1", nil, user).Once()
 
-	user, err := u.UserUseCase.RegisterUser(user)
+	user, err := u.UserUseCase.RegisterUser(user, entity.AuditActor{ClientIP: "10.0.0.1"})
 
 	u.NoError(err)
 	u.Equal("1", user.Id_user)
+	u.audit.AssertExpectations(u.T())
+}
+
+func (u *userUsecaseTestSuite) TestRegisterUser_WeakPassword() {
+	u.mockUserRepository.On("GetUserByUsername", "cashier").Return(entity.User{}, nil).Once()
+
+	_, err := u.UserUseCase.RegisterUser(entity.User{Username: "cashier", Password: "no digits"}, entity.AuditActor{})
+
+	u.ErrorIs(err, ErrWeakPassword)
+	u.mockUserRepository.AssertNotCalled(u.T(), "CreateUser", mock.Anything)
+}
+
+func (u *userUsecaseTestSuite) TestCreateUser_KeepsRole() {
+	matchUser := mock.MatchedBy(func(user entity.User) bool {
+		return user.Username == "finance" && user.Role == "finance" &&
+			bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("secret123")) == nil
+	})
+	u.mockUserRepository.On("CreateUserWithMerchant", matchUser, "merchant-1").Return(entity.User{Id_user: "1", Username: "finance", Role: "finance"}, nil).Once()
+	u.audit.On("Record", mock.Anything, entity.AuditCreate, entity.AuditUser, "1", nil, mock.Anything).Once()
+	u.mockRoleRepo.On("Permissions", entity.RoleAdmin).Return(entity.Permissions, nil).Once()
+
+	user, err := u.UserUseCase.CreateUser(entity.UserCreateRequest{Username: " finance ", Password: "secret123", Role: "finance", IdMerchant: "merchant-1"}, entity.AuditActor{IdUser: "admin-1", Role: entity.RoleAdmin})
+
+	u.NoError(err)
+	u.Equal("finance", user.Role)
+	u.mockUserRepository.AssertExpectations(u.T())
+}
+
+func (u *userUsecaseTestSuite) TestCreateUser_RoleAboveCaller() {
+	u.mockRoleRepo.On("Permissions", "hr").Return([]string{entity.PermUserManage}, nil).Once()
+	u.mockRoleRepo.On("Permissions", entity.RoleAdmin).Return(entity.Permissions, nil).Once()
+
+	_, err := u.UserUseCase.CreateUser(entity.UserCreateRequest{Username: "boss", Password: "secret123", Role: entity.RoleAdmin}, entity.AuditActor{IdUser: "hr-1", Role: "hr"})
+
+	u.ErrorIs(err, ErrRoleNotAssignable)
+	u.mockUserRepository.AssertNotCalled(u.T(), "CreateUserWithMerchant", mock.Anything, mock.Anything)
+}
+
+func (u *userUsecaseTestSuite) TestBootstrapAdmin_OnlyOnEmptyDatabase() {
+	u.mockUserRepository.On("CreateFirstUser", mock.MatchedBy(func(user entity.User) bool { return user.Role == entity.RoleAdmin })).
+		Return(entity.User{}, false, nil).Once()
+
+	created, err := u.UserUseCase.BootstrapAdmin("root", "secret123")
+
+	u.NoError(err)
+	u.False(created)
 }
 
 func (u *userUsecaseTestSuite) TestListAll_Success() {
@@ -102,11 +160,108 @@ func (u *userUsecaseTestSuite) TestUpdateUser_Success() {
 	}, nil).Once()
 
 	u.mockUserRepository.On("UpdateUser", mock.Anything).Return(updatedUser, nil).Once()
+	u.mockRoleRepo.On("Permissions", "").Return([]string{entity.PermRoleManage}, nil).Once()
+	u.mockSessionRepo.On("RevokeUserSessions", id, "", repository.SessionRevokedPasswordChange).Return(nil).Once()
+
+	u.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditUser, id, mock.Anything, updatedUser).Once()
+	u.audit.On("Record", entity.AuditActor{}, entity.AuditChangePassword, entity.AuditUser, id, nil, nil).Once()
 
-	userUpdated, err := u.UserUseCase.UpdateUser(updatedUser)
+	userUpdated, err := u.UserUseCase.UpdateUser(updatedUser, entity.AuditActor{})
 
 	u.Nil(err)
 	u.Equal(updatedUser.Id_user, userUpdated.Id_user)
+	u.audit.AssertExpectations(u.T())
+	u.mockSessionRepo.AssertExpectations(u.T())
+}
+
+func (u *userUsecaseTestSuite) TestUpdateUser_WithoutPasswordKeepsIt() {
+	u.mockUserRepository.On("GetUserByID", "1").Return(entity.User{Id_user: "1", Username: "cashier", Role: entity.RoleEmployee}, nil).Once()
+	u.mockUserRepository.On("UpdateUser", entity.User{Id_user: "1", Role: entity.RoleAdmin}).Return(entity.User{Id_user: "1"}, nil).Once()
+	u.mockRoleRepo.On("Permissions", "").Return([]string{entity.PermRoleManage}, nil).Once()
+	u.mockSessionRepo.On("RevokeUserSessions", "1", "", repository.SessionRevokedRoleChange).Return(nil).Once()
+
+	u.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditUser, "1", mock.Anything, entity.User{Id_user: "1"}).Once()
+
+	_, err := u.UserUseCase.UpdateUser(entity.User{Id_user: "1", Role: entity.RoleAdmin}, entity.AuditActor{})
+
+	u.NoError(err)
+	u.mockUserRepository.AssertExpectations(u.T())
+	u.mockSessionRepo.AssertExpectations(u.T())
+	u.audit.AssertNotCalled(u.T(), "Record", entity.AuditActor{}, entity.AuditChangePassword, entity.AuditUser, "1", nil, nil)
+}
+
+func (u *userUsecaseTestSuite) TestUpdateUser_SameRoleKeepsSessions() {
+	u.mockUserRepository.On("GetUserByID", "1").Return(entity.User{Id_user: "1", Username: "cashier", Role: entity.RoleEmployee}, nil).Once()
+	u.mockUserRepository.On("UpdateUser", entity.User{Id_user: "1", Username: "teller", Role: entity.RoleEmployee}).Return(entity.User{Id_user: "1"}, nil).Once()
+	u.mockRoleRepo.On("Permissions", "").Return([]string{entity.PermRoleManage}, nil).Once()
+	u.audit.On("Record", entity.AuditActor{}, entity.AuditUpdate, entity.AuditUser, "1", mock.Anything, entity.User{Id_user: "1"}).Once()
+
+	_, err := u.UserUseCase.UpdateUser(entity.User{Id_user: "1", Username: "teller", Role: entity.RoleEmployee}, entity.AuditActor{})
+
+	u.NoError(err)
+	u.mockSessionRepo.AssertNotCalled(u.T(), "RevokeUserSessions", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (u *userUsecaseTestSuite) TestUpdateUser_CannotPromoteSelf() {
+	hr := []string{entity.PermUserManage}
+	u.mockUserRepository.On("GetUserByID", "hr-1").Return(entity.User{Id_user: "hr-1", Username: "hr", Role: "hr"}, nil).Once()
+	u.mockRoleRepo.On("Permissions", "hr").Return(hr, nil).Twice()
+	u.mockRoleRepo.On("Permissions", entity.RoleAdmin).Return(entity.Permissions, nil).Once()
+
+	_, err := u.UserUseCase.UpdateUser(entity.User{Id_user: "hr-1", Role: entity.RoleAdmin}, entity.AuditActor{IdUser: "hr-1", Role: "hr"})
+
+	u.ErrorIs(err, ErrRoleNotAssignable)
+	u.mockUserRepository.AssertNotCalled(u.T(), "UpdateUser", mock.Anything)
+}
+
+func (u *userUsecaseTestSuite) TestChangePassword_RevokesOtherSessions() {
+	u.mockUserRepository.On("GetUserByID", "1").Return(entity.User{Id_user: "1", Username: "cashier", Password: hashPassword("old secret 1")}, nil).Once()
+	u.mockUserRepository.On("UpdatePassword", "1", mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new secret 2")) == nil
+	})).Return(nil).Once()
+	u.mockSessionRepo.On("RevokeUserSessions", "1", "session-1", repository.SessionRevokedPasswordChange).Return(nil).Once()
+	u.audit.On("Record", entity.AuditActor{IdUser: "1"}, entity.AuditChangePassword, entity.AuditUser, "1", nil, nil).Once()
+
+	err := u.UserUseCase.ChangePassword("1", "session-1", entity.ChangePasswordRequest{OldPassword: "old secret 1", NewPassword: "new secret 2"}, entity.AuditActor{IdUser: "1"})
+
+	u.NoError(err)
+	u.mockSessionRepo.AssertExpectations(u.T())
+}
+
+func (u *userUsecaseTestSuite) TestChangePassword_WrongOldPassword() {
+	u.mockUserRepository.On("GetUserByID", "1").Return(entity.User{Id_user: "1", Username: "cashier", Password: hashPassword("old secret 1")}, nil).Once()
+
+	err := u.UserUseCase.ChangePassword("1", "session-1", entity.ChangePasswordRequest{OldPassword: "guess 1234", NewPassword: "new secret 2"}, entity.AuditActor{IdUser: "1"})
+
+	u.ErrorIs(err, ErrWrongPassword)
+	u.mockUserRepository.AssertNotCalled(u.T(), "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func TestValidatePassword(t *testing.T) {
+	policy := config.PasswordConfig{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	cases := map[string]struct {
+		username, password string
+		ok                 bool
+	}{
+		"valid":        {"cashier", "Secret#123", true},
+		"too short":    {"cashier", "Se#1", false},
+		"username":     {"Secret#123", "secret#123", false},
+		"no uppercase": {"cashier", "secret#123", false},
+		"no lowercase": {"cashier", "SECRET#123", false},
+		"no digit":     {"cashier", "Secret#abc", false},
+		"no symbol":    {"cashier", "Secret1234", false},
+	}
+
+	for name, c := range cases {
+		err := validatePassword(policy, c.username, c.password)
+		if c.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+		if !c.ok && !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("%s: expected ErrWeakPassword, got %v", name, err)
+		}
+	}
 }
 
 func (u *userUsecaseTestSuite) TestDeleteUser_Success() {
@@ -119,13 +274,26 @@ func (u *userUsecaseTestSuite) TestDeleteUser_Success() {
 		Role:     "Test Role",
 	}, nil).Once()
 
+	u.mockRoleRepo.On("Permissions", "").Return([]string{entity.PermRoleManage}, nil).Once()
 	u.mockUserRepository.On("DeleteUser", id).Return(nil).Once()
+	u.audit.On("Record", entity.AuditActor{}, entity.AuditDelete, entity.AuditUser, id, mock.Anything, nil).Once()
 
-	err := u.UserUseCase.DeleteUser(id)
+	err := u.UserUseCase.DeleteUser(id, entity.AuditActor{})
 
 	u.Nil(err)
 }
 
+func (u *userUsecaseTestSuite) TestDeleteUser_CannotDeleteAdmin() {
+	u.mockUserRepository.On("GetUserByID", "admin-1").Return(entity.User{Id_user: "admin-1", Username: "admin", Role: entity.RoleAdmin}, nil).Once()
+	u.mockRoleRepo.On("Permissions", "hr").Return([]string{entity.PermUserManage}, nil).Once()
+	u.mockRoleRepo.On("Permissions", entity.RoleAdmin).Return(entity.Permissions, nil).Once()
+
+	err := u.UserUseCase.DeleteUser("admin-1", entity.AuditActor{IdUser: "hr-1", Role: "hr"})
+
+	u.ErrorIs(err, ErrRoleNotAssignable)
+	u.mockUserRepository.AssertNotCalled(u.T(), "DeleteUser", mock.Anything)
+}
+
 func hashPassword(password string) string {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"os"
 	_ "server-pulsa-app/docs"
 	"server-pulsa-app/internal"
 )
@@ -18,6 +20,13 @@ import (
 // @BasePath /api/v1
 // @schemes http https
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rotate-jwt-key" {
+		if err := internal.RotateJwtKey(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	internal.NewServer().Run()
 }